@@ -7,6 +7,10 @@ import (
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/spf13/cobra"
 	"k8s.io/component-base/cli"
+	"k8s.io/component-base/logs"
+	logsapi "k8s.io/component-base/logs/api/v1"
+	_ "k8s.io/component-base/logs/json/register"
+	tracingapi "k8s.io/component-base/tracing/api/v1"
 
 	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/operator"
 	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/version"
@@ -18,7 +22,11 @@ func main() {
 	os.Exit(code)
 }
 
-var guestKubeconfig *string
+var (
+	guestKubeconfig               *string
+	tracingEndpoint               *string
+	tracingSamplingRatePerMillion *int32
+)
 
 func NewOperatorCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -38,15 +46,37 @@ func NewOperatorCommand() *cobra.Command {
 	).NewCommand()
 
 	guestKubeconfig = ctrlCmd.Flags().String("guest-kubeconfig", "", "Path to the guest kubeconfig file. This flag enables hypershift integration.")
+	tracingEndpoint = ctrlCmd.Flags().String("tracing-endpoint", "", "OTLP gRPC endpoint, host:port, of the collector of the traces of the syncs. Tracing is enabled when it is set or when the OTEL_EXPORTER_OTLP_ENDPOINT environment variable is set.")
+	tracingSamplingRatePerMillion = ctrlCmd.Flags().Int32("tracing-sampling-rate-per-million", 1000000, "Number of the syncs traced per million when tracing is enabled.")
+	loggingConfig := logsapi.NewLoggingConfiguration()
+	logsapi.AddFlags(loggingConfig, ctrlCmd.Flags())
+	ctrlCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		return logsapi.ValidateAndApply(loggingConfig, nil)
+	}
 
 	ctrlCmd.Use = "start"
 	ctrlCmd.Short = "Start the AWS EBS CSI Driver Operator"
 
 	cmd.AddCommand(ctrlCmd)
+	// The logging configuration of the start command replaces the -v, -vmodule and -log-flush-frequency flags of klog.
+	logs.AddFlags(cmd.PersistentFlags(), logs.SkipLoggingConfigurationFlags())
 
 	return cmd
 }
 
 func runOperatorWithGuestKubeconfig(ctx context.Context, controllerConfig *controllercmd.ControllerContext) error {
-	return operator.RunOperator(ctx, controllerConfig, *guestKubeconfig)
+	return operator.RunOperator(ctx, controllerConfig, *guestKubeconfig, tracingConfig())
+}
+
+// tracingConfig returns the configuration of tracing, nil when it is disabled.
+func tracingConfig() *tracingapi.TracingConfiguration {
+	if *tracingEndpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return nil
+	}
+	config := &tracingapi.TracingConfiguration{SamplingRatePerMillion: tracingSamplingRatePerMillion}
+	// The exporter reads the endpoint from the environment otherwise.
+	if *tracingEndpoint != "" {
+		config.Endpoint = tracingEndpoint
+	}
+	return config
 }
@@ -0,0 +1,248 @@
+// Package awsclient is a minimal client of the AWS APIs used by the operator. The operator only needs
+// a handful of calls, so it builds the requests itself, and signs them with the signer and the credential
+// providers of the AWS SDK.
+package awsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// maxResponseSize limits the size of the responses read from AWS.
+const maxResponseSize = 10 << 20
+
+// Client calls AWS APIs that use the query protocol, like EC2, IAM and STS, REST APIs with JSON bodies, like DLM,
+// and JSON RPC APIs, like KMS.
+type Client struct {
+	Region string
+	// Credentials provides the credentials that sign the requests, usually an aws.CredentialsCache.
+	Credentials aws.CredentialsProvider
+	// Endpoints overrides the default endpoints, keyed by service name, e.g. "ec2".
+	Endpoints map[string]string
+	// HTTPClient sends the requests. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+
+	now func() time.Time
+}
+
+// APIError is an error returned by an AWS API.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// Endpoint returns the URL of the service endpoint in the client region.
+func (c *Client) Endpoint(service string) string {
+	if endpoint, ok := c.Endpoints[service]; ok && endpoint != "" {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		return endpoint
+	}
+	return DefaultEndpoint(service, c.Region)
+}
+
+// DefaultEndpoint returns the endpoint of the service in the region, in the DNS suffix of the partition of the region.
+func DefaultEndpoint(service, region string) string {
+	return fmt.Sprintf("https://%s.%s.%s", service, region, PartitionOf(region).DNSSuffix)
+}
+
+// Query calls an action of a query protocol API and returns the response body.
+// Errors returned by the API are returned as *APIError.
+func (c *Client) Query(ctx context.Context, service, version, action string, params url.Values) ([]byte, error) {
+	form := url.Values{}
+	for k, v := range params {
+		form[k] = v
+	}
+	form.Set("Action", action)
+	form.Set("Version", version)
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint(service), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	resp, respBody, err := c.send(req, body, service)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseAPIError(resp.StatusCode, respBody)
+	}
+	return respBody, nil
+}
+
+// Call calls an operation of a REST API with JSON bodies and returns the response body. The path is relative
+// to the service endpoint, body is the JSON request body or nil. Errors returned by the API are returned as *APIError.
+func (c *Client) Call(ctx context.Context, service, method, path string, query url.Values, body []byte) ([]byte, error) {
+	endpoint := strings.TrimSuffix(c.Endpoint(service), "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, respBody, err := c.send(req, body, service)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, parseJSONAPIError(resp, respBody)
+	}
+	return respBody, nil
+}
+
+// Invoke calls an operation of a JSON RPC API, e.g. "TrentService.DescribeKey", and returns the response body.
+// Errors returned by the API are returned as *APIError.
+func (c *Client) Invoke(ctx context.Context, service, target string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint(service), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	resp, respBody, err := c.send(req, body, service)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseJSONAPIError(resp, respBody)
+	}
+	return respBody, nil
+}
+
+// CredentialsError is the error of the credential providers of a client, e.g. when STS denies the role.
+type CredentialsError struct {
+	Err error
+}
+
+func (e *CredentialsError) Error() string {
+	return fmt.Sprintf("failed to retrieve the AWS credentials: %v", e.Err)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// send signs and sends the request, and reads the response body.
+func (c *Client) send(req *http.Request, body []byte, service string) (*http.Response, []byte, error) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	if c.Credentials == nil {
+		return nil, nil, &CredentialsError{Err: errors.New("no credentials provider")}
+	}
+	creds, err := c.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return nil, nil, &CredentialsError{Err: err}
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(req.Context(), creds, req, hex.EncodeToString(payloadHash[:]), service, c.Region, now()); err != nil {
+		return nil, nil, err
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
+
+// parseAPIError parses both the EC2 (<Response><Errors><Error>) and the IAM / STS
+// (<ErrorResponse><Error>) error formats.
+func parseAPIError(statusCode int, body []byte) error {
+	var resp struct {
+		Errors    []apiErrorXML `xml:"Errors>Error"`
+		Error     *apiErrorXML  `xml:"Error"`
+		RequestID string        `xml:"RequestID"`
+		RequestId string        `xml:"RequestId"`
+	}
+	apiErr := &APIError{StatusCode: statusCode}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return apiErr
+	}
+	switch {
+	case len(resp.Errors) > 0:
+		apiErr.Code, apiErr.Message = resp.Errors[0].Code, resp.Errors[0].Message
+	case resp.Error != nil:
+		apiErr.Code, apiErr.Message = resp.Error.Code, resp.Error.Message
+	}
+	apiErr.RequestID = resp.RequestID
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.RequestId
+	}
+	return apiErr
+}
+
+// parseJSONAPIError parses the error of a REST JSON API. The error code is in the X-Amzn-ErrorType header,
+// or in the body of APIs that don't set the header.
+func parseJSONAPIError(resp *http.Response, body []byte) error {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("X-Amzn-RequestId"),
+	}
+	var jsonErr struct {
+		Type string `json:"__type"`
+		Code string `json:"code"`
+		// Message matches both "message" and "Message".
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(body, &jsonErr)
+	apiErr.Code = resp.Header.Get("X-Amzn-ErrorType")
+	if apiErr.Code == "" {
+		apiErr.Code = jsonErr.Type
+	}
+	if apiErr.Code == "" {
+		apiErr.Code = jsonErr.Code
+	}
+	// The code may carry additional information after a colon, and a namespace before a hash.
+	apiErr.Code, _, _ = strings.Cut(apiErr.Code, ":")
+	if i := strings.LastIndex(apiErr.Code, "#"); i >= 0 {
+		apiErr.Code = apiErr.Code[i+1:]
+	}
+	apiErr.Message = jsonErr.Message
+	return apiErr
+}
+
+type apiErrorXML struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
@@ -0,0 +1,428 @@
+package awsclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const describeAvailabilityZonesResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<DescribeAvailabilityZonesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>1</requestId>
+  <availabilityZoneInfo>
+    <item><zoneName>us-east-1a</zoneName><zoneState>available</zoneState></item>
+    <item><zoneName>us-east-1b</zoneName><zoneState>available</zoneState></item>
+  </availabilityZoneInfo>
+</DescribeAvailabilityZonesResponse>`
+
+const authFailureResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<Response><Errors><Error><Code>AuthFailure</Code><Message>AWS was not able to validate the provided access credentials</Message></Error></Errors><RequestID>2</RequestID></Response>`
+
+func TestDescribeAvailabilityZones(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		response      string
+		expectedZones []string
+		expectedError *APIError
+	}{
+		{
+			name:          "success",
+			status:        http.StatusOK,
+			response:      describeAvailabilityZonesResponse,
+			expectedZones: []string{"us-east-1a", "us-east-1b"},
+		},
+		{
+			name:     "auth failure",
+			status:   http.StatusUnauthorized,
+			response: authFailureResponse,
+			expectedError: &APIError{
+				StatusCode: http.StatusUnauthorized,
+				Code:       "AuthFailure",
+				Message:    "AWS was not able to validate the provided access credentials",
+				RequestID:  "2",
+			},
+		},
+		{
+			name:          "unparseable error",
+			status:        http.StatusBadGateway,
+			response:      "bad gateway",
+			expectedError: &APIError{StatusCode: http.StatusBadGateway},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("failed to parse request: %v", err)
+				}
+				if a := r.Form.Get("Action"); a != "DescribeAvailabilityZones" {
+					t.Errorf("unexpected action %q", a)
+				}
+				if a := r.Header.Get("Authorization"); !strings.Contains(a, "/us-east-1/ec2/aws4_request") {
+					t.Errorf("unexpected Authorization header %q", a)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.response))
+			}))
+			defer server.Close()
+
+			c := &Client{
+				Region:      "us-east-1",
+				Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+				Endpoints:   map[string]string{"ec2": server.URL},
+			}
+			zones, err := c.DescribeAvailabilityZones(context.TODO())
+			if test.expectedError != nil {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || !reflect.DeepEqual(apiErr, test.expectedError) {
+					t.Errorf("expected error %#v, got %#v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(zones, test.expectedZones) {
+				t.Errorf("expected zones %v, got %v", test.expectedZones, zones)
+			}
+		})
+	}
+}
+
+func TestDescribeSnapshots(t *testing.T) {
+	pages := map[string]string{
+		"": `<DescribeSnapshotsResponse><snapshotSet><item><snapshotId>snap-1</snapshotId><volumeId>vol-1</volumeId><status>completed</status>` +
+			`<startTime>2023-05-10T12:00:00.000Z</startTime><tagSet><item><key>CSIVolumeSnapshotName</key><value>snapshot-1</value></item></tagSet></item></snapshotSet>` +
+			`<nextToken>page-2</nextToken></DescribeSnapshotsResponse>`,
+		"page-2": `<DescribeSnapshotsResponse><snapshotSet><item><snapshotId>snap-2</snapshotId><status>pending</status>` +
+			`<startTime>2023-05-11T12:00:00.000Z</startTime></item></snapshotSet></DescribeSnapshotsResponse>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse request: %v", err)
+		}
+		if a := r.Form.Get("Filter.1.Name"); a != "tag-key" {
+			t.Errorf("unexpected filter %q", a)
+		}
+		if a := r.Form.Get("Filter.2.Value.1"); a != "owned" {
+			t.Errorf("unexpected filter value %q", a)
+		}
+		w.Write([]byte(pages[r.Form.Get("NextToken")]))
+	}))
+	defer server.Close()
+
+	c := &Client{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Endpoints:   map[string]string{"ec2": server.URL},
+	}
+	snapshots, err := c.DescribeSnapshots(context.TODO(), map[string][]string{
+		"tag-key":                     {"CSIVolumeSnapshotName"},
+		"tag:kubernetes.io/cluster/x": {"owned"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Snapshot{
+		{
+			SnapshotID: "snap-1",
+			VolumeID:   "vol-1",
+			State:      "completed",
+			StartTime:  time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC),
+			Tags:       map[string]string{"CSIVolumeSnapshotName": "snapshot-1"},
+		},
+		{
+			SnapshotID: "snap-2",
+			State:      "pending",
+			StartTime:  time.Date(2023, 5, 11, 12, 0, 0, 0, time.UTC),
+			Tags:       map[string]string{},
+		},
+	}
+	if !reflect.DeepEqual(snapshots, expected) {
+		t.Errorf("expected snapshots %#v, got %#v", expected, snapshots)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse request: %v", err)
+		}
+		if r.Form.Get("DryRun") != "true" {
+			t.Errorf("expected DryRun=true, got %q", r.Form.Get("DryRun"))
+		}
+		if r.Form.Get("Action") == "DescribeTags" {
+			w.Write([]byte(`<DescribeTagsResponse><tagSet/></DescribeTagsResponse>`))
+			return
+		}
+		code := map[string]string{
+			"CreateVolume":   "DryRunOperation",
+			"DeleteVolume":   "UnauthorizedOperation",
+			"CreateSnapshot": "InvalidVolume.NotFound",
+		}[r.Form.Get("Action")]
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(`<Response><Errors><Error><Code>` + code + `</Code><Message>message</Message></Error></Errors><RequestID>id</RequestID></Response>`))
+	}))
+	defer server.Close()
+
+	c := &Client{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Endpoints:   map[string]string{"ec2": server.URL},
+	}
+	if allowed, err := c.DryRun(context.TODO(), "CreateVolume", url.Values{"Size": []string{"1"}}); !allowed || err != nil {
+		t.Errorf("expected CreateVolume to be allowed, got %v, %v", allowed, err)
+	}
+	if allowed, err := c.DryRun(context.TODO(), "DeleteVolume", nil); allowed || err != nil {
+		t.Errorf("expected DeleteVolume to be denied, got %v, %v", allowed, err)
+	}
+	if _, err := c.DryRun(context.TODO(), "CreateSnapshot", nil); err == nil {
+		t.Errorf("expected CreateSnapshot to fail")
+	}
+	if allowed, err := c.DryRun(context.TODO(), "DescribeTags", nil); allowed || err == nil {
+		t.Errorf("expected DescribeTags that ignored DryRun to fail, got %v, %v", allowed, err)
+	}
+}
+
+func TestLifecyclePolicies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := r.Header.Get("Authorization"); !strings.Contains(a, "/us-east-1/dlm/aws4_request") {
+			t.Errorf("unexpected Authorization header %q", a)
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/policies":
+			if a := r.URL.Query().Get("targetTags"); a != "kubernetes.io/cluster/test=owned" {
+				t.Errorf("unexpected targetTags %q", a)
+			}
+			w.Write([]byte(`{"Policies":[{"PolicyId":"policy-1","State":"ENABLED","Tags":{"owner":"test"}}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/policies":
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), `"ExecutionRoleArn":"arn:aws:iam::123456789012:role/dlm"`) {
+				t.Errorf("unexpected request body %s", body)
+			}
+			w.Write([]byte(`{"PolicyId":"policy-2"}`))
+		default:
+			w.Header().Set("X-Amzn-ErrorType", "ResourceNotFoundException:http://internal.amazon.com/coral/com.amazonaws.dlm/")
+			w.Header().Set("X-Amzn-RequestId", "3")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Policy not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Endpoints:   map[string]string{"dlm": server.URL},
+	}
+	policies, err := c.GetLifecyclePolicies(context.TODO(), "kubernetes.io/cluster/test=owned")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []LifecyclePolicy{{PolicyID: "policy-1", State: "ENABLED", Tags: map[string]string{"owner": "test"}}}
+	if !reflect.DeepEqual(policies, expected) {
+		t.Errorf("expected policies %#v, got %#v", expected, policies)
+	}
+
+	id, err := c.CreateLifecyclePolicy(context.TODO(), &LifecyclePolicy{ExecutionRoleARN: "arn:aws:iam::123456789012:role/dlm"})
+	if err != nil || id != "policy-2" {
+		t.Errorf("expected policy-2, got %q, %v", id, err)
+	}
+
+	err = c.DeleteLifecyclePolicy(context.TODO(), "policy-3")
+	expectedErr := &APIError{StatusCode: http.StatusNotFound, Code: "ResourceNotFoundException", Message: "Policy not found", RequestID: "3"}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !reflect.DeepEqual(apiErr, expectedErr) {
+		t.Errorf("expected error %#v, got %#v", expectedErr, err)
+	}
+}
+
+func TestKMS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := r.Header.Get("Content-Type"); a != "application/x-amz-json-1.1" {
+			t.Errorf("unexpected Content-Type %q", a)
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.DescribeKey":
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"KeyId":"alias/ebs"}` {
+				t.Errorf("unexpected request body %s", body)
+			}
+			w.Write([]byte(`{"KeyMetadata":{"KeyId":"1234","Arn":"arn:aws:kms:us-east-1:123456789012:key/1234","Enabled":false,"KeyState":"Disabled","KeyUsage":"ENCRYPT_DECRYPT","KeySpec":"SYMMETRIC_DEFAULT"}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"AccessDeniedException","Message":"not authorized"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("id", "secret", ""),
+		Endpoints:   map[string]string{"kms": server.URL},
+	}
+	key, err := c.DescribeKey(context.TODO(), "alias/ebs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &KeyMetadata{
+		KeyID:    "1234",
+		ARN:      "arn:aws:kms:us-east-1:123456789012:key/1234",
+		KeyState: "Disabled",
+		KeyUsage: "ENCRYPT_DECRYPT",
+		KeySpec:  "SYMMETRIC_DEFAULT",
+	}
+	if !reflect.DeepEqual(key, expected) {
+		t.Errorf("expected key %#v, got %#v", expected, key)
+	}
+
+	err = c.GenerateDataKeyWithoutPlaintext(context.TODO(), "alias/ebs")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "AccessDeniedException" || apiErr.Message != "not authorized" {
+		t.Errorf("expected AccessDeniedException, got %#v", err)
+	}
+}
+
+func TestCredentialsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request without credentials")
+	}))
+	defer server.Close()
+
+	c := &Client{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("InvalidIdentityToken")
+		}),
+		Endpoints: map[string]string{"ec2": server.URL},
+	}
+	_, err := c.DescribeAvailabilityZones(context.TODO())
+	var credsErr *CredentialsError
+	if !errors.As(err, &credsErr) || !strings.Contains(err.Error(), "InvalidIdentityToken") {
+		t.Errorf("expected a CredentialsError, got %#v", err)
+	}
+}
+
+func TestParseSharedConfig(t *testing.T) {
+	data := []byte(`
+# comment
+[default]
+aws_access_key_id = id
+aws_secret_access_key = secret
+
+[profile assume]
+role_arn = arn:aws:iam::123456789012:role/ebs
+web_identity_token_file = /var/run/secrets/openshift/serviceaccount/token
+`)
+	profiles := ParseSharedConfig(data)
+
+	creds, ok := profiles["default"].StaticCredentials()
+	if !ok || creds.AccessKeyID != "id" || creds.SecretAccessKey != "secret" {
+		t.Errorf("unexpected default credentials %#v", creds)
+	}
+	if _, ok := profiles["assume"].StaticCredentials(); ok {
+		t.Errorf("expected no static credentials in the assume profile")
+	}
+	if a := profiles["assume"]["role_arn"]; a != "arn:aws:iam::123456789012:role/ebs" {
+		t.Errorf("unexpected role_arn %q", a)
+	}
+}
+
+func TestInstanceRegion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" && r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") != "":
+			io.WriteString(w, "token")
+		case r.Method == http.MethodGet && r.URL.Path == "/latest/meta-data/placement/region" && r.Header.Get("X-aws-ec2-metadata-token") == "token":
+			io.WriteString(w, "us-east-2\n")
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	region, err := InstanceRegion(context.TODO(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if region != "us-east-2" {
+		t.Errorf("expected region us-east-2, got %q", region)
+	}
+
+	if _, err := InstanceRegion(context.TODO(), server.Client(), server.URL+"/invalid"); err == nil {
+		t.Errorf("expected error, got none")
+	}
+}
+
+func TestDefaultEndpoint(t *testing.T) {
+	tests := []struct {
+		region            string
+		expectedEndpoint  string
+		expectedPartition string
+		expectedIsolated  bool
+	}{
+		{
+			region:            "us-east-1",
+			expectedEndpoint:  "https://ec2.us-east-1.amazonaws.com",
+			expectedPartition: "aws",
+		},
+		{
+			region:            "cn-north-1",
+			expectedEndpoint:  "https://ec2.cn-north-1.amazonaws.com.cn",
+			expectedPartition: "aws-cn",
+		},
+		{
+			region:            "us-gov-west-1",
+			expectedEndpoint:  "https://ec2.us-gov-west-1.amazonaws.com",
+			expectedPartition: "aws-us-gov",
+		},
+		{
+			region:            "us-iso-east-1",
+			expectedEndpoint:  "https://ec2.us-iso-east-1.c2s.ic.gov",
+			expectedPartition: "aws-iso",
+			expectedIsolated:  true,
+		},
+		{
+			region:            "us-isob-east-1",
+			expectedEndpoint:  "https://ec2.us-isob-east-1.sc2s.sgov.gov",
+			expectedPartition: "aws-iso-b",
+			expectedIsolated:  true,
+		},
+		{
+			region:            "eu-isoe-west-1",
+			expectedEndpoint:  "https://ec2.eu-isoe-west-1.cloud.adc-e.uk",
+			expectedPartition: "aws-iso-e",
+			expectedIsolated:  true,
+		},
+		{
+			region:            "us-isof-south-1",
+			expectedEndpoint:  "https://ec2.us-isof-south-1.csp.hci.ic.gov",
+			expectedPartition: "aws-iso-f",
+			expectedIsolated:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.region, func(t *testing.T) {
+			if endpoint := DefaultEndpoint("ec2", test.region); endpoint != test.expectedEndpoint {
+				t.Errorf("expected endpoint %s, got %s", test.expectedEndpoint, endpoint)
+			}
+			partition := PartitionOf(test.region)
+			if partition.ID != test.expectedPartition || partition.Isolated != test.expectedIsolated {
+				t.Errorf("expected partition %s (isolated: %v), got %s (isolated: %v)",
+					test.expectedPartition, test.expectedIsolated, partition.ID, partition.Isolated)
+			}
+		})
+	}
+}
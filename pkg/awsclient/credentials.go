@@ -0,0 +1,51 @@
+package awsclient
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Profile is a profile of a shared AWS config or credentials file.
+type Profile map[string]string
+
+// ParseSharedConfig parses a shared AWS config or credentials file into profiles, keyed by profile name.
+// The "profile " prefix of section names used in config files is removed.
+func ParseSharedConfig(data []byte) map[string]Profile {
+	profiles := map[string]Profile{}
+	var current Profile
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			name = strings.TrimSpace(strings.TrimPrefix(name, "profile "))
+			if profiles[name] == nil {
+				profiles[name] = Profile{}
+			}
+			current = profiles[name]
+		case current != nil:
+			key, value, found := strings.Cut(line, "=")
+			if !found {
+				continue
+			}
+			current[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return profiles
+}
+
+// StaticCredentials returns the static credentials of the profile, if it has any.
+func (p Profile) StaticCredentials() (aws.Credentials, bool) {
+	creds := aws.Credentials{
+		AccessKeyID:     p["aws_access_key_id"],
+		SecretAccessKey: p["aws_secret_access_key"],
+		SessionToken:    p["aws_session_token"],
+	}
+	return creds, creds.AccessKeyID != "" && creds.SecretAccessKey != ""
+}
@@ -0,0 +1,141 @@
+package awsclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// LifecyclePolicy is an Amazon Data Lifecycle Manager (DLM) lifecycle policy.
+type LifecyclePolicy struct {
+	PolicyID         string            `json:"PolicyId,omitempty"`
+	Description      string            `json:"Description,omitempty"`
+	State            string            `json:"State,omitempty"`
+	StatusMessage    string            `json:"StatusMessage,omitempty"`
+	ExecutionRoleARN string            `json:"ExecutionRoleArn,omitempty"`
+	PolicyDetails    *PolicyDetails    `json:"PolicyDetails,omitempty"`
+	Tags             map[string]string `json:"Tags,omitempty"`
+}
+
+// PolicyDetails are the details of an EBS snapshot management policy.
+type PolicyDetails struct {
+	PolicyType    string           `json:"PolicyType,omitempty"`
+	ResourceTypes []string         `json:"ResourceTypes,omitempty"`
+	TargetTags    []Tag            `json:"TargetTags,omitempty"`
+	Schedules     []PolicySchedule `json:"Schedules,omitempty"`
+}
+
+// PolicySchedule is a schedule of a lifecycle policy.
+type PolicySchedule struct {
+	Name       string            `json:"Name,omitempty"`
+	CopyTags   bool              `json:"CopyTags,omitempty"`
+	TagsToAdd  []Tag             `json:"TagsToAdd,omitempty"`
+	CreateRule *PolicyCreateRule `json:"CreateRule,omitempty"`
+	RetainRule *PolicyRetainRule `json:"RetainRule,omitempty"`
+}
+
+// PolicyCreateRule sets when the snapshots are created.
+type PolicyCreateRule struct {
+	Interval     int32    `json:"Interval,omitempty"`
+	IntervalUnit string   `json:"IntervalUnit,omitempty"`
+	Times        []string `json:"Times,omitempty"`
+}
+
+// PolicyRetainRule sets how many snapshots are kept.
+type PolicyRetainRule struct {
+	Count int32 `json:"Count,omitempty"`
+}
+
+// Tag is an AWS resource tag.
+type Tag struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"`
+}
+
+// GetLifecyclePolicies returns the summaries of the lifecycle policies that target resources with the tag
+// (in key=value format). The summaries carry the ID, description, state and tags of the policies.
+func (c *Client) GetLifecyclePolicies(ctx context.Context, targetTag string) ([]LifecyclePolicy, error) {
+	query := url.Values{}
+	if targetTag != "" {
+		query.Set("targetTags", targetTag)
+	}
+	body, err := c.Call(ctx, "dlm", http.MethodGet, "/policies", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Policies []LifecyclePolicy `json:"Policies"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Policies, nil
+}
+
+// GetLifecyclePolicy returns the lifecycle policy with its details.
+func (c *Client) GetLifecyclePolicy(ctx context.Context, policyID string) (*LifecyclePolicy, error) {
+	body, err := c.Call(ctx, "dlm", http.MethodGet, "/policies/"+url.PathEscape(policyID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Policy *LifecyclePolicy `json:"Policy"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Policy == nil {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Code: "ResourceNotFoundException", Message: "lifecycle policy " + policyID + " not found"}
+	}
+	return resp.Policy, nil
+}
+
+// CreateLifecyclePolicy creates the lifecycle policy and returns its ID.
+func (c *Client) CreateLifecyclePolicy(ctx context.Context, policy *LifecyclePolicy) (string, error) {
+	create := &LifecyclePolicy{
+		Description:      policy.Description,
+		State:            policy.State,
+		ExecutionRoleARN: policy.ExecutionRoleARN,
+		PolicyDetails:    policy.PolicyDetails,
+		Tags:             policy.Tags,
+	}
+	reqBody, err := json.Marshal(create)
+	if err != nil {
+		return "", err
+	}
+	body, err := c.Call(ctx, "dlm", http.MethodPost, "/policies", nil, reqBody)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		PolicyID string `json:"PolicyId"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", err
+	}
+	return resp.PolicyID, nil
+}
+
+// UpdateLifecyclePolicy updates the description, state, execution role and details of the lifecycle policy.
+// The tags of the policy are not updated.
+func (c *Client) UpdateLifecyclePolicy(ctx context.Context, policy *LifecyclePolicy) error {
+	update := &LifecyclePolicy{
+		Description:      policy.Description,
+		State:            policy.State,
+		ExecutionRoleARN: policy.ExecutionRoleARN,
+		PolicyDetails:    policy.PolicyDetails,
+	}
+	reqBody, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	_, err = c.Call(ctx, "dlm", http.MethodPatch, "/policies/"+url.PathEscape(policy.PolicyID), nil, reqBody)
+	return err
+}
+
+// DeleteLifecyclePolicy deletes the lifecycle policy. The snapshots created by the policy are not deleted.
+func (c *Client) DeleteLifecyclePolicy(ctx context.Context, policyID string) error {
+	_, err := c.Call(ctx, "dlm", http.MethodDelete, "/policies/"+url.PathEscape(policyID)+"/", nil, nil)
+	return err
+}
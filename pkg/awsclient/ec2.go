@@ -0,0 +1,147 @@
+package awsclient
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+const ec2APIVersion = "2016-11-15"
+
+// DescribeAvailabilityZones returns the names of the availability zones enabled in the client region.
+func (c *Client) DescribeAvailabilityZones(ctx context.Context) ([]string, error) {
+	body, err := c.Query(ctx, "ec2", ec2APIVersion, "DescribeAvailabilityZones", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Zones []struct {
+			ZoneName string `xml:"zoneName"`
+		} `xml:"availabilityZoneInfo>item"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	zones := make([]string, 0, len(resp.Zones))
+	for _, zone := range resp.Zones {
+		zones = append(zones, zone.ZoneName)
+	}
+	return zones, nil
+}
+
+// Snapshot is an EBS snapshot.
+type Snapshot struct {
+	SnapshotID string
+	VolumeID   string
+	State      string
+	StartTime  time.Time
+	Tags       map[string]string
+}
+
+// DescribeSnapshots returns the snapshots owned by the account that match the filters, keyed by filter name
+// (e.g. "tag:Name" or "tag-key"). All pages of the results are returned.
+func (c *Client) DescribeSnapshots(ctx context.Context, filters map[string][]string) ([]Snapshot, error) {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := url.Values{}
+	params.Set("Owner.1", "self")
+	params.Set("MaxResults", "1000")
+	for i, name := range names {
+		params.Set(fmt.Sprintf("Filter.%d.Name", i+1), name)
+		for j, value := range filters[name] {
+			params.Set(fmt.Sprintf("Filter.%d.Value.%d", i+1, j+1), value)
+		}
+	}
+
+	var snapshots []Snapshot
+	for {
+		body, err := c.Query(ctx, "ec2", ec2APIVersion, "DescribeSnapshots", params)
+		if err != nil {
+			return nil, err
+		}
+		var resp struct {
+			Snapshots []struct {
+				SnapshotID string    `xml:"snapshotId"`
+				VolumeID   string    `xml:"volumeId"`
+				Status     string    `xml:"status"`
+				StartTime  time.Time `xml:"startTime"`
+				Tags       []struct {
+					Key   string `xml:"key"`
+					Value string `xml:"value"`
+				} `xml:"tagSet>item"`
+			} `xml:"snapshotSet>item"`
+			NextToken string `xml:"nextToken"`
+		}
+		if err := xml.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		for _, s := range resp.Snapshots {
+			snapshot := Snapshot{
+				SnapshotID: s.SnapshotID,
+				VolumeID:   s.VolumeID,
+				State:      s.Status,
+				StartTime:  s.StartTime,
+				Tags:       map[string]string{},
+			}
+			for _, tag := range s.Tags {
+				snapshot.Tags[tag.Key] = tag.Value
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		if resp.NextToken == "" {
+			return snapshots, nil
+		}
+		params.Set("NextToken", resp.NextToken)
+	}
+}
+
+// DeleteSnapshot deletes the snapshot.
+func (c *Client) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	_, err := c.Query(ctx, "ec2", ec2APIVersion, "DeleteSnapshot", url.Values{"SnapshotId": []string{snapshotID}})
+	return err
+}
+
+// CreateTags adds or overwrites the tags of the resource.
+func (c *Client) CreateTags(ctx context.Context, resourceID string, tags map[string]string) error {
+	params := url.Values{"ResourceId.1": []string{resourceID}}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		params.Set(fmt.Sprintf("Tag.%d.Key", i+1), key)
+		params.Set(fmt.Sprintf("Tag.%d.Value", i+1), tags[key])
+	}
+	_, err := c.Query(ctx, "ec2", ec2APIVersion, "CreateTags", params)
+	return err
+}
+
+// DryRun calls the EC2 action with DryRun set, which checks the permissions of the caller without
+// making the request. It returns whether the caller is allowed to call the action; other errors,
+// e.g. about invalid parameters, are returned as they are. A call that succeeds did not honor
+// DryRun and is an error.
+func (c *Client) DryRun(ctx context.Context, action string, params url.Values) (bool, error) {
+	form := url.Values{"DryRun": []string{"true"}}
+	for k, v := range params {
+		form[k] = v
+	}
+	_, err := c.Query(ctx, "ec2", ec2APIVersion, action, form)
+	var apiErr *APIError
+	switch {
+	case err == nil:
+		return false, fmt.Errorf("ec2:%s did not honor DryRun, the call was made", action)
+	case errors.As(err, &apiErr) && apiErr.Code == "DryRunOperation":
+		return true, nil
+	case errors.As(err, &apiErr) && apiErr.Code == "UnauthorizedOperation":
+		return false, nil
+	}
+	return false, err
+}
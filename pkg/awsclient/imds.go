@@ -0,0 +1,60 @@
+package awsclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultIMDSEndpoint is the endpoint of the EC2 instance metadata service.
+const DefaultIMDSEndpoint = "http://169.254.169.254"
+
+// InstanceRegion returns the region of the EC2 instance the caller runs on, from the instance metadata service at
+// the endpoint. It uses IMDSv2, which requires a session token. http.DefaultClient is used when httpClient is nil.
+func InstanceRegion(ctx context.Context, httpClient *http.Client, endpoint string) (string, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	token, err := imdsRequest(ctx, httpClient, http.MethodPut, endpoint+"/latest/api/token", map[string]string{
+		"X-aws-ec2-metadata-token-ttl-seconds": "60",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get an IMDS session token: %w", err)
+	}
+	region, err := imdsRequest(ctx, httpClient, http.MethodGet, endpoint+"/latest/meta-data/placement/region", map[string]string{
+		"X-aws-ec2-metadata-token": token,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the region from IMDS: %w", err)
+	}
+	return region, nil
+}
+
+func imdsRequest(ctx context.Context, httpClient *http.Client, method, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	value := strings.TrimSpace(string(body))
+	if value == "" {
+		return "", fmt.Errorf("empty response")
+	}
+	return value, nil
+}
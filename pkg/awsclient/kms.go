@@ -0,0 +1,46 @@
+package awsclient
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// KeyMetadata describes a KMS key.
+type KeyMetadata struct {
+	KeyID    string `json:"KeyId"`
+	ARN      string `json:"Arn"`
+	Enabled  bool   `json:"Enabled"`
+	KeyState string `json:"KeyState"`
+	KeyUsage string `json:"KeyUsage"`
+	KeySpec  string `json:"KeySpec"`
+}
+
+// DescribeKey returns the metadata of the KMS key. keyID is the ID, the ARN, or the alias of the key.
+func (c *Client) DescribeKey(ctx context.Context, keyID string) (*KeyMetadata, error) {
+	body, err := json.Marshal(map[string]string{"KeyId": keyID})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Invoke(ctx, "kms", "TrentService.DescribeKey", body)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		KeyMetadata KeyMetadata `json:"KeyMetadata"`
+	}
+	if err := json.Unmarshal(resp, &out); err != nil {
+		return nil, err
+	}
+	return &out.KeyMetadata, nil
+}
+
+// GenerateDataKeyWithoutPlaintext generates a data key encrypted with the KMS key, which is what EBS does for every
+// encrypted volume. The data key is discarded; the call only checks that the caller can use the key.
+func (c *Client) GenerateDataKeyWithoutPlaintext(ctx context.Context, keyID string) error {
+	body, err := json.Marshal(map[string]string{"KeyId": keyID, "KeySpec": "AES_256"})
+	if err != nil {
+		return err
+	}
+	_, err = c.Invoke(ctx, "kms", "TrentService.GenerateDataKeyWithoutPlaintext", body)
+	return err
+}
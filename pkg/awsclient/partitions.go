@@ -0,0 +1,41 @@
+package awsclient
+
+import "strings"
+
+// Partition is a group of AWS regions with its own DNS suffix, e.g. the China regions.
+type Partition struct {
+	// ID is the partition of the ARNs of the regions, e.g. "aws-cn".
+	ID string
+	// DNSSuffix is the suffix of the service endpoints of the regions.
+	DNSSuffix string
+	// Isolated is true for the air-gapped partitions, where only a subset of the services is available and the
+	// endpoints are signed by a private CA.
+	Isolated bool
+
+	regionPrefixes []string
+}
+
+// defaultPartition is the partition of the commercial regions, and of the regions that match no other partition.
+var defaultPartition = Partition{ID: "aws", DNSSuffix: "amazonaws.com"}
+
+// partitions are the partitions other than the commercial one, see the partitions.json file of the AWS SDKs.
+var partitions = []Partition{
+	{ID: "aws-cn", DNSSuffix: "amazonaws.com.cn", regionPrefixes: []string{"cn-"}},
+	{ID: "aws-us-gov", DNSSuffix: "amazonaws.com", regionPrefixes: []string{"us-gov-"}},
+	{ID: "aws-iso", DNSSuffix: "c2s.ic.gov", Isolated: true, regionPrefixes: []string{"us-iso-"}},
+	{ID: "aws-iso-b", DNSSuffix: "sc2s.sgov.gov", Isolated: true, regionPrefixes: []string{"us-isob-"}},
+	{ID: "aws-iso-e", DNSSuffix: "cloud.adc-e.uk", Isolated: true, regionPrefixes: []string{"eu-isoe-"}},
+	{ID: "aws-iso-f", DNSSuffix: "csp.hci.ic.gov", Isolated: true, regionPrefixes: []string{"us-isof-"}},
+}
+
+// PartitionOf returns the partition of the region.
+func PartitionOf(region string) Partition {
+	for _, partition := range partitions {
+		for _, prefix := range partition.regionPrefixes {
+			if strings.HasPrefix(region, prefix) {
+				return partition
+			}
+		}
+	}
+	return defaultPartition
+}
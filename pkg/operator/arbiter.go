@@ -0,0 +1,89 @@
+package operator
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// highlyAvailableArbiterTopologyMode is the control plane topology of the clusters with two control plane nodes
+// and an arbiter node, which runs etcd but no workloads.
+const highlyAvailableArbiterTopologyMode configv1.TopologyMode = "HighlyAvailableArbiter"
+
+// isArbiterCluster returns true when the control plane topology of the Infrastructure is HighlyAvailableArbiter.
+// It's false while the Infrastructure does not exist.
+func isArbiterCluster(infraLister configlisters.InfrastructureLister) (bool, error) {
+	infra, err := infraLister.Get(infrastructureName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return infra.Status.ControlPlaneTopology == highlyAvailableArbiterTopologyMode, nil
+}
+
+// withArbiterHook keeps the controller off the arbiter node of clusters with the HighlyAvailableArbiter topology
+// with a required node affinity, and sets its replicas from the control plane nodes without the arbiter: 2 when
+// there are two of them, 1 otherwise. The PodDisruptionBudget and the anti-affinity of these clusters are those of
+// the compact clusters, see compactClusterNodes. It must run after withHypershiftReplicasHook.
+func withArbiterHook(isHypershift bool, infraLister configlisters.InfrastructureLister, nodeLister corev1listers.NodeLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if isHypershift {
+			return nil
+		}
+		arbiter, err := isArbiterCluster(infraLister)
+		if err != nil || !arbiter {
+			return err
+		}
+
+		nodes, err := nodeLister.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		controlPlaneNodes := 0
+		for _, node := range nodes {
+			if isControlPlaneNode(node) {
+				controlPlaneNodes++
+			}
+		}
+		replicas := int32(1)
+		if controlPlaneNodes > 1 {
+			replicas = 2
+		}
+		deployment.Spec.Replicas = &replicas
+
+		requirement := corev1.NodeSelectorRequirement{
+			Key:      arbiterNodeRoleLabel,
+			Operator: corev1.NodeSelectorOpDoesNotExist,
+		}
+		podSpec := &deployment.Spec.Template.Spec
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &corev1.Affinity{}
+		}
+		if podSpec.Affinity.NodeAffinity == nil {
+			podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+		}
+		nodeAffinity := podSpec.Affinity.NodeAffinity
+		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+			nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+		}
+		required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if len(required.NodeSelectorTerms) == 0 {
+			required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+		}
+		// The terms are ORed, each of them must exclude the arbiter.
+		for i := range required.NodeSelectorTerms {
+			term := &required.NodeSelectorTerms[i]
+			term.MatchExpressions = append(term.MatchExpressions, requirement)
+		}
+		return nil
+	}
+}
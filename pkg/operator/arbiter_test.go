@@ -0,0 +1,86 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+)
+
+func TestWithArbiterHook(t *testing.T) {
+	notArbiter := corev1.NodeSelectorRequirement{Key: arbiterNodeRoleLabel, Operator: corev1.NodeSelectorOpDoesNotExist}
+	masterArbiter := newTestRoleNode("arbiter-0", arbiterNodeRoleLabel, true, false)
+	masterArbiter.Labels[masterNodeRoleLabel] = ""
+	tests := []struct {
+		name             string
+		topology         configv1.TopologyMode
+		nodes            []*corev1.Node
+		expectedReplicas int32
+		expectedAffinity *corev1.Affinity
+	}{
+		{
+			name:     "highly available",
+			topology: configv1.HighlyAvailableTopologyMode,
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-1", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-2", masterNodeRoleLabel, true, false),
+			},
+			expectedReplicas: 3,
+		},
+		{
+			name:     "arbiter",
+			topology: highlyAvailableArbiterTopologyMode,
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-1", controlPlaneNodeRoleLabel, true, false),
+				newTestRoleNode("arbiter-0", arbiterNodeRoleLabel, true, false),
+			},
+			expectedReplicas: 2,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{notArbiter}}},
+					},
+				},
+			},
+		},
+		{
+			name:     "arbiter with a master label and a single control plane node",
+			topology: highlyAvailableArbiterTopologyMode,
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+				masterArbiter,
+			},
+			expectedReplicas: 1,
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{notArbiter}}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			replicas := int32(3)
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Replicas = &replicas
+			if err := withArbiterHook(false, newTestTopologyInfraLister(test.topology), newTestNodeLister(test.nodes...))(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *deployment.Spec.Replicas != test.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", test.expectedReplicas, *deployment.Spec.Replicas)
+			}
+			if e, a := test.expectedAffinity, deployment.Spec.Template.Spec.Affinity; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected affinity\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
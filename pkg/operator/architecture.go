@@ -0,0 +1,79 @@
+package operator
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// operandArchitecturesEnvName lists the architectures of the operand images, e.g. "amd64,arm64" for a multi-arch
+// release payload. It defaults to the architecture of the operator, which comes from the same payload.
+const operandArchitecturesEnvName = "OPERAND_ARCHITECTURES"
+
+// knownArchitectures are the values of the kubernetes.io/arch node label of the architectures of OpenShift.
+var knownArchitectures = sets.NewString("amd64", "arm64", "ppc64le", "s390x")
+
+// operandArchitectures returns the architectures of the operand images from operandArchitecturesEnvName.
+func operandArchitectures() ([]string, error) {
+	value := os.Getenv(operandArchitecturesEnvName)
+	if value == "" {
+		return []string{runtime.GOARCH}, nil
+	}
+	var archs []string
+	for _, arch := range strings.Split(value, ",") {
+		arch = strings.TrimSpace(arch)
+		if !knownArchitectures.Has(arch) {
+			return nil, fmt.Errorf("invalid %s %q: unknown architecture %q, expected one of %s",
+				operandArchitecturesEnvName, value, arch, strings.Join(knownArchitectures.List(), ", "))
+		}
+		archs = append(archs, arch)
+	}
+	return archs, nil
+}
+
+// withArchitectureDeploymentHook keeps the controller pods on Hypershift off the nodes of the management cluster
+// with an architecture the operand images don't support, which a mixed amd64/arm64 management cluster has.
+// The token minter runs in the same pods, its image must support the same architectures.
+// It must run after the hooks that set the node affinity.
+func withArchitectureDeploymentHook(isHypershift bool, archs []string) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if !isHypershift {
+			return nil
+		}
+		requirement := corev1.NodeSelectorRequirement{
+			Key:      corev1.LabelArchStable,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   archs,
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &corev1.Affinity{}
+		}
+		if podSpec.Affinity.NodeAffinity == nil {
+			podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+		}
+		nodeAffinity := podSpec.Affinity.NodeAffinity
+		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+			nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+		}
+		required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if len(required.NodeSelectorTerms) == 0 {
+			required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+		}
+		// The terms are ORed, each of them must require the architecture.
+		for i := range required.NodeSelectorTerms {
+			term := &required.NodeSelectorTerms[i]
+			term.MatchExpressions = append(term.MatchExpressions, requirement)
+		}
+		return nil
+	}
+}
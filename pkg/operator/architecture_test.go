@@ -0,0 +1,110 @@
+package operator
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestOperandArchitectures(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:     "default",
+			expected: []string{runtime.GOARCH},
+		},
+		{
+			name:     "multi-arch",
+			value:    "amd64, arm64",
+			expected: []string{"amd64", "arm64"},
+		},
+		{
+			name:          "unknown",
+			value:         "amd64,x86_64",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(operandArchitecturesEnvName, test.value)
+			archs, err := operandArchitectures()
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(archs, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, archs)
+			}
+		})
+	}
+}
+
+func TestWithArchitectureDeploymentHook(t *testing.T) {
+	archRequirement := corev1.NodeSelectorRequirement{Key: corev1.LabelArchStable, Operator: corev1.NodeSelectorOpIn, Values: []string{"arm64"}}
+	zoneRequirement := corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"us-east-1a"}}
+
+	tests := []struct {
+		name          string
+		isHypershift  bool
+		affinity      *corev1.Affinity
+		expectedTerms []corev1.NodeSelectorTerm
+	}{
+		{
+			name: "standalone",
+		},
+		{
+			name:          "no affinity",
+			isHypershift:  true,
+			expectedTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{archRequirement}}},
+		},
+		{
+			name:         "required terms",
+			isHypershift: true,
+			affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: []corev1.NodeSelectorRequirement{zoneRequirement}},
+					{},
+				}},
+			}},
+			expectedTerms: []corev1.NodeSelectorTerm{
+				{MatchExpressions: []corev1.NodeSelectorRequirement{zoneRequirement, archRequirement}},
+				{MatchExpressions: []corev1.NodeSelectorRequirement{archRequirement}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Affinity = test.affinity
+			if err := withArchitectureDeploymentHook(test.isHypershift, []string{"arm64"})(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			affinity := deployment.Spec.Template.Spec.Affinity
+			if test.expectedTerms == nil {
+				if affinity != nil {
+					t.Errorf("unexpected affinity %+v", affinity)
+				}
+				return
+			}
+			terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			if !reflect.DeepEqual(terms, test.expectedTerms) {
+				t.Errorf("expected node selector terms %+v, got %+v", test.expectedTerms, terms)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package operator
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	v1 "github.com/openshift/client-go/config/listers/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	assumeRoleVolumeName = "aws-assume-role-config"
+	assumeRoleMountPath  = "/var/run/secrets/aws-assume-role"
+	assumeRoleConfigKey  = "config"
+	assumeRoleProfile    = "assume-role"
+	// assumeRoleAnnotation of the pod template carries the shared config file, which is projected into
+	// the pods by a downward API volume. A new file rolls the pods out.
+	assumeRoleAnnotation = "ebs.csi.openshift.io/aws-assume-role-config"
+)
+
+// withAssumeRole makes the csi-driver container assume the IAM role from the driver configuration, e.g. to manage
+// the volumes in another AWS account in shared VPC installs. A shared config file with a profile that assumes the
+// role is rendered, with the credentials from the Secret as its source: the static keys through the environment,
+// or the default profile of the credentials file, i.e. the web identity of STS clusters. The STS calls use the
+// regional endpoint, or the sts service endpoint of the Infrastructure, and bypass the proxy like the EC2 calls do.
+func withAssumeRole(secretLister corev1listers.SecretNamespaceLister, secretName string, infraLister v1.InfrastructureLister) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		assumeRole := cfg.AssumeRole
+		if assumeRole == nil {
+			return nil
+		}
+		if !roleARNRegexp.MatchString(assumeRole.RoleARN) {
+			return fmt.Errorf("invalid assumeRole roleARN %q, expected arn:aws:iam::<account ID>:role/<name>", assumeRole.RoleARN)
+		}
+		if cfg.SharedConfig != nil {
+			return fmt.Errorf("assumeRole can't be used together with sharedConfig, add the role to the shared config instead")
+		}
+		secret, err := secretLister.Get(secretName)
+		if apierrors.IsNotFound(err) {
+			// The driver waits for the Secret, the source of the credentials is not known yet.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		container := getContainer(podSpec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not assume role because the csi-driver container is missing from the deployment")
+		}
+
+		config := []string{
+			"[profile " + assumeRoleProfile + "]",
+			"role_arn = " + assumeRole.RoleARN,
+		}
+		if _, ok := secret.Data["aws_access_key_id"]; ok {
+			config = append(config, "credential_source = Environment")
+		} else {
+			// The web identity is read from the default profile, the variables would take precedence over the profile.
+			config = append(config, "source_profile = default")
+			for _, name := range []string{"AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_SESSION_NAME"} {
+				removeContainerEnv(container, name)
+			}
+		}
+		if assumeRole.ExternalID != "" {
+			config = append(config, "external_id = "+assumeRole.ExternalID)
+		}
+		if assumeRole.SessionName != "" {
+			config = append(config, "role_session_name = "+assumeRole.SessionName)
+		}
+
+		// The asset points AWS_CONFIG_FILE to the credentials Secret. Keep loading credentials from there.
+		if credentialsFile, ok := getContainerEnv(container, "AWS_CONFIG_FILE"); ok {
+			setContainerEnv(container, "AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+		}
+		setContainerEnv(container, "AWS_CONFIG_FILE", path.Join(assumeRoleMountPath, assumeRoleConfigKey))
+		setContainerEnv(container, "AWS_PROFILE", assumeRoleProfile)
+		setContainerEnv(container, "AWS_SDK_LOAD_CONFIG", "1")
+		setContainerEnv(container, "AWS_STS_REGIONAL_ENDPOINTS", "regional")
+		if err := setSTSEndpoint(container, infraLister); err != nil {
+			return err
+		}
+
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+		deployment.Spec.Template.Annotations[assumeRoleAnnotation] = strings.Join(config, "\n") + "\n"
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      assumeRoleVolumeName,
+			MountPath: assumeRoleMountPath,
+			ReadOnly:  true,
+		})
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: assumeRoleVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				DownwardAPI: &corev1.DownwardAPIVolumeSource{
+					Items: []corev1.DownwardAPIVolumeFile{{
+						Path:     assumeRoleConfigKey,
+						FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations['" + assumeRoleAnnotation + "']"},
+					}},
+				},
+			},
+		})
+		return nil
+	}
+}
+
+// setSTSEndpoint sets the sts service endpoint of the Infrastructure, and adds the STS endpoint to NO_PROXY
+// when the EC2 endpoint is there, so the STS calls take the same route to AWS as the EC2 calls.
+func setSTSEndpoint(container *corev1.Container, infraLister v1.InfrastructureLister) error {
+	infra, err := infraLister.Get(infrastructureName)
+	if err != nil {
+		return err
+	}
+	region, _ := getContainerEnv(container, "AWS_REGION")
+	stsEndpoint := awsclient.DefaultEndpoint("sts", region)
+	if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.AWS != nil {
+		for _, endpoint := range infra.Status.PlatformStatus.AWS.ServiceEndpoints {
+			if endpoint.Name == "sts" && endpoint.URL != "" {
+				stsEndpoint = endpoint.URL
+				setContainerEnv(container, serviceEndpointEnvNames["sts"], endpoint.URL)
+			}
+		}
+	}
+
+	noProxy, ok := getContainerEnv(container, "NO_PROXY")
+	if !ok || region == "" {
+		return nil
+	}
+	ec2Endpoint, ok := getContainerEnv(container, serviceEndpointEnvNames["ec2"])
+	if !ok {
+		ec2Endpoint = awsclient.DefaultEndpoint("ec2", region)
+	}
+	ec2Host, err := endpointHost(ec2Endpoint)
+	if err != nil {
+		return err
+	}
+	stsHost, err := endpointHost(stsEndpoint)
+	if err != nil {
+		return err
+	}
+	if noProxyMatches(noProxy, ec2Host) && !noProxyMatches(noProxy, stsHost) {
+		setContainerEnv(container, "NO_PROXY", noProxy+","+stsHost)
+	}
+	return nil
+}
+
+// endpointHost returns the host name of a service endpoint, which may have no scheme.
+func endpointHost(endpoint string) (string, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	return u.Hostname(), nil
+}
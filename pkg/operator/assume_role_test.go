@@ -0,0 +1,168 @@
+package operator
+
+import (
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWithAssumeRole(t *testing.T) {
+	const targetRoleARN = "arn:aws:iam::210987654321:role/ebs-shared-vpc"
+
+	tests := []struct {
+		name             string
+		overrides        string
+		secret           *corev1.Secret
+		stsEndpoint      string
+		noProxy          string
+		expectedConfig   string
+		expectedEnv      map[string]string
+		unexpectedEnv    []string
+		expectedNoChange bool
+		expectedError    bool
+	}{
+		{
+			name:             "not configured",
+			secret:           newTestCredentialsSecret(""),
+			expectedNoChange: true,
+		},
+		{
+			name:             "secret missing",
+			overrides:        `{"assumeRole": {"roleARN": "` + targetRoleARN + `"}}`,
+			expectedNoChange: true,
+		},
+		{
+			name:      "static credentials",
+			overrides: `{"assumeRole": {"roleARN": "` + targetRoleARN + `", "externalID": "shared-vpc", "sessionName": "ebs-csi"}}`,
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: defaultNamespace},
+				Data:       map[string][]byte{"aws_access_key_id": []byte("id"), "aws_secret_access_key": []byte("secret")},
+			},
+			expectedConfig: "[profile assume-role]\nrole_arn = " + targetRoleARN + "\ncredential_source = Environment\nexternal_id = shared-vpc\nrole_session_name = ebs-csi\n",
+			expectedEnv: map[string]string{
+				"AWS_CONFIG_FILE":             "/var/run/secrets/aws-assume-role/config",
+				"AWS_SHARED_CREDENTIALS_FILE": "/var/run/secrets/aws/credentials",
+				"AWS_PROFILE":                 "assume-role",
+				"AWS_STS_REGIONAL_ENDPOINTS":  "regional",
+				"NO_PROXY":                    ".cluster.local",
+			},
+			noProxy: ".cluster.local",
+		},
+		{
+			name:           "web identity",
+			overrides:      `{"assumeRole": {"roleARN": "` + targetRoleARN + `"}}`,
+			secret:         newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"),
+			expectedConfig: "[profile assume-role]\nrole_arn = " + targetRoleARN + "\nsource_profile = default\n",
+			expectedEnv:    map[string]string{"AWS_PROFILE": "assume-role"},
+			unexpectedEnv:  []string{"AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE"},
+		},
+		{
+			name:           "STS through the EC2 route",
+			overrides:      `{"assumeRole": {"roleARN": "` + targetRoleARN + `"}}`,
+			secret:         newTestCredentialsSecret(""),
+			stsEndpoint:    "https://vpce-sts.example.com",
+			noProxy:        ".cluster.local,ec2.us-east-1.amazonaws.com",
+			expectedConfig: "[profile assume-role]\nrole_arn = " + targetRoleARN + "\nsource_profile = default\n",
+			expectedEnv: map[string]string{
+				"AWS_ENDPOINT_URL_STS": "https://vpce-sts.example.com",
+				"NO_PROXY":             ".cluster.local,ec2.us-east-1.amazonaws.com,vpce-sts.example.com",
+			},
+		},
+		{
+			name:          "malformed role ARN",
+			overrides:     `{"assumeRole": {"roleARN": "ebs-shared-vpc"}}`,
+			secret:        newTestCredentialsSecret(""),
+			expectedError: true,
+		},
+		{
+			name:          "together with shared config",
+			overrides:     `{"assumeRole": {"roleARN": "` + targetRoleARN + `"}, "sharedConfig": {"configMapName": "aws-config"}}`,
+			secret:        newTestCredentialsSecret(""),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if test.secret != nil {
+				secretInformer.Informer().GetIndexer().Add(test.secret)
+			}
+			infra := &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{AWS: &configv1.AWSPlatformStatus{Region: "us-east-1"}},
+				},
+			}
+			if test.stsEndpoint != "" {
+				infra.Status.PlatformStatus.AWS.ServiceEndpoints = []configv1.AWSServiceEndpoint{{Name: "sts", URL: test.stsEndpoint}}
+			}
+			infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+			infraInformer.Informer().GetIndexer().Add(infra)
+
+			container := corev1.Container{
+				Name: driverContainerName,
+				Env: []corev1.EnvVar{
+					{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws/credentials"},
+					{Name: "AWS_REGION", Value: "us-east-1"},
+					{Name: "AWS_ROLE_ARN", Value: testRoleARN},
+					{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: "/var/run/secrets/openshift/serviceaccount/token"},
+				},
+			}
+			if test.noProxy != "" {
+				container.Env = append(container.Env, corev1.EnvVar{Name: "NO_PROXY", Value: test.noProxy})
+			}
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
+			original := deployment.DeepCopy()
+
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			err := withAssumeRole(secretInformer.Lister().Secrets(defaultNamespace), defaultSecretName, infraInformer.Lister())(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedNoChange {
+				if len(deployment.Spec.Template.Spec.Volumes) != 0 || len(deployment.Spec.Template.Spec.Containers[0].Env) != len(original.Spec.Template.Spec.Containers[0].Env) {
+					t.Errorf("expected no change, got %+v", deployment.Spec.Template)
+				}
+				return
+			}
+
+			if config := deployment.Spec.Template.Annotations[assumeRoleAnnotation]; config != test.expectedConfig {
+				t.Errorf("expected config %q, got %q", test.expectedConfig, config)
+			}
+			podSpec := deployment.Spec.Template.Spec
+			if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].DownwardAPI == nil {
+				t.Errorf("expected the downward API volume, got %+v", podSpec.Volumes)
+			}
+			driver := getContainer(&podSpec, driverContainerName)
+			for name, value := range test.expectedEnv {
+				if v, _ := getContainerEnv(driver, name); v != value {
+					t.Errorf("expected %s=%q, got %q", name, value, v)
+				}
+			}
+			for _, name := range test.unexpectedEnv {
+				if _, ok := getContainerEnv(driver, name); ok {
+					t.Errorf("unexpected env var %s", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package operator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// Volumes of the controller Deployment that carry the AWS configuration of the driver.
+	credentialsVolumeName     = "aws-credentials"
+	customCABundleVolumeName  = "ca-bundle"
+	trustedCABundleVolumeName = "non-standard-root-system-trust-ca-bundle"
+	trustedCABundleKey        = "ca-bundle.crt"
+)
+
+// serviceEndpointEnvNames maps the AWS services to the env vars of the csi-driver container that override
+// their endpoints.
+var serviceEndpointEnvNames = map[string]string{
+	"ec2": "AWS_EC2_ENDPOINT",
+	"sts": "AWS_ENDPOINT_URL_STS",
+	"kms": "AWS_ENDPOINT_URL_KMS",
+}
+
+// infraServiceEndpoint returns the endpoint of the AWS service in the Infrastructure, or "" when it's not overridden.
+func infraServiceEndpoint(infraLister configlisters.InfrastructureLister, service string) (string, error) {
+	infra, err := infraLister.Get(infrastructureName)
+	if err != nil {
+		return "", err
+	}
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
+		return "", nil
+	}
+	for _, endpoint := range infra.Status.PlatformStatus.AWS.ServiceEndpoints {
+		if endpoint.Name == service {
+			return endpoint.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// driverAWSClientBuilder builds AWS clients with the configuration of the CSI driver controller: the credentials,
+// the region, the service endpoints, the CA bundles and the proxy are all read from the controller Deployment as
+// rendered by the deployment hooks, so the operator sees AWS the same way the driver does.
+type driverAWSClientBuilder struct {
+	namespace       string
+	secretLister    corev1listers.SecretNamespaceLister
+	configMapLister corev1listers.ConfigMapNamespaceLister
+	// kubeClient and guestKubeClient request the service account tokens the driver exchanges for AWS credentials,
+	// in the cluster of the Deployment and in the guest cluster, where the token minter of Hypershift requests them.
+	kubeClient      kubeclient.Interface
+	guestKubeClient kubeclient.Interface
+}
+
+func newDriverAWSClientBuilder(
+	namespace string,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+) driverAWSClientBuilder {
+	return driverAWSClientBuilder{
+		namespace:       namespace,
+		secretLister:    secretInformer.Lister().Secrets(namespace),
+		configMapLister: configMapInformer.Lister().ConfigMaps(namespace),
+		kubeClient:      kubeClient,
+		guestKubeClient: guestKubeClient,
+	}
+}
+
+// newClient returns a client of the AWS service configured like the csi-driver container of the Deployment,
+// and the description of the configuration to name the culprit of failures.
+func (b *driverAWSClientBuilder) newClient(deployment *appsv1.Deployment, service string, timeout time.Duration) (*awsclient.Client, *awsHealthCheckTarget, error) {
+	return b.newClientWithEndpoint(deployment, service, "", timeout)
+}
+
+// newClientWithEndpoint is newClient for the services that the driver doesn't call, which have no endpoint in the
+// csi-driver container. A non-empty endpoint overrides the default endpoint of the service.
+func (b *driverAWSClientBuilder) newClientWithEndpoint(deployment *appsv1.Deployment, service, endpoint string, timeout time.Duration) (*awsclient.Client, *awsHealthCheckTarget, error) {
+	podSpec := &deployment.Spec.Template.Spec
+	container := getContainer(podSpec, driverContainerName)
+	if container == nil {
+		return nil, nil, fmt.Errorf("the csi-driver container is missing from Deployment %s", deployment.Name)
+	}
+	region, _ := getContainerEnv(container, "AWS_REGION")
+	if region == "" {
+		return nil, nil, &awsHealthCheckError{
+			status:  opv1.ConditionUnknown,
+			reason:  "NoRegion",
+			message: "The AWS region of the driver is not known, the check was skipped",
+		}
+	}
+
+	target := &awsHealthCheckTarget{}
+	rootCAs, err := b.getRootCAs(podSpec, container, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := &awsclient.Client{
+		Region:    region,
+		Endpoints: map[string]string{},
+	}
+	if endpoint == "" {
+		endpoint, _ = getContainerEnv(container, serviceEndpointEnvNames[service])
+	}
+	if endpoint != "" {
+		client.Endpoints[service] = endpoint
+		target.customEndpoint = true
+	}
+	target.endpoint = client.Endpoint(service)
+	endpointURL, err := url.Parse(target.endpoint)
+	if err != nil {
+		return nil, nil, newAWSHealthCheckError("InvalidEndpoint", "Invalid %s endpoint %q: %v. Check the %s service endpoint in Infrastructure %s", strings.ToUpper(service), target.endpoint, err, service, infrastructureName)
+	}
+	target.proxyURL, err = containerProxy(container, endpointURL)
+	if err != nil {
+		return nil, nil, newAWSHealthCheckError("ProxyError", "Invalid proxy configuration: %v. Check the cluster Proxy configuration", err)
+	}
+
+	// The STS calls of the credential providers take the same route as the ones of the driver.
+	proxyContainer := container.DeepCopy()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return containerProxy(proxyContainer, req.URL)
+	}
+	client.HTTPClient = &http.Client{Transport: traceAWSTransport(transport), Timeout: timeout}
+	client.Credentials, err = b.getCredentials(deployment, container, client.HTTPClient, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, target, nil
+}
+
+// awsHealthCheckTarget describes the AWS configuration of the driver used by a client, to name the culprit of a failure.
+type awsHealthCheckTarget struct {
+	endpoint          string
+	customEndpoint    bool
+	proxyURL          *url.URL
+	caSource          string
+	credentialsSource string
+}
+
+// getRootCAs returns the CA bundle the driver uses to verify the AWS endpoints and a description of its source.
+// The custom AWS CA bundle replaces all other CAs, the trusted CA bundle replaces the system CAs.
+func (b *driverAWSClientBuilder) getRootCAs(podSpec *corev1.PodSpec, container *corev1.Container, target *awsHealthCheckTarget) (*x509.CertPool, error) {
+	volumeName, key := "", ""
+	if _, ok := getContainerEnv(container, "AWS_CA_BUNDLE"); ok {
+		volumeName, key = customCABundleVolumeName, caBundleKey
+	} else {
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == trustedCABundleVolumeName {
+				volumeName, key = trustedCABundleVolumeName, trustedCABundleKey
+			}
+		}
+	}
+	if volumeName == "" {
+		target.caSource = "the system CA bundle"
+		return nil, nil
+	}
+
+	configMapName := ""
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == volumeName && volume.ConfigMap != nil {
+			configMapName = volume.ConfigMap.Name
+		}
+	}
+	if configMapName == "" {
+		return nil, fmt.Errorf("the %s volume is missing from the controller Deployment", volumeName)
+	}
+	target.caSource = fmt.Sprintf("the CA bundle in ConfigMap %s/%s", b.namespace, configMapName)
+	cm, err := b.configMapLister.Get(configMapName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, newAWSHealthCheckError("CertificateError", "ConfigMap %s/%s with the CA bundle does not exist", b.namespace, configMapName)
+		}
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(cm.Data[key])) {
+		return nil, newAWSHealthCheckError("CertificateError", "No valid certificates found in %s", target.caSource)
+	}
+	return pool, nil
+}
+
+// containerProxy returns the proxy the container uses to connect to the endpoint, or nil for a direct connection.
+func containerProxy(container *corev1.Container, endpoint *url.URL) (*url.URL, error) {
+	noProxy, _ := getContainerEnv(container, "NO_PROXY")
+	if noProxyMatches(noProxy, endpoint.Hostname()) {
+		return nil, nil
+	}
+	proxyEnv := "HTTPS_PROXY"
+	if endpoint.Scheme == "http" {
+		proxyEnv = "HTTP_PROXY"
+	}
+	proxy, _ := getContainerEnv(container, proxyEnv)
+	if proxy == "" {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	return url.Parse(proxy)
+}
+
+// noProxyMatches returns true when the host matches an entry of the NO_PROXY list: "*", a domain
+// (which matches its subdomains), an IP address or a CIDR.
+func noProxyMatches(noProxy, host string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
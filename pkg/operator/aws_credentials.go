@@ -0,0 +1,197 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	"k8s.io/utils/pointer"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// serviceAccountTokenExpiration is the minimum lifetime of requested tokens, they're only exchanged once
+	// for AWS credentials.
+	serviceAccountTokenExpiration = 600
+	serviceAccountTokenTimeout    = 10 * time.Second
+)
+
+// getCredentials returns the provider of the AWS credentials of the csi-driver container, which follows the
+// default credential chain of the AWS SDK with the configuration of the container: EKS Pod Identity, or the
+// credentials Secret with static keys, a web identity, or a role chained from another profile; and the role of
+// assumeRole on top of them. The service account tokens the driver reads from its projected volumes are
+// requested with TokenRequests of the same ServiceAccount and audience. The STS calls use the HTTP client.
+func (b *driverAWSClientBuilder) getCredentials(deployment *appsv1.Deployment, container *corev1.Container, httpClient *http.Client, target *awsHealthCheckTarget) (aws.CredentialsProvider, error) {
+	podSpec := &deployment.Spec.Template.Spec
+	region, _ := getContainerEnv(container, "AWS_REGION")
+	stsEndpoint, _ := getContainerEnv(container, serviceEndpointEnvNames["sts"])
+	newSTSClient := func(creds aws.CredentialsProvider) *sts.Client {
+		options := sts.Options{
+			Region:      region,
+			Credentials: creds,
+			HTTPClient:  httpClient,
+		}
+		if stsEndpoint != "" {
+			if !strings.Contains(stsEndpoint, "://") {
+				stsEndpoint = "https://" + stsEndpoint
+			}
+			options.EndpointResolver = sts.EndpointResolverFromURL(stsEndpoint)
+		}
+		return sts.New(options)
+	}
+
+	if uri, ok := getContainerEnv(container, "AWS_CONTAINER_CREDENTIALS_FULL_URI"); ok {
+		token := serviceAccountToken{
+			client:    b.kubeClient,
+			namespace: b.namespace,
+			name:      podSpec.ServiceAccountName,
+			audience:  podIdentityTokenAudience,
+		}
+		target.credentialsSource = fmt.Sprintf("the EKS Pod Identity of ServiceAccount %s/%s", token.namespace, token.name)
+		return aws.NewCredentialsCache(podIdentityCredentials(uri, token, httpClient.Timeout)), nil
+	}
+
+	secretName := ""
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == credentialsVolumeName && volume.Secret != nil {
+			secretName = volume.Secret.SecretName
+		}
+	}
+	if secretName == "" {
+		return nil, fmt.Errorf("the %s volume is missing from the controller Deployment", credentialsVolumeName)
+	}
+	target.credentialsSource = fmt.Sprintf("Secret %s/%s", b.namespace, secretName)
+	secret, err := b.secretLister.Get(secretName)
+	if apierrors.IsNotFound(err) {
+		return nil, newAWSHealthCheckError("InvalidCredentials", "Secret %s/%s with the AWS credentials does not exist", b.namespace, secretName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var provider aws.CredentialsProvider
+	accessKeyID, secretAccessKey := string(secret.Data["aws_access_key_id"]), string(secret.Data["aws_secret_access_key"])
+	profiles := awsclient.ParseSharedConfig(secret.Data[sharedCredentialsKey])
+	profile := profiles["default"]
+	switch {
+	case accessKeyID != "" && secretAccessKey != "":
+		provider = credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")
+	case profile["role_arn"] != "" && profile["web_identity_token_file"] != "":
+		provider = stscreds.NewWebIdentityRoleProvider(newSTSClient(nil), profile["role_arn"], b.webIdentityToken(podSpec), func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = profile["role_session_name"]
+		})
+	case profile["role_arn"] != "" && profile["source_profile"] != "":
+		source, ok := profiles[profile["source_profile"]].StaticCredentials()
+		if !ok {
+			return nil, newAWSHealthCheckError("InvalidCredentials", "Secret %s/%s chains role %s from profile %s, which has no static credentials",
+				b.namespace, secretName, profile["role_arn"], profile["source_profile"])
+		}
+		provider = assumeRoleCredentials(newSTSClient(credentials.StaticCredentialsProvider{Value: source}), profile)
+	default:
+		creds, ok := profile.StaticCredentials()
+		if !ok {
+			return nil, newAWSHealthCheckError("InvalidCredentials", "Secret %s/%s does not contain AWS credentials", b.namespace, secretName)
+		}
+		provider = credentials.StaticCredentialsProvider{Value: creds}
+	}
+
+	// withAssumeRole makes the driver assume the role of the driver configuration with the credentials of the Secret.
+	if profileName, _ := getContainerEnv(container, "AWS_PROFILE"); profileName == assumeRoleProfile {
+		assumeRole := awsclient.ParseSharedConfig([]byte(deployment.Spec.Template.Annotations[assumeRoleAnnotation]))[assumeRoleProfile]
+		if assumeRole["role_arn"] == "" {
+			return nil, fmt.Errorf("the %s annotation of the controller Deployment has no role_arn", assumeRoleAnnotation)
+		}
+		provider = assumeRoleCredentials(newSTSClient(aws.NewCredentialsCache(provider)), assumeRole)
+		target.credentialsSource += " assuming role " + assumeRole["role_arn"]
+	}
+	return aws.NewCredentialsCache(provider), nil
+}
+
+// assumeRoleCredentials returns the provider of the credentials of the role of the profile.
+func assumeRoleCredentials(client *sts.Client, profile awsclient.Profile) aws.CredentialsProvider {
+	return stscreds.NewAssumeRoleProvider(client, profile["role_arn"], func(o *stscreds.AssumeRoleOptions) {
+		if externalID := profile["external_id"]; externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+		if sessionName := profile["role_session_name"]; sessionName != "" {
+			o.RoleSessionName = sessionName
+		}
+	})
+}
+
+// webIdentityToken returns the token the driver exchanges for the role of its web identity: the token of the
+// token minter of Hypershift, requested in the guest cluster, or the projected token of the pods.
+func (b *driverAWSClientBuilder) webIdentityToken(podSpec *corev1.PodSpec) serviceAccountToken {
+	if minter := getContainer(podSpec, tokenMinterContainerName); minter != nil {
+		token := serviceAccountToken{client: b.guestKubeClient}
+		token.namespace, _ = getContainerArg(minter, "service-account-namespace")
+		token.name, _ = getContainerArg(minter, "service-account-name")
+		token.audience, _ = getContainerArg(minter, "token-audience")
+		return token
+	}
+	return serviceAccountToken{
+		client:    b.kubeClient,
+		namespace: b.namespace,
+		name:      podSpec.ServiceAccountName,
+		audience:  boundSATokenAudience,
+	}
+}
+
+// podIdentityCredentials returns the provider of the credentials of the EKS Pod Identity Agent at the uri, which
+// requests a new token of the ServiceAccount for every retrieval.
+func podIdentityCredentials(uri string, token serviceAccountToken, timeout time.Duration) aws.CredentialsProvider {
+	return aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		authorizationToken, err := token.request(ctx)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		return endpointcreds.New(uri, func(o *endpointcreds.Options) {
+			// The agent is on the node, the requests don't go through the cluster proxy.
+			o.HTTPClient = &http.Client{Timeout: timeout}
+			o.AuthorizationToken = authorizationToken
+		}).Retrieve(ctx)
+	})
+}
+
+// serviceAccountToken requests tokens of a ServiceAccount for an audience.
+type serviceAccountToken struct {
+	client    kubeclient.Interface
+	namespace string
+	name      string
+	audience  string
+}
+
+// GetIdentityToken implements stscreds.IdentityTokenRetriever.
+func (t serviceAccountToken) GetIdentityToken() ([]byte, error) {
+	token, err := t.request(context.Background())
+	return []byte(token), err
+}
+
+func (t serviceAccountToken) request(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, serviceAccountTokenTimeout)
+	defer cancel()
+	tokenRequest, err := t.client.CoreV1().ServiceAccounts(t.namespace).CreateToken(ctx, t.name, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         []string{t.audience},
+			ExpirationSeconds: pointer.Int64(serviceAccountTokenExpiration),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not request a token of ServiceAccount %s/%s: %w", t.namespace, t.name, err)
+	}
+	return tokenRequest.Status.Token, nil
+}
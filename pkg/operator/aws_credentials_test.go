@@ -0,0 +1,191 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+// newTestSTSServer returns an STS endpoint that issues the credentials of a web identity to "web-identity-<token>"
+// and the ones of an assumed role to "assumed-<access key ID of the caller>".
+func newTestSTSServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		action, accessKeyID := r.Form.Get("Action"), ""
+		switch action {
+		case "AssumeRoleWithWebIdentity":
+			accessKeyID = "web-identity-" + r.Form.Get("WebIdentityToken")
+		case "AssumeRole":
+			credential := strings.SplitN(strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="), "/", 2)[0]
+			accessKeyID = "assumed-" + credential
+		default:
+			t.Errorf("unexpected STS action %q", action)
+		}
+		fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult><Credentials><AccessKeyId>%[2]s</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>`+
+			`<SessionToken>session</SessionToken><Expiration>%[3]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`,
+			action, accessKeyID, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+}
+
+func TestDriverAWSCredentials(t *testing.T) {
+	stsServer := newTestSTSServer(t)
+	defer stsServer.Close()
+	podIdentityAgent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"AccessKeyId":     "pod-identity-" + r.Header.Get("Authorization"),
+			"SecretAccessKey": "secret",
+			"Token":           "session",
+			"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	}))
+	defer podIdentityAgent.Close()
+
+	webIdentity := "[default]\nrole_arn = arn:aws:iam::123456789012:role/ebs\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"
+	tokenMinter := corev1.Container{
+		Name: tokenMinterContainerName,
+		Args: []string{
+			"--service-account-namespace=guest-namespace",
+			"--service-account-name=guest-sa",
+			"--token-audience=openshift",
+		},
+	}
+
+	tests := []struct {
+		name                string
+		credentials         string
+		env                 []corev1.EnvVar
+		annotations         map[string]string
+		sidecars            []corev1.Container
+		expectedAccessKeyID string
+		expectedSource      string
+		expectedError       string
+	}{
+		{
+			name:                "static keys",
+			credentials:         "[default]\naws_access_key_id = id\naws_secret_access_key = secret\n",
+			expectedAccessKeyID: "id",
+			expectedSource:      "Secret " + defaultNamespace + "/" + defaultSecretName,
+		},
+		{
+			name:                "web identity",
+			credentials:         webIdentity,
+			expectedAccessKeyID: "web-identity-" + defaultNamespace + ":controller-sa:openshift",
+			expectedSource:      "Secret " + defaultNamespace + "/" + defaultSecretName,
+		},
+		{
+			name:                "web identity of the token minter",
+			credentials:         webIdentity,
+			sidecars:            []corev1.Container{tokenMinter},
+			expectedAccessKeyID: "web-identity-guest-namespace:guest-sa:openshift",
+			expectedSource:      "Secret " + defaultNamespace + "/" + defaultSecretName,
+		},
+		{
+			name:                "role chained from a profile",
+			credentials:         "[default]\nrole_arn = arn:aws:iam::123456789012:role/ebs\nsource_profile = base\n[profile base]\naws_access_key_id = id\naws_secret_access_key = secret\n",
+			expectedAccessKeyID: "assumed-id",
+			expectedSource:      "Secret " + defaultNamespace + "/" + defaultSecretName,
+		},
+		{
+			name:                "assumed role",
+			credentials:         webIdentity,
+			env:                 []corev1.EnvVar{{Name: "AWS_PROFILE", Value: assumeRoleProfile}},
+			annotations:         map[string]string{assumeRoleAnnotation: "[profile assume-role]\nrole_arn = arn:aws:iam::210987654321:role/shared-vpc\n"},
+			expectedAccessKeyID: "assumed-web-identity-" + defaultNamespace + ":controller-sa:openshift",
+			expectedSource:      "Secret " + defaultNamespace + "/" + defaultSecretName + " assuming role arn:aws:iam::210987654321:role/shared-vpc",
+		},
+		{
+			name:                "pod identity",
+			env:                 []corev1.EnvVar{{Name: "AWS_CONTAINER_CREDENTIALS_FULL_URI", Value: podIdentityAgent.URL}},
+			expectedAccessKeyID: "pod-identity-" + defaultNamespace + ":controller-sa:" + podIdentityTokenAudience,
+			expectedSource:      "the EKS Pod Identity of ServiceAccount " + defaultNamespace + "/controller-sa",
+		},
+		{
+			name:          "no credentials",
+			credentials:   "[default]\nregion = us-east-1\n",
+			expectedError: "does not contain AWS credentials",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The tokens are "<namespace>:<name>:<audience>" of the TokenRequest.
+			tokenReactor := func(action core.Action) (bool, runtime.Object, error) {
+				create := action.(core.CreateActionImpl)
+				request := create.GetObject().(*authenticationv1.TokenRequest)
+				token := fmt.Sprintf("%s:%s:%s", create.GetNamespace(), create.Name, strings.Join(request.Spec.Audiences, ","))
+				return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: token}}, nil
+			}
+			kubeClient := fake.NewSimpleClientset()
+			kubeClient.PrependReactor("create", "serviceaccounts", tokenReactor)
+			guestKubeClient := fake.NewSimpleClientset()
+			guestKubeClient.PrependReactor("create", "serviceaccounts", tokenReactor)
+
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			informerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: defaultNamespace},
+				Data:       map[string][]byte{sharedCredentialsKey: []byte(test.credentials)},
+			})
+			builder := newDriverAWSClientBuilder(defaultNamespace, informerFactory.Core().V1().Secrets(), informerFactory.Core().V1().ConfigMaps(), kubeClient, guestKubeClient)
+
+			container := corev1.Container{
+				Name: driverContainerName,
+				Env: append([]corev1.EnvVar{
+					{Name: "AWS_REGION", Value: "us-east-1"},
+					{Name: "AWS_ENDPOINT_URL_STS", Value: stsServer.URL},
+				}, test.env...),
+			}
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace},
+			}
+			deployment.Spec.Template.Annotations = test.annotations
+			deployment.Spec.Template.Spec = corev1.PodSpec{
+				ServiceAccountName: "controller-sa",
+				Containers:         append([]corev1.Container{container}, test.sidecars...),
+				Volumes: []corev1.Volume{{
+					Name: credentialsVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{SecretName: defaultSecretName},
+					},
+				}},
+			}
+
+			target := &awsHealthCheckTarget{}
+			provider, err := builder.getCredentials(deployment, &container, &http.Client{Timeout: time.Second}, target)
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			creds, err := provider.Retrieve(context.TODO())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if creds.AccessKeyID != test.expectedAccessKeyID {
+				t.Errorf("expected access key ID %q, got %q", test.expectedAccessKeyID, creds.AccessKeyID)
+			}
+			if target.credentialsSource != test.expectedSource {
+				t.Errorf("expected credentials source %q, got %q", test.expectedSource, target.credentialsSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	awsErrorsCheckInterval = 5 * time.Minute
+	// awsErrorsWindow is how far back the events of the failed operations are classified.
+	awsErrorsWindow = 15 * time.Minute
+)
+
+// awsErrorClass is a class of AWS errors of the driver, reported in its own condition.
+type awsErrorClass struct {
+	condition string
+	reason    string
+	// codes are the AWS error codes of the class, as found in the messages of the events.
+	codes []string
+	hint  string
+}
+
+var awsErrorClasses = []awsErrorClass{
+	{
+		condition: "AWSEBSDriverCredentialsDegraded",
+		reason:    "AuthorizationFailed",
+		codes: []string{"AuthFailure", "InvalidClientTokenId", "SignatureDoesNotMatch", "UnrecognizedClientException",
+			"InvalidAccessKeyId", "ExpiredToken", "InvalidIdentityToken", "UnauthorizedOperation", "AccessDenied"},
+		hint: "Check the credentials Secret of the driver and the permissions of its IAM user or role",
+	},
+	{
+		condition: "AWSEBSDriverThrottledProgressing",
+		reason:    "Throttled",
+		codes:     []string{"RequestLimitExceeded", "ThrottlingException", "Throttling"},
+		hint:      "The driver retries the calls, check the other clients of the EC2 API in the account and region",
+	},
+	{
+		condition: "AWSEBSDriverQuotaDegraded",
+		reason:    "QuotaExceeded",
+		codes: []string{"VolumeLimitExceeded", "MaxIOPSLimitExceeded", "ResourceLimitExceeded", "SnapshotLimitExceeded",
+			"AttachmentLimitExceeded", "InsufficientVolumeCapacity"},
+		hint: "Request an increase of the EBS quotas of the account, or free up volumes and snapshots",
+	},
+}
+
+// awsErrorEventReasons are the reasons of the events of the failed volume operations, whose messages carry the
+// AWS errors of the driver.
+var awsErrorEventReasons = []string{"ProvisioningFailed", "FailedAttachVolume", "VolumeResizeFailed"}
+
+// awsErrorsController classifies the AWS errors in the events of the volume operations that failed recently:
+// the driver reports its errors to the sidecars and the attach-detach controller, which record them in events of
+// the PersistentVolumeClaims and the pods. Without it, they only show as failed operations, or in the availability
+// of the controller Deployment.
+//
+// It produces the following conditions, True when the errors of their class are found in the events of the last
+// 15 minutes:
+// AWSEBSDriverCredentialsDegraded: the credentials of the driver are rejected or not allowed to call the API.
+// AWSEBSDriverThrottledProgressing: the calls of the driver are throttled.
+// AWSEBSDriverQuotaDegraded: a quota or a limit of the account is exceeded.
+// <name>Degraded: produced when the sync() method returns an error.
+type awsErrorsController struct {
+	operatorClient v1helpers.OperatorClient
+	kubeClient     kubeclient.Interface
+
+	now func() time.Time
+	// lastCheck records the last check, to check again when the interval elapses.
+	lastCheck time.Time
+}
+
+func newAWSErrorsController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubeclient.Interface,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &awsErrorsController{
+		operatorClient: operatorClient,
+		kubeClient:     kubeClient,
+		now:            time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *awsErrorsController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	now := c.now()
+	if !c.lastCheck.IsZero() && now.Sub(c.lastCheck) < awsErrorsCheckInterval {
+		return nil
+	}
+
+	// The events of all the namespaces are listed once per interval instead of being watched.
+	var failures []corev1.Event
+	for _, reason := range awsErrorEventReasons {
+		list, err := c.kubeClient.CoreV1().Events("").List(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("reason", reason).String(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list the %s events: %w", reason, err)
+		}
+		for _, event := range list.Items {
+			if event.Reason == reason && now.Sub(eventLastTime(&event)) <= awsErrorsWindow {
+				failures = append(failures, event)
+			}
+		}
+	}
+	// The most recent failures first.
+	sort.SliceStable(failures, func(i, j int) bool {
+		return eventLastTime(&failures[i]).After(eventLastTime(&failures[j]))
+	})
+
+	var updateFuncs []v1helpers.UpdateStatusFunc
+	for _, class := range awsErrorClasses {
+		updateFuncs = append(updateFuncs, v1helpers.UpdateConditionFn(classifyAWSErrors(class, failures)))
+	}
+	if _, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, updateFuncs...); err != nil {
+		return err
+	}
+	c.lastCheck = now
+	return nil
+}
+
+// classifyAWSErrors returns the condition of the class from the events of the failed operations, the most recent
+// first.
+func classifyAWSErrors(class awsErrorClass, failures []corev1.Event) opv1.OperatorCondition {
+	cond := opv1.OperatorCondition{
+		Type:   class.condition,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	var latest *corev1.Event
+	count := 0
+	for i := range failures {
+		event := &failures[i]
+		if !containsAWSErrorCode(event.Message, class.codes) {
+			continue
+		}
+		if latest == nil {
+			latest = event
+		}
+		count++
+	}
+	if latest == nil {
+		return cond
+	}
+	cond.Status = opv1.ConditionTrue
+	cond.Reason = class.reason
+	cond.Message = fmt.Sprintf("%d events of failed volume operations in the last %s, the last one of %s %s/%s: %s. %s",
+		count, awsErrorsWindow, latest.InvolvedObject.Kind, latest.InvolvedObject.Namespace, latest.InvolvedObject.Name,
+		latest.Message, class.hint)
+	return cond
+}
+
+// containsAWSErrorCode returns true when the message has one of the AWS error codes as a word, e.g. not
+// Throttling in ThrottlingException.
+func containsAWSErrorCode(message string, codes []string) bool {
+	words := strings.FieldsFunc(message, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	for _, word := range words {
+		for _, code := range codes {
+			if word == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventLastTime returns when the event last happened, from the fields of the core/v1 and events.k8s.io/v1 APIs.
+func eventLastTime(event *corev1.Event) time.Time {
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
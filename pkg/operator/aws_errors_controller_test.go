@@ -0,0 +1,122 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestFailureEvent(name, reason, message string, lastTimestamp time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "test", Name: name},
+		InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "test", Name: "pvc-" + name},
+		Reason:         reason,
+		Message:        message,
+		LastTimestamp:  metav1.NewTime(lastTimestamp),
+		Count:          1,
+	}
+}
+
+func TestAWSErrorsController(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	const (
+		credentials = "AWSEBSDriverCredentialsDegraded"
+		throttled   = "AWSEBSDriverThrottledProgressing"
+		quota       = "AWSEBSDriverQuotaDegraded"
+	)
+	tests := []struct {
+		name             string
+		events           []*corev1.Event
+		expectedTrue     map[string]string
+		expectedMessages map[string]string
+	}{
+		{
+			name: "no failure",
+		},
+		{
+			name: "unauthorized provisioning",
+			events: []*corev1.Event{
+				newTestFailureEvent("a", "ProvisioningFailed", `failed to provision volume with StorageClass "gp3-csi": rpc error: code = Internal desc = api error UnauthorizedOperation: You are not authorized to perform this operation.`, now.Add(-time.Minute)),
+			},
+			expectedTrue:     map[string]string{credentials: "AuthorizationFailed"},
+			expectedMessages: map[string]string{credentials: "PersistentVolumeClaim test/pvc-a"},
+		},
+		{
+			name: "throttled attach and volume limit",
+			events: []*corev1.Event{
+				newTestFailureEvent("a", "FailedAttachVolume", `AttachVolume.Attach failed for volume "pvc-a" : rpc error: code = Internal desc = api error RequestLimitExceeded: Request limit exceeded.`, now.Add(-2*time.Minute)),
+				newTestFailureEvent("b", "ProvisioningFailed", `rpc error: code = Internal desc = api error VolumeLimitExceeded: You have exceeded your maximum gp3 storage limit.`, now.Add(-3*time.Minute)),
+				newTestFailureEvent("c", "VolumeResizeFailed", `resize volume "pvc-c" failed: api error ThrottlingException: Rate exceeded`, now.Add(-time.Minute)),
+			},
+			expectedTrue:     map[string]string{throttled: "Throttled", quota: "QuotaExceeded"},
+			expectedMessages: map[string]string{throttled: "2 events of failed volume operations", quota: "pvc-b"},
+		},
+		{
+			name: "old failure",
+			events: []*corev1.Event{
+				newTestFailureEvent("a", "ProvisioningFailed", "api error AuthFailure: AWS was not able to validate the provided access credentials", now.Add(-time.Hour)),
+			},
+		},
+		{
+			name: "other reason",
+			events: []*corev1.Event{
+				newTestFailureEvent("a", "FailedMount", "api error AccessDenied", now.Add(-time.Minute)),
+			},
+		},
+		{
+			name: "code in another word",
+			events: []*corev1.Event{
+				newTestFailureEvent("a", "ProvisioningFailed", "api error NotThrottlingAtAll", now.Add(-time.Minute)),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, event := range test.events {
+				objects = append(objects, event)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+			c := &awsErrorsController{
+				operatorClient: operatorClient,
+				kubeClient:     fake.NewSimpleClientset(objects...),
+				now:            func() time.Time { return now },
+			}
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			for _, class := range awsErrorClasses {
+				cond := v1helpers.FindOperatorCondition(status.Conditions, class.condition)
+				if cond == nil {
+					t.Fatalf("expected condition %s", class.condition)
+				}
+				expectedReason, expectedTrue := test.expectedTrue[class.condition]
+				if !expectedTrue {
+					if cond.Status != opv1.ConditionFalse {
+						t.Errorf("expected %s False, got %+v", class.condition, cond)
+					}
+					continue
+				}
+				if cond.Status != opv1.ConditionTrue || cond.Reason != expectedReason {
+					t.Errorf("expected %s True with reason %s, got %+v", class.condition, expectedReason, cond)
+				}
+				if !strings.Contains(cond.Message, test.expectedMessages[class.condition]) {
+					t.Errorf("expected message of %s containing %q, got %q", class.condition, test.expectedMessages[class.condition], cond.Message)
+				}
+			}
+		})
+	}
+}
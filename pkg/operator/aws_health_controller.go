@@ -0,0 +1,225 @@
+package operator
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	awsReachableCondition = "AWSReachable"
+
+	defaultAWSHealthCheckInterval = 5 * time.Minute
+	minAWSHealthCheckInterval     = 1 * time.Minute
+	awsHealthCheckTimeout         = 30 * time.Second
+)
+
+// awsHealthController periodically checks that the AWS EC2 API is reachable with the configuration of the
+// CSI driver controller: the credentials, the EC2 endpoint, the CA bundles and the proxy are all read from the
+// controller Deployment as rendered by the deployment hooks. The check calls EC2 DescribeAvailabilityZones,
+// which is cheap and needs no special permissions.
+//
+// It produces the following conditions:
+// AWSReachable: False when the EC2 API can't be reached, with the likely culprit in the reason and message.
+// <name>Degraded: produced when the sync() method returns an error.
+type awsHealthController struct {
+	driverAWSClientBuilder
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+
+	now func() time.Time
+	// lastCheck and lastGeneration record the last check, to check again when the interval
+	// elapses or the Deployment changes.
+	lastCheck      time.Time
+	lastGeneration int64
+}
+
+func newAWSHealthController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &awsHealthController{
+		driverAWSClientBuilder: newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient),
+		operatorClient:         operatorClient,
+		deploymentLister:       deploymentInformer.Lister().Deployments(namespace),
+		now:                    time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *awsHealthController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+	interval, enabled, err := cfg.awsHealthCheckInterval()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, awsReachableCondition)
+			return nil
+		})
+		return err
+	}
+
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// Nothing to check until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	if !c.lastCheck.IsZero() && deployment.Generation == c.lastGeneration && now.Sub(c.lastCheck) < interval {
+		return nil
+	}
+
+	cond := c.check(ctx, deployment)
+	c.lastCheck = now
+	c.lastGeneration = deployment.Generation
+	if cond.Status != opv1.ConditionTrue {
+		klog.Warningf("AWS health check failed: %s", cond.Message)
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// awsHealthCheckError is a failed health check with the reason of the AWSReachable condition.
+type awsHealthCheckError struct {
+	status  opv1.ConditionStatus
+	reason  string
+	message string
+}
+
+func (e *awsHealthCheckError) Error() string {
+	return e.message
+}
+
+func newAWSHealthCheckError(reason, format string, args ...interface{}) error {
+	return &awsHealthCheckError{status: opv1.ConditionFalse, reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+func (c *awsHealthController) check(ctx context.Context, deployment *appsv1.Deployment) opv1.OperatorCondition {
+	cond := opv1.OperatorCondition{
+		Type:   awsReachableCondition,
+		Status: opv1.ConditionTrue,
+		Reason: "AsExpected",
+	}
+
+	err := c.describeAvailabilityZones(ctx, deployment)
+	if err == nil {
+		return cond
+	}
+	var checkErr *awsHealthCheckError
+	if !errors.As(err, &checkErr) {
+		checkErr = &awsHealthCheckError{status: opv1.ConditionUnknown, reason: "CheckFailed", message: err.Error()}
+	}
+	cond.Status = checkErr.status
+	cond.Reason = checkErr.reason
+	cond.Message = checkErr.message
+	return cond
+}
+
+func (c *awsHealthController) describeAvailabilityZones(ctx context.Context, deployment *appsv1.Deployment) error {
+	client, target, err := c.newClient(deployment, "ec2", awsHealthCheckTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = client.DescribeAvailabilityZones(ctx)
+	if err == nil {
+		return nil
+	}
+	return target.classifyError(err)
+}
+
+// classifyError translates an error of the EC2 call into a health check error that names the likely culprit.
+func (t *awsHealthCheckTarget) classifyError(err error) error {
+	var credsErr *awsclient.CredentialsError
+	if errors.As(err, &credsErr) {
+		return newAWSHealthCheckError("InvalidCredentials", "Failed to get the AWS credentials from %s: %v", t.credentialsSource, credsErr.Err)
+	}
+	var apiErr *awsclient.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case "AuthFailure", "InvalidClientTokenId", "SignatureDoesNotMatch", "UnrecognizedClientException", "InvalidAccessKeyId":
+			return newAWSHealthCheckError("InvalidCredentials", "EC2 rejected the credentials from %s: %v", t.credentialsSource, apiErr)
+		case "UnauthorizedOperation":
+			return newAWSHealthCheckError("PermissionDenied", "The credentials from %s are not allowed to describe availability zones: %v", t.credentialsSource, apiErr)
+		case "":
+			return newAWSHealthCheckError("EndpointError", "Unexpected response from the EC2 endpoint %s: %v. %s", t.endpoint, apiErr, t.endpointHint())
+		}
+		return newAWSHealthCheckError("UnexpectedResponse", "EC2 returned an error: %v", apiErr)
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &certInvalidErr) || errors.As(err, &hostnameErr) {
+		return newAWSHealthCheckError("CertificateError", "Failed to verify the certificate of the EC2 endpoint %s with %s: %v. Check the custom CA bundle of the cloud config", t.endpoint, t.caSource, err)
+	}
+
+	if t.proxyURL == nil {
+		return newAWSHealthCheckError("EndpointUnreachable", "Failed to connect to the EC2 endpoint %s: %v. %s", t.endpoint, err, t.endpointHint())
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return newAWSHealthCheckError("ProxyError", "Failed to connect to the EC2 endpoint %s through proxy %s: %v. Check the cluster Proxy configuration", t.endpoint, t.proxyURL.Redacted(), err)
+	}
+	return newAWSHealthCheckError("EndpointUnreachable", "Failed to connect to the EC2 endpoint %s through proxy %s: %v. %s", t.endpoint, t.proxyURL.Redacted(), err, t.endpointHint())
+}
+
+func (t *awsHealthCheckTarget) endpointHint() string {
+	if t.customEndpoint {
+		return fmt.Sprintf("Check the EC2 service endpoint in Infrastructure %s", infrastructureName)
+	}
+	return "Check the network connectivity to AWS"
+}
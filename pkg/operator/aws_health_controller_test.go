@@ -0,0 +1,242 @@
+package operator
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testAuthFailureResponse = `<Response><Errors><Error><Code>AuthFailure</Code><Message>AWS was not able to validate the provided access credentials</Message></Error></Errors></Response>`
+
+// newTestHealthCheckDeployment returns a controller Deployment that uses the given EC2 endpoint and proxy,
+// and optionally the custom CA bundle.
+func newTestHealthCheckDeployment(endpoint, proxy string, customCABundle bool) *appsv1.Deployment {
+	container := corev1.Container{
+		Name: "csi-driver",
+		Env: []corev1.EnvVar{
+			{Name: "AWS_REGION", Value: "us-east-1"},
+			{Name: "AWS_EC2_ENDPOINT", Value: endpoint},
+		},
+	}
+	if proxy != "" {
+		container.Env = append(container.Env, corev1.EnvVar{Name: "HTTPS_PROXY", Value: proxy})
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       controllerDeploymentName,
+			Namespace:  defaultNamespace,
+			Generation: 1,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{
+						Name: "aws-credentials",
+						VolumeSource: corev1.VolumeSource{
+							Secret: &corev1.SecretVolumeSource{SecretName: defaultSecretName},
+						},
+					}},
+				},
+			},
+		},
+	}
+	podSpec := &deployment.Spec.Template.Spec
+	podSpec.Containers = []corev1.Container{container}
+	if customCABundle {
+		if err := injectCustomAWSCABundle(podSpec, cloudConfigName); err != nil {
+			panic(err)
+		}
+	}
+	return deployment
+}
+
+func newTestAWSHealthController(spec *opv1.OperatorSpec, deployment *appsv1.Deployment, caBundle []byte) (*awsHealthController, v1helpers.OperatorClient) {
+	kubeClient := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(deployment)
+	informerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: defaultNamespace},
+		Data: map[string][]byte{
+			"credentials": []byte("[default]\naws_access_key_id = id\naws_secret_access_key = secret\n"),
+		},
+	})
+	informerFactory.Core().V1().ConfigMaps().Informer().GetIndexer().Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: cloudConfigName, Namespace: defaultNamespace},
+		Data:       map[string]string{caBundleKey: string(caBundle)},
+	})
+
+	spec.ManagementState = opv1.Managed
+	operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+	c := &awsHealthController{
+		driverAWSClientBuilder: newDriverAWSClientBuilder(defaultNamespace, informerFactory.Core().V1().Secrets(), informerFactory.Core().V1().ConfigMaps(), kubeClient, kubeClient),
+		operatorClient:         operatorClient,
+		deploymentLister:       informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace),
+		now:                    time.Now,
+	}
+	return c, operatorClient
+}
+
+func TestAWSHealthController(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<DescribeAvailabilityZonesResponse><availabilityZoneInfo><item><zoneName>us-east-1a</zoneName></item></availabilityZoneInfo></DescribeAvailabilityZonesResponse>`))
+	}))
+	defer server.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	authFailureServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(testAuthFailureResponse))
+	}))
+	defer authFailureServer.Close()
+	authFailureCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: authFailureServer.Certificate().Raw})
+
+	tests := []struct {
+		name           string
+		overrides      string
+		deployment     *appsv1.Deployment
+		caBundle       []byte
+		expectedStatus opv1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "reachable",
+			deployment:     newTestHealthCheckDeployment(server.URL, "", true),
+			caBundle:       serverCA,
+			expectedStatus: opv1.ConditionTrue,
+			expectedReason: "AsExpected",
+		},
+		{
+			name:           "untrusted certificate",
+			deployment:     newTestHealthCheckDeployment(server.URL, "", false),
+			expectedStatus: opv1.ConditionFalse,
+			expectedReason: "CertificateError",
+		},
+		{
+			name:           "invalid CA bundle",
+			deployment:     newTestHealthCheckDeployment(server.URL, "", true),
+			caBundle:       []byte("not a certificate"),
+			expectedStatus: opv1.ConditionFalse,
+			expectedReason: "CertificateError",
+		},
+		{
+			name:           "rejected credentials",
+			deployment:     newTestHealthCheckDeployment(authFailureServer.URL, "", true),
+			caBundle:       authFailureCA,
+			expectedStatus: opv1.ConditionFalse,
+			expectedReason: "InvalidCredentials",
+		},
+		{
+			name:           "unreachable endpoint",
+			deployment:     newTestHealthCheckDeployment("https://127.0.0.1:1", "", false),
+			expectedStatus: opv1.ConditionFalse,
+			expectedReason: "EndpointUnreachable",
+		},
+		{
+			name:           "unreachable proxy",
+			deployment:     newTestHealthCheckDeployment(server.URL, "http://127.0.0.1:1", true),
+			caBundle:       serverCA,
+			expectedStatus: opv1.ConditionFalse,
+			expectedReason: "ProxyError",
+		},
+		{
+			name:       "disabled",
+			overrides:  `{"awsHealthCheck": {"disabled": true}}`,
+			deployment: newTestHealthCheckDeployment("https://127.0.0.1:1", "", false),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, operatorClient := newTestAWSHealthController(spec, test.deployment, test.caBundle)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, awsReachableCondition)
+			if test.expectedStatus == "" {
+				if cond != nil {
+					t.Errorf("expected no %s condition, got %#v", awsReachableCondition, cond)
+				}
+				return
+			}
+			if cond == nil {
+				t.Fatalf("expected %s condition, got none", awsReachableCondition)
+			}
+			if cond.Status != test.expectedStatus || cond.Reason != test.expectedReason {
+				t.Errorf("expected condition %s/%s, got %s/%s: %s", test.expectedStatus, test.expectedReason, cond.Status, cond.Reason, cond.Message)
+			}
+		})
+	}
+}
+
+func TestAWSHealthControllerInterval(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`<DescribeAvailabilityZonesResponse/>`))
+	}))
+	defer server.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	spec := &opv1.OperatorSpec{}
+	spec.UnsupportedConfigOverrides.Raw = []byte(`{"awsHealthCheck": {"interval": "10m"}}`)
+	c, _ := newTestAWSHealthController(spec, newTestHealthCheckDeployment(server.URL, "", true), serverCA)
+	now := time.Now()
+
+	syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+	for _, step := range []struct {
+		elapsed          time.Duration
+		expectedRequests int
+	}{
+		{elapsed: 0, expectedRequests: 1},
+		{elapsed: 5 * time.Minute, expectedRequests: 1},
+		{elapsed: 11 * time.Minute, expectedRequests: 2},
+	} {
+		c.now = func() time.Time { return now.Add(step.elapsed) }
+		if err := c.sync(context.TODO(), syncCtx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if requests != step.expectedRequests {
+			t.Errorf("after %s: expected %d requests, got %d", step.elapsed, step.expectedRequests, requests)
+		}
+	}
+}
+
+func TestNoProxyMatches(t *testing.T) {
+	tests := []struct {
+		noProxy  string
+		host     string
+		expected bool
+	}{
+		{noProxy: "", host: "ec2.us-east-1.amazonaws.com", expected: false},
+		{noProxy: "*", host: "ec2.us-east-1.amazonaws.com", expected: true},
+		{noProxy: ".amazonaws.com", host: "ec2.us-east-1.amazonaws.com", expected: true},
+		{noProxy: "amazonaws.com", host: "ec2.us-east-1.amazonaws.com", expected: true},
+		{noProxy: "example.com,10.0.0.0/16", host: "10.0.1.1", expected: true},
+		{noProxy: "example.com,10.0.0.0/16", host: "ec2.us-east-1.amazonaws.com", expected: false},
+		{noProxy: "zonaws.com", host: "ec2.us-east-1.amazonaws.com", expected: false},
+	}
+	for _, test := range tests {
+		if a := noProxyMatches(test.noProxy, test.host); a != test.expected {
+			t.Errorf("noProxyMatches(%q, %q): expected %t, got %t", test.noProxy, test.host, test.expected, a)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package operator
+
+import (
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+)
+
+// awsProviderIDPrefix is the prefix of the providerID of the nodes that run on AWS instances.
+const awsProviderIDPrefix = "aws://"
+
+// nonAWSNodes returns the sorted names of the nodes that don't run on AWS, i.e. whose providerID is set by another
+// cloud provider, e.g. bare metal workers attached to an AWS control plane. Nodes without providerID are not
+// initialized yet and are not included.
+func nonAWSNodes(nodeLister corev1listers.NodeLister) ([]string, error) {
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, node := range nodes {
+		if node.Spec.ProviderID != "" && !strings.HasPrefix(node.Spec.ProviderID, awsProviderIDPrefix) {
+			names = append(names, node.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// withAWSNodesDaemonSetHook keeps the node DaemonSet off the nodes that don't run on AWS in mixed-platform clusters,
+// where the driver can't reach the instance metadata service and crash-loops. Node affinity can't select the
+// providerID, so the nodes are excluded by name in every term of the required node affinity. The DaemonSet is not
+// changed when all nodes run on AWS.
+func withAWSNodesDaemonSetHook(nodeLister corev1listers.NodeLister) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(_ *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		names, err := nonAWSNodes(nodeLister)
+		if err != nil || len(names) == 0 {
+			return err
+		}
+		requirement := corev1.NodeSelectorRequirement{
+			Key:      "metadata.name",
+			Operator: corev1.NodeSelectorOpNotIn,
+			Values:   names,
+		}
+
+		podSpec := &daemonSet.Spec.Template.Spec
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &corev1.Affinity{}
+		}
+		if podSpec.Affinity.NodeAffinity == nil {
+			podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+		}
+		nodeAffinity := podSpec.Affinity.NodeAffinity
+		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+			nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+		}
+		required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if len(required.NodeSelectorTerms) == 0 {
+			required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+		}
+		// The terms are ORed, each of them must exclude the nodes.
+		for i := range required.NodeSelectorTerms {
+			term := &required.NodeSelectorTerms[i]
+			term.MatchFields = append(term.MatchFields, requirement)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+)
+
+func newTestProviderNode(name, providerID string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{ProviderID: providerID},
+	}
+}
+
+func TestWithAWSNodesDaemonSetHook(t *testing.T) {
+	archTerm := corev1.NodeSelectorTerm{
+		MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "kubernetes.io/arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd64"}}},
+	}
+	tests := []struct {
+		name             string
+		nodes            []*corev1.Node
+		affinity         *corev1.Affinity
+		expectedAffinity *corev1.Affinity
+	}{
+		{
+			name: "AWS nodes",
+			nodes: []*corev1.Node{
+				newTestProviderNode("node-0", "aws:///us-east-1a/i-0123456789abcdef0"),
+				newTestProviderNode("node-1", ""),
+			},
+		},
+		{
+			name: "mixed-platform cluster",
+			nodes: []*corev1.Node{
+				newTestProviderNode("node-0", "aws:///us-east-1a/i-0123456789abcdef0"),
+				newTestProviderNode("metal-1", "baremetalhost:///openshift-machine-api/metal-1"),
+				newTestProviderNode("metal-0", "baremetalhost:///openshift-machine-api/metal-0"),
+			},
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchFields: []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"metal-0", "metal-1"}}},
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "mixed-platform cluster with node affinity",
+			nodes: []*corev1.Node{
+				newTestProviderNode("metal-0", "baremetalhost:///openshift-machine-api/metal-0"),
+			},
+			affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{archTerm}},
+				},
+			},
+			expectedAffinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: archTerm.MatchExpressions,
+							MatchFields:      []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"metal-0"}}},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			daemonSet := &appsv1.DaemonSet{}
+			daemonSet.Spec.Template.Spec.Affinity = test.affinity
+			if err := withAWSNodesDaemonSetHook(newTestNodeLister(test.nodes...))(&opv1.OperatorSpec{}, daemonSet); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expectedAffinity, daemonSet.Spec.Template.Spec.Affinity; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected affinity\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package operator
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+)
+
+const (
+	masterNodeRoleLabel       = "node-role.kubernetes.io/master"
+	controlPlaneNodeRoleLabel = "node-role.kubernetes.io/control-plane"
+	// arbiterNodeRoleLabel marks the arbiter node of two-node clusters, which runs etcd but no workloads.
+	arbiterNodeRoleLabel = "node-role.kubernetes.io/arbiter"
+)
+
+// compactClusterNodes returns true on compact clusters, i.e. clusters with several control plane nodes and no
+// dedicated worker nodes, like three-node clusters, or clusters with the HighlyAvailableArbiter topology, whose
+// two control plane nodes run the controller even when there are workers. It also returns the number of control
+// plane nodes that can run the controller: Ready and not cordoned. Arbiter nodes don't run the controller.
+func compactClusterNodes(infraLister configlisters.InfrastructureLister, nodeLister corev1listers.NodeLister) (bool, int, error) {
+	arbiter, err := isArbiterCluster(infraLister)
+	if err != nil {
+		return false, 0, err
+	}
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		return false, 0, err
+	}
+	controlPlaneNodes, schedulable := 0, 0
+	workers := false
+	for _, node := range nodes {
+		if !isControlPlaneNode(node) {
+			workers = workers || !isArbiterNode(node)
+			continue
+		}
+		controlPlaneNodes++
+		if !node.Spec.Unschedulable && nodeReady(node) {
+			schedulable++
+		}
+	}
+	return controlPlaneNodes > 1 && (arbiter || !workers), schedulable, nil
+}
+
+// isControlPlaneNode returns true for the master and control-plane nodes, except the arbiter nodes.
+func isControlPlaneNode(node *corev1.Node) bool {
+	if isArbiterNode(node) {
+		return false
+	}
+	_, master := node.Labels[masterNodeRoleLabel]
+	_, controlPlane := node.Labels[controlPlaneNodeRoleLabel]
+	return master || controlPlane
+}
+
+func isArbiterNode(node *corev1.Node) bool {
+	_, ok := node.Labels[arbiterNodeRoleLabel]
+	return ok
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// withCompactClusterHook turns the required pod anti-affinity of the controller Deployment into a preferred one on
+// compact standalone clusters. There, the replicas can't be spread when a control plane node is down or drained, and
+// a required anti-affinity would keep a replica pending and block the upgrade. It must run after the hooks that set
+// the affinity.
+func withCompactClusterHook(isHypershift bool, infraLister configlisters.InfrastructureLister, nodeLister corev1listers.NodeLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if isHypershift {
+			return nil
+		}
+		affinity := deployment.Spec.Template.Spec.Affinity
+		if affinity == nil || affinity.PodAntiAffinity == nil || len(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) == 0 {
+			return nil
+		}
+		compact, _, err := compactClusterNodes(infraLister, nodeLister)
+		if err != nil || !compact {
+			return err
+		}
+		antiAffinity := affinity.PodAntiAffinity
+		for _, term := range antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+				corev1.WeightedPodAffinityTerm{Weight: 100, PodAffinityTerm: term})
+		}
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = nil
+		return nil
+	}
+}
+
+// withCompactClusterPDB sets the minAvailable of the controller PodDisruptionBudget on compact standalone clusters
+// from the number of schedulable control plane nodes, instead of its maxUnavailable. A replica that can't be
+// rescheduled while a control plane node is down or drained would otherwise block the drain of the next node: one
+// replica must stay available only while there are at least two schedulable control plane nodes for the replicas.
+func withCompactClusterPDB(isHypershift bool, infraLister configlisters.InfrastructureLister, nodeLister corev1listers.NodeLister, assetFunc resourceapply.AssetFunc) resourceapply.AssetFunc {
+	if isHypershift {
+		return assetFunc
+	}
+	return func(name string) ([]byte, error) {
+		content, err := assetFunc(name)
+		if err != nil || name != controllerPDBFile {
+			return content, err
+		}
+		compact, schedulable, err := compactClusterNodes(infraLister, nodeLister)
+		if err != nil {
+			return nil, err
+		}
+		if !compact {
+			return content, nil
+		}
+		jsonContent, err := yaml.ToJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", name, err)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(jsonContent); err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", name, err)
+		}
+		minAvailable := int64(1)
+		if schedulable < 2 {
+			minAvailable = 0
+		}
+		unstructured.RemoveNestedField(obj.Object, "spec", "maxUnavailable")
+		if err := unstructured.SetNestedField(obj.Object, minAvailable, "spec", "minAvailable"); err != nil {
+			return nil, err
+		}
+		return obj.MarshalJSON()
+	}
+}
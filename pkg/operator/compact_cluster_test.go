@@ -0,0 +1,151 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"sigs.k8s.io/yaml"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+)
+
+func newTestRoleNode(name, role string, ready, unschedulable bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{role: ""}},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+	}
+}
+
+func newTestNodeLister(nodes ...*corev1.Node) corev1listers.NodeLister {
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+	for _, node := range nodes {
+		nodeInformer.Informer().GetIndexer().Add(node)
+	}
+	return nodeInformer.Lister()
+}
+
+func TestCompactCluster(t *testing.T) {
+	tests := []struct {
+		name                 string
+		topology             configv1.TopologyMode
+		nodes                []*corev1.Node
+		expectedPreferred    bool
+		expectedMinAvailable *int
+	}{
+		{
+			name: "cluster with workers",
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-1", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-2", masterNodeRoleLabel, true, false),
+				newTestRoleNode("worker-0", "node-role.kubernetes.io/worker", true, false),
+			},
+		},
+		{
+			name: "three-node cluster",
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-1", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-2", controlPlaneNodeRoleLabel, true, false),
+			},
+			expectedPreferred:    true,
+			expectedMinAvailable: newInt(1),
+		},
+		{
+			name: "three-node cluster with a node down and a drained node",
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, false, false),
+				newTestRoleNode("master-1", masterNodeRoleLabel, true, true),
+				newTestRoleNode("master-2", masterNodeRoleLabel, true, false),
+			},
+			expectedPreferred:    true,
+			expectedMinAvailable: newInt(0),
+		},
+		{
+			name: "two-node cluster with an arbiter and a node down",
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, false, false),
+				newTestRoleNode("master-1", masterNodeRoleLabel, true, false),
+				newTestRoleNode("arbiter-0", arbiterNodeRoleLabel, true, false),
+			},
+			expectedPreferred:    true,
+			expectedMinAvailable: newInt(0),
+		},
+		{
+			name:     "arbiter cluster with workers",
+			topology: highlyAvailableArbiterTopologyMode,
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+				newTestRoleNode("master-1", masterNodeRoleLabel, true, false),
+				newTestRoleNode("arbiter-0", arbiterNodeRoleLabel, true, false),
+				newTestRoleNode("worker-0", "node-role.kubernetes.io/worker", true, false),
+			},
+			expectedPreferred:    true,
+			expectedMinAvailable: newInt(1),
+		},
+		{
+			name: "single node",
+			nodes: []*corev1.Node{
+				newTestRoleNode("master-0", masterNodeRoleLabel, true, false),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infraLister := newTestTopologyInfraLister(test.topology)
+			nodeLister := newTestNodeLister(test.nodes...)
+
+			term := corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "aws-ebs-csi-driver-controller"}},
+				TopologyKey:   "kubernetes.io/hostname",
+			}
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Affinity = &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term}},
+			}
+			if err := withCompactClusterHook(false, infraLister, nodeLister)(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			antiAffinity := deployment.Spec.Template.Spec.Affinity.PodAntiAffinity
+			preferred := len(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 1 && len(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) == 0
+			if preferred != test.expectedPreferred {
+				t.Errorf("expected preferred anti-affinity %v, got %+v", test.expectedPreferred, antiAffinity)
+			}
+
+			content, err := withCompactClusterPDB(false, infraLister, nodeLister, assetWithNamespaceFunc(defaultNamespace))(controllerPDBFile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			pdb := &policyv1.PodDisruptionBudget{}
+			if err := yaml.Unmarshal(content, pdb); err != nil {
+				t.Fatalf("invalid PodDisruptionBudget: %v", err)
+			}
+			if test.expectedMinAvailable == nil {
+				if pdb.Spec.MinAvailable != nil || pdb.Spec.MaxUnavailable == nil || pdb.Spec.MaxUnavailable.IntValue() != 1 {
+					t.Errorf("expected the PodDisruptionBudget of the asset, got %+v", pdb.Spec)
+				}
+				return
+			}
+			if pdb.Spec.MaxUnavailable != nil || pdb.Spec.MinAvailable == nil || pdb.Spec.MinAvailable.IntValue() != *test.expectedMinAvailable {
+				t.Errorf("expected minAvailable %d, got %+v", *test.expectedMinAvailable, pdb.Spec)
+			}
+		})
+	}
+}
+
+func newInt(i int) *int {
+	return &i
+}
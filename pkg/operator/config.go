@@ -0,0 +1,298 @@
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+)
+
+// driverConfig is the AWS EBS specific configuration of the operator.
+// The ClusterCSIDriver API does not have a section dedicated to the AWS EBS CSI driver,
+// therefore the configuration is read from ClusterCSIDriver.Spec.UnsupportedConfigOverrides.
+// Unknown fields are ignored.
+type driverConfig struct {
+	// ImageMirrors replaces the source repository prefix of all operand images with the mirror.
+	ImageMirrors []imageMirror `json:"imageMirrors,omitempty"`
+	// DefaultStorageClass selects the managed StorageClass that is the cluster default: "gp2", "gp3",
+	// an enabled optional StorageClass or "None". When empty, the default StorageClass annotation set by the user is preserved.
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty"`
+	// RetireGP2 stops applying the legacy gp2-csi StorageClass: "Retain" keeps the existing StorageClass,
+	// "Delete" deletes it.
+	RetireGP2 string `json:"retireGP2,omitempty"`
+	// OptionalStorageClasses lists the optional StorageClasses managed by the operator: "io2", "st1" and / or "sc1".
+	OptionalStorageClasses []string `json:"optionalStorageClasses,omitempty"`
+	// RemoveDuplicateDefault removes the default annotation from the managed StorageClasses when another
+	// StorageClass is default too. It's ignored when DefaultStorageClass is set.
+	RemoveDuplicateDefault bool `json:"removeDuplicateDefault,omitempty"`
+	// SharedConfig references a shared AWS config file in the control plane namespace that is used by
+	// the CSI driver controller, e.g. to assume roles through role chaining.
+	SharedConfig *sharedConfigSource `json:"sharedConfig,omitempty"`
+	// AssumeRole makes the CSI driver controller assume an IAM role, e.g. in another AWS account.
+	AssumeRole *assumeRoleConfig `json:"assumeRole,omitempty"`
+	// ProxyCustomEndpoints sends the calls to the custom service endpoints of the Infrastructure through the
+	// cluster proxy. By default they bypass it.
+	ProxyCustomEndpoints bool `json:"proxyCustomEndpoints,omitempty"`
+	// ServiceProxy routes the calls to an AWS service, "ec2", "kms" or "sts", through the cluster proxy ("Proxy")
+	// or directly ("Direct"). It takes precedence over ProxyCustomEndpoints.
+	ServiceProxy map[string]string `json:"serviceProxy,omitempty"`
+	// EC2FallbackEndpoints are used by the CSI driver controller in order when the ec2 service endpoint of the
+	// Infrastructure is unreachable, e.g. VPC interface endpoints in other availability zones.
+	EC2FallbackEndpoints []string `json:"ec2FallbackEndpoints,omitempty"`
+	// DualStackEndpoints of the AWS services for the CSI driver controller: "Auto", the default, uses them on
+	// IPv6 and dual-stack clusters, "Enabled" and "Disabled" force them on or off.
+	DualStackEndpoints string `json:"dualStackEndpoints,omitempty"`
+	// IsolatedRegion checks that the CSI driver controller has the CA bundle, the region and the service
+	// endpoints that the regions of the isolated partitions, e.g. C2S and SC2S, require.
+	IsolatedRegion *isolatedRegionConfig `json:"isolatedRegion,omitempty"`
+	// CredentialsSource of the CSI driver controller: "Secret", the default, reads the credentials from the
+	// ebs-cloud-credentials Secret, "PodIdentity" gets them from EKS Pod Identity on hosted control planes.
+	CredentialsSource string `json:"credentialsSource,omitempty"`
+	// Zones restricts provisioning of the managed StorageClasses to the listed availability zones.
+	Zones []string `json:"zones,omitempty"`
+	// AutoZones restricts provisioning of the managed StorageClasses to the availability zones that have nodes.
+	AutoZones bool `json:"autoZones,omitempty"`
+	// RecreateOnTopologyChange deletes and re-creates the managed StorageClasses whose allowedTopologies change,
+	// the field is immutable. Otherwise the existing StorageClasses are kept and reported.
+	RecreateOnTopologyChange bool `json:"recreateOnTopologyChange,omitempty"`
+	// ZoneStorageClasses lists the managed StorageClasses, e.g. "gp3", that are copied for each availability
+	// zone in Zones, or that has nodes when Zones is empty.
+	ZoneStorageClasses []string `json:"zoneStorageClasses,omitempty"`
+	// Outposts lists the ARNs of the Outposts that get a gp2 StorageClass restricted to their nodes.
+	Outposts []string `json:"outposts,omitempty"`
+	// ExtraArgs are appended to the arguments of the csi-driver container of the controller and node pods.
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+	// ExtraEnv are appended to the environment of the csi-driver container of the controller and node pods.
+	ExtraEnv []envVar `json:"extraEnv,omitempty"`
+	// PriorityClassName of the controller pods on standalone clusters. Defaults to system-cluster-critical.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// GP3 sets the default IOPS and throughput of the volumes of the managed gp3 StorageClasses.
+	GP3 *gp3Config `json:"gp3,omitempty"`
+	// ReclaimPolicy of the managed StorageClasses, "Delete" or "Retain". Defaults to "Delete".
+	ReclaimPolicy string `json:"reclaimPolicy,omitempty"`
+	// VolumeBindingMode of the managed StorageClasses, "Immediate" or "WaitForFirstConsumer".
+	// Defaults to "WaitForFirstConsumer".
+	VolumeBindingMode string `json:"volumeBindingMode,omitempty"`
+	// StorageClassParameters are merged into the parameters of the managed StorageClasses, keyed by
+	// StorageClass name, e.g. {"gp3-csi": {"throughput": "250"}}.
+	StorageClassParameters map[string]map[string]string `json:"storageClassParameters,omitempty"`
+	// KMSKeyARN is the ARN of the customer managed KMS key used to encrypt volumes of the managed StorageClasses.
+	KMSKeyARN string `json:"kmsKeyARN,omitempty"`
+	// EncryptByDefault makes encryption of the volumes of all managed StorageClasses mandatory.
+	EncryptByDefault bool `json:"encryptByDefault,omitempty"`
+	// VolumeAttachLimit is the maximum number of EBS volumes attached to a node.
+	VolumeAttachLimit *int32 `json:"volumeAttachLimit,omitempty"`
+	// Batching enables batching of the EC2 API calls of the CSI driver controller.
+	Batching bool `json:"batching,omitempty"`
+	// DisabledSidecars lists the optional sidecars of the controller that are not deployed: "resizer" and / or "snapshotter".
+	DisabledSidecars []string `json:"disabledSidecars,omitempty"`
+	// Sidecars tunes the timeouts and workers of the CSI sidecars of the controller.
+	Sidecars *sidecarsConfig `json:"sidecars,omitempty"`
+	// TokenMinter configures the token minter sidecar of the controller on Hypershift.
+	TokenMinter *tokenMinterConfig `json:"tokenMinter,omitempty"`
+	// KubeRBACProxies of the controller on Hypershift: "Keep", the default, serves the metrics of the controller
+	// through the kube-rbac-proxy sidecars, "Remove" removes the sidecars and the ServiceMonitor.
+	KubeRBACProxies string `json:"kubeRBACProxies,omitempty"`
+	// NodePlacement overrides the node selector and tolerations of the controller and node pods.
+	NodePlacement *nodePlacementConfig `json:"nodePlacement,omitempty"`
+	// Resources overrides the resource requests and limits of the controller and node containers.
+	Resources *resourcesConfig `json:"resources,omitempty"`
+	// VolumeSnapshotClass configures the csi-aws-vsc VolumeSnapshotClass.
+	VolumeSnapshotClass *volumeSnapshotClassConfig `json:"volumeSnapshotClass,omitempty"`
+	// AWSHealthCheck configures the periodic check of the AWS API reachability.
+	AWSHealthCheck *awsHealthCheckConfig `json:"awsHealthCheck,omitempty"`
+	// PermissionsCheck configures the periodic check of the EC2 permissions of the driver.
+	PermissionsCheck *permissionsCheckConfig `json:"permissionsCheck,omitempty"`
+	// KMSKeyCheck configures the periodic check of the KMS key in KMSKeyARN.
+	KMSKeyCheck *kmsKeyCheckConfig `json:"kmsKeyCheck,omitempty"`
+	// GP2Migration converts the gp2 volumes of the driver to gp3 through the volume modifier.
+	GP2Migration *gp2MigrationConfig `json:"gp2Migration,omitempty"`
+	// SnapshotLifecyclePolicy creates a DLM lifecycle policy that snapshots the volumes of the cluster.
+	SnapshotLifecyclePolicy *snapshotLifecyclePolicyConfig `json:"snapshotLifecyclePolicy,omitempty"`
+	// LeakedSnapshotCleanup reports or deletes the EBS snapshots of the cluster without a VolumeSnapshotContent.
+	LeakedSnapshotCleanup *leakedSnapshotCleanupConfig `json:"leakedSnapshotCleanup,omitempty"`
+}
+
+const defaultStorageClassNone = "None"
+
+type imageMirror struct {
+	Source string `json:"source"`
+	Mirror string `json:"mirror"`
+	// digestOnly limits the mirror to images referenced by digest, like the mirrors of ImageDigestMirrorSets.
+	digestOnly bool
+}
+
+type sharedConfigSource struct {
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+	// Key of the ConfigMap / Secret with the config file. Defaults to "config".
+	Key string `json:"key,omitempty"`
+}
+
+type assumeRoleConfig struct {
+	// RoleARN of the IAM role the driver assumes to manage the volumes.
+	RoleARN string `json:"roleARN"`
+	// ExternalID required by the trust policy of the role, if any.
+	ExternalID string `json:"externalID,omitempty"`
+	// SessionName of the role sessions, to identify the driver in CloudTrail.
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+type isolatedRegionConfig struct {
+	// Mode is "Auto", the default, which enables the checks in the regions of the isolated partitions,
+	// "Enabled" or "Disabled".
+	Mode string `json:"mode,omitempty"`
+	// DisableIMDS keeps the driver from calling the EC2 instance metadata service.
+	DisableIMDS bool `json:"disableIMDS,omitempty"`
+}
+
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type sidecarsConfig struct {
+	// KubeAPIQPS and KubeAPIBurst set the client-side rate limits of the Kubernetes API clients of all sidecars.
+	KubeAPIQPS   *float64       `json:"kubeAPIQPS,omitempty"`
+	KubeAPIBurst *int32         `json:"kubeAPIBurst,omitempty"`
+	Provisioner  *sidecarTuning `json:"provisioner,omitempty"`
+	Attacher     *sidecarTuning `json:"attacher,omitempty"`
+	Resizer      *sidecarTuning `json:"resizer,omitempty"`
+	Snapshotter  *sidecarTuning `json:"snapshotter,omitempty"`
+}
+
+type sidecarTuning struct {
+	// Timeout of the CSI calls, e.g. "60s".
+	Timeout string `json:"timeout,omitempty"`
+	// RetryIntervalMax is the maximum interval between retries of failed operations, e.g. "5m".
+	RetryIntervalMax string `json:"retryIntervalMax,omitempty"`
+	WorkerThreads    *int32 `json:"workerThreads,omitempty"`
+}
+
+type tokenMinterConfig struct {
+	// TokenLifetime is the lifetime of the web identity token of the driver, e.g. "1h".
+	TokenLifetime string `json:"tokenLifetime,omitempty"`
+	// Audience is the audience of the token, "openshift" by default. It must match the audience the IAM OIDC
+	// provider of the hosted cluster accepts.
+	Audience string `json:"audience,omitempty"`
+	// ServiceAccountName and ServiceAccountNamespace are the guest ServiceAccount the token is minted for,
+	// aws-ebs-csi-driver-controller-sa in openshift-cluster-csi-drivers by default.
+	ServiceAccountName      string `json:"serviceAccountName,omitempty"`
+	ServiceAccountNamespace string `json:"serviceAccountNamespace,omitempty"`
+	// TokenFile is the path of the token in the controller pods, in /var/run/secrets/openshift/serviceaccount
+	// where the driver reads it.
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+type nodePlacementConfig struct {
+	Controller *nodePlacement `json:"controller,omitempty"`
+	Node       *nodePlacement `json:"node,omitempty"`
+}
+
+// nodePlacement replaces the node selector and / or the tolerations of the operand pods. Fields that are
+// not set keep the values from the assets.
+type nodePlacement struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+type gp3Config struct {
+	IOPS *int32 `json:"iops,omitempty"`
+	// Throughput in MiB/s.
+	Throughput *int32 `json:"throughput,omitempty"`
+}
+
+// resourcesConfig maps container names of the controller and node pods to their resources. Only the
+// listed requests and limits are changed, the others keep the values from the assets.
+type resourcesConfig struct {
+	Controller map[string]corev1.ResourceRequirements `json:"controller,omitempty"`
+	Node       map[string]corev1.ResourceRequirements `json:"node,omitempty"`
+}
+
+type volumeSnapshotClassConfig struct {
+	// DeletionPolicy is "Delete" or "Retain". Defaults to "Delete".
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// Parameters of the VolumeSnapshotClass, e.g. {"tagSpecification_1": "backup=true"}.
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// FastSnapshotRestoreAvailabilityZones enables Fast Snapshot Restore of the snapshots in the listed zones.
+	FastSnapshotRestoreAvailabilityZones []string `json:"fastSnapshotRestoreAvailabilityZones,omitempty"`
+}
+
+type gp2MigrationConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxInProgress is the maximum number of volumes modified at the same time. Defaults to 5.
+	MaxInProgress *int32 `json:"maxInProgress,omitempty"`
+}
+
+type snapshotLifecyclePolicyConfig struct {
+	// ExecutionRoleARN is the IAM role DLM assumes to create and delete the snapshots.
+	ExecutionRoleARN string `json:"executionRoleARN"`
+	// IntervalHours between the snapshots, one of 1, 2, 3, 4, 6, 8, 12 or 24. Defaults to 24.
+	IntervalHours *int32 `json:"intervalHours,omitempty"`
+	// Time of the day of the first snapshot, "hh:mm" in UTC. Defaults to "00:00".
+	Time string `json:"time,omitempty"`
+	// RetainCount is the number of snapshots kept per volume, from 1 to 1000. Defaults to 7.
+	RetainCount *int32 `json:"retainCount,omitempty"`
+}
+
+type leakedSnapshotCleanupConfig struct {
+	// Action is "Report" or "Delete". Defaults to "Report".
+	Action string `json:"action,omitempty"`
+	// MinAge of the leaked snapshots, e.g. "72h". Defaults to 24 hours, the minimum is 1 hour.
+	MinAge string `json:"minAge,omitempty"`
+	// ExcludeTags are the keys of the tags of the snapshots that are never reported or deleted, e.g. a tag set by
+	// the tagSpecification parameters of the VolumeSnapshotClass of a backup tool.
+	ExcludeTags []string `json:"excludeTags,omitempty"`
+}
+
+type awsHealthCheckConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// Interval between the checks, e.g. "10m". Defaults to 5 minutes.
+	Interval string `json:"interval,omitempty"`
+}
+
+type permissionsCheckConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+type kmsKeyCheckConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// awsHealthCheckInterval returns the interval of the AWS health check and whether the check is enabled.
+func (cfg *driverConfig) awsHealthCheckInterval() (time.Duration, bool, error) {
+	check := cfg.AWSHealthCheck
+	if check == nil {
+		return defaultAWSHealthCheckInterval, true, nil
+	}
+	if check.Disabled {
+		return 0, false, nil
+	}
+	if check.Interval == "" {
+		return defaultAWSHealthCheckInterval, true, nil
+	}
+	interval, err := time.ParseDuration(check.Interval)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid awsHealthCheck interval %q: %w", check.Interval, err)
+	}
+	if interval < minAWSHealthCheckInterval {
+		return 0, false, fmt.Errorf("invalid awsHealthCheck interval %q: it must be at least %s", check.Interval, minAWSHealthCheckInterval)
+	}
+	return interval, true, nil
+}
+
+// getDriverConfig parses the driver configuration from the operator spec.
+// An empty configuration is returned when the spec carries no overrides.
+func getDriverConfig(spec *opv1.OperatorSpec) (*driverConfig, error) {
+	cfg := &driverConfig{}
+	if spec == nil || len(spec.UnsupportedConfigOverrides.Raw) == 0 {
+		return cfg, nil
+	}
+	if err := json.Unmarshal(spec.UnsupportedConfigOverrides.Raw, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse unsupportedConfigOverrides: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,152 @@
+package operator
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// Sources of the replicas of the controller Deployment, reported by configurationInfo.
+const (
+	replicasSourceNodes                = "Nodes"
+	replicasSourceHostedControlPlane   = "HostedControlPlane"
+	replicasSourceSingleNode           = "SingleNode"
+	replicasSourceExternalControlPlane = "ExternalControlPlane"
+	replicasSourceArbiter              = "Arbiter"
+)
+
+// configMetricsController reports the effective configuration of the driver in metrics, so that fleets can be
+// queried for it: customCABundleInUse, serviceEndpointOverridden, extraTags and configurationInfo. The configuration
+// is read from the controller Deployment as rendered by the deployment hooks.
+type configMetricsController struct {
+	isHypershift     bool
+	deploymentLister appslisters.DeploymentNamespaceLister
+	infraLister      configlisters.InfrastructureLister
+}
+
+func newConfigMetricsController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	isHypershift bool,
+	deploymentNamespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	infraInformer configinformersv1.InfrastructureInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &configMetricsController{
+		isHypershift:     isHypershift,
+		deploymentLister: deploymentInformer.Lister().Deployments(deploymentNamespace),
+		infraLister:      infraInformer.Lister(),
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+		infraInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *configMetricsController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// The metrics are reported once the Deployment controller creates it.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	replicasSource, err := controllerReplicasSource(c.isHypershift, c.infraLister)
+	if err != nil {
+		return err
+	}
+	setConfigMetrics(deployment, c.isHypershift, replicasSource)
+	return nil
+}
+
+// setConfigMetrics sets the metrics of the configuration of the csi-driver container of the Deployment.
+func setConfigMetrics(deployment *appsv1.Deployment, isHypershift bool, replicasSource string) {
+	container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+	if container == nil {
+		return
+	}
+
+	caBundle := 0.0
+	if _, ok := getContainerEnv(container, "AWS_CA_BUNDLE"); ok {
+		caBundle = 1
+	}
+	customCABundleInUse.Set(caBundle)
+
+	services := make([]string, 0, len(serviceEndpointEnvNames))
+	for service := range serviceEndpointEnvNames {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		overridden := 0.0
+		if endpoint, ok := getContainerEnv(container, serviceEndpointEnvNames[service]); ok && endpoint != "" {
+			overridden = 1
+		}
+		serviceEndpointOverridden.WithLabelValues(service).Set(overridden)
+	}
+
+	tags := 0
+	if value, ok := getContainerArg(container, "extra-tags"); ok && value != "" {
+		tags = len(strings.Split(value, ","))
+	}
+	extraTags.Set(float64(tags))
+
+	region, _ := getContainerEnv(container, "AWS_REGION")
+	// The labels change with the configuration, the series of the previous one must go.
+	configurationInfo.Reset()
+	configurationInfo.WithLabelValues(region, strconv.FormatBool(isHypershift), replicasSource).Set(1)
+}
+
+// controllerReplicasSource returns what sets the replicas of the controller Deployment. The hooks that set them run
+// in the order withHypershiftReplicasHook, withSingleNodeHook, withExternalControlPlaneHook and withArbiterHook, the
+// last one that applies to the cluster wins.
+func controllerReplicasSource(isHypershift bool, infraLister configlisters.InfrastructureLister) (string, error) {
+	if isHypershift {
+		return replicasSourceHostedControlPlane, nil
+	}
+	arbiter, err := isArbiterCluster(infraLister)
+	if err != nil {
+		return "", err
+	}
+	if arbiter {
+		return replicasSourceArbiter, nil
+	}
+	infra, err := externalControlPlaneInfra(infraLister)
+	if err != nil {
+		return "", err
+	}
+	if infra != nil {
+		return replicasSourceExternalControlPlane, nil
+	}
+	singleNode, err := isSingleNodeCluster(infraLister)
+	if err != nil {
+		return "", err
+	}
+	if singleNode {
+		return replicasSourceSingleNode, nil
+	}
+	return replicasSourceNodes, nil
+}
@@ -0,0 +1,123 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+func TestControllerReplicasSource(t *testing.T) {
+	tests := []struct {
+		name           string
+		isHypershift   bool
+		topology       configv1.TopologyMode
+		expectedSource string
+	}{
+		{
+			name:           "highly available",
+			topology:       configv1.HighlyAvailableTopologyMode,
+			expectedSource: replicasSourceNodes,
+		},
+		{
+			name:           "no Infrastructure",
+			expectedSource: replicasSourceNodes,
+		},
+		{
+			name:           "single node",
+			topology:       configv1.SingleReplicaTopologyMode,
+			expectedSource: replicasSourceSingleNode,
+		},
+		{
+			name:           "external control plane",
+			topology:       configv1.ExternalTopologyMode,
+			expectedSource: replicasSourceExternalControlPlane,
+		},
+		{
+			name:           "arbiter",
+			topology:       highlyAvailableArbiterTopologyMode,
+			expectedSource: replicasSourceArbiter,
+		},
+		{
+			name:           "hypershift",
+			isHypershift:   true,
+			topology:       configv1.SingleReplicaTopologyMode,
+			expectedSource: replicasSourceHostedControlPlane,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := controllerReplicasSource(test.isHypershift, newTestTopologyInfraLister(test.topology))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if source != test.expectedSource {
+				t.Errorf("expected source %s, got %s", test.expectedSource, source)
+			}
+		})
+	}
+}
+
+func TestSetConfigMetrics(t *testing.T) {
+	newDeployment := func(container corev1.Container) *appsv1.Deployment {
+		container.Name = driverContainerName
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
+		return deployment
+	}
+	gauge := func(metric metrics.GaugeMetric) float64 {
+		value, err := testutil.GetGaugeMetricValue(metric)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return value
+	}
+
+	setConfigMetrics(newDeployment(corev1.Container{
+		Args: []string{"--endpoint=$(CSI_ENDPOINT)", "--extra-tags=team=storage,env=prod,owner=me"},
+		Env: []corev1.EnvVar{
+			{Name: "AWS_REGION", Value: "us-east-1"},
+			{Name: "AWS_CA_BUNDLE", Value: "/etc/ca/ca-bundle.pem"},
+			{Name: "AWS_EC2_ENDPOINT", Value: "https://ec2.example.com"},
+		},
+	}), false, replicasSourceNodes)
+
+	if value := gauge(customCABundleInUse); value != 1 {
+		t.Errorf("expected the custom CA bundle in use, got %v", value)
+	}
+	for service, expected := range map[string]float64{"ec2": 1, "kms": 0, "sts": 0} {
+		if value := gauge(serviceEndpointOverridden.WithLabelValues(service)); value != expected {
+			t.Errorf("expected %v for the %s endpoint, got %v", expected, service, value)
+		}
+	}
+	if value := gauge(extraTags); value != 3 {
+		t.Errorf("expected 3 extra tags, got %v", value)
+	}
+	if value := gauge(configurationInfo.WithLabelValues("us-east-1", "false", replicasSourceNodes)); value != 1 {
+		t.Errorf("expected the configuration info, got %v", value)
+	}
+
+	setConfigMetrics(newDeployment(corev1.Container{
+		Env: []corev1.EnvVar{{Name: "AWS_REGION", Value: "eu-west-1"}},
+	}), true, replicasSourceHostedControlPlane)
+
+	if value := gauge(customCABundleInUse); value != 0 {
+		t.Errorf("expected no custom CA bundle, got %v", value)
+	}
+	if value := gauge(serviceEndpointOverridden.WithLabelValues("ec2")); value != 0 {
+		t.Errorf("expected the default ec2 endpoint, got %v", value)
+	}
+	if value := gauge(extraTags); value != 0 {
+		t.Errorf("expected no extra tags, got %v", value)
+	}
+	if value := gauge(configurationInfo.WithLabelValues("us-east-1", "false", replicasSourceNodes)); value != 0 {
+		t.Errorf("expected the series of the previous configuration to be removed, got %v", value)
+	}
+	if value := gauge(configurationInfo.WithLabelValues("eu-west-1", "true", replicasSourceHostedControlPlane)); value != 1 {
+		t.Errorf("expected the configuration info, got %v", value)
+	}
+}
@@ -0,0 +1,48 @@
+package operator
+
+import (
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+)
+
+// volumeSnapshotClassCRDName is the CRD that must exist before the VolumeSnapshotClass is created.
+const volumeSnapshotClassCRDName = "volumesnapshotclasses.snapshot.storage.k8s.io"
+
+// newCRDInformer returns an informer that watches a single CRD, so controllers can react to its
+// installation without polling the API server.
+func newCRDInformer(client apiextclient.Interface, name string, resync time.Duration) cache.SharedIndexInformer {
+	lw := cache.NewListWatchFromClient(
+		client.ApiextensionsV1().RESTClient(),
+		"customresourcedefinitions",
+		metav1.NamespaceAll,
+		fields.OneTermEqualSelector("metadata.name", name),
+	)
+	return cache.NewSharedIndexInformer(lw, &apiextensionsv1.CustomResourceDefinition{}, resync, cache.Indexers{})
+}
+
+// crdExists returns a function that reports whether the CRD is in the informer cache.
+func crdExists(informer cache.SharedIndexInformer, name string) resourceapply.ConditionalFunction {
+	return func() bool {
+		_, exists, err := informer.GetIndexer().GetByKey(name)
+		return err == nil && exists
+	}
+}
+
+// crdMissing returns a function that reports whether the CRD does not exist. It's false until the informer
+// is synced, so objects are not deleted just because the cache is still empty.
+func crdMissing(informer cache.SharedIndexInformer, name string) resourceapply.ConditionalFunction {
+	return func() bool {
+		if !informer.HasSynced() {
+			return false
+		}
+		_, exists, err := informer.GetIndexer().GetByKey(name)
+		return err == nil && !exists
+	}
+}
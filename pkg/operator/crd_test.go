@@ -0,0 +1,52 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCRDConditions(t *testing.T) {
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: volumeSnapshotClassCRDName},
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &apiextensionsv1.CustomResourceDefinitionList{}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+	informer := cache.NewSharedIndexInformer(lw, &apiextensionsv1.CustomResourceDefinition{}, 0, cache.Indexers{})
+	exists := crdExists(informer, volumeSnapshotClassCRDName)
+	missing := crdMissing(informer, volumeSnapshotClassCRDName)
+
+	// The informer is not synced yet, nothing is created nor deleted.
+	if exists() || missing() {
+		t.Errorf("expected neither existing nor missing CRD before sync, got %v and %v", exists(), missing())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatalf("informer did not sync")
+	}
+	if exists() || !missing() {
+		t.Errorf("expected missing CRD, got exists=%v missing=%v", exists(), missing())
+	}
+
+	if err := informer.GetIndexer().Add(crd); err != nil {
+		t.Fatalf("failed to add CRD: %v", err)
+	}
+	if !exists() || missing() {
+		t.Errorf("expected existing CRD, got exists=%v missing=%v", exists(), missing())
+	}
+}
@@ -0,0 +1,241 @@
+package operator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	credentialsSourceSecret = "secret"
+	credentialsSourceToken  = "token"
+
+	// sessionCredentialsExpiryWarning is how long before their expiration the temporary credentials in the
+	// credentials Secret are reported.
+	sessionCredentialsExpiryWarning = 24 * time.Hour
+	// The kubelet refreshes projected service account tokens after 80% of their lifetime, an older token
+	// was not refreshed.
+	tokenRefreshRatio = 0.8
+	// tokenExpiryWarning is how long before their expiration tokens without an iat claim are reported.
+	tokenExpiryWarning = 10 * time.Minute
+)
+
+// sessionExpirationKeys are the keys of a shared credentials profile that tools writing temporary credentials
+// use for their expiration.
+var sessionExpirationKeys = []string{"expiration", "x_security_token_expires", "aws_expiration"}
+
+// credentialsExpiryController warns about the credentials of the driver before they expire: the temporary
+// credentials with an expiration in the default profile of the credentials Secret, and the web identity token
+// when the operator mounts it at the path from the Secret, which is the case on STS clusters where the operator
+// shares the role of the driver. The expiration of each is exported in the credentials_expiration_timestamp_seconds
+// metric, and an event is emitted when credentials start to expire.
+//
+// It produces the following conditions:
+// <name>CredentialsExpiring: True when credentials are about to expire or expired, they're listed in the message.
+// <name>Degraded: produced when the sync() method returns an error.
+type credentialsExpiryController struct {
+	name           string
+	operatorClient v1helpers.OperatorClient
+	secretName     string
+	secretLister   corev1listers.SecretNamespaceLister
+	readFile       func(name string) ([]byte, error)
+	now            func() time.Time
+}
+
+func newCredentialsExpiryController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	secretName string,
+	secretInformer coreinformersv1.SecretInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &credentialsExpiryController{
+		name:           name,
+		operatorClient: operatorClient,
+		secretName:     secretName,
+		secretLister:   secretInformer.Lister().Secrets(namespace),
+		readFile:       os.ReadFile,
+		now:            time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		secretInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+// credentialsExpiration is the validity of credentials, and when they should be reported.
+type credentialsExpiration struct {
+	source    string
+	expiresAt time.Time
+	warnAt    time.Time
+}
+
+func (c *credentialsExpiryController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	var expirations []credentialsExpiration
+	secret, err := c.secretLister.Get(c.secretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		expiration, err := sessionCredentialsExpiration(awsclient.ParseSharedConfig(secret.Data["credentials"])["default"])
+		if err != nil {
+			return fmt.Errorf("credentials Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+		if expiration != nil {
+			expirations = append(expirations, *expiration)
+		}
+		if cfg, ok, _ := getWebIdentityConfig(secret); ok && cfg != nil {
+			// The validation of the web identity configuration is reported by the credentials Secret controller.
+			expiration, err := c.tokenExpiration(cfg.tokenFile)
+			if err != nil {
+				return err
+			}
+			if expiration != nil {
+				expirations = append(expirations, *expiration)
+			}
+		}
+	}
+
+	credentialsExpirationTimestamp.Reset()
+	now := c.now()
+	var expiring []string
+	for _, e := range expirations {
+		credentialsExpirationTimestamp.WithLabelValues(e.source).Set(float64(e.expiresAt.Unix()))
+		switch {
+		case !now.Before(e.expiresAt):
+			expiring = append(expiring, fmt.Sprintf("the %s credentials expired at %s", e.source, e.expiresAt.Format(time.RFC3339)))
+		case !now.Before(e.warnAt):
+			expiring = append(expiring, fmt.Sprintf("the %s credentials expire at %s", e.source, e.expiresAt.Format(time.RFC3339)))
+		}
+	}
+
+	cond := opv1.OperatorCondition{
+		Type:   c.name + "CredentialsExpiring",
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(expiring) > 0 {
+		sort.Strings(expiring)
+		cond.Status = opv1.ConditionTrue
+		cond.Reason = "CredentialsExpiring"
+		cond.Message = fmt.Sprintf("AWS credentials of the driver need to be renewed: %s", strings.Join(expiring, ", "))
+		if old := v1helpers.FindOperatorCondition(opStatus.Conditions, cond.Type); old == nil || old.Message != cond.Message {
+			klog.Warning(cond.Message)
+			syncCtx.Recorder().Warning("CredentialsExpiring", cond.Message)
+		}
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// tokenExpiration returns the expiration of the web identity token at the path, or nil when the operator
+// does not mount it.
+func (c *credentialsExpiryController) tokenExpiration(path string) (*credentialsExpiration, error) {
+	token, err := c.readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the web identity token: %w", err)
+	}
+	issuedAt, expiresAt, err := parseTokenValidity(strings.TrimSpace(string(token)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the web identity token %s: %w", path, err)
+	}
+	expiration := &credentialsExpiration{
+		source:    credentialsSourceToken,
+		expiresAt: expiresAt,
+		warnAt:    expiresAt.Add(-tokenExpiryWarning),
+	}
+	if !issuedAt.IsZero() {
+		// Leave a minute to the kubelet to write the refreshed token.
+		lifetime := expiresAt.Sub(issuedAt)
+		expiration.warnAt = issuedAt.Add(time.Duration(float64(lifetime) * tokenRefreshRatio)).Add(time.Minute)
+	}
+	return expiration, nil
+}
+
+// sessionCredentialsExpiration returns the expiration of the temporary credentials of the profile, or nil when
+// the profile has no expiration.
+func sessionCredentialsExpiration(profile awsclient.Profile) (*credentialsExpiration, error) {
+	for _, key := range sessionExpirationKeys {
+		value := profile[key]
+		if value == "" {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q in the default profile, expected an RFC 3339 time", key, value)
+		}
+		return &credentialsExpiration{
+			source:    credentialsSourceSecret,
+			expiresAt: expiresAt,
+			warnAt:    expiresAt.Add(-sessionCredentialsExpiryWarning),
+		}, nil
+	}
+	return nil, nil
+}
+
+// parseTokenValidity returns the iat and exp claims of a JWT, iat is zero when missing. The signature is not verified.
+func parseTokenValidity(token string) (time.Time, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, time.Time{}, fmt.Errorf("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	var claims struct {
+		IssuedAt  int64 `json:"iat"`
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	if claims.ExpiresAt == 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("the JWT has no exp claim")
+	}
+	var issuedAt time.Time
+	if claims.IssuedAt != 0 {
+		issuedAt = time.Unix(claims.IssuedAt, 0)
+	}
+	return issuedAt, time.Unix(claims.ExpiresAt, 0), nil
+}
@@ -0,0 +1,138 @@
+package operator
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestToken(issuedAt, expiresAt time.Time) []byte {
+	payload := fmt.Sprintf(`{"iss":"https://oidc.example.com","iat":%d,"exp":%d}`, issuedAt.Unix(), expiresAt.Unix())
+	return []byte("eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl")
+}
+
+func TestCredentialsExpiryController(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	webIdentity := "[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"
+
+	tests := []struct {
+		name              string
+		secret            *corev1.Secret
+		token             []byte
+		expectedCondition opv1.ConditionStatus
+		expectedMessage   string
+		expectedEvents    int
+		expectedError     bool
+	}{
+		{
+			name:              "no secret",
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "static credentials",
+			secret:            newTestCredentialsSecret("[default]\naws_access_key_id = id\naws_secret_access_key = secret\n"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "session credentials valid",
+			secret:            newTestCredentialsSecret("[default]\naws_access_key_id = id\naws_secret_access_key = secret\naws_session_token = token\nexpiration = 2023-05-12T12:00:00Z\n"),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "session credentials expiring",
+			secret:            newTestCredentialsSecret("[default]\naws_access_key_id = id\naws_secret_access_key = secret\naws_session_token = token\nx_security_token_expires = 2023-05-10T18:00:00Z\n"),
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage:   "AWS credentials of the driver need to be renewed: the secret credentials expire at 2023-05-10T18:00:00Z",
+			expectedEvents:    1,
+		},
+		{
+			name:          "invalid session expiration",
+			secret:        newTestCredentialsSecret("[default]\naws_session_token = token\nexpiration = tomorrow\n"),
+			expectedError: true,
+		},
+		{
+			name:              "web identity token not mounted",
+			secret:            newTestCredentialsSecret(webIdentity),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "web identity token refreshed",
+			secret:            newTestCredentialsSecret(webIdentity),
+			token:             newTestToken(now.Add(-10*time.Minute), now.Add(50*time.Minute)),
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "web identity token not refreshed",
+			secret:            newTestCredentialsSecret(webIdentity),
+			token:             newTestToken(now.Add(-70*time.Minute), now.Add(-10*time.Minute)),
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage:   "AWS credentials of the driver need to be renewed: the token credentials expired at 2023-05-10T11:50:00Z",
+			expectedEvents:    1,
+		},
+		{
+			name:          "malformed token",
+			secret:        newTestCredentialsSecret(webIdentity),
+			token:         []byte("token"),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if test.secret != nil {
+				informer.Informer().GetIndexer().Add(test.secret)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+			c := &credentialsExpiryController{
+				name:           "Test",
+				operatorClient: operatorClient,
+				secretName:     defaultSecretName,
+				secretLister:   informer.Lister().Secrets(defaultNamespace),
+				readFile: func(name string) ([]byte, error) {
+					if test.token == nil || name != boundSATokenMountPath+"/token" {
+						return nil, fs.ErrNotExist
+					}
+					return test.token, nil
+				},
+				now: func() time.Time { return now },
+			}
+
+			recorder := events.NewInMemoryRecorder("test")
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", recorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// A second sync with the same expiration does not emit another event.
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", recorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(recorder.Events()) != test.expectedEvents {
+				t.Errorf("expected %d events, got %d", test.expectedEvents, len(recorder.Events()))
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestCredentialsExpiring")
+			if cond == nil || cond.Status != test.expectedCondition || cond.Message != test.expectedMessage {
+				t.Errorf("expected CredentialsExpiring=%s with message %q, got %+v", test.expectedCondition, test.expectedMessage, cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	operatorinformersv1 "github.com/openshift/client-go/operator/informers/externalversions/operator/v1"
+	operatorlistersv1 "github.com/openshift/client-go/operator/listers/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	credentialsRequestFile     = "credentials_request.yaml"
+	cloudCredentialName        = "cluster"
+	credentialsProvisionedTrue = "True"
+)
+
+var credentialsRequestGVR = schema.GroupVersionResource{
+	Group:    resourceapply.CredentialsRequestGroup,
+	Version:  resourceapply.CredentialsRequestVersion,
+	Resource: resourceapply.CredentialsRequestResource,
+}
+
+// credentialsRequestFailureConditions are the conditions of a CredentialsRequest that cloud-credential-operator
+// sets when it can't provision the credentials, with the hint to fix them.
+var credentialsRequestFailureConditions = map[string]string{
+	"CredentialsProvisionFailure": "Check the logs of cloud-credential-operator",
+	"InsufficientCloudCreds":      "The root AWS credentials of the cluster can't mint or pass through the credentials of the driver, check their IAM permissions",
+	"MissingTargetNamespace":      "Check that the namespace of the credentials Secret exists",
+	"Ignored":                     "cloud-credential-operator ignores the CredentialsRequest, check the platform of the cluster",
+	"StaleCredentials":            "The credentials are stale, check the logs of cloud-credential-operator",
+}
+
+// credentialsRequestController reports whether cloud-credential-operator provisioned the ebs-cloud-credentials
+// Secret of the CredentialsRequest of the driver. The CredentialsRequest is part of the release payload and is
+// applied by the cluster-version-operator, the controller only reads it: two owners would revert each other's
+// changes. credentialsRequestFile is the reference of its name and of the IAM actions the driver needs. Nothing is
+// done when cloud-credential-operator is not installed or runs in the Manual mode, where the Secret is created by
+// the cluster admin. Failures reported in the CredentialsRequest conditions degrade the operator.
+//
+// It produces the following conditions:
+// <name>Available: False until the credentials Secret is provisioned.
+// <name>Progressing: True while cloud-credential-operator provisions the credentials.
+// <name>Degraded: produced when the sync() method returns an error.
+type credentialsRequestController struct {
+	name                  string
+	operatorClient        v1helpers.OperatorClient
+	dynamicClient         dynamic.Interface
+	manifest              []byte
+	cloudCredentialLister operatorlistersv1.CloudCredentialLister
+	secretLister          corev1listers.SecretNamespaceLister
+}
+
+func newCredentialsRequestController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	dynamicClient dynamic.Interface,
+	namespace string,
+	assetFunc resourceapply.AssetFunc,
+	cloudCredentialInformer operatorinformersv1.CloudCredentialInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	eventRecorder events.Recorder,
+) (factory.Controller, error) {
+	manifest, err := assetFunc(credentialsRequestFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &credentialsRequestController{
+		name:                  name,
+		operatorClient:        operatorClient,
+		dynamicClient:         dynamicClient,
+		manifest:              manifest,
+		cloudCredentialLister: cloudCredentialInformer.Lister(),
+		secretLister:          secretInformer.Lister().Secrets(namespace),
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		cloudCredentialInformer.Informer(),
+		secretInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	), nil
+}
+
+func (c *credentialsRequestController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	cloudCredential, err := c.cloudCredentialLister.Get(cloudCredentialName)
+	if apierrors.IsNotFound(err) {
+		// cloud-credential-operator is not installed, the credentials are provided by the cluster admin.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cloudCredential.Spec.CredentialsMode == opv1.CloudCredentialsModeManual {
+		return nil
+	}
+
+	reference := resourceread.ReadCredentialRequestsOrDie(c.manifest)
+	cr, err := c.dynamicClient.Resource(credentialsRequestGVR).Namespace(reference.GetNamespace()).Get(ctx, reference.GetName(), metav1.GetOptions{})
+	crExists := true
+	if apierrors.IsNotFound(err) {
+		crExists = false
+	} else if err != nil {
+		return fmt.Errorf("failed to get CredentialsRequest %s/%s: %w", reference.GetNamespace(), reference.GetName(), err)
+	}
+
+	available := opv1.OperatorCondition{
+		Type:   c.name + opv1.OperatorStatusTypeAvailable,
+		Status: opv1.ConditionTrue,
+		Reason: "AsExpected",
+	}
+	progressing := opv1.OperatorCondition{
+		Type:   c.name + opv1.OperatorStatusTypeProgressing,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if !crExists {
+		available.Status = opv1.ConditionFalse
+		available.Reason = "CredentialsRequestNotFound"
+		available.Message = fmt.Sprintf("CredentialsRequest %s/%s of the release payload does not exist", reference.GetNamespace(), reference.GetName())
+		progressing.Status = opv1.ConditionTrue
+		progressing.Reason = "CredentialsRequestNotFound"
+		progressing.Message = fmt.Sprintf("Waiting for the cluster-version-operator to create CredentialsRequest %s/%s", reference.GetNamespace(), reference.GetName())
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(available), v1helpers.UpdateConditionFn(progressing))
+		return err
+	}
+
+	secretName, _, _ := unstructured.NestedString(cr.Object, "spec", "secretRef", "name")
+	secretExists := true
+	if _, err := c.secretLister.Get(secretName); apierrors.IsNotFound(err) {
+		secretExists = false
+	} else if err != nil {
+		return err
+	}
+	provisioned, checkErr := checkCredentialsRequest(cr, secretExists)
+	if !provisioned {
+		available.Status = opv1.ConditionFalse
+		available.Reason = "CredentialsNotProvisioned"
+		available.Message = fmt.Sprintf("Secret %s was not provisioned by cloud-credential-operator yet", secretName)
+		progressing.Status = opv1.ConditionTrue
+		progressing.Reason = "CredentialsNotProvisioned"
+		progressing.Message = fmt.Sprintf("Waiting for cloud-credential-operator to provision Secret %s", secretName)
+	}
+	if _, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(available), v1helpers.UpdateConditionFn(progressing)); err != nil {
+		return err
+	}
+	return checkErr
+}
+
+// checkCredentialsRequest returns whether the credentials of the CredentialsRequest were provisioned,
+// and an error with the failures reported by cloud-credential-operator.
+func checkCredentialsRequest(cr *unstructured.Unstructured, secretExists bool) (bool, error) {
+	conditions, _, _ := unstructured.NestedSlice(cr.Object, "status", "conditions")
+	var failures []string
+	for _, item := range conditions {
+		cond, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condType, _, _ := unstructured.NestedString(cond, "type")
+		status, _, _ := unstructured.NestedString(cond, "status")
+		hint, known := credentialsRequestFailureConditions[condType]
+		if !known || status != credentialsProvisionedTrue {
+			continue
+		}
+		message, _, _ := unstructured.NestedString(cond, "message")
+		failures = append(failures, fmt.Sprintf("%s: %s. %s", condType, message, hint))
+	}
+	// A provisioned CredentialsRequest without the Secret means the Secret was deleted, cloud-credential-operator
+	// re-creates it on its next sync.
+	provisioned, _, _ := unstructured.NestedBool(cr.Object, "status", "provisioned")
+	provisioned = provisioned && secretExists
+
+	if len(failures) > 0 {
+		return provisioned, fmt.Errorf("cloud-credential-operator failed to provision CredentialsRequest %s/%s: %s",
+			cr.GetNamespace(), cr.GetName(), strings.Join(failures, "; "))
+	}
+	return provisioned, nil
+}
@@ -0,0 +1,120 @@
+package operator
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+)
+
+func TestCredentialsRequestManifest(t *testing.T) {
+	manifest, err := assetWithNamespaceFunc(defaultNamespace)(credentialsRequestFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cr := resourceread.ReadCredentialRequestsOrDie(manifest)
+	ns, _, _ := unstructured.NestedString(cr.Object, "spec", "secretRef", "namespace")
+	name, _, _ := unstructured.NestedString(cr.Object, "spec", "secretRef", "name")
+	if ns != defaultNamespace || name != defaultSecretName {
+		t.Errorf("expected secretRef %s/%s, got %s/%s", defaultNamespace, defaultSecretName, ns, name)
+	}
+
+	// The snapshot lifecycle policy controller needs the DLM actions and to pass the DLM service role.
+	entries, _, _ := unstructured.NestedSlice(cr.Object, "spec", "providerSpec", "statementEntries")
+	actions := map[string]string{}
+	for _, item := range entries {
+		entry, _ := item.(map[string]interface{})
+		resource, _, _ := unstructured.NestedString(entry, "resource")
+		list, _, _ := unstructured.NestedStringSlice(entry, "action")
+		for _, action := range list {
+			actions[action] = resource
+		}
+	}
+	for _, action := range []string{"dlm:GetLifecyclePolicies", "dlm:GetLifecyclePolicy", "dlm:CreateLifecyclePolicy", "dlm:UpdateLifecyclePolicy", "dlm:DeleteLifecyclePolicy", "dlm:TagResource"} {
+		if _, ok := actions[action]; !ok {
+			t.Errorf("expected action %s", action)
+		}
+	}
+	if resource := actions["iam:PassRole"]; !strings.HasSuffix(resource, ":role/AWSDataLifecycleManagerDefaultRole") {
+		t.Errorf("expected iam:PassRole limited to the DLM service role, got %q", resource)
+	}
+}
+
+func TestCheckCredentialsRequest(t *testing.T) {
+	newCredentialsRequest := func(provisioned bool, conditions ...map[string]interface{}) *unstructured.Unstructured {
+		cr := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		cr.SetNamespace("openshift-cloud-credential-operator")
+		cr.SetName("openshift-aws-ebs-csi-driver")
+		unstructured.SetNestedField(cr.Object, provisioned, "status", "provisioned")
+		if len(conditions) > 0 {
+			items := make([]interface{}, 0, len(conditions))
+			for _, cond := range conditions {
+				items = append(items, runtime.DeepCopyJSONValue(cond))
+			}
+			unstructured.SetNestedSlice(cr.Object, items, "status", "conditions")
+		}
+		return cr
+	}
+
+	tests := []struct {
+		name                string
+		cr                  *unstructured.Unstructured
+		secretExists        bool
+		expectedProvisioned bool
+		expectedError       string
+	}{
+		{
+			name:                "provisioned",
+			cr:                  newCredentialsRequest(true),
+			secretExists:        true,
+			expectedProvisioned: true,
+		},
+		{
+			name: "not provisioned yet",
+			cr:   newCredentialsRequest(false),
+		},
+		{
+			name: "secret deleted",
+			cr:   newCredentialsRequest(true),
+		},
+		{
+			name: "insufficient root credentials",
+			cr: newCredentialsRequest(false, map[string]interface{}{
+				"type":    "InsufficientCloudCreds",
+				"status":  "True",
+				"message": "cloud creds are insufficient to satisfy CredentialsRequest",
+			}),
+			expectedError: "InsufficientCloudCreds: cloud creds are insufficient to satisfy CredentialsRequest. The root AWS credentials",
+		},
+		{
+			name: "resolved failure",
+			cr: newCredentialsRequest(true, map[string]interface{}{
+				"type":   "CredentialsProvisionFailure",
+				"status": "False",
+			}),
+			secretExists:        true,
+			expectedProvisioned: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provisioned, err := checkCredentialsRequest(test.cr, test.secretExists)
+			if provisioned != test.expectedProvisioned {
+				t.Errorf("expected provisioned=%v, got %v", test.expectedProvisioned, provisioned)
+			}
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+			}
+		})
+	}
+}
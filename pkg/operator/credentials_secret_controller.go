@@ -0,0 +1,200 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+// credentialsSecretNameEnvName overrides the name of the credentials Secret on Hypershift, where the
+// control-plane-operator creates it with a name of its own. On standalone clusters the name is the one of the
+// CredentialsRequest.
+const credentialsSecretNameEnvName = "CREDENTIALS_SECRET_NAME"
+
+// hypershiftCredentialsSecretName returns the name of the credentials Secret on Hypershift.
+func hypershiftCredentialsSecretName() (string, error) {
+	name := os.Getenv(credentialsSecretNameEnvName)
+	if name == "" {
+		return defaultSecretName, nil
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid %s %q: %s", credentialsSecretNameEnvName, name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// withCredentialsSecretNameHook makes the controller pods use the credentials Secret with the given name instead
+// of the ebs-cloud-credentials Secret of the assets, in the credentials volume and the environment variables.
+func withCredentialsSecretNameHook(secretName string) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if secretName == defaultSecretName {
+			return nil
+		}
+		podSpec := &deployment.Spec.Template.Spec
+		for i := range podSpec.Volumes {
+			if secret := podSpec.Volumes[i].Secret; secret != nil && secret.SecretName == defaultSecretName {
+				secret.SecretName = secretName
+			}
+		}
+		for i := range podSpec.Containers {
+			for _, env := range podSpec.Containers[i].Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == defaultSecretName {
+					env.ValueFrom.SecretKeyRef.Name = secretName
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// credentialsSecretController validates the credentials Secret before the driver uses it, so a missing
+// or malformed Secret is reported with what to fix instead of a crash looping driver. The Secret must carry
+// either the aws_access_key_id and aws_secret_access_key keys, or a shared credentials file in the credentials key
+// whose default profile has static credentials, a web identity or a role to assume. Nothing is validated when
+// the driver uses EKS Pod Identity.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error.
+type credentialsSecretController struct {
+	operatorClient v1helpers.OperatorClient
+	namespace      string
+	secretName     string
+	secretLister   corev1listers.SecretNamespaceLister
+}
+
+func newCredentialsSecretController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	secretName string,
+	secretInformer coreinformersv1.SecretInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &credentialsSecretController{
+		operatorClient: operatorClient,
+		namespace:      namespace,
+		secretName:     secretName,
+		secretLister:   secretInformer.Lister().Secrets(namespace),
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		secretInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *credentialsSecretController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	podIdentity, err := usesPodIdentity(opSpec)
+	if err != nil || podIdentity {
+		// The driver does not use the Secret.
+		credentialsSecretMissing.Set(0)
+		return err
+	}
+	secret, err := c.secretLister.Get(c.secretName)
+	if apierrors.IsNotFound(err) {
+		credentialsSecretMissing.Set(1)
+		return fmt.Errorf("credentials Secret %s/%s is missing. It is provisioned by cloud-credential-operator from CredentialsRequest openshift-aws-ebs-csi-driver, "+
+			"or created by the cluster admin when cloud-credential-operator runs in the Manual mode", c.namespace, c.secretName)
+	}
+	if err != nil {
+		return err
+	}
+	credentialsSecretMissing.Set(0)
+	return validateCredentialsSecret(secret)
+}
+
+// validateCredentialsSecret returns an error that tells what is wrong with the credentials Secret,
+// or nil when the driver can use it.
+func validateCredentialsSecret(secret *corev1.Secret) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("credentials Secret %s/%s is invalid: %s", secret.Namespace, secret.Name, fmt.Sprintf(format, args...))
+	}
+
+	_, hasAccessKeyID := secret.Data["aws_access_key_id"]
+	_, hasSecretAccessKey := secret.Data["aws_secret_access_key"]
+	credentials, hasCredentials := secret.Data["credentials"]
+	if !hasAccessKeyID && !hasSecretAccessKey && !hasCredentials {
+		return invalid("it has none of the aws_access_key_id, aws_secret_access_key and credentials keys")
+	}
+	if hasAccessKeyID != hasSecretAccessKey {
+		return invalid("aws_access_key_id and aws_secret_access_key must be set together")
+	}
+	for _, key := range []string{"aws_access_key_id", "aws_secret_access_key"} {
+		value, ok := secret.Data[key]
+		if ok && len(value) == 0 {
+			return invalid("%s key is empty", key)
+		}
+		if strings.ContainsAny(string(value), " \t\r\n") {
+			return invalid("%s key contains whitespace, check that the value is not encoded twice or has a trailing new line", key)
+		}
+	}
+	if !hasCredentials {
+		return nil
+	}
+
+	if len(strings.TrimSpace(string(credentials))) == 0 {
+		if hasAccessKeyID {
+			return nil
+		}
+		return invalid("credentials key is empty")
+	}
+	profile, ok := awsclient.ParseSharedConfig(credentials)["default"]
+	if !ok {
+		if hasAccessKeyID {
+			return nil
+		}
+		return invalid("credentials key has no [default] profile")
+	}
+	// The web identity configuration is validated by getWebIdentityConfig, which configures the driver with it.
+	if _, ok, err := getWebIdentityConfig(secret); ok || err != nil {
+		return err
+	}
+	if roleARN := profile["role_arn"]; roleARN != "" {
+		if !roleARNRegexp.MatchString(roleARN) {
+			return invalid("STS role ARN %q in the default profile is malformed, expected arn:aws:iam::<account ID>:role/<name>", roleARN)
+		}
+		if profile["source_profile"] == "" && profile["credential_source"] == "" {
+			return invalid("default profile sets role_arn without web_identity_token_file, source_profile or credential_source")
+		}
+		return nil
+	}
+	if _, ok := profile.StaticCredentials(); ok || hasAccessKeyID {
+		return nil
+	}
+	if profile["aws_access_key_id"] != "" || profile["aws_secret_access_key"] != "" {
+		return invalid("default profile must set both aws_access_key_id and aws_secret_access_key")
+	}
+	return invalid("default profile has neither static credentials nor a role_arn")
+}
@@ -0,0 +1,214 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/component-base/metrics/testutil"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestCredentialsSecretController(t *testing.T) {
+	newSecret := func(data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: defaultNamespace},
+			Data:       map[string][]byte{},
+		}
+		for key, value := range data {
+			secret.Data[key] = []byte(value)
+		}
+		return secret
+	}
+
+	tests := []struct {
+		name          string
+		overrides     string
+		secret        *corev1.Secret
+		expectedError string
+	}{
+		{
+			name:          "secret missing",
+			expectedError: "is missing",
+		},
+		{
+			name:      "pod identity",
+			overrides: `{"credentialsSource": "PodIdentity"}`,
+		},
+		{
+			name:   "static keys",
+			secret: newSecret(map[string]string{"aws_access_key_id": "AKIAEXAMPLE", "aws_secret_access_key": "secret"}),
+		},
+		{
+			name: "static keys and credentials file",
+			secret: newSecret(map[string]string{
+				"aws_access_key_id":     "AKIAEXAMPLE",
+				"aws_secret_access_key": "secret",
+				"credentials":           "[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n",
+			}),
+		},
+		{
+			name:   "web identity",
+			secret: newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"),
+		},
+		{
+			name:   "role chaining",
+			secret: newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nsource_profile = base\n[base]\naws_access_key_id = id\naws_secret_access_key = secret\n"),
+		},
+		{
+			name:          "no keys",
+			secret:        newSecret(nil),
+			expectedError: "has none of the",
+		},
+		{
+			name:          "secret access key missing",
+			secret:        newSecret(map[string]string{"aws_access_key_id": "AKIAEXAMPLE"}),
+			expectedError: "must be set together",
+		},
+		{
+			name:          "trailing new line",
+			secret:        newSecret(map[string]string{"aws_access_key_id": "AKIAEXAMPLE\n", "aws_secret_access_key": "secret"}),
+			expectedError: "aws_access_key_id key contains whitespace",
+		},
+		{
+			name:          "credentials key empty",
+			secret:        newTestCredentialsSecret(""),
+			expectedError: "credentials key is empty",
+		},
+		{
+			name:          "no default profile",
+			secret:        newTestCredentialsSecret("[other]\naws_access_key_id = id\n"),
+			expectedError: "no [default] profile",
+		},
+		{
+			name:          "STS role ARN malformed",
+			secret:        newTestCredentialsSecret("[default]\nrole_arn = ebs-csi\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"),
+			expectedError: "malformed role_arn",
+		},
+		{
+			name:          "role without source",
+			secret:        newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\n"),
+			expectedError: "without web_identity_token_file, source_profile or credential_source",
+		},
+		{
+			name:          "half static credentials",
+			secret:        newTestCredentialsSecret("[default]\naws_access_key_id = id\n"),
+			expectedError: "must set both",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if test.secret != nil {
+				informer.Informer().GetIndexer().Add(test.secret)
+			}
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c := &credentialsSecretController{
+				operatorClient: v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil),
+				namespace:      defaultNamespace,
+				secretName:     defaultSecretName,
+				secretLister:   informer.Lister().Secrets(defaultNamespace),
+			}
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			expectedMissing := 0.0
+			if test.secret == nil && test.overrides == "" {
+				expectedMissing = 1
+			}
+			if missing, _ := testutil.GetGaugeMetricValue(credentialsSecretMissing); missing != expectedMissing {
+				t.Errorf("expected credentials_secret_missing %v, got %v", expectedMissing, missing)
+			}
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestWithCredentialsSecretNameHook(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           string
+		expectedName  string
+		expectedError bool
+	}{
+		{
+			name:         "default name",
+			expectedName: defaultSecretName,
+		},
+		{
+			name:         "name from the environment",
+			env:          "aws-ebs-csi-driver-creds",
+			expectedName: "aws-ebs-csi-driver-creds",
+		},
+		{
+			name:          "invalid name",
+			env:           "EBS_Credentials",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(credentialsSecretNameEnvName, test.env)
+			name, err := hypershiftCredentialsSecretName()
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			asset, err := assets.ReadFile("controller.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := resourceread.ReadDeploymentV1OrDie(asset)
+			if err := withCredentialsSecretNameHook(name)(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			podSpec := &deployment.Spec.Template.Spec
+			for _, volume := range podSpec.Volumes {
+				if volume.Name == credentialsVolumeName && volume.Secret.SecretName != test.expectedName {
+					t.Errorf("expected the %s volume from Secret %s, got %s", credentialsVolumeName, test.expectedName, volume.Secret.SecretName)
+				}
+			}
+			refs := 0
+			for _, env := range getContainer(podSpec, driverContainerName).Env {
+				if env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil {
+					continue
+				}
+				refs++
+				if env.ValueFrom.SecretKeyRef.Name != test.expectedName {
+					t.Errorf("expected %s from Secret %s, got %s", env.Name, test.expectedName, env.ValueFrom.SecretKeyRef.Name)
+				}
+			}
+			if refs == 0 {
+				t.Errorf("expected the csi-driver container to reference the credentials Secret")
+			}
+		})
+	}
+}
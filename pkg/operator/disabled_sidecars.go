@@ -0,0 +1,86 @@
+package operator
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// optionalSidecars maps the sidecars that can be disabled to the containers of the controller
+// Deployment and the RBAC assets they need.
+var optionalSidecars = map[string]struct {
+	containers []string
+	rbacFiles  []string
+}{
+	"resizer": {
+		containers: []string{"csi-resizer", "resizer-kube-rbac-proxy"},
+		rbacFiles:  []string{"rbac/resizer_role.yaml", "rbac/resizer_binding.yaml"},
+	},
+	"snapshotter": {
+		containers: []string{"csi-snapshotter", "snapshotter-kube-rbac-proxy"},
+		rbacFiles:  []string{"rbac/snapshotter_role.yaml", "rbac/snapshotter_binding.yaml"},
+	},
+}
+
+// withDisabledSidecarsHook removes the disabled sidecars and their kube-rbac-proxies from the controller Deployment.
+func withDisabledSidecarsHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if len(cfg.DisabledSidecars) == 0 {
+			return nil
+		}
+		removed := map[string]bool{}
+		for _, sidecar := range cfg.DisabledSidecars {
+			optional, ok := optionalSidecars[sidecar]
+			if !ok {
+				return fmt.Errorf("invalid disabledSidecars %q: only resizer and snapshotter can be disabled", sidecar)
+			}
+			for _, container := range optional.containers {
+				removed[container] = true
+			}
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		filtered := []corev1.Container{}
+		for i := range podSpec.Containers {
+			if !removed[podSpec.Containers[i].Name] {
+				filtered = append(filtered, podSpec.Containers[i])
+			}
+		}
+		podSpec.Containers = filtered
+		return nil
+	}
+}
+
+// sidecarEnabled returns a function that reports whether the optional sidecar is enabled in the driver
+// configuration. It's used to create or delete the RBAC assets of the sidecar.
+func sidecarEnabled(operatorClient v1helpers.OperatorClient, sidecar string) resourceapply.ConditionalFunction {
+	return func() bool {
+		spec, _, _, err := operatorClient.GetOperatorState()
+		if err != nil {
+			klog.Warningf("Failed to get the operator spec, assuming %s is enabled: %v", sidecar, err)
+			return true
+		}
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			// The error is reported by the controller Deployment hook, keep the RBAC untouched.
+			return true
+		}
+		for _, disabled := range cfg.DisabledSidecars {
+			if disabled == sidecar {
+				return false
+			}
+		}
+		return true
+	}
+}
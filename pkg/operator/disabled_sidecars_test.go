@@ -0,0 +1,102 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWithDisabledSidecarsHook(t *testing.T) {
+	allContainers := []string{
+		"csi-driver",
+		"csi-provisioner",
+		"csi-resizer",
+		"resizer-kube-rbac-proxy",
+		"csi-snapshotter",
+		"snapshotter-kube-rbac-proxy",
+	}
+
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:     "all sidecars",
+			expected: allContainers,
+		},
+		{
+			name:      "snapshotter disabled",
+			overrides: `{"disabledSidecars": ["snapshotter"]}`,
+			expected:  []string{"csi-driver", "csi-provisioner", "csi-resizer", "resizer-kube-rbac-proxy"},
+		},
+		{
+			name:      "resizer and snapshotter disabled",
+			overrides: `{"disabledSidecars": ["resizer", "snapshotter"]}`,
+			expected:  []string{"csi-driver", "csi-provisioner"},
+		},
+		{
+			name:          "mandatory sidecar",
+			overrides:     `{"disabledSidecars": ["provisioner"]}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := &appsv1.Deployment{}
+			for _, name := range allContainers {
+				deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: name})
+			}
+			err := withDisabledSidecarsHook()(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				names = append(names, container.Name)
+			}
+			if len(names) != len(test.expected) {
+				t.Fatalf("expected containers %v, got %v", test.expected, names)
+			}
+			for i := range names {
+				if names[i] != test.expected[i] {
+					t.Errorf("expected containers %v, got %v", test.expected, names)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSidecarEnabled(t *testing.T) {
+	spec := &opv1.OperatorSpec{
+		ManagementState: opv1.Managed,
+		UnsupportedConfigOverrides: runtime.RawExtension{
+			Raw: []byte(`{"disabledSidecars": ["snapshotter"]}`),
+		},
+	}
+	operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+
+	if !sidecarEnabled(operatorClient, "resizer")() {
+		t.Errorf("expected resizer to be enabled")
+	}
+	if sidecarEnabled(operatorClient, "snapshotter")() {
+		t.Errorf("expected snapshotter to be disabled")
+	}
+}
@@ -0,0 +1,54 @@
+package operator
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// withVolumeAttachLimitHook sets the maximum number of EBS volumes attached to a node, so that slots
+// are left for other devices (e.g. ENIs or volumes of other CSI drivers).
+func withVolumeAttachLimitHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.VolumeAttachLimit == nil {
+			return nil
+		}
+		if *cfg.VolumeAttachLimit < 1 {
+			return fmt.Errorf("invalid volumeAttachLimit %d: it must be a positive number", *cfg.VolumeAttachLimit)
+		}
+		container := getContainer(&daemonSet.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not set the volume attach limit because the csi-driver container is missing")
+		}
+		container.Args = append(container.Args, fmt.Sprintf("--volume-attach-limit=%d", *cfg.VolumeAttachLimit))
+		return nil
+	}
+}
+
+// withBatchingHook enables batching of the EC2 API calls of the CSI driver controller, which reduces
+// DescribeVolumes throttling on large clusters.
+func withBatchingHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if !cfg.Batching {
+			return nil
+		}
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not enable batching because the csi-driver container is missing")
+		}
+		container.Args = append(container.Args, "--batching=true")
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestWithVolumeAttachLimitHook(t *testing.T) {
+	newDaemonSet := func(args ...string) *appsv1.DaemonSet {
+		ds := &appsv1.DaemonSet{}
+		ds.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "csi-driver",
+			Args: append([]string{"node", "--v=2"}, args...),
+		}}
+		return ds
+	}
+
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      *appsv1.DaemonSet
+		expectedError bool
+	}{
+		{
+			name:     "no limit",
+			expected: newDaemonSet(),
+		},
+		{
+			name:      "limit",
+			overrides: `{"volumeAttachLimit": 20}`,
+			expected:  newDaemonSet("--volume-attach-limit=20"),
+		},
+		{
+			name:          "zero limit",
+			overrides:     `{"volumeAttachLimit": 0}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			ds := newDaemonSet()
+			err := withVolumeAttachLimitHook()(spec, ds)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, ds; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected daemonset\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
+
+func TestWithBatchingHook(t *testing.T) {
+	newDeployment := func(args ...string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "csi-driver",
+			Args: append([]string{"controller", "--v=2"}, args...),
+		}}
+		return deployment
+	}
+
+	tests := []struct {
+		name      string
+		overrides string
+		expected  *appsv1.Deployment
+	}{
+		{
+			name:     "default",
+			expected: newDeployment(),
+		},
+		{
+			name:      "disabled",
+			overrides: `{"batching": false}`,
+			expected:  newDeployment(),
+		},
+		{
+			name:      "enabled",
+			overrides: `{"batching": true}`,
+			expected:  newDeployment("--batching=true"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := newDeployment()
+			if err := withBatchingHook()(spec, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, deployment; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package operator
+
+import (
+	"fmt"
+	"net"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+const (
+	// Values of dualStackEndpoints in the driver configuration.
+	dualStackEndpointsAuto     = "Auto"
+	dualStackEndpointsEnabled  = "Enabled"
+	dualStackEndpointsDisabled = "Disabled"
+
+	networkConfigName = "cluster"
+)
+
+// withDualStackEndpointsHook makes the AWS SDK of the csi-driver container of the controller use the dual-stack
+// endpoints of the AWS services, which are reachable over IPv6, with AWS_USE_DUALSTACK_ENDPOINT. By default
+// ("Auto") they're used when the cluster network or the service network of the cluster has an IPv6 CIDR, i.e. on
+// IPv6 and dual-stack clusters. dualStackEndpoints in the driver configuration forces them on or off. Custom
+// service endpoints of the Infrastructure take precedence over the dual-stack endpoints in the SDK.
+func withDualStackEndpointsHook(networkLister configlisters.NetworkLister) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		var enabled bool
+		switch cfg.DualStackEndpoints {
+		case "", dualStackEndpointsAuto:
+			network, err := networkLister.Get(networkConfigName)
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			enabled = hasIPv6Network(network)
+		case dualStackEndpointsEnabled:
+			enabled = true
+		case dualStackEndpointsDisabled:
+			enabled = false
+		default:
+			return fmt.Errorf("invalid dualStackEndpoints %q, expected %s, %s or %s",
+				cfg.DualStackEndpoints, dualStackEndpointsAuto, dualStackEndpointsEnabled, dualStackEndpointsDisabled)
+		}
+		if !enabled {
+			return nil
+		}
+
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not use the dual-stack endpoints because the csi-driver container is missing from the deployment")
+		}
+		setContainerEnv(container, "AWS_USE_DUALSTACK_ENDPOINT", "true")
+		return nil
+	}
+}
+
+// hasIPv6Network returns true when the cluster network or the service network of the cluster has an IPv6 CIDR.
+// The spec is used until the network operator reports the status.
+func hasIPv6Network(network *configv1.Network) bool {
+	var cidrs []string
+	clusterNetwork := network.Status.ClusterNetwork
+	serviceNetwork := network.Status.ServiceNetwork
+	if len(clusterNetwork) == 0 && len(serviceNetwork) == 0 {
+		clusterNetwork = network.Spec.ClusterNetwork
+		serviceNetwork = network.Spec.ServiceNetwork
+	}
+	for _, entry := range clusterNetwork {
+		cidrs = append(cidrs, entry.CIDR)
+	}
+	cidrs = append(cidrs, serviceNetwork...)
+	for _, cidr := range cidrs {
+		ip, _, err := net.ParseCIDR(cidr)
+		if err == nil && ip.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
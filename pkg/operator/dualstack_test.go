@@ -0,0 +1,102 @@
+package operator
+
+import (
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithDualStackEndpointsHook(t *testing.T) {
+	newNetwork := func(serviceNetwork ...string) *configv1.Network {
+		return &configv1.Network{
+			ObjectMeta: metav1.ObjectMeta{Name: networkConfigName},
+			Status: configv1.NetworkStatus{
+				ClusterNetwork: []configv1.ClusterNetworkEntry{{CIDR: "10.128.0.0/14", HostPrefix: 23}},
+				ServiceNetwork: serviceNetwork,
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		overrides     string
+		network       *configv1.Network
+		expected      bool
+		expectedError bool
+	}{
+		{
+			name:    "IPv4 cluster",
+			network: newNetwork("172.30.0.0/16"),
+		},
+		{
+			name:     "dual-stack cluster",
+			network:  newNetwork("172.30.0.0/16", "fd02::/112"),
+			expected: true,
+		},
+		{
+			name: "IPv6 cluster from the spec",
+			network: &configv1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: networkConfigName},
+				Spec: configv1.NetworkSpec{
+					ClusterNetwork: []configv1.ClusterNetworkEntry{{CIDR: "fd01::/48", HostPrefix: 64}},
+					ServiceNetwork: []string{"fd02::/112"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "missing network config",
+		},
+		{
+			name:      "enabled on an IPv4 cluster",
+			overrides: `{"dualStackEndpoints": "Enabled"}`,
+			network:   newNetwork("172.30.0.0/16"),
+			expected:  true,
+		},
+		{
+			name:      "disabled on a dual-stack cluster",
+			overrides: `{"dualStackEndpoints": "Disabled"}`,
+			network:   newNetwork("172.30.0.0/16", "fd02::/112"),
+		},
+		{
+			name:          "invalid value",
+			overrides:     `{"dualStackEndpoints": "true"}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			informer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Networks()
+			if test.network != nil {
+				informer.Informer().GetIndexer().Add(test.network)
+			}
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: driverContainerName}}
+			err := withDualStackEndpointsHook(informer.Lister())(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, ok := getContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "AWS_USE_DUALSTACK_ENDPOINT")
+			if enabled := ok && value == "true"; enabled != test.expected {
+				t.Errorf("expected dual-stack endpoints %v, got %v", test.expected, enabled)
+			}
+		})
+	}
+}
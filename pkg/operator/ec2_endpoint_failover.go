@@ -0,0 +1,247 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// ec2EndpointFailover is the EC2 endpoint of the driver, chosen by the ec2EndpointFailoverController among the
+// ec2 service endpoint of the Infrastructure, the primary, and the ec2FallbackEndpoints of the driver
+// configuration, and rendered by withEC2EndpointFailoverHook. Until the controller has checked the endpoints,
+// the endpoint of the controller Deployment is kept so that a restart of the operator does not roll out the
+// driver back to an unreachable primary.
+type ec2EndpointFailover struct {
+	deploymentLister appslisters.DeploymentNamespaceLister
+
+	lock   sync.Mutex
+	active string
+}
+
+func newEC2EndpointFailover(deploymentLister appslisters.DeploymentNamespaceLister) *ec2EndpointFailover {
+	return &ec2EndpointFailover{deploymentLister: deploymentLister}
+}
+
+// activeEndpoint returns the endpoint the driver uses among the candidates, the primary by default.
+func (f *ec2EndpointFailover) activeEndpoint(candidates []string) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.active == "" {
+		f.active = f.deployedEndpoint()
+	}
+	for _, candidate := range candidates {
+		if candidate == f.active {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+func (f *ec2EndpointFailover) setActiveEndpoint(endpoint string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.active = endpoint
+}
+
+// deployedEndpoint returns the EC2 endpoint of the controller Deployment, if any.
+func (f *ec2EndpointFailover) deployedEndpoint() string {
+	deployment, err := f.deploymentLister.Get(controllerDeploymentName)
+	if err != nil {
+		return ""
+	}
+	container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+	if container == nil {
+		return ""
+	}
+	endpoint, _ := getContainerEnv(container, serviceEndpointEnvNames["ec2"])
+	return endpoint
+}
+
+// ec2EndpointCandidates returns the primary EC2 endpoint followed by the fallback endpoints of the driver
+// configuration, or nil when there are no fallback endpoints.
+func ec2EndpointCandidates(primary string, cfg *driverConfig) ([]string, error) {
+	if len(cfg.EC2FallbackEndpoints) == 0 {
+		return nil, nil
+	}
+	if primary == "" {
+		return nil, fmt.Errorf("ec2FallbackEndpoints require the ec2 service endpoint of Infrastructure %s", infrastructureName)
+	}
+	candidates := []string{primary}
+	for _, endpoint := range cfg.EC2FallbackEndpoints {
+		if host, err := endpointHost(endpoint); err != nil || host == "" {
+			return nil, fmt.Errorf("invalid ec2FallbackEndpoints endpoint %q", endpoint)
+		}
+		candidates = append(candidates, endpoint)
+	}
+	return candidates, nil
+}
+
+// withEC2EndpointFailoverHook sets the EC2 endpoint of the csi-driver container of the controller to the one
+// chosen by the ec2EndpointFailoverController when the driver configuration has ec2FallbackEndpoints. It must
+// run after withCustomEndPoint, which sets the primary endpoint, and withCustomEndpointsNoProxy, which adds the
+// hosts of the primary and of the fallback endpoints to NO_PROXY.
+func withEC2EndpointFailoverHook(failover *ec2EndpointFailover) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if len(cfg.EC2FallbackEndpoints) == 0 {
+			return nil
+		}
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not set the EC2 endpoint because the csi-driver container is missing from the deployment")
+		}
+		primary, _ := getContainerEnv(container, serviceEndpointEnvNames["ec2"])
+		candidates, err := ec2EndpointCandidates(primary, cfg)
+		if err != nil {
+			return err
+		}
+		setContainerEnv(container, serviceEndpointEnvNames["ec2"], failover.activeEndpoint(candidates))
+		return nil
+	}
+}
+
+// ec2EndpointFailoverController checks the EC2 endpoints of the driver in order, the ec2 service endpoint of the
+// Infrastructure first, and makes the driver use the first reachable one. The driver fails over to a fallback
+// endpoint, e.g. another VPC interface endpoint, when the primary is down and fails back when it's reachable
+// again. The endpoints are checked with the CA bundles and the proxy of the controller Deployment like in the
+// endpointCheckController.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error, e.g. when no EC2 endpoint is reachable.
+type ec2EndpointFailoverController struct {
+	driverAWSClientBuilder
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+	infraLister      configlisters.InfrastructureLister
+	failover         *ec2EndpointFailover
+}
+
+func newEC2EndpointFailoverController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	failover *ec2EndpointFailover,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	infraInformer configinformersv1.InfrastructureInformer,
+	infraLister configlisters.InfrastructureLister,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &ec2EndpointFailoverController{
+		driverAWSClientBuilder: newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient),
+		operatorClient:         operatorClient,
+		deploymentLister:       deploymentInformer.Lister().Deployments(namespace),
+		infraLister:            infraLister,
+		failover:               failover,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		infraInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *ec2EndpointFailoverController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+	if len(cfg.EC2FallbackEndpoints) == 0 {
+		return nil
+	}
+
+	infra, err := c.infraLister.Get(infrastructureName)
+	if err != nil {
+		return err
+	}
+	candidates, err := ec2EndpointCandidates(primaryEC2Endpoint(infra), cfg)
+	if err != nil {
+		return err
+	}
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// Nothing to check until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	podSpec := &deployment.Spec.Template.Spec
+	container := getContainer(podSpec, driverContainerName)
+	if container == nil {
+		return fmt.Errorf("the csi-driver container is missing from Deployment %s", deployment.Name)
+	}
+
+	active := c.failover.activeEndpoint(candidates)
+	var errs []error
+	for _, candidate := range candidates {
+		err := c.checkEndpoint(ctx, podSpec, container, "ec2", candidate)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if candidate == active {
+			return nil
+		}
+		c.failover.setActiveEndpoint(candidate)
+		if candidate == candidates[0] {
+			syncCtx.Recorder().Eventf("EC2EndpointFailback", "The EC2 endpoint %s is reachable again, the driver stops using %s", candidate, active)
+		} else {
+			syncCtx.Recorder().Warningf("EC2EndpointFailover", "The driver uses the EC2 endpoint %s instead of %s: %v", candidate, active, utilerrors.NewAggregate(errs))
+		}
+		return nil
+	}
+	return fmt.Errorf("none of the EC2 endpoints is reachable: %w", utilerrors.NewAggregate(errs))
+}
+
+// primaryEC2Endpoint returns the ec2 service endpoint of the Infrastructure, if any.
+func primaryEC2Endpoint(infra *configv1.Infrastructure) string {
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
+		return ""
+	}
+	for _, endpoint := range infra.Status.PlatformStatus.AWS.ServiceEndpoints {
+		if endpoint.Name == "ec2" {
+			return endpoint.URL
+		}
+	}
+	return ""
+}
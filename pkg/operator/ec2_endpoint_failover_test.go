@@ -0,0 +1,183 @@
+package operator
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestEC2EndpointInfra(endpoint string) *configv1.Infrastructure {
+	return &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{
+				AWS: &configv1.AWSPlatformStatus{
+					ServiceEndpoints: []configv1.AWSServiceEndpoint{{Name: "ec2", URL: endpoint}},
+				},
+			},
+		},
+	}
+}
+
+func TestWithEC2EndpointFailoverHook(t *testing.T) {
+	const (
+		primary  = "https://vpce-1.ec2.us-east-1.vpce.amazonaws.com"
+		fallback = "https://vpce-2.ec2.us-east-1.vpce.amazonaws.com"
+	)
+	tests := []struct {
+		name             string
+		overrides        string
+		deployedEndpoint string
+		active           string
+		expectedEndpoint string
+		expectedError    bool
+	}{
+		{
+			name:             "no fallback endpoints",
+			active:           fallback,
+			expectedEndpoint: primary,
+		},
+		{
+			name:             "primary endpoint by default",
+			overrides:        `{"ec2FallbackEndpoints": ["` + fallback + `"]}`,
+			expectedEndpoint: primary,
+		},
+		{
+			name:             "failed over",
+			overrides:        `{"ec2FallbackEndpoints": ["` + fallback + `"]}`,
+			active:           fallback,
+			expectedEndpoint: fallback,
+		},
+		{
+			name:             "endpoint of the deployment",
+			overrides:        `{"ec2FallbackEndpoints": ["` + fallback + `"]}`,
+			deployedEndpoint: fallback,
+			expectedEndpoint: fallback,
+		},
+		{
+			name:             "removed fallback endpoint",
+			overrides:        `{"ec2FallbackEndpoints": ["https://vpce-3.ec2.us-east-1.vpce.amazonaws.com"]}`,
+			active:           fallback,
+			expectedEndpoint: primary,
+		},
+		{
+			name:          "invalid fallback endpoint",
+			overrides:     `{"ec2FallbackEndpoints": ["https://"]}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			if test.deployedEndpoint != "" {
+				informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(newTestHealthCheckDeployment(test.deployedEndpoint, "", false))
+			}
+			failover := newEC2EndpointFailover(informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace))
+			failover.setActiveEndpoint(test.active)
+
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: driverContainerName,
+				Env:  []corev1.EnvVar{{Name: "AWS_EC2_ENDPOINT", Value: primary}},
+			}}
+			err := withEC2EndpointFailoverHook(failover)(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			endpoint, _ := getContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "AWS_EC2_ENDPOINT")
+			if endpoint != test.expectedEndpoint {
+				t.Errorf("expected EC2 endpoint %q, got %q", test.expectedEndpoint, endpoint)
+			}
+		})
+	}
+}
+
+func TestEC2EndpointFailoverController(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	otherServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer otherServer.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	spec := &opv1.OperatorSpec{}
+	spec.UnsupportedConfigOverrides.Raw = []byte(`{"ec2FallbackEndpoints": ["` + server.URL + `"]}`)
+	deployment := newTestHealthCheckDeployment(closedServer.URL, "", true)
+	healthController, _ := newTestAWSHealthController(spec, deployment, serverCA)
+	infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+	infraInformer.Informer().GetIndexer().Add(newTestEC2EndpointInfra(closedServer.URL))
+	c := &ec2EndpointFailoverController{
+		driverAWSClientBuilder: healthController.driverAWSClientBuilder,
+		operatorClient:         healthController.operatorClient,
+		deploymentLister:       healthController.deploymentLister,
+		infraLister:            infraInformer.Lister(),
+		failover:               newEC2EndpointFailover(healthController.deploymentLister),
+	}
+	recorder := events.NewInMemoryRecorder("test")
+	sync := func() error {
+		return c.sync(context.TODO(), factory.NewSyncContext("test", recorder))
+	}
+
+	// The primary endpoint is down.
+	if err := sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if active := c.failover.activeEndpoint([]string{closedServer.URL, server.URL}); active != server.URL {
+		t.Errorf("expected the fallback endpoint %s, got %s", server.URL, active)
+	}
+	if len(recorder.Events()) != 1 || recorder.Events()[0].Reason != "EC2EndpointFailover" {
+		t.Errorf("expected a failover event, got %+v", recorder.Events())
+	}
+	if err := sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorder.Events()) != 1 {
+		t.Errorf("expected no new event, got %+v", recorder.Events())
+	}
+
+	// The primary endpoint is reachable.
+	infraInformer.Informer().GetIndexer().Update(newTestEC2EndpointInfra(otherServer.URL))
+	if err := sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if active := c.failover.activeEndpoint([]string{otherServer.URL, server.URL}); active != otherServer.URL {
+		t.Errorf("expected the primary endpoint %s, got %s", otherServer.URL, active)
+	}
+	if len(recorder.Events()) != 2 || recorder.Events()[1].Reason != "EC2EndpointFailback" {
+		t.Errorf("expected a failback event, got %+v", recorder.Events())
+	}
+
+	// No endpoint is reachable.
+	spec.UnsupportedConfigOverrides.Raw = []byte(`{"ec2FallbackEndpoints": ["` + closedServer.URL + `"]}`)
+	infraInformer.Informer().GetIndexer().Update(newTestEC2EndpointInfra(closedServer.URL))
+	if err := sync(); err == nil || !strings.Contains(err.Error(), "none of the EC2 endpoints is reachable") {
+		t.Errorf("expected an unreachable endpoints error, got %v", err)
+	}
+}
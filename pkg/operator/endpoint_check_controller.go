@@ -0,0 +1,203 @@
+package operator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	endpointCheckInterval = 5 * time.Minute
+	endpointCheckTimeout  = 10 * time.Second
+)
+
+// endpointCheckController checks that the custom service endpoints of the Infrastructure, which the driver gets
+// in the env vars of serviceEndpointEnvNames, can be reached with the CA bundles and the proxy of the csi-driver
+// container of the controller Deployment: their host names resolve and the TLS handshake succeeds. A typo in an
+// endpoint is reported with the endpoint and the precise error instead of only in the logs of the driver.
+// The endpoints are checked again when the Deployment changes or every endpointCheckInterval.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error, e.g. when an endpoint is unreachable.
+type endpointCheckController struct {
+	driverAWSClientBuilder
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+
+	now func() time.Time
+	// lastCheck, lastGeneration and lastErr record the last check, which is reported until the interval
+	// elapses or the Deployment changes.
+	lastCheck      time.Time
+	lastGeneration int64
+	lastErr        error
+}
+
+func newEndpointCheckController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &endpointCheckController{
+		driverAWSClientBuilder: newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient),
+		operatorClient:         operatorClient,
+		deploymentLister:       deploymentInformer.Lister().Deployments(namespace),
+		now:                    time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *endpointCheckController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// Nothing to check until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := c.now()
+	if !c.lastCheck.IsZero() && deployment.Generation == c.lastGeneration && now.Sub(c.lastCheck) < endpointCheckInterval {
+		return c.lastErr
+	}
+	c.lastErr = c.check(ctx, deployment)
+	c.lastCheck = now
+	c.lastGeneration = deployment.Generation
+	if c.lastErr != nil {
+		syncCtx.Recorder().Warning("CustomEndpointUnreachable", c.lastErr.Error())
+	}
+	return c.lastErr
+}
+
+// check returns the errors of all the custom service endpoints of the csi-driver container that can't be reached.
+func (c *endpointCheckController) check(ctx context.Context, deployment *appsv1.Deployment) error {
+	podSpec := &deployment.Spec.Template.Spec
+	container := getContainer(podSpec, driverContainerName)
+	if container == nil {
+		return fmt.Errorf("the csi-driver container is missing from Deployment %s", deployment.Name)
+	}
+	services := make([]string, 0, len(serviceEndpointEnvNames))
+	for service := range serviceEndpointEnvNames {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	var errs []error
+	for _, service := range services {
+		endpoint, ok := getContainerEnv(container, serviceEndpointEnvNames[service])
+		if !ok || endpoint == "" {
+			continue
+		}
+		if err := c.checkEndpoint(ctx, podSpec, container, service, endpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// checkEndpoint connects to the endpoint like the driver does. Any HTTP response means that the endpoint is
+// reachable, the requests of the driver are signed and are not checked.
+func (b *driverAWSClientBuilder) checkEndpoint(ctx context.Context, podSpec *corev1.PodSpec, container *corev1.Container, service, endpoint string) error {
+	raw := endpoint
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	endpointURL, err := url.Parse(raw)
+	if err != nil || endpointURL.Host == "" {
+		return fmt.Errorf("the %s service endpoint %q is not a valid URL. Check the %s service endpoint in Infrastructure %s", service, endpoint, service, infrastructureName)
+	}
+	target := &awsHealthCheckTarget{}
+	rootCAs, err := b.getRootCAs(podSpec, container, target)
+	if err != nil {
+		return fmt.Errorf("failed to check the %s service endpoint %s: %w", service, endpoint, err)
+	}
+	proxyURL, err := containerProxy(container, endpointURL)
+	if err != nil {
+		return fmt.Errorf("failed to check the %s service endpoint %s: invalid proxy configuration: %w", service, endpoint, err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{Transport: transport, Timeout: endpointCheckTimeout}
+	defer transport.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		return nil
+	}
+
+	var dnsErr *net.DNSError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &unknownAuthorityErr) || errors.As(err, &certInvalidErr) || errors.As(err, &hostnameErr):
+		return fmt.Errorf("failed to verify the certificate of the %s service endpoint %s with %s: %v. Check the custom CA bundle of the cloud config",
+			service, endpoint, target.caSource, err)
+	case proxyURL != nil && errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return fmt.Errorf("failed to connect to the %s service endpoint %s through proxy %s: %v. Check the cluster Proxy configuration",
+			service, endpoint, proxyURL.Redacted(), err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("the host of the %s service endpoint %s can't be resolved: %v. Check the %s service endpoint in Infrastructure %s",
+			service, endpoint, dnsErr, service, infrastructureName)
+	case proxyURL != nil:
+		return fmt.Errorf("failed to connect to the %s service endpoint %s through proxy %s: %v. Check the %s service endpoint in Infrastructure %s and the proxyCustomEndpoints of the driver configuration",
+			service, endpoint, proxyURL.Redacted(), err, service, infrastructureName)
+	}
+	return fmt.Errorf("failed to connect to the %s service endpoint %s: %v. Check the %s service endpoint in Infrastructure %s",
+		service, endpoint, err, service, infrastructureName)
+}
@@ -0,0 +1,99 @@
+package operator
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestEndpointCheckController(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	tests := []struct {
+		name          string
+		deployment    *appsv1.Deployment
+		caBundle      []byte
+		expectedError string
+	}{
+		{
+			name:       "default endpoint",
+			deployment: newTestHealthCheckDeployment("", "", false),
+		},
+		{
+			name:       "reachable endpoint",
+			deployment: newTestHealthCheckDeployment(server.URL, "", true),
+			caBundle:   serverCA,
+		},
+		{
+			name:          "untrusted certificate",
+			deployment:    newTestHealthCheckDeployment(server.URL, "", false),
+			expectedError: "failed to verify the certificate of the ec2 service endpoint",
+		},
+		{
+			name:          "unreachable endpoint",
+			deployment:    newTestHealthCheckDeployment(closedServer.URL, "", false),
+			expectedError: "failed to connect to the ec2 service endpoint",
+		},
+		{
+			name:          "unknown host",
+			deployment:    newTestHealthCheckDeployment("https://ec2.invalid", "", false),
+			expectedError: "the host of the ec2 service endpoint https://ec2.invalid can't be resolved",
+		},
+		{
+			name:          "unreachable proxy",
+			deployment:    newTestHealthCheckDeployment(server.URL, closedServer.URL, true),
+			caBundle:      serverCA,
+			expectedError: "through proxy",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			healthController, _ := newTestAWSHealthController(&opv1.OperatorSpec{}, test.deployment, test.caBundle)
+			c := &endpointCheckController{
+				driverAWSClientBuilder: healthController.driverAWSClientBuilder,
+				operatorClient:         healthController.operatorClient,
+				deploymentLister:       healthController.deploymentLister,
+				now:                    time.Now,
+			}
+			recorder := events.NewInMemoryRecorder("test")
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", recorder))
+			if test.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Fatalf("expected error containing %q, got %v", test.expectedError, err)
+			}
+			if len(recorder.Events()) != 1 {
+				t.Errorf("expected a warning event, got %d events", len(recorder.Events()))
+			}
+
+			// The result is reported again until the interval elapses.
+			if err2 := c.sync(context.TODO(), factory.NewSyncContext("test", recorder)); err2 == nil || err2.Error() != err.Error() {
+				t.Errorf("expected the last error %v, got %v", err, err2)
+			}
+			if len(recorder.Events()) != 1 {
+				t.Errorf("expected no new event until the next check, got %d events", len(recorder.Events()))
+			}
+		})
+	}
+}
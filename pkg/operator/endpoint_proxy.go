@@ -0,0 +1,176 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// Values of serviceProxy in the driver configuration.
+	serviceProxyProxy  = "Proxy"
+	serviceProxyDirect = "Direct"
+)
+
+// withCustomEndpointsNoProxy adds the hosts of the custom service endpoints of the csi-driver container, set from
+// the Infrastructure, to its NO_PROXY when the cluster has a proxy: custom endpoints are usually VPC endpoints or
+// private AWS regions that the proxy can't reach. proxyCustomEndpoints in the driver configuration keeps the calls
+// on the proxy. It must run after withCustomEndPoint and before withAssumeRole, which sends the STS calls the way
+// the EC2 calls go.
+func withCustomEndpointsNoProxy() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.ProxyCustomEndpoints {
+			return nil
+		}
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return nil
+		}
+		_, httpProxy := getContainerEnv(container, "HTTP_PROXY")
+		_, httpsProxy := getContainerEnv(container, "HTTPS_PROXY")
+		if !httpProxy && !httpsProxy {
+			return nil
+		}
+
+		services := make([]string, 0, len(serviceEndpointEnvNames))
+		for service := range serviceEndpointEnvNames {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+		noProxy, _ := getContainerEnv(container, "NO_PROXY")
+		addHost := func(service, endpoint string) error {
+			host, err := endpointHost(endpoint)
+			if err != nil {
+				return fmt.Errorf("invalid %s service endpoint: %w", service, err)
+			}
+			if host == "" || noProxyMatches(noProxy, host) {
+				return nil
+			}
+			if noProxy != "" {
+				noProxy += ","
+			}
+			noProxy += host
+			return nil
+		}
+		for _, service := range services {
+			endpoint, ok := getContainerEnv(container, serviceEndpointEnvNames[service])
+			if !ok || endpoint == "" {
+				continue
+			}
+			if err := addHost(service, endpoint); err != nil {
+				return err
+			}
+		}
+		// The fallback EC2 endpoints bypass the proxy like the primary so that failing over does not
+		// change the way to EC2.
+		for _, endpoint := range cfg.EC2FallbackEndpoints {
+			if err := addHost("ec2", endpoint); err != nil {
+				return err
+			}
+		}
+		if noProxy != "" {
+			setContainerEnv(container, "NO_PROXY", noProxy)
+		}
+		return nil
+	}
+}
+
+// withServiceProxyHook routes the calls of the csi-driver container of the controller to each AWS service listed in
+// serviceProxy of the driver configuration through the cluster proxy ("Proxy") or directly ("Direct"), e.g. EC2
+// through the proxy and KMS to a VPC endpoint. The host of the service endpoint, the custom one of the
+// Infrastructure or the regional one, and of the fallback EC2 endpoints, is added to or removed from NO_PROXY.
+// The services that are not listed keep their route. It runs after withCustomEndpointsNoProxy and withAssumeRole
+// and takes precedence over proxyCustomEndpoints and the STS route of assumeRole.
+func withServiceProxyHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if len(cfg.ServiceProxy) == 0 {
+			return nil
+		}
+		services := make([]string, 0, len(cfg.ServiceProxy))
+		for service, route := range cfg.ServiceProxy {
+			if _, ok := serviceEndpointEnvNames[service]; !ok {
+				return fmt.Errorf("invalid serviceProxy service %q, expected ec2, kms or sts", service)
+			}
+			if route != serviceProxyProxy && route != serviceProxyDirect {
+				return fmt.Errorf("invalid serviceProxy route %q of service %s, expected %s or %s", route, service, serviceProxyProxy, serviceProxyDirect)
+			}
+			services = append(services, service)
+		}
+		sort.Strings(services)
+
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return nil
+		}
+		_, httpProxy := getContainerEnv(container, "HTTP_PROXY")
+		_, httpsProxy := getContainerEnv(container, "HTTPS_PROXY")
+		if !httpProxy && !httpsProxy {
+			return nil
+		}
+		region, _ := getContainerEnv(container, "AWS_REGION")
+		noProxy, hasNoProxy := getContainerEnv(container, "NO_PROXY")
+		for _, service := range services {
+			endpoints := []string{}
+			if endpoint, ok := getContainerEnv(container, serviceEndpointEnvNames[service]); ok && endpoint != "" {
+				endpoints = append(endpoints, endpoint)
+			} else if region != "" {
+				endpoints = append(endpoints, awsclient.DefaultEndpoint(service, region))
+			} else {
+				return fmt.Errorf("could not route the %s calls because the region is not known", service)
+			}
+			if service == "ec2" {
+				endpoints = append(endpoints, cfg.EC2FallbackEndpoints...)
+			}
+			for _, endpoint := range endpoints {
+				host, err := endpointHost(endpoint)
+				if err != nil {
+					return fmt.Errorf("invalid %s service endpoint: %w", service, err)
+				}
+				if host == "" {
+					continue
+				}
+				if cfg.ServiceProxy[service] == serviceProxyDirect {
+					if !noProxyMatches(noProxy, host) {
+						noProxy = strings.TrimPrefix(noProxy+","+host, ",")
+					}
+					continue
+				}
+				noProxy = removeNoProxyHost(noProxy, host)
+				if noProxyMatches(noProxy, host) {
+					return fmt.Errorf("could not send the %s calls through the proxy because %s is in NO_PROXY of the cluster proxy", service, host)
+				}
+			}
+		}
+		if hasNoProxy || noProxy != "" {
+			setContainerEnv(container, "NO_PROXY", noProxy)
+		}
+		return nil
+	}
+}
+
+// removeNoProxyHost removes the entries of the host from the NO_PROXY list.
+func removeNoProxyHost(noProxy, host string) string {
+	var entries []string
+	for _, entry := range strings.Split(noProxy, ",") {
+		if trimmed := strings.TrimSpace(entry); trimmed == "" || strings.TrimPrefix(trimmed, ".") == host {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, ",")
+}
@@ -0,0 +1,195 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWithCustomEndpointsNoProxy(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrides       string
+		env             []corev1.EnvVar
+		expectedNoProxy string
+	}{
+		{
+			name: "no proxy",
+			env: []corev1.EnvVar{
+				{Name: "AWS_EC2_ENDPOINT", Value: "https://ec2.example.com"},
+			},
+		},
+		{
+			name: "default endpoint",
+			env: []corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".cluster.local"},
+			},
+			expectedNoProxy: ".cluster.local",
+		},
+		{
+			name: "custom endpoint",
+			env: []corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".cluster.local"},
+				{Name: "AWS_EC2_ENDPOINT", Value: "https://vpce-1234.ec2.us-east-1.vpce.amazonaws.com"},
+			},
+			expectedNoProxy: ".cluster.local,vpce-1234.ec2.us-east-1.vpce.amazonaws.com",
+		},
+		{
+			name: "custom endpoint without NO_PROXY",
+			env: []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "AWS_EC2_ENDPOINT", Value: "ec2.example.com:8443"},
+			},
+			expectedNoProxy: "ec2.example.com",
+		},
+		{
+			name: "custom endpoint already in NO_PROXY",
+			env: []corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".example.com"},
+				{Name: "AWS_EC2_ENDPOINT", Value: "https://ec2.example.com"},
+			},
+			expectedNoProxy: ".example.com",
+		},
+		{
+			name:      "fallback EC2 endpoints",
+			overrides: `{"ec2FallbackEndpoints": ["https://vpce-2.ec2.us-east-1.vpce.amazonaws.com"]}`,
+			env: []corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "AWS_EC2_ENDPOINT", Value: "https://vpce-1.ec2.us-east-1.vpce.amazonaws.com"},
+			},
+			expectedNoProxy: "vpce-1.ec2.us-east-1.vpce.amazonaws.com,vpce-2.ec2.us-east-1.vpce.amazonaws.com",
+		},
+		{
+			name:      "custom endpoint through the proxy",
+			overrides: `{"proxyCustomEndpoints": true}`,
+			env: []corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".cluster.local"},
+				{Name: "AWS_EC2_ENDPOINT", Value: "https://ec2.example.com"},
+			},
+			expectedNoProxy: ".cluster.local",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: driverContainerName, Env: test.env}}
+			if err := withCustomEndpointsNoProxy()(spec, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			noProxy, _ := getContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "NO_PROXY")
+			if noProxy != test.expectedNoProxy {
+				t.Errorf("expected NO_PROXY %q, got %q", test.expectedNoProxy, noProxy)
+			}
+		})
+	}
+}
+
+func TestWithServiceProxyHook(t *testing.T) {
+	proxyEnv := []corev1.EnvVar{
+		{Name: "AWS_REGION", Value: "us-east-1"},
+		{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+		{Name: "NO_PROXY", Value: ".cluster.local,vpce-1234.ec2.us-east-1.vpce.amazonaws.com"},
+		{Name: "AWS_EC2_ENDPOINT", Value: "https://vpce-1234.ec2.us-east-1.vpce.amazonaws.com"},
+	}
+	tests := []struct {
+		name            string
+		overrides       string
+		env             []corev1.EnvVar
+		expectedNoProxy string
+		expectedError   bool
+	}{
+		{
+			name:            "no service proxy",
+			env:             proxyEnv,
+			expectedNoProxy: ".cluster.local,vpce-1234.ec2.us-east-1.vpce.amazonaws.com",
+		},
+		{
+			name:            "EC2 through the proxy, KMS direct",
+			overrides:       `{"serviceProxy": {"ec2": "Proxy", "kms": "Direct"}}`,
+			env:             proxyEnv,
+			expectedNoProxy: ".cluster.local,kms.us-east-1.amazonaws.com",
+		},
+		{
+			name:      "custom KMS endpoint direct",
+			overrides: `{"serviceProxy": {"kms": "Direct"}}`,
+			env: append([]corev1.EnvVar{
+				{Name: "AWS_ENDPOINT_URL_KMS", Value: "https://kms.example.com"},
+			}, proxyEnv...),
+			expectedNoProxy: ".cluster.local,vpce-1234.ec2.us-east-1.vpce.amazonaws.com,kms.example.com",
+		},
+		{
+			name:      "fallback EC2 endpoints through the proxy",
+			overrides: `{"serviceProxy": {"ec2": "Proxy"}, "ec2FallbackEndpoints": ["https://vpce-5678.ec2.us-east-1.vpce.amazonaws.com"]}`,
+			env: []corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: "vpce-1234.ec2.us-east-1.vpce.amazonaws.com,.vpce-5678.ec2.us-east-1.vpce.amazonaws.com"},
+				{Name: "AWS_EC2_ENDPOINT", Value: "https://vpce-1234.ec2.us-east-1.vpce.amazonaws.com"},
+			},
+			expectedNoProxy: "",
+		},
+		{
+			name:      "no proxy",
+			overrides: `{"serviceProxy": {"kms": "Direct"}}`,
+			env:       []corev1.EnvVar{{Name: "AWS_REGION", Value: "us-east-1"}},
+		},
+		{
+			name:      "service in NO_PROXY of the cluster",
+			overrides: `{"serviceProxy": {"sts": "Proxy"}}`,
+			env: []corev1.EnvVar{
+				{Name: "AWS_REGION", Value: "us-east-1"},
+				{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "NO_PROXY", Value: ".amazonaws.com"},
+			},
+			expectedError: true,
+		},
+		{
+			name:          "unknown service",
+			overrides:     `{"serviceProxy": {"s3": "Direct"}}`,
+			env:           proxyEnv,
+			expectedError: true,
+		},
+		{
+			name:          "invalid route",
+			overrides:     `{"serviceProxy": {"kms": "direct"}}`,
+			env:           proxyEnv,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := &appsv1.Deployment{}
+			env := append([]corev1.EnvVar{}, test.env...)
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: driverContainerName, Env: env}}
+			err := withServiceProxyHook()(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			noProxy, _ := getContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "NO_PROXY")
+			if noProxy != test.expectedNoProxy {
+				t.Errorf("expected NO_PROXY %q, got %q", test.expectedNoProxy, noProxy)
+			}
+		})
+	}
+}
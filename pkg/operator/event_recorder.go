@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"context"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// fanOutRecorder records the events with all its recorders. On Hypershift, the events of the operator are
+// recorded in the guest cluster for the cluster admin and mirrored in the control plane namespace of the
+// management cluster for its SREs. The component name is the one of the first recorder.
+type fanOutRecorder struct {
+	recorders []events.Recorder
+}
+
+var _ events.Recorder = &fanOutRecorder{}
+
+func newFanOutRecorder(recorders ...events.Recorder) events.Recorder {
+	return &fanOutRecorder{recorders: recorders}
+}
+
+func (r *fanOutRecorder) Event(reason, message string) {
+	for _, recorder := range r.recorders {
+		recorder.Event(reason, message)
+	}
+}
+
+func (r *fanOutRecorder) Eventf(reason, messageFmt string, args ...interface{}) {
+	for _, recorder := range r.recorders {
+		recorder.Eventf(reason, messageFmt, args...)
+	}
+}
+
+func (r *fanOutRecorder) Warning(reason, message string) {
+	for _, recorder := range r.recorders {
+		recorder.Warning(reason, message)
+	}
+}
+
+func (r *fanOutRecorder) Warningf(reason, messageFmt string, args ...interface{}) {
+	for _, recorder := range r.recorders {
+		recorder.Warningf(reason, messageFmt, args...)
+	}
+}
+
+func (r *fanOutRecorder) ForComponent(componentName string) events.Recorder {
+	return r.each(func(recorder events.Recorder) events.Recorder {
+		return recorder.ForComponent(componentName)
+	})
+}
+
+func (r *fanOutRecorder) WithComponentSuffix(componentNameSuffix string) events.Recorder {
+	return r.each(func(recorder events.Recorder) events.Recorder {
+		return recorder.WithComponentSuffix(componentNameSuffix)
+	})
+}
+
+func (r *fanOutRecorder) WithContext(ctx context.Context) events.Recorder {
+	return r.each(func(recorder events.Recorder) events.Recorder {
+		return recorder.WithContext(ctx)
+	})
+}
+
+func (r *fanOutRecorder) ComponentName() string {
+	if len(r.recorders) == 0 {
+		return ""
+	}
+	return r.recorders[0].ComponentName()
+}
+
+func (r *fanOutRecorder) Shutdown() {
+	for _, recorder := range r.recorders {
+		recorder.Shutdown()
+	}
+}
+
+// each returns a fanOutRecorder with the recorders derived from the ones of r.
+func (r *fanOutRecorder) each(derive func(events.Recorder) events.Recorder) events.Recorder {
+	recorders := make([]events.Recorder, 0, len(r.recorders))
+	for _, recorder := range r.recorders {
+		recorders = append(recorders, derive(recorder))
+	}
+	return newFanOutRecorder(recorders...)
+}
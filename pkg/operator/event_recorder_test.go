@@ -0,0 +1,32 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+func TestFanOutRecorder(t *testing.T) {
+	guest := events.NewInMemoryRecorder("guest")
+	controlPlane := events.NewInMemoryRecorder("control-plane")
+	recorder := newFanOutRecorder(guest, controlPlane)
+	if name := recorder.ComponentName(); name != "guest" {
+		t.Errorf("expected the component name of the first recorder, got %s", name)
+	}
+
+	recorder.Eventf("DeploymentUpdated", "Updated Deployment %s", controllerDeploymentName)
+	recorder.ForComponent("test").Warning("HookFailed", "the hook failed")
+
+	for _, r := range []events.InMemoryRecorder{guest, controlPlane} {
+		recorded := r.Events()
+		if len(recorded) != 2 {
+			t.Fatalf("expected 2 events in %s, got %d", r.ComponentName(), len(recorded))
+		}
+		if recorded[0].Reason != "DeploymentUpdated" || recorded[0].Message != "Updated Deployment "+controllerDeploymentName {
+			t.Errorf("unexpected event %s: %s", recorded[0].Reason, recorded[0].Message)
+		}
+		if recorded[1].Reason != "HookFailed" || recorded[1].Source.Component != "test" {
+			t.Errorf("unexpected event %s from %s", recorded[1].Reason, recorded[1].Source.Component)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// externalControlPlaneInfra returns the Infrastructure when its control plane topology is External, i.e. when the
+// control plane of a standalone cluster is managed outside of the cluster and there are no control plane nodes.
+// It returns nil otherwise.
+func externalControlPlaneInfra(infraLister configlisters.InfrastructureLister) (*configv1.Infrastructure, error) {
+	infra, err := infraLister.Get(infrastructureName)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if infra.Status.ControlPlaneTopology != configv1.ExternalTopologyMode {
+		return nil, nil
+	}
+	return infra, nil
+}
+
+// externalControlPlaneReplicas returns the replicas of the controller when the control plane is External: the
+// controller runs on the worker nodes, with 2 replicas when their infrastructure topology is HighlyAvailable.
+func externalControlPlaneReplicas(infra *configv1.Infrastructure) int32 {
+	if infra.Status.InfrastructureTopology == configv1.SingleReplicaTopologyMode {
+		return 1
+	}
+	return 2
+}
+
+// withExternalControlPlaneHook runs the controller on the worker nodes of standalone clusters whose control plane
+// is External. The controller Deployment selects the master nodes, which don't exist there: the replicas counted
+// by withHypershiftReplicasHook are replaced by externalControlPlaneReplicas, and the master node selector is
+// removed unless the nodeSelector comes from the nodePlacement of the driver configuration. It must run after
+// withNodePlacementDeploymentHook and withHypershiftReplicasHook.
+func withExternalControlPlaneHook(isHypershift bool, infraLister configlisters.InfrastructureLister) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if isHypershift {
+			return nil
+		}
+		infra, err := externalControlPlaneInfra(infraLister)
+		if err != nil || infra == nil {
+			return err
+		}
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		replicas := externalControlPlaneReplicas(infra)
+		deployment.Spec.Replicas = &replicas
+		if cfg.NodePlacement == nil || cfg.NodePlacement.Controller == nil || cfg.NodePlacement.Controller.NodeSelector == nil {
+			delete(deployment.Spec.Template.Spec.NodeSelector, masterNodeRoleLabel)
+		}
+		return nil
+	}
+}
+
+// externalControlPlaneController reports how the controller runs on standalone clusters whose control plane is
+// External, see withExternalControlPlaneHook.
+//
+// It produces the following conditions:
+// <name>ExternalControlPlane: True when the control plane topology of the Infrastructure is External, with the
+// replicas of the controller in the message. It's removed otherwise.
+// <name>Degraded: produced when the sync() method returns an error.
+type externalControlPlaneController struct {
+	name           string
+	operatorClient v1helpers.OperatorClient
+	infraLister    configlisters.InfrastructureLister
+}
+
+func newExternalControlPlaneController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	infraInformer configinformersv1.InfrastructureInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &externalControlPlaneController{
+		name:           name,
+		operatorClient: operatorClient,
+		infraLister:    infraInformer.Lister(),
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		infraInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *externalControlPlaneController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	conditionType := c.name + "ExternalControlPlane"
+	infra, err := externalControlPlaneInfra(c.infraLister)
+	if err != nil {
+		return err
+	}
+	if infra == nil {
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+
+	cond := opv1.OperatorCondition{
+		Type:   conditionType,
+		Status: opv1.ConditionTrue,
+		Reason: "ExternalControlPlane",
+		Message: fmt.Sprintf("The control plane of the cluster is External, the controller runs %d replica(s) on the worker nodes for the %s infrastructure topology",
+			externalControlPlaneReplicas(infra), infra.Status.InfrastructureTopology),
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
@@ -0,0 +1,123 @@
+package operator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+func newTestExternalControlPlaneInfraLister(controlPlaneTopology, infrastructureTopology configv1.TopologyMode) configlisters.InfrastructureLister {
+	infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+	infraInformer.Informer().GetIndexer().Add(&configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+		Status: configv1.InfrastructureStatus{
+			ControlPlaneTopology:   controlPlaneTopology,
+			InfrastructureTopology: infrastructureTopology,
+		},
+	})
+	return infraInformer.Lister()
+}
+
+func TestExternalControlPlane(t *testing.T) {
+	tests := []struct {
+		name                   string
+		controlPlaneTopology   configv1.TopologyMode
+		infrastructureTopology configv1.TopologyMode
+		overrides              string
+		expectedReplicas       int32
+		expectedNodeSelector   map[string]string
+		expectedCondition      bool
+	}{
+		{
+			name:                   "highly available control plane",
+			controlPlaneTopology:   configv1.HighlyAvailableTopologyMode,
+			infrastructureTopology: configv1.HighlyAvailableTopologyMode,
+			expectedReplicas:       1,
+			expectedNodeSelector:   map[string]string{masterNodeRoleLabel: ""},
+		},
+		{
+			name:                   "external control plane",
+			controlPlaneTopology:   configv1.ExternalTopologyMode,
+			infrastructureTopology: configv1.HighlyAvailableTopologyMode,
+			expectedReplicas:       2,
+			expectedNodeSelector:   map[string]string{},
+			expectedCondition:      true,
+		},
+		{
+			name:                   "external control plane with a single worker",
+			controlPlaneTopology:   configv1.ExternalTopologyMode,
+			infrastructureTopology: configv1.SingleReplicaTopologyMode,
+			expectedReplicas:       1,
+			expectedNodeSelector:   map[string]string{},
+			expectedCondition:      true,
+		},
+		{
+			name:                   "external control plane with nodePlacement",
+			controlPlaneTopology:   configv1.ExternalTopologyMode,
+			infrastructureTopology: configv1.HighlyAvailableTopologyMode,
+			overrides:              `{"nodePlacement": {"controller": {"nodeSelector": {"node-role.kubernetes.io/master": ""}}}}`,
+			expectedReplicas:       2,
+			expectedNodeSelector:   map[string]string{masterNodeRoleLabel: ""},
+			expectedCondition:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infraLister := newTestExternalControlPlaneInfraLister(test.controlPlaneTopology, test.infrastructureTopology)
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+
+			replicas := int32(1)
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Replicas = &replicas
+			deployment.Spec.Template.Spec.NodeSelector = map[string]string{masterNodeRoleLabel: ""}
+			if err := withExternalControlPlaneHook(false, infraLister)(spec, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *deployment.Spec.Replicas != test.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", test.expectedReplicas, *deployment.Spec.Replicas)
+			}
+			if !reflect.DeepEqual(deployment.Spec.Template.Spec.NodeSelector, test.expectedNodeSelector) {
+				t.Errorf("expected nodeSelector %v, got %v", test.expectedNodeSelector, deployment.Spec.Template.Spec.NodeSelector)
+			}
+
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{
+				Conditions: []opv1.OperatorCondition{{Type: "TestExternalControlPlane", Status: opv1.ConditionTrue}},
+			}, nil)
+			c := &externalControlPlaneController{
+				name:           "Test",
+				operatorClient: operatorClient,
+				infraLister:    infraLister,
+			}
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestExternalControlPlane")
+			if !test.expectedCondition {
+				if cond != nil {
+					t.Errorf("expected no condition, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != opv1.ConditionTrue || cond.Reason != "ExternalControlPlane" {
+				t.Errorf("expected the ExternalControlPlane condition, got %+v", cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+var (
+	// managedDriverArgs are the csi-driver arguments set by the operator, they can't be passed as extra args.
+	managedDriverArgs = map[string]bool{
+		"aws-sdk-debug-log":   true,
+		"batching":            true,
+		"endpoint":            true,
+		"k8s-tag-cluster-id":  true,
+		"extra-tags":          true,
+		"http-endpoint":       true,
+		"logtostderr":         true,
+		"v":                   true,
+		"volume-attach-limit": true,
+	}
+	// managedDriverEnv are the csi-driver environment variables set by the operator, they can't be
+	// passed as extra env.
+	managedDriverEnv = map[string]bool{
+		"CSI_ENDPOINT":                           true,
+		"AWS_ACCESS_KEY_ID":                      true,
+		"AWS_SECRET_ACCESS_KEY":                  true,
+		"AWS_ROLE_ARN":                           true,
+		"AWS_WEB_IDENTITY_TOKEN_FILE":            true,
+		"AWS_ROLE_SESSION_NAME":                  true,
+		"AWS_REGION":                             true,
+		"AWS_DEFAULT_REGION":                     true,
+		"AWS_CA_BUNDLE":                          true,
+		"AWS_EC2_ENDPOINT":                       true,
+		"AWS_ENDPOINT_URL":                       true,
+		"AWS_ENDPOINT_URL_EC2":                   true,
+		"AWS_ENDPOINT_URL_STS":                   true,
+		"AWS_ENDPOINT_URL_KMS":                   true,
+		"AWS_USE_DUALSTACK_ENDPOINT":             true,
+		"AWS_EC2_METADATA_DISABLED":              true,
+		"AWS_PROFILE":                            true,
+		"AWS_STS_REGIONAL_ENDPOINTS":             true,
+		"AWS_CONFIG_FILE":                        true,
+		"AWS_SHARED_CREDENTIALS_FILE":            true,
+		"AWS_SDK_LOAD_CONFIG":                    true,
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI":     true,
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE": true,
+		"HTTP_PROXY":                             true,
+		"HTTPS_PROXY":                            true,
+		"NO_PROXY":                               true,
+	}
+)
+
+// withExtraArgsDeploymentHook appends the extra args and env vars from the driver configuration
+// to the csi-driver container of the controller Deployment.
+func withExtraArgsDeploymentHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		return injectExtraArgs(spec, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withExtraArgsDaemonSetHook appends the extra args and env vars from the driver configuration
+// to the csi-driver container of the node DaemonSet.
+func withExtraArgsDaemonSetHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		return injectExtraArgs(spec, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+func injectExtraArgs(spec *opv1.OperatorSpec, podSpec *corev1.PodSpec) error {
+	cfg, err := getDriverConfig(spec)
+	if err != nil {
+		return err
+	}
+	if len(cfg.ExtraArgs) == 0 && len(cfg.ExtraEnv) == 0 {
+		return nil
+	}
+	if err := validateExtraArgs(cfg); err != nil {
+		return err
+	}
+
+	container := getContainer(podSpec, driverContainerName)
+	if container == nil {
+		return fmt.Errorf("could not add extra args because the csi-driver container is missing")
+	}
+	container.Args = append(container.Args, cfg.ExtraArgs...)
+	for _, env := range cfg.ExtraEnv {
+		container.Env = append(container.Env, corev1.EnvVar{Name: env.Name, Value: env.Value})
+	}
+	return nil
+}
+
+// validateExtraArgs rejects extra args and env vars that would override the ones managed by the operator.
+func validateExtraArgs(cfg *driverConfig) error {
+	for _, arg := range cfg.ExtraArgs {
+		if !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("invalid extraArgs %q: only flags are allowed", arg)
+		}
+		name := strings.TrimLeft(arg, "-")
+		name, _, _ = strings.Cut(name, "=")
+		if managedDriverArgs[name] {
+			return fmt.Errorf("invalid extraArgs %q: --%s is managed by the operator", arg, name)
+		}
+	}
+	for _, env := range cfg.ExtraEnv {
+		if env.Name == "" {
+			return fmt.Errorf("invalid extraEnv: name must not be empty")
+		}
+		if managedDriverEnv[env.Name] {
+			return fmt.Errorf("invalid extraEnv %q: %s is managed by the operator", env.Name, env.Name)
+		}
+	}
+	return nil
+}
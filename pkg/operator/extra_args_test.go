@@ -0,0 +1,156 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestExtraArgsHooks(t *testing.T) {
+	newPodSpec := func(args []string, env []corev1.EnvVar) corev1.PodSpec {
+		return corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "csi-driver",
+					Args: args,
+					Env:  env,
+				},
+				{
+					Name: "csi-liveness-probe",
+					Args: []string{"--probe-timeout=3s"},
+				},
+			},
+		}
+	}
+	inArgs := []string{"controller", "--v=2"}
+	inEnv := []corev1.EnvVar{{Name: "CSI_ENDPOINT", Value: "unix:/csi/csi.sock"}}
+
+	tests := []struct {
+		name        string
+		overrides   string
+		expected    corev1.PodSpec
+		expectError bool
+	}{
+		{
+			name:     "no extra args",
+			expected: newPodSpec(inArgs, inEnv),
+		},
+		{
+			name:      "extra args and env",
+			overrides: `{"extraArgs": ["--user-agent-extra=test", "--modify-volume-request-handler-timeout=5s"], "extraEnv": [{"name": "FOO", "value": "bar"}]}`,
+			expected: newPodSpec(
+				[]string{"controller", "--v=2", "--user-agent-extra=test", "--modify-volume-request-handler-timeout=5s"},
+				[]corev1.EnvVar{
+					{Name: "CSI_ENDPOINT", Value: "unix:/csi/csi.sock"},
+					{Name: "FOO", Value: "bar"},
+				},
+			),
+		},
+		{
+			name:        "managed arg",
+			overrides:   `{"extraArgs": ["--extra-tags=a=b"]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed arg without value",
+			overrides:   `{"extraArgs": ["-logtostderr"]}`,
+			expectError: true,
+		},
+		{
+			name:        "positional arg",
+			overrides:   `{"extraArgs": ["node"]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed env",
+			overrides:   `{"extraEnv": [{"name": "AWS_REGION", "value": "us-east-1"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed default region env",
+			overrides:   `{"extraEnv": [{"name": "AWS_DEFAULT_REGION", "value": "us-east-1"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed endpoint url env",
+			overrides:   `{"extraEnv": [{"name": "AWS_ENDPOINT_URL", "value": "https://ec2.example.com"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed ec2 endpoint url env",
+			overrides:   `{"extraEnv": [{"name": "AWS_ENDPOINT_URL_EC2", "value": "https://ec2.example.com"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed access key id env",
+			overrides:   `{"extraEnv": [{"name": "AWS_ACCESS_KEY_ID", "value": "AKIA"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed secret access key env",
+			overrides:   `{"extraEnv": [{"name": "AWS_SECRET_ACCESS_KEY", "value": "secret"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed role arn env",
+			overrides:   `{"extraEnv": [{"name": "AWS_ROLE_ARN", "value": "arn:aws:iam::123456789012:role/ebs"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed web identity token file env",
+			overrides:   `{"extraEnv": [{"name": "AWS_WEB_IDENTITY_TOKEN_FILE", "value": "/tmp/token"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed role session name env",
+			overrides:   `{"extraEnv": [{"name": "AWS_ROLE_SESSION_NAME", "value": "ebs"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed container credentials env",
+			overrides:   `{"extraEnv": [{"name": "AWS_CONTAINER_CREDENTIALS_FULL_URI", "value": "http://169.254.170.23/v1/credentials"}]}`,
+			expectError: true,
+		},
+		{
+			name:        "managed container authorization token file env",
+			overrides:   `{"extraEnv": [{"name": "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", "value": "/tmp/token"}]}`,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec = newPodSpec(inArgs, inEnv)
+			deploymentErr := withExtraArgsDeploymentHook()(spec, deployment)
+
+			daemonSet := &appsv1.DaemonSet{}
+			daemonSet.Spec.Template.Spec = newPodSpec(inArgs, inEnv)
+			daemonSetErr := withExtraArgsDaemonSetHook()(spec, daemonSet)
+
+			if test.expectError {
+				if deploymentErr == nil || daemonSetErr == nil {
+					t.Errorf("expected errors, got %v and %v", deploymentErr, daemonSetErr)
+				}
+				return
+			}
+			if deploymentErr != nil || daemonSetErr != nil {
+				t.Fatalf("unexpected errors: %v, %v", deploymentErr, daemonSetErr)
+			}
+			if e, a := test.expected, deployment.Spec.Template.Spec; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment pod spec\nwant=%#v\ngot= %#v", e, a)
+			}
+			if e, a := test.expected, daemonSet.Spec.Template.Spec; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected daemonset pod spec\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package operator
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+)
+
+const featureGateName = "cluster"
+
+// techPreviewFeatureGates are the feature gates used by the operator that are enabled by the
+// TechPreviewNoUpgrade feature set.
+var techPreviewFeatureGates = map[string]bool{
+	volumeAttributesClassFeatureGate: true,
+}
+
+// featureGateEnabled returns true if the feature gate is enabled in the cluster FeatureGate, either by the
+// TechPreviewNoUpgrade feature set or explicitly by the CustomNoUpgrade feature set.
+func featureGateEnabled(featureGateLister configlisters.FeatureGateLister, name string) (bool, error) {
+	featureGate, err := featureGateLister.Get(featureGateName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get FeatureGate %s: %w", featureGateName, err)
+	}
+	switch featureGate.Spec.FeatureSet {
+	case configv1.TechPreviewNoUpgrade:
+		return techPreviewFeatureGates[name], nil
+	case configv1.CustomNoUpgrade:
+		if featureGate.Spec.CustomNoUpgrade == nil {
+			return false, nil
+		}
+		for _, disabled := range featureGate.Spec.CustomNoUpgrade.Disabled {
+			if disabled == name {
+				return false, nil
+			}
+		}
+		for _, enabled := range featureGate.Spec.CustomNoUpgrade.Enabled {
+			if enabled == name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// featureGateEnabledFunc returns a function that reports whether the feature gate is enabled. It's used to
+// create or delete the static resources of a feature.
+func featureGateEnabledFunc(featureGateLister configlisters.FeatureGateLister, name string) resourceapply.ConditionalFunction {
+	return func() bool {
+		enabled, err := featureGateEnabled(featureGateLister, name)
+		if err != nil {
+			klog.Warningf("Failed to check feature gate %s, assuming it's disabled: %v", name, err)
+			return false
+		}
+		return enabled
+	}
+}
@@ -0,0 +1,117 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+const (
+	// The install-config of the cluster, with its fips field, is kept in this ConfigMap.
+	installConfigNamespace = "kube-system"
+	installConfigName      = "cluster-config-v1"
+	installConfigKey       = "install-config"
+
+	fipsMinTLSVersion = "VersionTLS12"
+)
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved by FIPS 140-2 that kube-rbac-proxy supports, in
+// the order of the Intermediate TLS security profile.
+var fipsCipherSuites = []string{
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+}
+
+// fipsTLSVersions are the TLS versions allowed in FIPS mode.
+var fipsTLSVersions = sets.NewString("VersionTLS12", "VersionTLS13")
+
+// isFIPSEnabled returns true when the cluster was installed in FIPS mode, i.e. "fips: true" is set in its
+// install-config. A cluster without install-config, e.g. a hosted cluster that doesn't publish it, is not in
+// FIPS mode.
+func isFIPSEnabled(configMapLister corev1listers.ConfigMapNamespaceLister) (bool, error) {
+	cm, err := configMapLister.Get(installConfigName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get ConfigMap %s/%s: %w", installConfigNamespace, installConfigName, err)
+	}
+	installConfig := struct {
+		FIPS bool `json:"fips"`
+	}{}
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(cm.Data[installConfigKey]), 4096).Decode(&installConfig); err != nil {
+		return false, fmt.Errorf("failed to parse %s of ConfigMap %s/%s: %w", installConfigKey, installConfigNamespace, installConfigName, err)
+	}
+	return installConfig.FIPS, nil
+}
+
+// fipsTLSSettings restricts TLS settings to the ones allowed in FIPS mode. It returns the cipher suites without
+// the ones that are not approved, or all approved cipher suites when none is left, and a minimum TLS version of
+// at least TLS 1.2.
+func fipsTLSSettings(minTLSVersion string, cipherSuites []string) (string, []string) {
+	if !fipsTLSVersions.Has(minTLSVersion) {
+		minTLSVersion = fipsMinTLSVersion
+	}
+	approved := sets.NewString(fipsCipherSuites...)
+	var fipsSuites []string
+	for _, suite := range cipherSuites {
+		if approved.Has(suite) {
+			fipsSuites = append(fipsSuites, suite)
+		}
+	}
+	if len(fipsSuites) == 0 {
+		fipsSuites = fipsCipherSuites
+	}
+	return minTLSVersion, fipsSuites
+}
+
+// withFIPSDeploymentHook restricts the TLS settings of the kube-rbac-proxy containers to the ones allowed in
+// FIPS mode when the cluster is installed in FIPS mode. It must run after withTLSSecurityProfileDeploymentHook,
+// whose settings it restricts.
+func withFIPSDeploymentHook(installConfigLister corev1listers.ConfigMapNamespaceLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		return setFIPSTLSSettings(installConfigLister, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withFIPSDaemonSetHook is withFIPSDeploymentHook for the node DaemonSet. It must run after
+// withTLSSecurityProfileDaemonSetHook.
+func withFIPSDaemonSetHook(installConfigLister corev1listers.ConfigMapNamespaceLister) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(_ *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		return setFIPSTLSSettings(installConfigLister, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+func setFIPSTLSSettings(installConfigLister corev1listers.ConfigMapNamespaceLister, podSpec *corev1.PodSpec) error {
+	fips, err := isFIPSEnabled(installConfigLister)
+	if err != nil || !fips {
+		return err
+	}
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if !strings.HasSuffix(container.Name, "kube-rbac-proxy") {
+			continue
+		}
+		minTLSVersion, _ := getContainerArg(container, "tls-min-version")
+		var cipherSuites []string
+		if suites, ok := getContainerArg(container, "tls-cipher-suites"); ok {
+			cipherSuites = strings.Split(suites, ",")
+		}
+		minTLSVersion, cipherSuites = fipsTLSSettings(minTLSVersion, cipherSuites)
+		setContainerArg(container, "tls-min-version", minTLSVersion)
+		setContainerArg(container, "tls-cipher-suites", strings.Join(cipherSuites, ","))
+	}
+	return nil
+}
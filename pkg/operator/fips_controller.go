@@ -0,0 +1,151 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// fipsController reports the configuration that is not compatible with FIPS mode when the cluster is installed
+// in FIPS mode: a TLS security profile of the cluster APIServer with TLS versions or cipher suites that are not
+// allowed, which the kube-rbac-proxy sidecars don't use (see withFIPSDeploymentHook), and AWS service endpoints
+// that don't use TLS.
+//
+// It produces the following conditions:
+// <name>FIPSIncompatible: True when the configuration is not compatible with FIPS mode, with the reasons in the
+// message. It's removed when the cluster is not in FIPS mode.
+// <name>Degraded: produced when the sync() method returns an error.
+type fipsController struct {
+	name                string
+	operatorClient      v1helpers.OperatorClient
+	installConfigLister corev1listers.ConfigMapNamespaceLister
+	infraLister         configlistersv1.InfrastructureLister
+}
+
+func newFIPSController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	installConfigInformer coreinformersv1.ConfigMapInformer,
+	infraInformer configinformersv1.InfrastructureInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &fipsController{
+		name:                name,
+		operatorClient:      operatorClient,
+		installConfigLister: installConfigInformer.Lister().ConfigMaps(installConfigNamespace),
+		infraLister:         infraInformer.Lister(),
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		installConfigInformer.Informer(),
+		infraInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *fipsController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	conditionType := c.name + "FIPSIncompatible"
+	fips, err := isFIPSEnabled(c.installConfigLister)
+	if err != nil {
+		return err
+	}
+	if !fips {
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+
+	issues, err := c.incompatibilities(opSpec)
+	if err != nil {
+		return err
+	}
+	cond := opv1.OperatorCondition{
+		Type:   conditionType,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(issues) > 0 {
+		cond.Status = opv1.ConditionTrue
+		cond.Reason = "FIPSIncompatibleConfiguration"
+		cond.Message = "The cluster is in FIPS mode: " + strings.Join(issues, "; ")
+		if old := v1helpers.FindOperatorCondition(opStatus.Conditions, conditionType); old == nil || old.Message != cond.Message {
+			klog.Warning(cond.Message)
+			syncCtx.Recorder().Warning("FIPSIncompatibleConfiguration", cond.Message)
+		}
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// incompatibilities returns what is not compatible with FIPS mode in the configuration.
+func (c *fipsController) incompatibilities(opSpec *opv1.OperatorSpec) ([]string, error) {
+	var issues []string
+	minTLSVersion, cipherSuites, err := observedTLSSecurityProfile(opSpec)
+	if err != nil {
+		return nil, err
+	}
+	if minTLSVersion != "" && !fipsTLSVersions.Has(minTLSVersion) {
+		issues = append(issues, fmt.Sprintf("the minimum TLS version %s of the TLS security profile of APIServer %s is not allowed, the metrics endpoints use %s",
+			minTLSVersion, apiServerName, fipsMinTLSVersion))
+	}
+	var notApproved []string
+	approved := sets.NewString(fipsCipherSuites...)
+	for _, suite := range cipherSuites {
+		if !approved.Has(suite) {
+			notApproved = append(notApproved, suite)
+		}
+	}
+	if len(notApproved) > 0 {
+		issues = append(issues, fmt.Sprintf("the cipher suites %s of the TLS security profile of APIServer %s are not FIPS approved and not used by the metrics endpoints",
+			strings.Join(notApproved, ", "), apiServerName))
+	}
+
+	infra, err := c.infraLister.Get(infrastructureName)
+	if apierrors.IsNotFound(err) {
+		return issues, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.AWS != nil {
+		for _, endpoint := range infra.Status.PlatformStatus.AWS.ServiceEndpoints {
+			if _, ok := serviceEndpointEnvNames[endpoint.Name]; ok && !strings.HasPrefix(strings.ToLower(endpoint.URL), "https://") {
+				issues = append(issues, fmt.Sprintf("the %s service endpoint %s of Infrastructure %s does not use TLS, use an https:// endpoint",
+					endpoint.Name, endpoint.URL, infrastructureName))
+			}
+		}
+	}
+	return issues, nil
+}
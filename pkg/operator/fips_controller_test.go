@@ -0,0 +1,93 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFIPSController(t *testing.T) {
+	tests := []struct {
+		name              string
+		installConfig     string
+		observedConfig    string
+		endpoints         []configv1.AWSServiceEndpoint
+		expectedCondition opv1.ConditionStatus
+		expectedMessage   string
+	}{
+		{
+			name:          "not FIPS",
+			installConfig: "fips: false\n",
+			endpoints:     []configv1.AWSServiceEndpoint{{Name: "ec2", URL: "http://ec2.example.com"}},
+		},
+		{
+			name:              "compatible",
+			installConfig:     "fips: true\n",
+			observedConfig:    `{"targetcsiconfig": {"servingInfo": {"minTLSVersion": "VersionTLS12", "cipherSuites": ["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"]}}}`,
+			endpoints:         []configv1.AWSServiceEndpoint{{Name: "ec2", URL: "https://ec2.example.com"}},
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "incompatible",
+			installConfig:     "fips: true\n",
+			observedConfig:    `{"targetcsiconfig": {"servingInfo": {"minTLSVersion": "VersionTLS10", "cipherSuites": ["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_AES_128_CBC_SHA"]}}}`,
+			endpoints:         []configv1.AWSServiceEndpoint{{Name: "ec2", URL: "http://ec2.example.com"}, {Name: "elasticloadbalancing", URL: "http://elb.example.com"}},
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage: "The cluster is in FIPS mode: " +
+				"the minimum TLS version VersionTLS10 of the TLS security profile of APIServer cluster is not allowed, the metrics endpoints use VersionTLS12; " +
+				"the cipher suites TLS_RSA_WITH_AES_128_CBC_SHA of the TLS security profile of APIServer cluster are not FIPS approved and not used by the metrics endpoints; " +
+				"the ec2 service endpoint http://ec2.example.com of Infrastructure cluster does not use TLS, use an https:// endpoint",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{AWS: &configv1.AWSPlatformStatus{ServiceEndpoints: test.endpoints}},
+				},
+			}
+			infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+			infraInformer.Informer().GetIndexer().Add(infra)
+
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			spec.ObservedConfig.Raw = []byte(test.observedConfig)
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+			c := &fipsController{
+				name:                "Test",
+				operatorClient:      operatorClient,
+				installConfigLister: newTestInstallConfigLister(test.installConfig),
+				infraLister:         infraInformer.Lister(),
+			}
+
+			recorder := events.NewInMemoryRecorder("test")
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", recorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestFIPSIncompatible")
+			if test.expectedCondition == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != test.expectedCondition || cond.Message != test.expectedMessage {
+				t.Errorf("expected FIPSIncompatible=%s with message %q, got %+v", test.expectedCondition, test.expectedMessage, cond)
+			}
+			if test.expectedCondition == opv1.ConditionTrue && len(recorder.Events()) != 1 {
+				t.Errorf("expected one event, got %d", len(recorder.Events()))
+			}
+		})
+	}
+}
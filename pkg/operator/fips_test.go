@@ -0,0 +1,128 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+func newTestInstallConfigLister(installConfig string) corev1listers.ConfigMapNamespaceLister {
+	informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().ConfigMaps()
+	if installConfig != "" {
+		informer.Informer().GetIndexer().Add(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: installConfigName, Namespace: installConfigNamespace},
+			Data:       map[string]string{installConfigKey: installConfig},
+		})
+	}
+	return informer.Lister().ConfigMaps(installConfigNamespace)
+}
+
+func TestIsFIPSEnabled(t *testing.T) {
+	tests := []struct {
+		name          string
+		installConfig string
+		expected      bool
+		expectedError bool
+	}{
+		{
+			name: "no install-config",
+		},
+		{
+			name:          "FIPS",
+			installConfig: "apiVersion: v1\nbaseDomain: example.com\nfips: true\nplatform:\n  aws:\n    region: us-east-1\n",
+			expected:      true,
+		},
+		{
+			name:          "not FIPS",
+			installConfig: "apiVersion: v1\nbaseDomain: example.com\n",
+		},
+		{
+			name:          "malformed",
+			installConfig: "fips: [",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fips, err := isFIPSEnabled(newTestInstallConfigLister(test.installConfig))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fips != test.expected {
+				t.Errorf("expected FIPS %v, got %v", test.expected, fips)
+			}
+		})
+	}
+}
+
+func TestWithFIPSDeploymentHook(t *testing.T) {
+	newDeployment := func(proxyArgs ...string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: "csi-driver", Args: []string{"controller"}},
+			{Name: "driver-kube-rbac-proxy", Args: append([]string{"--secure-listen-address=0.0.0.0:9201"}, proxyArgs...)},
+		}
+		return deployment
+	}
+
+	tests := []struct {
+		name          string
+		installConfig string
+		deployment    *appsv1.Deployment
+		expected      *appsv1.Deployment
+	}{
+		{
+			name:          "not FIPS",
+			installConfig: "fips: false\n",
+			deployment:    newDeployment("--tls-cipher-suites=TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305", "--tls-min-version=VersionTLS11"),
+			expected:      newDeployment("--tls-cipher-suites=TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305", "--tls-min-version=VersionTLS11"),
+		},
+		{
+			name:          "default cipher suites",
+			installConfig: "fips: true\n",
+			deployment:    newDeployment("--tls-cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305"),
+			expected:      newDeployment("--tls-cipher-suites=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "--tls-min-version=VersionTLS12"),
+		},
+		{
+			name:          "no approved cipher suite",
+			installConfig: "fips: true\n",
+			deployment:    newDeployment("--tls-cipher-suites=TLS_RSA_WITH_AES_128_CBC_SHA", "--tls-min-version=VersionTLS10"),
+			expected: newDeployment(
+				"--tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+				"--tls-min-version=VersionTLS12",
+			),
+		},
+		{
+			name:          "TLS 1.3",
+			installConfig: "fips: true\n",
+			deployment:    newDeployment("--tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "--tls-min-version=VersionTLS13"),
+			expected:      newDeployment("--tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "--tls-min-version=VersionTLS13"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := withFIPSDeploymentHook(newTestInstallConfigLister(test.installConfig))(&opv1.OperatorSpec{}, test.deployment)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.deployment, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected.Spec.Template.Spec.Containers, test.deployment.Spec.Template.Spec.Containers)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	storageinformersv1 "k8s.io/client-go/informers/storage/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// volumeTypeAnnotation requests a new volume type on a PVC. The volume modifier sets it on the PV
+	// when the volume is modified.
+	volumeTypeAnnotation = "ebs.csi.aws.com/volumeType"
+
+	defaultGP2MigrationMaxInProgress = 5
+)
+
+// gp2MigrationController converts the gp2 volumes of the driver to gp3 when enabled in the driver configuration.
+// It annotates the PVCs of the gp2 volumes with the gp3 volume type, a few at a time, and the volume modifier
+// sidecar modifies the volumes in AWS. A volume is gp2 when its StorageClass has the gp2 type and it was
+// not modified yet. The progress is tracked through the volume type annotation the volume modifier sets on PVs.
+//
+// It produces the following conditions:
+// <name>GP2MigrationComplete: False while gp2 volumes are left, with the progress in the message.
+// <name>Degraded: produced when the sync() method returns an error.
+type gp2MigrationController struct {
+	name               string
+	operatorClient     v1helpers.OperatorClient
+	kubeClient         kubeclient.Interface
+	pvLister           corev1listers.PersistentVolumeLister
+	pvcLister          corev1listers.PersistentVolumeClaimLister
+	storageClassLister storagelisters.StorageClassLister
+	// volumeModifierEnabled reports whether the volume modifier sidecar is deployed.
+	volumeModifierEnabled resourceapply.ConditionalFunction
+}
+
+func newGP2MigrationController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubeclient.Interface,
+	pvInformer coreinformersv1.PersistentVolumeInformer,
+	pvcInformer coreinformersv1.PersistentVolumeClaimInformer,
+	storageClassInformer storageinformersv1.StorageClassInformer,
+	volumeModifierEnabled resourceapply.ConditionalFunction,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &gp2MigrationController{
+		name:                  name,
+		operatorClient:        operatorClient,
+		kubeClient:            kubeClient,
+		pvLister:              pvInformer.Lister(),
+		pvcLister:             pvcInformer.Lister(),
+		storageClassLister:    storageClassInformer.Lister(),
+		volumeModifierEnabled: volumeModifierEnabled,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		pvInformer.Informer(),
+		pvcInformer.Informer(),
+		storageClassInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *gp2MigrationController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+	conditionType := c.name + "GP2MigrationComplete"
+	if cfg.GP2Migration == nil || !cfg.GP2Migration.Enabled {
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+	maxInProgress := defaultGP2MigrationMaxInProgress
+	if cfg.GP2Migration.MaxInProgress != nil {
+		if *cfg.GP2Migration.MaxInProgress < 1 {
+			return fmt.Errorf("invalid gp2Migration maxInProgress %d: it must be a positive number", *cfg.GP2Migration.MaxInProgress)
+		}
+		maxInProgress = int(*cfg.GP2Migration.MaxInProgress)
+	}
+	if !c.volumeModifierEnabled() {
+		return fmt.Errorf("gp2Migration needs the volume modifier: enable the %s feature gate and set %s", volumeAttributesClassFeatureGate, volumeModifierImageEnvName)
+	}
+
+	pvs, err := c.pvLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	sort.Slice(pvs, func(i, j int) bool { return pvs[i].Name < pvs[j].Name })
+
+	var migrated, inProgress int
+	var pending []*corev1.PersistentVolumeClaim
+	for _, pv := range pvs {
+		if !c.isGP2Volume(pv) {
+			continue
+		}
+		if strings.EqualFold(pv.Annotations[volumeTypeAnnotation], "gp3") {
+			migrated++
+			continue
+		}
+		pvc, err := c.pvcLister.PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(pvc.Annotations[volumeTypeAnnotation], "gp3") {
+			inProgress++
+			continue
+		}
+		pending = append(pending, pvc)
+	}
+
+	total := migrated + inProgress + len(pending)
+	for _, pvc := range pending {
+		if inProgress >= maxInProgress {
+			break
+		}
+		patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:"gp3"}}}`, volumeTypeAnnotation))
+		_, err := c.kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to request gp3 for PVC %s/%s: %w", pvc.Namespace, pvc.Name, err)
+		}
+		klog.V(2).Infof("Requested migration of PVC %s/%s to gp3", pvc.Namespace, pvc.Name)
+		syncCtx.Recorder().Eventf("GP2MigrationStarted", "Requested migration of PVC %s/%s to gp3", pvc.Namespace, pvc.Name)
+		inProgress++
+	}
+
+	cond := opv1.OperatorCondition{
+		Type:    conditionType,
+		Status:  opv1.ConditionTrue,
+		Reason:  "AsExpected",
+		Message: fmt.Sprintf("All %d gp2 volumes were migrated to gp3", total),
+	}
+	if migrated < total {
+		cond.Status = opv1.ConditionFalse
+		cond.Reason = "InProgress"
+		cond.Message = fmt.Sprintf("Migrated %d of %d gp2 volumes to gp3, %d in progress", migrated, total, inProgress)
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// isGP2Volume returns true if the bound PV is a volume of the driver provisioned by a gp2 StorageClass.
+func (c *gp2MigrationController) isGP2Volume(pv *corev1.PersistentVolume) bool {
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName || pv.Spec.ClaimRef == nil || pv.Status.Phase != corev1.VolumeBound {
+		return false
+	}
+	if pv.Spec.StorageClassName == "" {
+		return false
+	}
+	sc, err := c.storageClassLister.Get(pv.Spec.StorageClassName)
+	if err != nil {
+		// The type of volumes of deleted StorageClasses is not known.
+		return false
+	}
+	return strings.EqualFold(sc.Parameters["type"], "gp2")
+}
@@ -0,0 +1,180 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestMigrationVolume(name, storageClass, pvType, pvcType string) (*corev1.PersistentVolume, *corev1.PersistentVolumeClaim) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Annotations: map[string]string{}},
+	}
+	if pvcType != "" {
+		pvc.Annotations[volumeTypeAnnotation] = pvcType
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-" + name, Annotations: map[string]string{}},
+		Spec: corev1.PersistentVolumeSpec{
+			StorageClassName: storageClass,
+			ClaimRef:         &corev1.ObjectReference{Namespace: "test", Name: name},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: driverName, VolumeHandle: "vol-" + name},
+			},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+	if pvType != "" {
+		pv.Annotations[volumeTypeAnnotation] = pvType
+	}
+	return pv, pvc
+}
+
+func TestGP2MigrationController(t *testing.T) {
+	storageClasses := []*storagev1.StorageClass{
+		{ObjectMeta: metav1.ObjectMeta{Name: "gp2-csi"}, Provisioner: driverName, Parameters: map[string]string{"type": "gp2"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gp3-csi"}, Provisioner: driverName, Parameters: map[string]string{"type": "gp3"}},
+	}
+	migratedPV, migratedPVC := newTestMigrationVolume("migrated", "gp2-csi", "gp3", "gp3")
+	inProgressPV, inProgressPVC := newTestMigrationVolume("in-progress", "gp2-csi", "", "gp3")
+	pendingPV1, pendingPVC1 := newTestMigrationVolume("pending-1", "gp2-csi", "", "")
+	pendingPV2, pendingPVC2 := newTestMigrationVolume("pending-2", "gp2-csi", "", "")
+	gp3PV, gp3PVC := newTestMigrationVolume("gp3", "gp3-csi", "", "")
+
+	tests := []struct {
+		name                  string
+		overrides             string
+		volumeModifierEnabled bool
+		pvs                   []*corev1.PersistentVolume
+		pvcs                  []*corev1.PersistentVolumeClaim
+		expectedGP3PVCs       []string
+		expectedCondition     opv1.ConditionStatus
+		expectedError         bool
+	}{
+		{
+			name:            "disabled",
+			pvs:             []*corev1.PersistentVolume{pendingPV1},
+			pvcs:            []*corev1.PersistentVolumeClaim{pendingPVC1},
+			expectedGP3PVCs: []string{},
+		},
+		{
+			name:                  "migration in progress",
+			overrides:             `{"gp2Migration": {"enabled": true, "maxInProgress": 2}}`,
+			volumeModifierEnabled: true,
+			pvs:                   []*corev1.PersistentVolume{migratedPV, inProgressPV, pendingPV1, pendingPV2, gp3PV},
+			pvcs:                  []*corev1.PersistentVolumeClaim{migratedPVC, inProgressPVC, pendingPVC1, pendingPVC2, gp3PVC},
+			// pending-2 waits for a free slot, gp3 volumes are not touched.
+			expectedGP3PVCs:   []string{"in-progress", "migrated", "pending-1"},
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:                  "migration complete",
+			overrides:             `{"gp2Migration": {"enabled": true}}`,
+			volumeModifierEnabled: true,
+			pvs:                   []*corev1.PersistentVolume{migratedPV, gp3PV},
+			pvcs:                  []*corev1.PersistentVolumeClaim{migratedPVC, gp3PVC},
+			expectedGP3PVCs:       []string{"migrated"},
+			expectedCondition:     opv1.ConditionTrue,
+		},
+		{
+			name:          "volume modifier not deployed",
+			overrides:     `{"gp2Migration": {"enabled": true}}`,
+			pvs:           []*corev1.PersistentVolume{pendingPV1},
+			pvcs:          []*corev1.PersistentVolumeClaim{pendingPVC1},
+			expectedError: true,
+		},
+		{
+			name:                  "invalid maxInProgress",
+			overrides:             `{"gp2Migration": {"enabled": true, "maxInProgress": 0}}`,
+			volumeModifierEnabled: true,
+			expectedError:         true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, pvc := range test.pvcs {
+				objects = append(objects, pvc.DeepCopy())
+			}
+			kubeClient := fake.NewSimpleClientset(objects...)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			for _, pv := range test.pvs {
+				informerFactory.Core().V1().PersistentVolumes().Informer().GetIndexer().Add(pv)
+			}
+			for _, pvc := range test.pvcs {
+				informerFactory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().Add(pvc)
+			}
+			for _, sc := range storageClasses {
+				informerFactory.Storage().V1().StorageClasses().Informer().GetIndexer().Add(sc)
+			}
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+			c := &gp2MigrationController{
+				name:                  "Test",
+				operatorClient:        operatorClient,
+				kubeClient:            kubeClient,
+				pvLister:              informerFactory.Core().V1().PersistentVolumes().Lister(),
+				pvcLister:             informerFactory.Core().V1().PersistentVolumeClaims().Lister(),
+				storageClassLister:    informerFactory.Storage().V1().StorageClasses().Lister(),
+				volumeModifierEnabled: func() bool { return test.volumeModifierEnabled },
+			}
+
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			pvcs, err := kubeClient.CoreV1().PersistentVolumeClaims("test").List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			gp3PVCs := []string{}
+			for _, pvc := range pvcs.Items {
+				if pvc.Annotations[volumeTypeAnnotation] == "gp3" {
+					gp3PVCs = append(gp3PVCs, pvc.Name)
+				}
+			}
+			if len(gp3PVCs) != len(test.expectedGP3PVCs) {
+				t.Fatalf("expected gp3 PVCs %v, got %v", test.expectedGP3PVCs, gp3PVCs)
+			}
+			for i := range gp3PVCs {
+				if gp3PVCs[i] != test.expectedGP3PVCs[i] {
+					t.Errorf("expected gp3 PVCs %v, got %v", test.expectedGP3PVCs, gp3PVCs)
+					break
+				}
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestGP2MigrationComplete")
+			if test.expectedCondition == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != test.expectedCondition {
+				t.Errorf("expected GP2MigrationComplete=%s, got %+v", test.expectedCondition, cond)
+			}
+		})
+	}
+}
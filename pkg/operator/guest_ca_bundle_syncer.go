@@ -0,0 +1,110 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// guestCABundleSyncer copies the custom AWS CA bundle from the control plane namespace in the management cluster
+// to the guest cluster on Hypershift, so the node DaemonSet can use it. The copy is named like the cloud config
+// ConfigMap synced on standalone clusters, so the node DaemonSet hook does not need to know where it runs.
+// The copy is removed when the control plane has no custom CA bundle.
+type guestCABundleSyncer struct {
+	operatorClient       v1helpers.OperatorClient
+	controlPlaneCMLister corev1listers.ConfigMapNamespaceLister
+	guestNamespace       string
+	guestKubeClient      kubeclient.Interface
+	guestConfigMapLister corev1listers.ConfigMapNamespaceLister
+}
+
+func newGuestCABundleSyncer(
+	operatorClient v1helpers.OperatorClient,
+	controlPlaneNamespace string,
+	controlPlaneConfigMapInformer coreinformersv1.ConfigMapInformer,
+	guestNamespace string,
+	guestKubeClient kubeclient.Interface,
+	guestConfigMapInformer coreinformersv1.ConfigMapInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &guestCABundleSyncer{
+		operatorClient:       operatorClient,
+		controlPlaneCMLister: controlPlaneConfigMapInformer.Lister().ConfigMaps(controlPlaneNamespace),
+		guestNamespace:       guestNamespace,
+		guestKubeClient:      guestKubeClient,
+		guestConfigMapLister: guestConfigMapInformer.Lister().ConfigMaps(guestNamespace),
+	}
+	return factory.New().WithSync(
+		traceSync("AWSEBSDriverGuestCABundleSyncer", c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		controlPlaneConfigMapInformer.Informer(),
+		guestConfigMapInformer.Informer(),
+	).ToController(
+		"AWSEBSDriverGuestCABundleSyncer",
+		eventRecorder,
+	)
+}
+
+func (c *guestCABundleSyncer) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	configName, err := customAWSCABundle(true, c.controlPlaneCMLister)
+	if err != nil {
+		return err
+	}
+	if configName == "" {
+		return c.removeGuestCABundle(ctx)
+	}
+
+	src, err := c.controlPlaneCMLister.Get(configName)
+	if err != nil {
+		return err
+	}
+	required := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cloudConfigName,
+			Namespace: c.guestNamespace,
+		},
+		Data: map[string]string{
+			caBundleKey: src.Data[caBundleKey],
+		},
+	}
+	_, _, err = resourceapply.ApplyConfigMap(ctx, c.guestKubeClient.CoreV1(), syncCtx.Recorder(), required)
+	return err
+}
+
+func (c *guestCABundleSyncer) removeGuestCABundle(ctx context.Context) error {
+	if _, err := c.guestConfigMapLister.Get(cloudConfigName); apierrors.IsNotFound(err) {
+		return nil
+	}
+	err := c.guestKubeClient.CoreV1().ConfigMaps(c.guestNamespace).Delete(ctx, cloudConfigName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.V(2).Infof("Deleted ConfigMap %s/%s", c.guestNamespace, cloudConfigName)
+	return nil
+}
@@ -0,0 +1,97 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGuestCABundleSyncer(t *testing.T) {
+	const controlPlaneNamespace = "clusters-test"
+	tests := []struct {
+		name         string
+		controlPlane []*corev1.ConfigMap
+		guest        []*corev1.ConfigMap
+		expected     *corev1.ConfigMap
+	}{
+		{
+			name: "no custom CA bundle",
+		},
+		{
+			name: "custom CA bundle is copied",
+			controlPlane: []*corev1.ConfigMap{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: controlPlaneNamespace, Name: "user-ca-bundle"},
+				Data:       map[string]string{"ca-bundle.pem": "a custom bundle", "other": "data"},
+			}},
+			expected: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: defaultNamespace, Name: cloudConfigName},
+				Data:       map[string]string{"ca-bundle.pem": "a custom bundle"},
+			},
+		},
+		{
+			name: "stale copy is removed",
+			guest: []*corev1.ConfigMap{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: defaultNamespace, Name: cloudConfigName},
+				Data:       map[string]string{"ca-bundle.pem": "an old bundle"},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			controlPlaneObjects := []runtime.Object{}
+			for _, cm := range test.controlPlane {
+				controlPlaneObjects = append(controlPlaneObjects, cm)
+			}
+			controlPlaneClient := fake.NewSimpleClientset(controlPlaneObjects...)
+			controlPlaneInformers := informers.NewSharedInformerFactory(controlPlaneClient, 0)
+			for _, cm := range test.controlPlane {
+				controlPlaneInformers.Core().V1().ConfigMaps().Informer().GetIndexer().Add(cm)
+			}
+			guestObjects := []runtime.Object{}
+			for _, cm := range test.guest {
+				guestObjects = append(guestObjects, cm)
+			}
+			guestClient := fake.NewSimpleClientset(guestObjects...)
+			guestInformers := informers.NewSharedInformerFactory(guestClient, 0)
+			for _, cm := range test.guest {
+				guestInformers.Core().V1().ConfigMaps().Informer().GetIndexer().Add(cm)
+			}
+
+			c := &guestCABundleSyncer{
+				operatorClient:       v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil),
+				controlPlaneCMLister: controlPlaneInformers.Core().V1().ConfigMaps().Lister().ConfigMaps(controlPlaneNamespace),
+				guestNamespace:       defaultNamespace,
+				guestKubeClient:      guestClient,
+				guestConfigMapLister: guestInformers.Core().V1().ConfigMaps().Lister().ConfigMaps(defaultNamespace),
+			}
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			cm, err := guestClient.CoreV1().ConfigMaps(defaultNamespace).Get(context.TODO(), cloudConfigName, metav1.GetOptions{})
+			if test.expected == nil {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected the guest ConfigMap to be removed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get the guest ConfigMap: %v", err)
+			}
+			if cm.Data[caBundleKey] != test.expected.Data[caBundleKey] || len(cm.Data) != len(test.expected.Data) {
+				t.Errorf("unexpected guest ConfigMap data: %v", cm.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	controllerServiceAccountName = "aws-ebs-csi-driver-controller-sa"
+
+	// The Secret with the kubeconfig of the guest cluster that the CSI sidecars of the controller use on Hypershift.
+	guestKubeconfigSecretName = "aws-ebs-csi-driver-controller-kubeconfig"
+	guestKubeconfigKey        = "kubeconfig"
+	guestTokenKey             = "token"
+	guestKubeconfigMountPath  = "/etc/hosted-kubernetes"
+	// guestKubeconfigHashAnnotation holds the hash of the kubeconfig of the CSI sidecars on the controller pods.
+	guestKubeconfigHashAnnotation = "operator.openshift.io/dep-guest-kubeconfig"
+
+	// guestTokenExpiration is the requested lifetime of the token, it's rotated after tokenRefreshRatio of it.
+	guestTokenExpiration = 24 * time.Hour
+)
+
+// guestKubeconfigController provides the CSI sidecars of the controller on Hypershift with a kubeconfig of the
+// controller ServiceAccount of the guest cluster, so they get only the permissions of its RBAC instead of the
+// admin kubeconfig of the hosted control plane. It requests a token of the ServiceAccount and stores it with
+// the kubeconfig in a Secret in the control plane namespace. The kubeconfig reads the token from its file, so
+// the sidecars pick up the rotated token without a restart when the kubelet updates the Secret volume.
+// The token is rotated after 80% of its lifetime, like the kubelet does for projected tokens. The kubeconfig is
+// rewritten when the CA of the guest cluster rotates and the sidecars, which read it only at start, are rolled
+// out by withGuestKubeconfigHashHook.
+type guestKubeconfigController struct {
+	operatorClient        v1helpers.OperatorClient
+	controlPlaneNamespace string
+	controlPlaneClient    kubeclient.Interface
+	secretLister          corev1listers.SecretNamespaceLister
+	guestNamespace        string
+	guestKubeClient       kubeclient.Interface
+	guestKubeConfig       func() *rest.Config
+	now                   func() time.Time
+}
+
+func newGuestKubeconfigController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	controlPlaneNamespace string,
+	controlPlaneClient kubeclient.Interface,
+	secretInformer coreinformersv1.SecretInformer,
+	guestNamespace string,
+	guestKubeClient kubeclient.Interface,
+	guestKubeConfig func() *rest.Config,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &guestKubeconfigController{
+		operatorClient:        operatorClient,
+		controlPlaneNamespace: controlPlaneNamespace,
+		controlPlaneClient:    controlPlaneClient,
+		secretLister:          secretInformer.Lister().Secrets(controlPlaneNamespace),
+		guestNamespace:        guestNamespace,
+		guestKubeClient:       guestKubeClient,
+		guestKubeConfig:       guestKubeConfig,
+		now:                   time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		secretInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *guestKubeconfigController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	kubeconfig, err := c.kubeconfig()
+	if err != nil {
+		return err
+	}
+	secret, err := c.secretLister.Get(guestKubeconfigSecretName)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && bytes.Equal(secret.Data[guestKubeconfigKey], kubeconfig) && !c.needsRotation(secret.Data[guestTokenKey]) &&
+		secret.Labels[hostedControlPlanePodLabel] == c.controlPlaneNamespace {
+		return nil
+	}
+
+	tokenRequest, err := c.guestKubeClient.CoreV1().ServiceAccounts(c.guestNamespace).CreateToken(ctx, controllerServiceAccountName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: pointer.Int64(int64(guestTokenExpiration.Seconds())),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not request a token of ServiceAccount %s/%s: %w", c.guestNamespace, controllerServiceAccountName, err)
+	}
+	required := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      guestKubeconfigSecretName,
+			Namespace: c.controlPlaneNamespace,
+			Labels:    hostedControlPlaneLabels(c.controlPlaneNamespace),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			guestKubeconfigKey: kubeconfig,
+			guestTokenKey:      []byte(tokenRequest.Status.Token),
+		},
+	}
+	if _, _, err := resourceapply.ApplySecret(ctx, c.controlPlaneClient.CoreV1(), syncCtx.Recorder(), required); err != nil {
+		return err
+	}
+	klog.V(2).Infof("Rotated the token of ServiceAccount %s/%s in Secret %s/%s, it expires at %s",
+		c.guestNamespace, controllerServiceAccountName, c.controlPlaneNamespace, guestKubeconfigSecretName, tokenRequest.Status.ExpirationTimestamp)
+	return nil
+}
+
+// needsRotation returns true when the token is missing, invalid or after tokenRefreshRatio of its lifetime.
+func (c *guestKubeconfigController) needsRotation(token []byte) bool {
+	issuedAt, expiresAt, err := parseTokenValidity(string(token))
+	if err != nil || issuedAt.IsZero() {
+		return true
+	}
+	lifetime := expiresAt.Sub(issuedAt)
+	return !c.now().Before(issuedAt.Add(time.Duration(float64(lifetime) * tokenRefreshRatio)))
+}
+
+// kubeconfig returns the kubeconfig of the controller ServiceAccount, with the API server and CA of the guest
+// cluster from the current kubeconfig of the operator, so it follows the rotation of the CA.
+func (c *guestKubeconfigController) kubeconfig() ([]byte, error) {
+	guestKubeConfig := c.guestKubeConfig()
+	caData := guestKubeConfig.CAData
+	if len(caData) == 0 && guestKubeConfig.CAFile != "" {
+		var err error
+		caData, err = os.ReadFile(guestKubeConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the CA of the guest cluster: %w", err)
+		}
+	}
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters["guest"] = &clientcmdapi.Cluster{
+		Server:                   guestKubeConfig.Host,
+		CertificateAuthorityData: caData,
+	}
+	config.AuthInfos[controllerServiceAccountName] = &clientcmdapi.AuthInfo{
+		TokenFile: path.Join(guestKubeconfigMountPath, guestTokenKey),
+	}
+	config.Contexts["guest"] = &clientcmdapi.Context{
+		Cluster:   "guest",
+		AuthInfo:  controllerServiceAccountName,
+		Namespace: c.guestNamespace,
+	}
+	config.CurrentContext = "guest"
+	return clientcmd.Write(*config)
+}
+
+// withGuestKubeconfigHashHook annotates the controller pods on Hypershift with the hash of the kubeconfig of the
+// CSI sidecars to roll them out when it changes. The token is left out, the sidecars reload it from its file.
+func withGuestKubeconfigHashHook(isHypershift bool, secretLister corev1listers.SecretNamespaceLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if !isHypershift {
+			return nil
+		}
+		secret, err := secretLister.Get(guestKubeconfigSecretName)
+		if apierrors.IsNotFound(err) {
+			// The guest kubeconfig controller creates it, the pods can't start without it anyway.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+		deployment.Spec.Template.Annotations[guestKubeconfigHashAnnotation] = fmt.Sprintf("%x", sha256.Sum256(secret.Data[guestKubeconfigKey]))
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestGuestKubeconfigController(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	guestKubeConfig := &rest.Config{
+		Host:            "https://kube-apiserver:6443",
+		TLSClientConfig: rest.TLSClientConfig{CAData: []byte("guest-ca")},
+	}
+	kubeconfig, err := (&guestKubeconfigController{guestKubeConfig: func() *rest.Config { return guestKubeConfig }, guestNamespace: defaultNamespace}).kubeconfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		secret          *corev1.Secret
+		expectedRotated bool
+	}{
+		{
+			name:            "no Secret",
+			expectedRotated: true,
+		},
+		{
+			name:   "valid token",
+			secret: newTestKubeconfigSecret(kubeconfig, newTestToken(now.Add(-time.Hour), now.Add(23*time.Hour))),
+		},
+		{
+			name:            "token to rotate",
+			secret:          newTestKubeconfigSecret(kubeconfig, newTestToken(now.Add(-20*time.Hour), now.Add(4*time.Hour))),
+			expectedRotated: true,
+		},
+		{
+			name:            "changed kubeconfig",
+			secret:          newTestKubeconfigSecret([]byte("old"), newTestToken(now.Add(-time.Hour), now.Add(23*time.Hour))),
+			expectedRotated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if test.secret != nil {
+				objects = append(objects, test.secret)
+			}
+			controlPlaneClient := fake.NewSimpleClientset(objects...)
+			secretInformer := informers.NewSharedInformerFactory(controlPlaneClient, 0).Core().V1().Secrets()
+			for _, obj := range objects {
+				secretInformer.Informer().GetIndexer().Add(obj)
+			}
+
+			newToken := string(newTestToken(now, now.Add(guestTokenExpiration)))
+			guestClient := fake.NewSimpleClientset()
+			guestClient.PrependReactor("create", "serviceaccounts", func(action core.Action) (bool, runtime.Object, error) {
+				create := action.(core.CreateAction)
+				if create.GetSubresource() != "token" || create.GetNamespace() != defaultNamespace {
+					t.Errorf("unexpected action %+v", action)
+				}
+				return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: newToken}}, nil
+			})
+
+			c := &guestKubeconfigController{
+				operatorClient:        v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil),
+				controlPlaneNamespace: "hcp",
+				controlPlaneClient:    controlPlaneClient,
+				secretLister:          secretInformer.Lister().Secrets("hcp"),
+				guestNamespace:        defaultNamespace,
+				guestKubeClient:       guestClient,
+				guestKubeConfig:       func() *rest.Config { return guestKubeConfig },
+				now:                   func() time.Time { return now },
+			}
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if rotated := len(guestClient.Actions()) > 0; rotated != test.expectedRotated {
+				t.Fatalf("expected rotation %v, got %v", test.expectedRotated, rotated)
+			}
+			if !test.expectedRotated {
+				return
+			}
+			secret, err := controlPlaneClient.CoreV1().Secrets("hcp").Get(context.TODO(), guestKubeconfigSecretName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(secret.Data[guestTokenKey]) != newToken {
+				t.Errorf("expected the new token, got %q", secret.Data[guestTokenKey])
+			}
+			config, err := clientcmd.Load(secret.Data[guestKubeconfigKey])
+			if err != nil {
+				t.Fatalf("invalid kubeconfig: %v", err)
+			}
+			cluster := config.Clusters[config.Contexts[config.CurrentContext].Cluster]
+			authInfo := config.AuthInfos[config.Contexts[config.CurrentContext].AuthInfo]
+			if cluster.Server != guestKubeConfig.Host || string(cluster.CertificateAuthorityData) != "guest-ca" {
+				t.Errorf("unexpected cluster %+v", cluster)
+			}
+			if authInfo.TokenFile != "/etc/hosted-kubernetes/token" || authInfo.Token != "" {
+				t.Errorf("expected the token file, got %+v", authInfo)
+			}
+		})
+	}
+}
+
+func newTestKubeconfigSecret(kubeconfig, token []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: guestKubeconfigSecretName, Namespace: "hcp", Labels: hostedControlPlaneLabels("hcp")},
+		Data:       map[string][]byte{guestKubeconfigKey: kubeconfig, guestTokenKey: token},
+	}
+}
+
+func TestWithGuestKubeconfigHashHook(t *testing.T) {
+	secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+	secretLister := secretInformer.Lister().Secrets("hcp")
+	hash := func() string {
+		deployment := &appsv1.Deployment{}
+		if err := withGuestKubeconfigHashHook(true, secretLister)(nil, deployment); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return deployment.Spec.Template.Annotations[guestKubeconfigHashAnnotation]
+	}
+
+	if h := hash(); h != "" {
+		t.Errorf("expected no hash without the Secret, got %q", h)
+	}
+	secretInformer.Informer().GetIndexer().Add(newTestKubeconfigSecret([]byte("kubeconfig"), []byte("token")))
+	initial := hash()
+	if initial == "" {
+		t.Fatalf("expected the hash of the kubeconfig")
+	}
+	secretInformer.Informer().GetIndexer().Update(newTestKubeconfigSecret([]byte("kubeconfig"), []byte("rotated token")))
+	if h := hash(); h != initial {
+		t.Errorf("expected the same hash after the rotation of the token, got %q", h)
+	}
+	secretInformer.Informer().GetIndexer().Update(newTestKubeconfigSecret([]byte("kubeconfig with a new CA"), []byte("token")))
+	if h := hash(); h == initial {
+		t.Errorf("expected a new hash after the change of the kubeconfig")
+	}
+}
@@ -0,0 +1,135 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+// guestKubeconfigCheckInterval is how often the guest kubeconfig file is checked for changes.
+const guestKubeconfigCheckInterval = 30 * time.Second
+
+var errGuestAPIServerChanged = errors.New("the API server of the guest kubeconfig changed")
+
+// guestKubeconfigReloader keeps the clients of the guest cluster on Hypershift working when Hypershift rotates the
+// certificates in the guest kubeconfig file of the operator, without a restart of the operator. The clients use
+// the reloader as their transport, which it rebuilds from the file when the file changes. Requests that are in
+// flight and open watches finish with the previous transport, the next ones use the new one.
+// A change of the API server in the file can't be applied to the clients, it stops the operator.
+type guestKubeconfigReloader struct {
+	path string
+
+	lock      sync.RWMutex
+	content   []byte
+	config    *rest.Config
+	transport http.RoundTripper
+}
+
+// newGuestKubeconfigReloader loads the guest kubeconfig file.
+func newGuestKubeconfigReloader(path string) (*guestKubeconfigReloader, error) {
+	r := &guestKubeconfigReloader{path: path}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ClientConfig returns the config of the clients of the guest cluster, with the reloader as their transport.
+func (r *guestKubeconfigReloader) ClientConfig() *rest.Config {
+	config := r.Config()
+	return &rest.Config{
+		Host:      config.Host,
+		APIPath:   config.APIPath,
+		QPS:       config.QPS,
+		Burst:     config.Burst,
+		Timeout:   config.Timeout,
+		Transport: r,
+	}
+}
+
+// Config returns the config loaded from the current guest kubeconfig file, with its CA and credentials.
+func (r *guestKubeconfigReloader) Config() *rest.Config {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return rest.CopyConfig(r.config)
+}
+
+// RoundTrip sends the request with the transport of the current guest kubeconfig file.
+func (r *guestKubeconfigReloader) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.lock.RLock()
+	transport := r.transport
+	r.lock.RUnlock()
+	return transport.RoundTrip(req)
+}
+
+// reload rebuilds the transport when the guest kubeconfig file changed and returns whether it did.
+func (r *guestKubeconfigReloader) reload() (bool, error) {
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the guest kubeconfig: %w", err)
+	}
+	r.lock.RLock()
+	unchanged := bytes.Equal(content, r.content)
+	r.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	clientConfig, err := clientcmd.NewClientConfigFromBytes(content)
+	if err != nil {
+		return false, fmt.Errorf("failed to load the guest kubeconfig: %w", err)
+	}
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return false, fmt.Errorf("failed to load the guest kubeconfig: %w", err)
+	}
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return false, fmt.Errorf("failed to create the transport of the guest kubeconfig: %w", err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.config != nil && r.config.Host != config.Host {
+		return false, fmt.Errorf("%w from %s to %s", errGuestAPIServerChanged, r.config.Host, config.Host)
+	}
+	r.content = content
+	r.config = config
+	r.transport = transport
+	return true, nil
+}
+
+// Run checks the guest kubeconfig file for changes until the context is done. It returns an error when the API
+// server in the file changed.
+func (r *guestKubeconfigReloader) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var runErr error
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		reloaded, err := r.reload()
+		if errors.Is(err, errGuestAPIServerChanged) {
+			runErr = err
+			cancel()
+			return
+		}
+		if err != nil {
+			// Keep the previous transport, the file may be in the middle of an update.
+			klog.Warningf("Could not reload the guest kubeconfig %s: %v", r.path, err)
+			return
+		}
+		if reloaded {
+			klog.Infof("Reloaded the guest kubeconfig %s", r.path)
+		}
+	}, guestKubeconfigCheckInterval)
+	return runErr
+}
@@ -0,0 +1,84 @@
+package operator
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestGuestKubeconfigReloader(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	writeKubeconfig := func(server, token string) {
+		config := clientcmdapi.NewConfig()
+		config.Clusters["guest"] = &clientcmdapi.Cluster{Server: server, InsecureSkipTLSVerify: true}
+		config.AuthInfos["guest"] = &clientcmdapi.AuthInfo{Token: token}
+		config.Contexts["guest"] = &clientcmdapi.Context{Cluster: "guest", AuthInfo: "guest"}
+		config.CurrentContext = "guest"
+		if err := clientcmd.WriteToFile(*config, path); err != nil {
+			t.Fatalf("failed to write the kubeconfig: %v", err)
+		}
+	}
+	get := func(r *guestKubeconfigReloader) int {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := r.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	writeKubeconfig(server.URL, "old-token")
+	r, err := newGuestKubeconfigReloader(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config := r.ClientConfig(); config.Host != server.URL || config.Transport != r || config.BearerToken != "" {
+		t.Errorf("unexpected client config %+v", config)
+	}
+	if code := get(r); code != http.StatusUnauthorized {
+		t.Errorf("expected the old credentials, got %d", code)
+	}
+
+	if reloaded, err := r.reload(); reloaded || err != nil {
+		t.Errorf("expected no reload of the unchanged file, got %v: %v", reloaded, err)
+	}
+	writeKubeconfig(server.URL, "new-token")
+	if reloaded, err := r.reload(); !reloaded || err != nil {
+		t.Fatalf("expected a reload, got %v: %v", reloaded, err)
+	}
+	if code := get(r); code != http.StatusOK {
+		t.Errorf("expected the new credentials, got %d", code)
+	}
+	if token := r.Config().BearerToken; token != "new-token" {
+		t.Errorf("expected the new config, got token %q", token)
+	}
+
+	if err := os.WriteFile(path, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.reload(); err == nil {
+		t.Errorf("expected an error for an invalid file")
+	}
+	if code := get(r); code != http.StatusOK {
+		t.Errorf("expected the previous credentials after an invalid file, got %d", code)
+	}
+
+	writeKubeconfig("https://another-server:6443", "new-token")
+	if _, err := r.reload(); !errors.Is(err, errGuestAPIServerChanged) {
+		t.Errorf("expected the API server change, got %v", err)
+	}
+}
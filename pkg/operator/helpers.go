@@ -0,0 +1,105 @@
+package operator
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const driverContainerName = "csi-driver"
+
+// getContainer returns the container with the given name or nil when the pod has no such container.
+func getContainer(podSpec *corev1.PodSpec, name string) *corev1.Container {
+	for i := range podSpec.Containers {
+		if podSpec.Containers[i].Name == name {
+			return &podSpec.Containers[i]
+		}
+	}
+	return nil
+}
+
+// setContainerEnv sets the value of an environment variable of the container, replacing any previous value.
+func setContainerEnv(container *corev1.Container, name, value string) {
+	for i := range container.Env {
+		if container.Env[i].Name == name {
+			container.Env[i] = corev1.EnvVar{Name: name, Value: value}
+			return
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+}
+
+// removeContainerEnv removes an environment variable from the container.
+func removeContainerEnv(container *corev1.Container, name string) {
+	env := container.Env[:0]
+	for _, e := range container.Env {
+		if e.Name != name {
+			env = append(env, e)
+		}
+	}
+	container.Env = env
+}
+
+// getContainerEnv returns the value of an environment variable of the container.
+func getContainerEnv(container *corev1.Container, name string) (string, bool) {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value, true
+		}
+	}
+	return "", false
+}
+
+// hasContainerArg returns true if the container has a --name or --name=value argument.
+func hasContainerArg(container *corev1.Container, name string) bool {
+	for _, arg := range container.Args {
+		if arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// getContainerArg returns the value of a --name=value argument of the container and whether it is set.
+func getContainerArg(container *corev1.Container, name string) (string, bool) {
+	for _, arg := range container.Args {
+		if strings.HasPrefix(arg, "--"+name+"=") {
+			return strings.TrimPrefix(arg, "--"+name+"="), true
+		}
+	}
+	return "", false
+}
+
+// setContainerArg sets the value of a --name=value argument of the container, replacing any previous value.
+func setContainerArg(container *corev1.Container, name, value string) {
+	arg := "--" + name + "=" + value
+	for i := range container.Args {
+		if container.Args[i] == "--"+name || strings.HasPrefix(container.Args[i], "--"+name+"=") {
+			container.Args[i] = arg
+			return
+		}
+	}
+	container.Args = append(container.Args, arg)
+}
+
+// addContainerFeatureGate enables a feature gate in the --feature-gates argument of the container,
+// keeping the feature gates that are already set.
+func addContainerFeatureGate(container *corev1.Container, gate string) {
+	prefix := "--feature-gates="
+	for i, arg := range container.Args {
+		if !strings.HasPrefix(arg, prefix) {
+			continue
+		}
+		gates := strings.Split(strings.TrimPrefix(arg, prefix), ",")
+		for j, g := range gates {
+			if name, _, _ := strings.Cut(g, "="); name == gate {
+				gates[j] = gate + "=true"
+				container.Args[i] = prefix + strings.Join(gates, ",")
+				return
+			}
+		}
+		container.Args[i] = arg + "," + gate + "=true"
+		return
+	}
+	container.Args = append(container.Args, prefix+gate+"=true")
+}
@@ -0,0 +1,312 @@
+package operator
+
+import (
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// Values of HostedControlPlane.Spec.ControllerAvailabilityPolicy.
+	highlyAvailablePolicy = "HighlyAvailable"
+	singleReplicaPolicy   = "SingleReplica"
+
+	controllerPDBFile = "controller_pdb.yaml"
+
+	// Hypershift dedicates management cluster nodes to control planes with these labels and taints, and labels
+	// the pods of each control plane with its namespace.
+	controlPlaneNodeLabel      = "hypershift.openshift.io/control-plane"
+	clusterNodeLabel           = "hypershift.openshift.io/cluster"
+	hostedControlPlanePodLabel = "hypershift.openshift.io/hosted-control-plane"
+)
+
+// hostedControlPlaneGVR is the HostedControlPlane of Hypershift, in the control plane namespace of the
+// management cluster.
+var hostedControlPlaneGVR = schema.GroupVersionResource{
+	Group:    "hypershift.openshift.io",
+	Version:  "v1beta1",
+	Resource: "hostedcontrolplanes",
+}
+
+// hostedControlPlaneSpec is the part of HostedControlPlane.Spec used by the operator.
+type hostedControlPlaneSpec struct {
+	ControllerAvailabilityPolicy string              `json:"controllerAvailabilityPolicy,omitempty"`
+	NodeSelector                 map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations                  []corev1.Toleration `json:"tolerations,omitempty"`
+	PausedUntil                  string              `json:"pausedUntil,omitempty"`
+	// AdditionalTrustBundle references a ConfigMap in the control plane namespace with the trust bundle of the
+	// HostedCluster, e.g. of a proxy or of the AWS endpoints that intercept TLS.
+	AdditionalTrustBundle *corev1.LocalObjectReference `json:"additionalTrustBundle,omitempty"`
+	Platform              struct {
+		AWS *struct {
+			ResourceTags []configv1.AWSResourceTag `json:"resourceTags,omitempty"`
+		} `json:"aws,omitempty"`
+	} `json:"platform,omitempty"`
+}
+
+// getHostedControlPlane returns the HostedControlPlane in the control plane namespace, or nil when it was not found.
+func getHostedControlPlane(hcpLister cache.GenericNamespaceLister) (*unstructured.Unstructured, error) {
+	objs, err := hcpLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, nil
+	}
+	if len(objs) > 1 {
+		return nil, fmt.Errorf("found %d HostedControlPlanes in the control plane namespace, expected one", len(objs))
+	}
+	hcp, ok := objs[0].(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected HostedControlPlane type %T", objs[0])
+	}
+	return hcp, nil
+}
+
+// getHostedControlPlaneSpec returns the spec of the HostedControlPlane in the control plane namespace, or nil when
+// the HostedControlPlane was not found.
+func getHostedControlPlaneSpec(hcpLister cache.GenericNamespaceLister) (*hostedControlPlaneSpec, error) {
+	hcp, err := getHostedControlPlane(hcpLister)
+	if err != nil || hcp == nil {
+		return nil, err
+	}
+	rawSpec, _, err := unstructured.NestedMap(hcp.Object, "spec")
+	if err != nil {
+		return nil, fmt.Errorf("invalid HostedControlPlane %s: %w", hcp.GetName(), err)
+	}
+	spec := &hostedControlPlaneSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, spec); err != nil {
+		return nil, fmt.Errorf("invalid HostedControlPlane %s: %w", hcp.GetName(), err)
+	}
+	return spec, nil
+}
+
+// controllerAvailabilityPolicy returns the ControllerAvailabilityPolicy of the HostedControlPlane in the control
+// plane namespace, or "" when the HostedControlPlane was not found or has no policy.
+func controllerAvailabilityPolicy(hcpLister cache.GenericNamespaceLister) (string, error) {
+	spec, err := getHostedControlPlaneSpec(hcpLister)
+	if err != nil || spec == nil {
+		return "", err
+	}
+	return spec.ControllerAvailabilityPolicy, nil
+}
+
+// hostedControlPlaneResourceTags returns the AWS resource tags of the HostedControlPlane in the control plane
+// namespace, the tags of the HostedCluster set by the cluster admin or the managed service.
+func hostedControlPlaneResourceTags(hcpLister cache.GenericNamespaceLister) ([]configv1.AWSResourceTag, error) {
+	spec, err := getHostedControlPlaneSpec(hcpLister)
+	if err != nil || spec == nil || spec.Platform.AWS == nil {
+		return nil, err
+	}
+	return spec.Platform.AWS.ResourceTags, nil
+}
+
+// hostedControlPlanePaused returns true when the reconciliation of the HostedControlPlane is paused: its pausedUntil
+// is "true", or an RFC3339 date in the future. The hosted cluster may be hibernating and its API server unreachable.
+func hostedControlPlanePaused(hcpLister cache.GenericNamespaceLister, now time.Time) (bool, error) {
+	spec, err := getHostedControlPlaneSpec(hcpLister)
+	if err != nil || spec == nil || spec.PausedUntil == "" {
+		return false, err
+	}
+	if spec.PausedUntil == "true" {
+		return true, nil
+	}
+	until, err := time.Parse(time.RFC3339, spec.PausedUntil)
+	if err != nil {
+		return false, fmt.Errorf("invalid pausedUntil %q of the HostedControlPlane, expected true or an RFC3339 date: %w", spec.PausedUntil, err)
+	}
+	return now.Before(until), nil
+}
+
+// withHypershiftReplicasHook sets the replicas of the controller Deployment. On standalone clusters it depends on
+// the number of nodes. On Hypershift, the controller runs 2 replicas when the ControllerAvailabilityPolicy of the
+// HostedControlPlane is HighlyAvailable, and 1 replica otherwise. It's scaled to zero while the HostedControlPlane
+// is paused.
+func withHypershiftReplicasHook(isHypershift bool, guestNodeLister corev1listers.NodeLister, hcpLister cache.GenericNamespaceLister) dc.DeploymentHookFunc {
+	if !isHypershift {
+		return csidrivercontrollerservicecontroller.WithReplicasHook(guestNodeLister)
+	}
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		policy, err := controllerAvailabilityPolicy(hcpLister)
+		if err != nil {
+			return err
+		}
+		paused, err := hostedControlPlanePaused(hcpLister, time.Now())
+		if err != nil {
+			return err
+		}
+		replicas := int32(1)
+		switch {
+		case paused:
+			replicas = 0
+		case policy == highlyAvailablePolicy:
+			replicas = 2
+		}
+		deployment.Spec.Replicas = &replicas
+		return nil
+	}
+}
+
+// controllerPDBEnabled returns true when the controller Deployment needs its PodDisruptionBudget: on standalone
+// clusters except single-node OpenShift, and on Hypershift when the controller is highly available.
+func controllerPDBEnabled(isHypershift bool, hcpLister cache.GenericNamespaceLister, infraLister configlisters.InfrastructureLister) resourceapply.ConditionalFunction {
+	return func() bool {
+		if !isHypershift {
+			singleNode, err := isSingleNodeCluster(infraLister)
+			if err != nil {
+				klog.Warningf("Could not get the control plane topology of the cluster: %v", err)
+				return true
+			}
+			return !singleNode
+		}
+		policy, err := controllerAvailabilityPolicy(hcpLister)
+		if err != nil {
+			klog.Warningf("Could not get the availability policy of the hosted control plane: %v", err)
+			return false
+		}
+		return policy == highlyAvailablePolicy
+	}
+}
+
+// controllerPDBDisabled returns true when the PodDisruptionBudget must be removed, i.e. when the single replica
+// of the controller on Hypershift or on single-node OpenShift would not need it. The PodDisruptionBudget is kept
+// while the policy or the topology is unknown.
+func controllerPDBDisabled(isHypershift bool, hcpLister cache.GenericNamespaceLister, infraLister configlisters.InfrastructureLister) resourceapply.ConditionalFunction {
+	return func() bool {
+		if !isHypershift {
+			singleNode, err := isSingleNodeCluster(infraLister)
+			return err == nil && singleNode
+		}
+		policy, err := controllerAvailabilityPolicy(hcpLister)
+		if err != nil {
+			return false
+		}
+		return policy == singleReplicaPolicy
+	}
+}
+
+// withHostedControlPlaneSchedulingHook schedules the controller pods on Hypershift like the other components of
+// the hosted control plane: with the nodeSelector and tolerations of the HostedControlPlane, tolerating the taints
+// of the nodes dedicated to control planes and to this cluster, preferring those nodes and the nodes of the other
+// pods of the control plane. Highly available controllers prefer to spread their replicas across zones.
+// The node placement of the master nodes in the assets does not apply to the management cluster.
+func withHostedControlPlaneSchedulingHook(isHypershift bool, namespace string, hcpLister cache.GenericNamespaceLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if !isHypershift {
+			return nil
+		}
+		spec, err := getHostedControlPlaneSpec(hcpLister)
+		if err != nil {
+			return err
+		}
+		if spec == nil {
+			spec = &hostedControlPlaneSpec{}
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		podSpec.NodeSelector = spec.NodeSelector
+		podSpec.Tolerations = append([]corev1.Toleration{
+			{Key: controlPlaneNodeLabel, Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule},
+			{Key: clusterNodeLabel, Operator: corev1.TolerationOpEqual, Value: namespace, Effect: corev1.TaintEffectNoSchedule},
+		}, spec.Tolerations...)
+
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &corev1.Affinity{}
+		}
+		podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+				{
+					Weight: 50,
+					Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: controlPlaneNodeLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{"true"}},
+					}},
+				},
+				{
+					Weight: 100,
+					Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: clusterNodeLabel, Operator: corev1.NodeSelectorOpIn, Values: []string{namespace}},
+					}},
+				},
+			},
+		}
+		podSpec.Affinity.PodAffinity = &corev1.PodAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{hostedControlPlanePodLabel: namespace}},
+					TopologyKey:   corev1.LabelHostname,
+				},
+			}},
+		}
+		if spec.ControllerAvailabilityPolicy == highlyAvailablePolicy {
+			if podSpec.Affinity.PodAntiAffinity == nil {
+				podSpec.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+			}
+			podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+				podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+				corev1.WeightedPodAffinityTerm{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: deployment.Spec.Selector,
+						TopologyKey:   corev1.LabelTopologyZone,
+					},
+				},
+			)
+		}
+		return nil
+	}
+}
+
+// pausableOperatorClient reports the operator as Unmanaged while the HostedControlPlane is paused, so the
+// controllers that reconcile the guest cluster, which check the management state, stop until it's resumed.
+// The controller Deployment in the management cluster keeps the real client to be scaled down.
+type pausableOperatorClient struct {
+	v1helpers.OperatorClientWithFinalizers
+	hcpLister cache.GenericNamespaceLister
+	now       func() time.Time
+}
+
+var _ v1helpers.OperatorClientWithFinalizers = &pausableOperatorClient{}
+
+func newPausableOperatorClient(operatorClient v1helpers.OperatorClientWithFinalizers, hcpLister cache.GenericNamespaceLister) *pausableOperatorClient {
+	return &pausableOperatorClient{
+		OperatorClientWithFinalizers: operatorClient,
+		hcpLister:                    hcpLister,
+		now:                          time.Now,
+	}
+}
+
+func (c *pausableOperatorClient) GetOperatorState() (*opv1.OperatorSpec, *opv1.OperatorStatus, string, error) {
+	spec, status, resourceVersion, err := c.OperatorClientWithFinalizers.GetOperatorState()
+	if err != nil || spec.ManagementState != opv1.Managed {
+		return spec, status, resourceVersion, err
+	}
+	paused, err := hostedControlPlanePaused(c.hcpLister, c.now())
+	if err != nil {
+		klog.Warningf("Could not get the paused state of the hosted control plane: %v", err)
+		return spec, status, resourceVersion, nil
+	}
+	if paused {
+		spec = spec.DeepCopy()
+		spec.ManagementState = opv1.Unmanaged
+	}
+	return spec, status, resourceVersion, nil
+}
@@ -0,0 +1,257 @@
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestHCPLister(policies ...string) cache.GenericNamespaceLister {
+	var specs []map[string]interface{}
+	for _, policy := range policies {
+		specs = append(specs, map[string]interface{}{"controllerAvailabilityPolicy": policy})
+	}
+	return newTestHCPListerWithSpecs(specs...)
+}
+
+func newTestHCPListerWithSpecs(specs ...map[string]interface{}) cache.GenericNamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for i, spec := range specs {
+		indexer.Add(newTestHCP(fmt.Sprintf("hcp-%d", i), nil, spec))
+	}
+	return cache.NewGenericLister(indexer, hostedControlPlaneGVR.GroupResource()).ByNamespace("clusters-test")
+}
+
+// newTestHCPListerWithAnnotations returns a lister of a HostedControlPlane with the annotations and spec.
+func newTestHCPListerWithAnnotations(annotations map[string]string, spec map[string]interface{}) cache.GenericNamespaceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(newTestHCP("hcp-0", annotations, spec))
+	return cache.NewGenericLister(indexer, hostedControlPlaneGVR.GroupResource()).ByNamespace("clusters-test")
+}
+
+func newTestHCP(name string, annotations map[string]string, spec map[string]interface{}) *unstructured.Unstructured {
+	hcp := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "hypershift.openshift.io/v1beta1",
+		"kind":       "HostedControlPlane",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "clusters-test"},
+		"spec":       spec,
+	}}
+	hcp.SetAnnotations(annotations)
+	return hcp
+}
+
+func TestWithHypershiftReplicasHook(t *testing.T) {
+	tests := []struct {
+		name               string
+		hcpLister          cache.GenericNamespaceLister
+		expectedReplicas   int32
+		expectedPDB        bool
+		expectedPDBRemoved bool
+		expectedError      bool
+	}{
+		{
+			name:             "no HostedControlPlane",
+			hcpLister:        newTestHCPLister(),
+			expectedReplicas: 1,
+		},
+		{
+			name:               "single replica",
+			hcpLister:          newTestHCPLister(singleReplicaPolicy),
+			expectedReplicas:   1,
+			expectedPDBRemoved: true,
+		},
+		{
+			name:             "highly available",
+			hcpLister:        newTestHCPLister(highlyAvailablePolicy),
+			expectedReplicas: 2,
+			expectedPDB:      true,
+		},
+		{
+			name:             "paused",
+			hcpLister:        newTestHCPListerWithSpecs(map[string]interface{}{"controllerAvailabilityPolicy": highlyAvailablePolicy, "pausedUntil": "true"}),
+			expectedReplicas: 0,
+			expectedPDB:      true,
+		},
+		{
+			name:          "several HostedControlPlanes",
+			hcpLister:     newTestHCPLister(highlyAvailablePolicy, singleReplicaPolicy),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			err := withHypershiftReplicasHook(true, nil, test.hcpLister)(&opv1.OperatorSpec{}, deployment)
+			if pdb := controllerPDBEnabled(true, test.hcpLister, nil)(); pdb != test.expectedPDB {
+				t.Errorf("expected PodDisruptionBudget %v, got %v", test.expectedPDB, pdb)
+			}
+			if removed := controllerPDBDisabled(true, test.hcpLister, nil)(); removed != test.expectedPDBRemoved {
+				t.Errorf("expected PodDisruptionBudget removal %v, got %v", test.expectedPDBRemoved, removed)
+			}
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *deployment.Spec.Replicas != test.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", test.expectedReplicas, *deployment.Spec.Replicas)
+			}
+		})
+	}
+
+}
+
+func TestWithHostedControlPlaneSchedulingHook(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "aws-ebs-csi-driver-controller"}}
+		deployment.Spec.Template.Spec.NodeSelector = map[string]string{"node-role.kubernetes.io/master": ""}
+		deployment.Spec.Template.Spec.Tolerations = []corev1.Toleration{{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists}}
+		return deployment
+	}
+
+	tests := []struct {
+		name                 string
+		isHypershift         bool
+		hcpLister            cache.GenericNamespaceLister
+		expectedNodeSelector map[string]string
+		expectedTolerations  int
+		expectedZoneSpread   bool
+	}{
+		{
+			name:                 "standalone",
+			expectedNodeSelector: map[string]string{"node-role.kubernetes.io/master": ""},
+			expectedTolerations:  1,
+		},
+		{
+			name:                "no HostedControlPlane",
+			isHypershift:        true,
+			hcpLister:           newTestHCPLister(),
+			expectedTolerations: 2,
+		},
+		{
+			name:         "HostedControlPlane scheduling",
+			isHypershift: true,
+			hcpLister: newTestHCPListerWithSpecs(map[string]interface{}{
+				"controllerAvailabilityPolicy": highlyAvailablePolicy,
+				"nodeSelector":                 map[string]interface{}{"node-pool": "control-plane"},
+				"tolerations":                  []interface{}{map[string]interface{}{"key": "dedicated", "operator": "Exists"}},
+			}),
+			expectedNodeSelector: map[string]string{"node-pool": "control-plane"},
+			expectedTolerations:  3,
+			expectedZoneSpread:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := newDeployment()
+			err := withHostedControlPlaneSchedulingHook(test.isHypershift, "clusters-test", test.hcpLister)(&opv1.OperatorSpec{}, deployment)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			podSpec := deployment.Spec.Template.Spec
+			if !reflect.DeepEqual(podSpec.NodeSelector, test.expectedNodeSelector) {
+				t.Errorf("expected nodeSelector %v, got %v", test.expectedNodeSelector, podSpec.NodeSelector)
+			}
+			if len(podSpec.Tolerations) != test.expectedTolerations {
+				t.Errorf("expected %d tolerations, got %+v", test.expectedTolerations, podSpec.Tolerations)
+			}
+			if !test.isHypershift {
+				if podSpec.Affinity != nil {
+					t.Errorf("unexpected affinity %+v", podSpec.Affinity)
+				}
+				return
+			}
+			if podSpec.Tolerations[1].Key != clusterNodeLabel || podSpec.Tolerations[1].Value != "clusters-test" {
+				t.Errorf("expected the toleration of the cluster nodes, got %+v", podSpec.Tolerations[1])
+			}
+			if podSpec.Affinity.NodeAffinity == nil || podSpec.Affinity.PodAffinity == nil {
+				t.Errorf("expected node and pod affinity, got %+v", podSpec.Affinity)
+			}
+			if zoneSpread := podSpec.Affinity.PodAntiAffinity != nil; zoneSpread != test.expectedZoneSpread {
+				t.Errorf("expected zone spread %v, got %+v", test.expectedZoneSpread, podSpec.Affinity.PodAntiAffinity)
+			}
+		})
+	}
+}
+
+func TestHostedControlPlanePaused(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		pausedUntil   string
+		expected      bool
+		expectedError bool
+	}{
+		{
+			name: "not paused",
+		},
+		{
+			name:        "paused",
+			pausedUntil: "true",
+			expected:    true,
+		},
+		{
+			name:        "paused until a later date",
+			pausedUntil: "2024-01-02T00:00:00Z",
+			expected:    true,
+		},
+		{
+			name:        "paused until a past date",
+			pausedUntil: "2024-01-01T00:00:00Z",
+		},
+		{
+			name:          "invalid",
+			pausedUntil:   "tomorrow",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hcpLister := newTestHCPListerWithSpecs(map[string]interface{}{"pausedUntil": test.pausedUntil})
+			paused, err := hostedControlPlanePaused(hcpLister, now)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if paused != test.expected {
+				t.Errorf("expected paused %v, got %v", test.expected, paused)
+			}
+
+			operatorClient := newPausableOperatorClient(v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil), hcpLister)
+			operatorClient.now = func() time.Time { return now }
+			spec, _, _, err := operatorClient.GetOperatorState()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expectedState := opv1.Managed
+			if test.expected {
+				expectedState = opv1.Unmanaged
+			}
+			if spec.ManagementState != expectedState {
+				t.Errorf("expected management state %s, got %s", expectedState, spec.ManagementState)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// hostedTrustBundleConfigMapName is the copy of the additional trust bundle of the HostedControlPlane that
+	// the controller uses as the custom AWS CA bundle.
+	hostedTrustBundleConfigMapName = "aws-ebs-csi-driver-trust-bundle"
+	// hostedTrustBundleKey is the key of the trust bundle in the ConfigMap referenced by the HostedControlPlane.
+	hostedTrustBundleKey = "ca-bundle.crt"
+)
+
+// hostedTrustBundleSyncer copies the additional trust bundle of the HostedControlPlane on Hypershift to the
+// ConfigMap hostedTrustBundleConfigMapName in the control plane namespace, with the key of the custom AWS CA
+// bundle, so the controller and, through the guest CA bundle syncer, the node DaemonSet trust it. The ConfigMap
+// referenced by the HostedControlPlane is owned by Hypershift and has another key, the copy is kept up to date
+// when either of them changes and removed when the HostedControlPlane has no additional trust bundle.
+type hostedTrustBundleSyncer struct {
+	operatorClient        v1helpers.OperatorClient
+	controlPlaneNamespace string
+	controlPlaneClient    kubeclient.Interface
+	configMapLister       corev1listers.ConfigMapNamespaceLister
+	hcpLister             cache.GenericNamespaceLister
+}
+
+func newHostedTrustBundleSyncer(
+	operatorClient v1helpers.OperatorClient,
+	controlPlaneNamespace string,
+	controlPlaneClient kubeclient.Interface,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	hcpInformer cache.SharedIndexInformer,
+	hcpLister cache.GenericNamespaceLister,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &hostedTrustBundleSyncer{
+		operatorClient:        operatorClient,
+		controlPlaneNamespace: controlPlaneNamespace,
+		controlPlaneClient:    controlPlaneClient,
+		configMapLister:       configMapInformer.Lister().ConfigMaps(controlPlaneNamespace),
+		hcpLister:             hcpLister,
+	}
+	return factory.New().WithSync(
+		traceSync("AWSEBSDriverHostedTrustBundleSyncer", c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		configMapInformer.Informer(),
+		hcpInformer,
+	).ToController(
+		"AWSEBSDriverHostedTrustBundleSyncer",
+		eventRecorder,
+	)
+}
+
+func (c *hostedTrustBundleSyncer) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	spec, err := getHostedControlPlaneSpec(c.hcpLister)
+	if err != nil {
+		return err
+	}
+	if spec == nil || spec.AdditionalTrustBundle == nil || spec.AdditionalTrustBundle.Name == "" {
+		return c.removeTrustBundle(ctx)
+	}
+
+	srcName := spec.AdditionalTrustBundle.Name
+	src, err := c.configMapLister.Get(srcName)
+	if err != nil {
+		return fmt.Errorf("failed to get the additional trust bundle ConfigMap %s of the HostedControlPlane: %w", srcName, err)
+	}
+	bundle, ok := src.Data[hostedTrustBundleKey]
+	if !ok {
+		// Accept the key of the custom AWS CA bundle too, like the user-ca-bundle ConfigMap has.
+		bundle, ok = src.Data[caBundleKey]
+	}
+	if !ok || bundle == "" {
+		return fmt.Errorf("the additional trust bundle ConfigMap %s of the HostedControlPlane has no %s key", srcName, hostedTrustBundleKey)
+	}
+
+	required := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hostedTrustBundleConfigMapName,
+			Namespace: c.controlPlaneNamespace,
+			Labels:    hostedControlPlaneLabels(c.controlPlaneNamespace),
+		},
+		Data: map[string]string{
+			caBundleKey: bundle,
+		},
+	}
+	_, _, err = resourceapply.ApplyConfigMap(ctx, c.controlPlaneClient.CoreV1(), syncCtx.Recorder(), required)
+	return err
+}
+
+func (c *hostedTrustBundleSyncer) removeTrustBundle(ctx context.Context) error {
+	if _, err := c.configMapLister.Get(hostedTrustBundleConfigMapName); apierrors.IsNotFound(err) {
+		return nil
+	}
+	err := c.controlPlaneClient.CoreV1().ConfigMaps(c.controlPlaneNamespace).Delete(ctx, hostedTrustBundleConfigMapName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.V(2).Infof("Deleted ConfigMap %s/%s", c.controlPlaneNamespace, hostedTrustBundleConfigMapName)
+	return nil
+}
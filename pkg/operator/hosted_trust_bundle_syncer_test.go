@@ -0,0 +1,131 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHostedTrustBundleSyncer(t *testing.T) {
+	const controlPlaneNamespace = "clusters-test"
+	trustBundleSpec := map[string]interface{}{"additionalTrustBundle": map[string]interface{}{"name": "user-ca-bundle"}}
+	staleCopy := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: controlPlaneNamespace, Name: hostedTrustBundleConfigMapName},
+		Data:       map[string]string{caBundleKey: "an old bundle"},
+	}
+
+	tests := []struct {
+		name           string
+		hcpSpec        map[string]interface{}
+		configMaps     []*corev1.ConfigMap
+		expectedBundle string
+		expectedError  bool
+	}{
+		{
+			name:    "no additional trust bundle",
+			hcpSpec: map[string]interface{}{},
+		},
+		{
+			name:       "stale copy is removed",
+			hcpSpec:    map[string]interface{}{},
+			configMaps: []*corev1.ConfigMap{staleCopy},
+		},
+		{
+			name:    "trust bundle is copied",
+			hcpSpec: trustBundleSpec,
+			configMaps: []*corev1.ConfigMap{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: controlPlaneNamespace, Name: "user-ca-bundle"},
+				Data:       map[string]string{hostedTrustBundleKey: "a trust bundle"},
+			}},
+			expectedBundle: "a trust bundle",
+		},
+		{
+			name:    "changed trust bundle is updated",
+			hcpSpec: trustBundleSpec,
+			configMaps: []*corev1.ConfigMap{staleCopy, {
+				ObjectMeta: metav1.ObjectMeta{Namespace: controlPlaneNamespace, Name: "user-ca-bundle"},
+				Data:       map[string]string{caBundleKey: "a new bundle"},
+			}},
+			expectedBundle: "a new bundle",
+		},
+		{
+			name:          "missing trust bundle ConfigMap",
+			hcpSpec:       trustBundleSpec,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			for _, cm := range test.configMaps {
+				objects = append(objects, cm)
+			}
+			client := fake.NewSimpleClientset(objects...)
+			configMapInformer := informers.NewSharedInformerFactory(client, 0).Core().V1().ConfigMaps()
+			for _, cm := range test.configMaps {
+				configMapInformer.Informer().GetIndexer().Add(cm)
+			}
+
+			c := &hostedTrustBundleSyncer{
+				operatorClient:        v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil),
+				controlPlaneNamespace: controlPlaneNamespace,
+				controlPlaneClient:    client,
+				configMapLister:       configMapInformer.Lister().ConfigMaps(controlPlaneNamespace),
+				hcpLister:             newTestHCPListerWithSpecs(test.hcpSpec),
+			}
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			cm, err := client.CoreV1().ConfigMaps(controlPlaneNamespace).Get(context.TODO(), hostedTrustBundleConfigMapName, metav1.GetOptions{})
+			if test.expectedBundle == "" {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected the copy to be removed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get the copy: %v", err)
+			}
+			if cm.Data[caBundleKey] != test.expectedBundle || len(cm.Data) != 1 {
+				t.Errorf("unexpected copy data: %v", cm.Data)
+			}
+			if cm.Labels[hostedControlPlanePodLabel] != controlPlaneNamespace {
+				t.Errorf("expected the hosted control plane labels, got %v", cm.Labels)
+			}
+
+			// The copy takes precedence over user-ca-bundle and its changes roll out the controller.
+			configMapInformer.Informer().GetIndexer().Add(cm)
+			configName, err := customAWSCABundle(true, c.configMapLister)
+			if err != nil || configName != hostedTrustBundleConfigMapName {
+				t.Errorf("expected the custom CA bundle %s, got %q: %v", hostedTrustBundleConfigMapName, configName, err)
+			}
+			deployment := &appsv1.Deployment{}
+			if err := withCustomAWSCABundleHashHook(true, c.configMapLister)(nil, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if deployment.Spec.Template.Annotations[customCABundleHashAnnotation] == "" {
+				t.Errorf("expected the trust bundle hash annotation, got %v", deployment.Spec.Template.Annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/cache"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+)
+
+const (
+	// controlPlaneComponentLabel names the component of the hosted control plane that a pod belongs to.
+	controlPlaneComponentLabel = "hypershift.openshift.io/control-plane-component"
+	// needManagementKASAccessLabel lets the pods of a hosted control plane through the network policies to the
+	// API server of the management cluster.
+	needManagementKASAccessLabel = "hypershift.openshift.io/need-management-kas-access"
+	// hostedClusterAnnotation is the <namespace>/<name> of the HostedCluster of a HostedControlPlane.
+	hostedClusterAnnotation = "hypershift.openshift.io/cluster"
+	// safeToEvictLocalVolumesAnnotation lists the local volumes that don't prevent the cluster autoscaler from
+	// evicting a pod to scale down the management cluster.
+	safeToEvictLocalVolumesAnnotation = "cluster-autoscaler.kubernetes.io/safe-to-evict-local-volumes"
+)
+
+// hostedControlPlaneLabels returns the labels that Hypershift expects on the resources of the hosted control plane
+// in the namespace, e.g. to select its pods in its network policies and dashboards.
+func hostedControlPlaneLabels(namespace string) map[string]string {
+	return map[string]string{
+		hostedControlPlanePodLabel: namespace,
+	}
+}
+
+// withHostedControlPlaneLabels adds the labels of the hosted control plane to the assets applied in the control
+// plane namespace on Hypershift.
+func withHostedControlPlaneLabels(isHypershift bool, namespace string, assetFunc resourceapply.AssetFunc) resourceapply.AssetFunc {
+	if !isHypershift {
+		return assetFunc
+	}
+	return func(name string) ([]byte, error) {
+		content, err := assetFunc(name)
+		if err != nil {
+			return nil, err
+		}
+		jsonContent, err := yaml.ToJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", name, err)
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(jsonContent); err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", name, err)
+		}
+		objLabels := obj.GetLabels()
+		if objLabels == nil {
+			objLabels = map[string]string{}
+		}
+		for key, value := range hostedControlPlaneLabels(namespace) {
+			objLabels[key] = value
+		}
+		obj.SetLabels(objLabels)
+		return obj.MarshalJSON()
+	}
+}
+
+// withHostedControlPlaneLabelsHook stamps the controller Deployment and its pods on Hypershift with the labels of
+// the hosted control plane and of the component, the HostedCluster of the HostedControlPlane, and lets the
+// cluster autoscaler evict the pods despite their emptyDir volumes, which only hold sockets and tokens. The pods
+// need access to the management API server, where the kube-rbac-proxy sidecars review the tokens of the scrapes.
+// It must run after the hooks that add volumes.
+func withHostedControlPlaneLabelsHook(isHypershift bool, namespace string, hcpLister cache.GenericNamespaceLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if !isHypershift {
+			return nil
+		}
+		hcp, err := getHostedControlPlane(hcpLister)
+		if err != nil {
+			return err
+		}
+
+		labels := hostedControlPlaneLabels(namespace)
+		labels[controlPlaneComponentLabel] = deployment.Name
+		labels[needManagementKASAccessLabel] = "true"
+		template := &deployment.Spec.Template
+		if deployment.Labels == nil {
+			deployment.Labels = map[string]string{}
+		}
+		if template.Labels == nil {
+			template.Labels = map[string]string{}
+		}
+		for key, value := range labels {
+			deployment.Labels[key] = value
+			template.Labels[key] = value
+		}
+
+		annotations := map[string]string{}
+		if hcp != nil {
+			if cluster := hcp.GetAnnotations()[hostedClusterAnnotation]; cluster != "" {
+				annotations[hostedClusterAnnotation] = cluster
+			}
+		}
+		var localVolumes []string
+		for _, volume := range template.Spec.Volumes {
+			if volume.EmptyDir != nil {
+				localVolumes = append(localVolumes, volume.Name)
+			}
+		}
+		if len(localVolumes) > 0 {
+			sort.Strings(localVolumes)
+			annotations[safeToEvictLocalVolumesAnnotation] = strings.Join(localVolumes, ",")
+		}
+		if len(annotations) == 0 {
+			return nil
+		}
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		for key, value := range annotations {
+			template.Annotations[key] = value
+		}
+		if cluster, ok := annotations[hostedClusterAnnotation]; ok {
+			if deployment.Annotations == nil {
+				deployment.Annotations = map[string]string{}
+			}
+			deployment.Annotations[hostedClusterAnnotation] = cluster
+		}
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWithHostedControlPlaneLabels(t *testing.T) {
+	assetFunc := withHostedControlPlaneLabels(true, "clusters-test", assetWithNamespaceFunc("clusters-test"))
+	content, err := assetFunc(controllerPDBFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(content); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if obj.GetNamespace() != "clusters-test" || obj.GetLabels()[hostedControlPlanePodLabel] != "clusters-test" {
+		t.Errorf("expected the labels of the hosted control plane, got %s", content)
+	}
+
+	standalone, err := withHostedControlPlaneLabels(false, defaultNamespace, assetWithNamespaceFunc(defaultNamespace))(controllerPDBFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected, _ := assetWithNamespaceFunc(defaultNamespace)(controllerPDBFile)
+	if !reflect.DeepEqual(standalone, expected) {
+		t.Errorf("expected the asset unchanged on standalone clusters, got %s", standalone)
+	}
+}
+
+func TestWithHostedControlPlaneLabelsHook(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Name = controllerDeploymentName
+		deployment.Spec.Template.Labels = map[string]string{"app": controllerDeploymentName}
+		deployment.Spec.Template.Spec.Volumes = []corev1.Volume{
+			{Name: "socket-dir", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: "bound-sa-token", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			{Name: "hosted-kubeconfig", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: guestKubeconfigSecretName}}},
+		}
+		return deployment
+	}
+	hcpLister := func() cache.GenericNamespaceLister {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		indexer.Add(&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "hypershift.openshift.io/v1beta1",
+			"kind":       "HostedControlPlane",
+			"metadata": map[string]interface{}{
+				"name":        "test",
+				"namespace":   "clusters-test",
+				"annotations": map[string]interface{}{hostedClusterAnnotation: "clusters/test"},
+			},
+		}})
+		return cache.NewGenericLister(indexer, hostedControlPlaneGVR.GroupResource()).ByNamespace("clusters-test")
+	}()
+
+	deployment := newDeployment()
+	if err := withHostedControlPlaneLabelsHook(false, defaultNamespace, nil)(&opv1.OperatorSpec{}, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(deployment, newDeployment()) {
+		t.Errorf("expected no change on standalone clusters, got %+v", deployment)
+	}
+
+	if err := withHostedControlPlaneLabelsHook(true, "clusters-test", hcpLister)(&opv1.OperatorSpec{}, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedLabels := map[string]string{
+		"app":                        controllerDeploymentName,
+		hostedControlPlanePodLabel:   "clusters-test",
+		controlPlaneComponentLabel:   controllerDeploymentName,
+		needManagementKASAccessLabel: "true",
+	}
+	if !reflect.DeepEqual(deployment.Spec.Template.Labels, expectedLabels) {
+		t.Errorf("expected pod labels %v, got %v", expectedLabels, deployment.Spec.Template.Labels)
+	}
+	if deployment.Labels[hostedControlPlanePodLabel] != "clusters-test" || deployment.Annotations[hostedClusterAnnotation] != "clusters/test" {
+		t.Errorf("unexpected Deployment metadata %+v", deployment.ObjectMeta)
+	}
+	expectedAnnotations := map[string]string{
+		hostedClusterAnnotation:           "clusters/test",
+		safeToEvictLocalVolumesAnnotation: "bound-sa-token,socket-dir",
+	}
+	if !reflect.DeepEqual(deployment.Spec.Template.Annotations, expectedAnnotations) {
+		t.Errorf("expected pod annotations %v, got %v", expectedAnnotations, deployment.Spec.Template.Annotations)
+	}
+}
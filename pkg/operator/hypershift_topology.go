@@ -0,0 +1,80 @@
+package operator
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+const (
+	// topologyAnnotation is the annotation of the HostedControlPlane, copied from the HostedCluster, with the
+	// isolation of the components of the hosted control plane on the management cluster.
+	topologyAnnotation = "hypershift.openshift.io/topology"
+	// dedicatedRequestServingTopology runs the components that serve the requests of the guest cluster, such as
+	// the API server, on a dedicated node pool labeled with requestServingComponentLabel, and spreads the control
+	// plane across zones.
+	dedicatedRequestServingTopology = "dedicated-request-serving-components"
+	requestServingComponentLabel    = "hypershift.openshift.io/request-serving-component"
+)
+
+// withRequestServingIsolationHook schedules the controller pods on Hypershift like the other components of the
+// hosted control plane that don't serve requests when the HostedControlPlane has the dedicated request serving
+// topology: they must not run on the request serving nodes, even when the tolerations of the HostedControlPlane
+// allow it, and the replicas of a highly available controller must run in different zones.
+// It must run after withHostedControlPlaneSchedulingHook and before the hooks that add to the required node
+// affinity terms.
+func withRequestServingIsolationHook(isHypershift bool, hcpLister cache.GenericNamespaceLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if !isHypershift {
+			return nil
+		}
+		hcp, err := getHostedControlPlane(hcpLister)
+		if err != nil || hcp == nil {
+			return err
+		}
+		if hcp.GetAnnotations()[topologyAnnotation] != dedicatedRequestServingTopology {
+			return nil
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		if podSpec.Affinity == nil {
+			podSpec.Affinity = &corev1.Affinity{}
+		}
+		if podSpec.Affinity.NodeAffinity == nil {
+			podSpec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+		}
+		nodeAffinity := podSpec.Affinity.NodeAffinity
+		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+			nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+		}
+		required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if len(required.NodeSelectorTerms) == 0 {
+			required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+		}
+		// The terms are ORed, each of them must exclude the request serving nodes.
+		for i := range required.NodeSelectorTerms {
+			term := &required.NodeSelectorTerms[i]
+			term.MatchExpressions = append(term.MatchExpressions, corev1.NodeSelectorRequirement{
+				Key:      requestServingComponentLabel,
+				Operator: corev1.NodeSelectorOpDoesNotExist,
+			})
+		}
+
+		policy, err := controllerAvailabilityPolicy(hcpLister)
+		if err != nil {
+			return err
+		}
+		if policy == highlyAvailablePolicy {
+			podSpec.TopologySpreadConstraints = append(podSpec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+				MaxSkew:           1,
+				TopologyKey:       corev1.LabelTopologyZone,
+				WhenUnsatisfiable: corev1.DoNotSchedule,
+				LabelSelector:     deployment.Spec.Selector,
+			})
+		}
+		return nil
+	}
+}
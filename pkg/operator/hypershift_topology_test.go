@@ -0,0 +1,78 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWithRequestServingIsolationHook(t *testing.T) {
+	dedicated := map[string]string{topologyAnnotation: dedicatedRequestServingTopology}
+	tests := []struct {
+		name               string
+		isHypershift       bool
+		hcpLister          cache.GenericNamespaceLister
+		expectedIsolation  bool
+		expectedZoneSpread bool
+	}{
+		{
+			name: "standalone",
+		},
+		{
+			name:         "shared topology",
+			isHypershift: true,
+			hcpLister:    newTestHCPListerWithAnnotations(nil, map[string]interface{}{"controllerAvailabilityPolicy": highlyAvailablePolicy}),
+		},
+		{
+			name:              "dedicated request serving topology",
+			isHypershift:      true,
+			hcpLister:         newTestHCPListerWithAnnotations(dedicated, map[string]interface{}{"controllerAvailabilityPolicy": singleReplicaPolicy}),
+			expectedIsolation: true,
+		},
+		{
+			name:               "highly available with dedicated request serving topology",
+			isHypershift:       true,
+			hcpLister:          newTestHCPListerWithAnnotations(dedicated, map[string]interface{}{"controllerAvailabilityPolicy": highlyAvailablePolicy}),
+			expectedIsolation:  true,
+			expectedZoneSpread: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "aws-ebs-csi-driver-controller"}}
+			// Run the hooks in the same order as the operator.
+			if err := withRequestServingIsolationHook(test.isHypershift, test.hcpLister)(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := withArchitectureDeploymentHook(test.isHypershift, []string{"amd64"})(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			podSpec := deployment.Spec.Template.Spec
+			isolated := false
+			if podSpec.Affinity != nil && podSpec.Affinity.NodeAffinity != nil && podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+				terms := podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+				if len(terms) != 1 {
+					t.Fatalf("expected a single required node selector term, got %+v", terms)
+				}
+				for _, expression := range terms[0].MatchExpressions {
+					if expression.Key == requestServingComponentLabel && expression.Operator == corev1.NodeSelectorOpDoesNotExist {
+						isolated = true
+					}
+				}
+			}
+			if isolated != test.expectedIsolation {
+				t.Errorf("expected isolation from the request serving nodes %v, got %+v", test.expectedIsolation, podSpec.Affinity)
+			}
+			if zoneSpread := len(podSpec.TopologySpreadConstraints) == 1; zoneSpread != test.expectedZoneSpread {
+				t.Errorf("expected zone spread %v, got %+v", test.expectedZoneSpread, podSpec.TopologySpreadConstraints)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package operator
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	v1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// imageOverridesEnvName lists the registry overrides of Hypershift for disconnected management clusters, as
+// comma separated source=mirror pairs, e.g. "quay.io/openshift-release-dev=mirror.example.com/openshift".
+// The control plane operators get them from the HostedCluster.
+const imageOverridesEnvName = "OPENSHIFT_IMG_OVERRIDES"
+
+// hypershiftImageOverrides returns the registry overrides from imageOverridesEnvName.
+func hypershiftImageOverrides() ([]imageMirror, error) {
+	value := os.Getenv(imageOverridesEnvName)
+	if value == "" {
+		return nil, nil
+	}
+	var overrides []imageMirror
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid %s %q: %q is not a source=mirror pair", imageOverridesEnvName, value, pair)
+		}
+		overrides = append(overrides, imageMirror{Source: parts[0], Mirror: parts[1]})
+	}
+	return overrides, nil
+}
+
+// withImageMirrorsDeploymentHook rewrites the images of all containers in the Deployment,
+// including the ones injected by other hooks (e.g. token-minter), to point to their mirrors.
+// On Hypershift, the Deployment runs in the management cluster: the registry overrides of the
+// management cluster apply to it and idmsLister must be nil, the ImageDigestMirrorSets of the
+// guest cluster only apply to the pods of the guest cluster.
+// It must be the last hook that adds containers to the Deployment.
+func withImageMirrorsDeploymentHook(idmsLister v1.ImageDigestMirrorSetLister, overrides []imageMirror) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		return mirrorPodImages(spec, idmsLister, overrides, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withImageMirrorsDaemonSetHook rewrites the images of all containers in the DaemonSet to point to their mirrors.
+func withImageMirrorsDaemonSetHook(idmsLister v1.ImageDigestMirrorSetLister) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		return mirrorPodImages(spec, idmsLister, nil, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+func mirrorPodImages(spec *opv1.OperatorSpec, idmsLister v1.ImageDigestMirrorSetLister, overrides []imageMirror, podSpec *corev1.PodSpec) error {
+	mirrors, err := getImageMirrors(spec, idmsLister, overrides)
+	if err != nil {
+		return err
+	}
+	if len(mirrors) == 0 {
+		return nil
+	}
+	for i := range podSpec.InitContainers {
+		podSpec.InitContainers[i].Image = mirrorImage(podSpec.InitContainers[i].Image, mirrors)
+	}
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].Image = mirrorImage(podSpec.Containers[i].Image, mirrors)
+	}
+	return nil
+}
+
+// getImageMirrors returns the mirrors configured in the ClusterCSIDriver, followed by the overrides and
+// the first mirror of each source in the cluster ImageDigestMirrorSets. ImageDigestMirrorSets are sorted
+// by name so the result is stable across syncs, and their mirrors only apply to images referenced by
+// digest, as in the container runtime.
+func getImageMirrors(spec *opv1.OperatorSpec, idmsLister v1.ImageDigestMirrorSetLister, overrides []imageMirror) ([]imageMirror, error) {
+	cfg, err := getDriverConfig(spec)
+	if err != nil {
+		return nil, err
+	}
+	mirrors := append([]imageMirror{}, cfg.ImageMirrors...)
+	mirrors = append(mirrors, overrides...)
+
+	if idmsLister == nil {
+		return mirrors, nil
+	}
+	idmsList, err := idmsLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(idmsList, func(i, j int) bool {
+		return idmsList[i].Name < idmsList[j].Name
+	})
+	for _, idms := range idmsList {
+		for _, m := range idms.Spec.ImageDigestMirrors {
+			if len(m.Mirrors) == 0 {
+				continue
+			}
+			mirrors = append(mirrors, imageMirror{
+				Source:     m.Source,
+				Mirror:     string(m.Mirrors[0]),
+				digestOnly: true,
+			})
+		}
+	}
+	return mirrors, nil
+}
+
+// mirrorImage replaces the repository of the image with the mirror of the longest matching source.
+// The first mirror wins when several mirrors have the same source.
+func mirrorImage(image string, mirrors []imageMirror) string {
+	best := -1
+	for i, m := range mirrors {
+		if m.Source == "" || m.Mirror == "" || !imageHasSource(image, m.Source) {
+			continue
+		}
+		if m.digestOnly && !strings.Contains(image, "@sha256:") {
+			continue
+		}
+		if best == -1 || len(m.Source) > len(mirrors[best].Source) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return image
+	}
+	return mirrors[best].Mirror + strings.TrimPrefix(image, mirrors[best].Source)
+}
+
+// imageHasSource returns true when the image is located in the source repository or in one of its sub-repositories.
+func imageHasSource(image, source string) bool {
+	if !strings.HasPrefix(image, source) {
+		return false
+	}
+	rest := image[len(source):]
+	return rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "@") || strings.HasPrefix(rest, ":")
+}
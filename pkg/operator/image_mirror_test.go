@@ -0,0 +1,211 @@
+package operator
+
+import (
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWithImageMirrors(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "csi-driver",
+							Image: "quay.io/openshift/origin-aws-ebs-csi-driver@sha256:1234",
+						},
+						{
+							Name:  "token-minter",
+							Image: "registry.ci.openshift.org/hypershift/hypershift:latest",
+						},
+						{
+							Name:  "csi-provisioner",
+							Image: "quay.io/openshiftfoo/provisioner:latest",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		overrides      string
+		imageOverrides string
+		idms           []*configv1.ImageDigestMirrorSet
+		hypershift     bool
+		expected       []string
+	}{
+		{
+			name: "no mirrors",
+			expected: []string{
+				"quay.io/openshift/origin-aws-ebs-csi-driver@sha256:1234",
+				"registry.ci.openshift.org/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+		{
+			name:      "ClusterCSIDriver mirrors",
+			overrides: `{"imageMirrors": [{"source": "quay.io/openshift", "mirror": "mirror.local/ocp"}, {"source": "registry.ci.openshift.org", "mirror": "mirror.local/ci"}]}`,
+			expected: []string{
+				"mirror.local/ocp/origin-aws-ebs-csi-driver@sha256:1234",
+				"mirror.local/ci/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+		{
+			name: "ImageDigestMirrorSet",
+			idms: []*configv1.ImageDigestMirrorSet{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "mirrors"},
+					Spec: configv1.ImageDigestMirrorSetSpec{
+						ImageDigestMirrors: []configv1.ImageDigestMirrors{
+							{
+								Source:  "quay.io/openshift",
+								Mirrors: []configv1.ImageMirror{"mirror.local/idms", "mirror.local/other"},
+							},
+							{
+								Source:  "quay.io/openshift/origin-aws-ebs-csi-driver",
+								Mirrors: []configv1.ImageMirror{"mirror.local/driver"},
+							},
+							{
+								// Images referenced by tag are not mirrored.
+								Source:  "registry.ci.openshift.org",
+								Mirrors: []configv1.ImageMirror{"mirror.local/ci"},
+							},
+						},
+					},
+				},
+			},
+			expected: []string{
+				"mirror.local/driver@sha256:1234",
+				"registry.ci.openshift.org/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+		{
+			name: "ImageDigestMirrorSet of the guest cluster on Hypershift",
+			idms: []*configv1.ImageDigestMirrorSet{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "mirrors"},
+					Spec: configv1.ImageDigestMirrorSetSpec{
+						ImageDigestMirrors: []configv1.ImageDigestMirrors{
+							{
+								Source:  "quay.io/openshift",
+								Mirrors: []configv1.ImageMirror{"mirror.local/idms"},
+							},
+						},
+					},
+				},
+			},
+			imageOverrides: "registry.ci.openshift.org/hypershift=mirror.local/hypershift",
+			hypershift:     true,
+			expected: []string{
+				"quay.io/openshift/origin-aws-ebs-csi-driver@sha256:1234",
+				"mirror.local/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+		{
+			name:      "ClusterCSIDriver mirrors take precedence",
+			overrides: `{"imageMirrors": [{"source": "quay.io/openshift/origin-aws-ebs-csi-driver", "mirror": "mirror.local/ocp"}]}`,
+			idms: []*configv1.ImageDigestMirrorSet{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "mirrors"},
+					Spec: configv1.ImageDigestMirrorSetSpec{
+						ImageDigestMirrors: []configv1.ImageDigestMirrors{
+							{
+								Source:  "quay.io/openshift/origin-aws-ebs-csi-driver",
+								Mirrors: []configv1.ImageMirror{"mirror.local/idms"},
+							},
+						},
+					},
+				},
+			},
+			expected: []string{
+				"mirror.local/ocp@sha256:1234",
+				"registry.ci.openshift.org/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+		{
+			name:           "Hypershift image overrides",
+			imageOverrides: "quay.io/openshift=mirror.local/hcp, registry.ci.openshift.org/hypershift=mirror.local/hypershift",
+			expected: []string{
+				"mirror.local/hcp/origin-aws-ebs-csi-driver@sha256:1234",
+				"mirror.local/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+		{
+			name:           "ClusterCSIDriver mirrors take precedence over Hypershift image overrides",
+			overrides:      `{"imageMirrors": [{"source": "quay.io/openshift", "mirror": "mirror.local/ocp"}]}`,
+			imageOverrides: "quay.io/openshift=mirror.local/hcp",
+			expected: []string{
+				"mirror.local/ocp/origin-aws-ebs-csi-driver@sha256:1234",
+				"registry.ci.openshift.org/hypershift/hypershift:latest",
+				"quay.io/openshiftfoo/provisioner:latest",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			for _, idms := range test.idms {
+				objects = append(objects, idms)
+			}
+			configClient := fakeconfig.NewSimpleClientset(objects...)
+			configInformerFactory := configinformers.NewSharedInformerFactory(configClient, 0)
+			for _, idms := range test.idms {
+				configInformerFactory.Config().V1().ImageDigestMirrorSets().Informer().GetIndexer().Add(idms)
+			}
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+
+			t.Setenv(imageOverridesEnvName, test.imageOverrides)
+			imageOverrides, err := hypershiftImageOverrides()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			idmsLister := configInformerFactory.Config().V1().ImageDigestMirrorSets().Lister()
+			if test.hypershift {
+				idmsLister = nil
+			}
+			d := deployment.DeepCopy()
+			err = withImageMirrorsDeploymentHook(idmsLister, imageOverrides)(spec, d)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			images := []string{}
+			for _, c := range d.Spec.Template.Spec.Containers {
+				images = append(images, c.Image)
+			}
+			if e, a := test.expected, images; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected images\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
+
+func TestHypershiftImageOverrides(t *testing.T) {
+	for _, value := range []string{"quay.io/openshift", "=mirror.local/hcp", "quay.io/openshift="} {
+		t.Setenv(imageOverridesEnvName, value)
+		if _, err := hypershiftImageOverrides(); err == nil {
+			t.Errorf("expected error for %q, got none", value)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// Values of isolatedRegion.mode in the driver configuration.
+	isolatedRegionAuto     = "Auto"
+	isolatedRegionEnabled  = "Enabled"
+	isolatedRegionDisabled = "Disabled"
+)
+
+// withIsolatedRegionHook checks that the csi-driver container of the controller has everything the driver
+// needs in an isolated region, where the AWS services have private endpoints signed by a private CA: the
+// region, the custom CA bundle and the ec2, kms and sts service endpoints, which are set by the previous hooks
+// from the Infrastructure and the cloud config. All the missing items are reported at once, the controller
+// is not rolled out without them. isolatedRegion.disableIMDS keeps the driver from calling the instance metadata
+// service when it's not reachable.
+// The mode is enabled by default ("Auto") in the regions of the isolated partitions, e.g. C2S and SC2S,
+// isolatedRegion.mode in the driver configuration forces it on or off.
+func withIsolatedRegionHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		var isolated isolatedRegionConfig
+		if cfg.IsolatedRegion != nil {
+			isolated = *cfg.IsolatedRegion
+		}
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return nil
+		}
+		region, _ := getContainerEnv(container, "AWS_REGION")
+
+		switch isolated.Mode {
+		case "", isolatedRegionAuto:
+			if !isIsolatedRegion(region) {
+				return nil
+			}
+		case isolatedRegionEnabled:
+		case isolatedRegionDisabled:
+			return nil
+		default:
+			return fmt.Errorf("invalid isolatedRegion mode %q, expected %s, %s or %s",
+				isolated.Mode, isolatedRegionAuto, isolatedRegionEnabled, isolatedRegionDisabled)
+		}
+
+		var missing []string
+		if region == "" {
+			missing = append(missing, fmt.Sprintf("the region of Infrastructure %s", infrastructureName))
+		}
+		if caBundle, ok := getContainerEnv(container, "AWS_CA_BUNDLE"); !ok || caBundle == "" {
+			missing = append(missing, fmt.Sprintf("the custom CA bundle in the %s key of ConfigMap %s/%s", caBundleKey, cloudConfigNamespace, cloudConfigName))
+		}
+		services := make([]string, 0, len(serviceEndpointEnvNames))
+		for service := range serviceEndpointEnvNames {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+		for _, service := range services {
+			if endpoint, ok := getContainerEnv(container, serviceEndpointEnvNames[service]); !ok || endpoint == "" {
+				missing = append(missing, fmt.Sprintf("the %s service endpoint of Infrastructure %s", service, infrastructureName))
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("the isolated region mode requires %s", strings.Join(missing, ", "))
+		}
+
+		if isolated.DisableIMDS {
+			setContainerEnv(container, "AWS_EC2_METADATA_DISABLED", "true")
+		}
+		return nil
+	}
+}
+
+// isIsolatedRegion returns true for the regions of the isolated partitions, e.g. C2S (us-iso-*) and SC2S (us-isob-*).
+func isIsolatedRegion(region string) bool {
+	return awsclient.PartitionOf(region).Isolated
+}
+
+// inIsolatedRegion returns true when the isolated region mode of the driver configuration applies to the region.
+// An invalid mode is handled like "Auto", withIsolatedRegionHook reports it.
+func inIsolatedRegion(cfg *driverConfig, region string) bool {
+	if cfg != nil && cfg.IsolatedRegion != nil {
+		switch cfg.IsolatedRegion.Mode {
+		case isolatedRegionEnabled:
+			return true
+		case isolatedRegionDisabled:
+			return false
+		}
+	}
+	return isIsolatedRegion(region)
+}
@@ -0,0 +1,120 @@
+package operator
+
+import (
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWithIsolatedRegionHook(t *testing.T) {
+	isolatedEnv := []corev1.EnvVar{
+		{Name: "AWS_REGION", Value: "us-iso-east-1"},
+		{Name: "AWS_CA_BUNDLE", Value: "/etc/ca/ca-bundle.pem"},
+		{Name: "AWS_EC2_ENDPOINT", Value: "https://ec2.us-iso-east-1.c2s.ic.gov"},
+		{Name: "AWS_ENDPOINT_URL_KMS", Value: "https://kms.us-iso-east-1.c2s.ic.gov"},
+		{Name: "AWS_ENDPOINT_URL_STS", Value: "https://sts.us-iso-east-1.c2s.ic.gov"},
+	}
+	without := func(names ...string) []corev1.EnvVar {
+		var env []corev1.EnvVar
+		for _, e := range isolatedEnv {
+			keep := true
+			for _, name := range names {
+				if e.Name == name {
+					keep = false
+				}
+			}
+			if keep {
+				env = append(env, e)
+			}
+		}
+		return env
+	}
+
+	tests := []struct {
+		name                 string
+		overrides            string
+		env                  []corev1.EnvVar
+		expectedMissing      []string
+		expectedIMDSDisabled bool
+	}{
+		{
+			name: "commercial region",
+			env:  []corev1.EnvVar{{Name: "AWS_REGION", Value: "us-east-1"}},
+		},
+		{
+			name: "complete isolated region",
+			env:  isolatedEnv,
+		},
+		{
+			name:            "isolated region without CA bundle and KMS endpoint",
+			env:             without("AWS_CA_BUNDLE", "AWS_ENDPOINT_URL_KMS"),
+			expectedMissing: []string{"custom CA bundle", "kms service endpoint"},
+		},
+		{
+			name:            "SC2S region without CA bundle",
+			env:             []corev1.EnvVar{{Name: "AWS_REGION", Value: "us-isob-east-1"}},
+			expectedMissing: []string{"custom CA bundle", "ec2 service endpoint", "kms service endpoint", "sts service endpoint"},
+		},
+		{
+			name:            "aws-iso-f region without CA bundle",
+			env:             []corev1.EnvVar{{Name: "AWS_REGION", Value: "us-isof-south-1"}},
+			expectedMissing: []string{"custom CA bundle", "ec2 service endpoint", "kms service endpoint", "sts service endpoint"},
+		},
+		{
+			name:      "disabled in an isolated region",
+			overrides: `{"isolatedRegion": {"mode": "Disabled"}}`,
+			env:       without("AWS_CA_BUNDLE"),
+		},
+		{
+			name:            "enabled in another region",
+			overrides:       `{"isolatedRegion": {"mode": "Enabled"}}`,
+			env:             []corev1.EnvVar{{Name: "AWS_EC2_ENDPOINT", Value: "https://ec2.example.com"}},
+			expectedMissing: []string{"region", "custom CA bundle", "kms service endpoint", "sts service endpoint"},
+		},
+		{
+			name:                 "IMDS disabled",
+			overrides:            `{"isolatedRegion": {"disableIMDS": true}}`,
+			env:                  isolatedEnv,
+			expectedIMDSDisabled: true,
+		},
+		{
+			name:            "invalid mode",
+			overrides:       `{"isolatedRegion": {"mode": "true"}}`,
+			env:             isolatedEnv,
+			expectedMissing: []string{"invalid isolatedRegion mode"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: driverContainerName, Env: test.env}}
+			err := withIsolatedRegionHook()(spec, deployment)
+			if len(test.expectedMissing) > 0 {
+				if err == nil {
+					t.Fatalf("expected error, got none")
+				}
+				for _, missing := range test.expectedMissing {
+					if !strings.Contains(err.Error(), missing) {
+						t.Errorf("expected error to mention %q, got %q", missing, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, ok := getContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "AWS_EC2_METADATA_DISABLED")
+			if disabled := ok && value == "true"; disabled != test.expectedIMDSDisabled {
+				t.Errorf("expected IMDS disabled %v, got %v", test.expectedIMDSDisabled, disabled)
+			}
+		})
+	}
+}
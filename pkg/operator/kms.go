@@ -0,0 +1,58 @@
+package operator
+
+import (
+	"fmt"
+	"regexp"
+
+	storagev1 "k8s.io/api/storage/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+)
+
+var kmsKeyARNRegexp = regexp.MustCompile(`^arn:aws[a-z-]*:kms:[a-z0-9-]+:[0-9]{12}:(key|alias)/.+$`)
+
+// withKMSKeyHook encrypts the volumes of the managed StorageClasses with the customer managed KMS key
+// from the driver configuration.
+//
+// The vendored ClusterCSIDriver API does not have an AWS section in spec.driverConfig yet, so the
+// key is read from the driver configuration in unsupportedConfigOverrides.
+func withKMSKeyHook() csistorageclasscontroller.StorageClassHookFunc {
+	return func(spec *opv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.KMSKeyARN == "" {
+			return nil
+		}
+		if !kmsKeyARNRegexp.MatchString(cfg.KMSKeyARN) {
+			return fmt.Errorf("invalid kmsKeyARN %q: it must be the ARN of a KMS key or alias", cfg.KMSKeyARN)
+		}
+		if sc.Parameters == nil {
+			sc.Parameters = map[string]string{}
+		}
+		sc.Parameters["encrypted"] = "true"
+		sc.Parameters["kmsKeyId"] = cfg.KMSKeyARN
+		return nil
+	}
+}
+
+// withEncryptionHook makes encryption of the managed StorageClasses mandatory when encryptByDefault is
+// set, so it can't be turned off by storageClassParameters. It runs after the other StorageClass hooks.
+func withEncryptionHook() csistorageclasscontroller.StorageClassHookFunc {
+	return func(spec *opv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if !cfg.EncryptByDefault {
+			return nil
+		}
+		if sc.Parameters == nil {
+			sc.Parameters = map[string]string{}
+		}
+		sc.Parameters["encrypted"] = "true"
+		return nil
+	}
+}
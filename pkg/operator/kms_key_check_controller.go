@@ -0,0 +1,185 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	kmsKeyCheckInterval = 30 * time.Minute
+	kmsTimeout          = 30 * time.Second
+)
+
+// kmsAPI is the part of the KMS API used by the kmsKeyCheckController.
+type kmsAPI interface {
+	DescribeKey(ctx context.Context, keyID string) (*awsclient.KeyMetadata, error)
+	GenerateDataKeyWithoutPlaintext(ctx context.Context, keyID string) error
+}
+
+// kmsKeyCheckController checks that the KMS key of the managed StorageClasses can encrypt volumes: the key must
+// exist in the region of the cluster, be enabled, be a symmetric encryption key, and the credentials of the driver
+// must be allowed to generate data keys with it. A key that can't be used leaves the volumes stuck in creating,
+// so the operator is degraded with the reason. The check runs every 30 minutes and when the key or the controller
+// Deployment changes, unless it's disabled. Nothing is checked when the AWS configuration of the driver is not
+// known, e.g. without a region.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error.
+type kmsKeyCheckController struct {
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+	// newKMSClient returns a KMS client configured like the driver in the controller Deployment.
+	newKMSClient func(deployment *appsv1.Deployment) (kmsAPI, error)
+
+	now func() time.Time
+	// lastCheck, lastKey and lastGeneration record the last check, to check again when the interval elapses,
+	// the key or the Deployment changes. lastErr is its result, reported until the next check.
+	lastCheck      time.Time
+	lastKey        string
+	lastGeneration int64
+	lastErr        error
+}
+
+func newKMSKeyCheckController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	builder := newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient)
+	c := &kmsKeyCheckController{
+		operatorClient:   operatorClient,
+		deploymentLister: deploymentInformer.Lister().Deployments(namespace),
+		newKMSClient: func(deployment *appsv1.Deployment) (kmsAPI, error) {
+			client, _, err := builder.newClient(deployment, "kms", kmsTimeout)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+		now: time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *kmsKeyCheckController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+	// An invalid ARN is reported by the StorageClass controller.
+	disabled := cfg.KMSKeyCheck != nil && cfg.KMSKeyCheck.Disabled
+	if disabled || cfg.KMSKeyARN == "" || !kmsKeyARNRegexp.MatchString(cfg.KMSKeyARN) {
+		c.lastCheck, c.lastErr = time.Time{}, nil
+		return nil
+	}
+
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// The credentials of the driver are not known until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	if !c.lastCheck.IsZero() && cfg.KMSKeyARN == c.lastKey && deployment.Generation == c.lastGeneration && now.Sub(c.lastCheck) < kmsKeyCheckInterval {
+		return c.lastErr
+	}
+
+	checkErr := c.check(ctx, deployment, cfg.KMSKeyARN)
+	var skipped *awsHealthCheckError
+	if errors.As(checkErr, &skipped) {
+		klog.V(2).Infof("KMS key check skipped: %v", checkErr)
+		checkErr = nil
+	}
+	c.lastCheck = now
+	c.lastKey = cfg.KMSKeyARN
+	c.lastGeneration = deployment.Generation
+	c.lastErr = checkErr
+	return checkErr
+}
+
+// check returns an error that tells why the key can't encrypt volumes, or nil when it can.
+func (c *kmsKeyCheckController) check(ctx context.Context, deployment *appsv1.Deployment, keyARN string) error {
+	// The ARN is arn:<partition>:kms:<region>:<account ID>:(key|alias)/<name>.
+	keyRegion := strings.Split(keyARN, ":")[3]
+	if container := getContainer(&deployment.Spec.Template.Spec, driverContainerName); container != nil {
+		if region, ok := getContainerEnv(container, "AWS_REGION"); ok && region != keyRegion {
+			return fmt.Errorf("KMS key %s is in region %s, EBS volumes in region %s can only be encrypted with a key of the same region", keyARN, keyRegion, region)
+		}
+	}
+
+	client, err := c.newKMSClient(deployment)
+	if err != nil {
+		return err
+	}
+	key, err := client.DescribeKey(ctx, keyARN)
+	var apiErr *awsclient.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == "NotFoundException":
+		return fmt.Errorf("KMS key %s does not exist, check kmsKeyARN in the driver configuration", keyARN)
+	case errors.As(err, &apiErr) && apiErr.Code == "AccessDeniedException":
+		return fmt.Errorf("the AWS credentials of the driver are not allowed to call kms:DescribeKey on KMS key %s, check the IAM policy of the credentials and the key policy: %s", keyARN, apiErr.Message)
+	case err != nil:
+		return fmt.Errorf("failed to describe KMS key %s: %w", keyARN, err)
+	}
+	if !key.Enabled || key.KeyState != "Enabled" {
+		return fmt.Errorf("KMS key %s is in state %s, it must be enabled to encrypt volumes", key.ARN, key.KeyState)
+	}
+	if key.KeyUsage != "ENCRYPT_DECRYPT" || (key.KeySpec != "" && key.KeySpec != "SYMMETRIC_DEFAULT") {
+		return fmt.Errorf("KMS key %s is a %s key for %s, EBS needs a symmetric encryption key", key.ARN, key.KeySpec, key.KeyUsage)
+	}
+
+	err = client.GenerateDataKeyWithoutPlaintext(ctx, keyARN)
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == "AccessDeniedException":
+		return fmt.Errorf("the AWS credentials of the driver are not allowed to call kms:GenerateDataKeyWithoutPlaintext on KMS key %s, "+
+			"add it to the IAM policy of the credentials and the key policy, or set kmsKeyCheck.disabled when the key policy only allows EC2 through kms:ViaService: %s", key.ARN, apiErr.Message)
+	case err != nil:
+		return fmt.Errorf("failed to generate a data key with KMS key %s: %w", key.ARN, err)
+	}
+	return nil
+}
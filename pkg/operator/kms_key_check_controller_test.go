@@ -0,0 +1,167 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const testKMSKeyARN = "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+type fakeKMS struct {
+	key         *awsclient.KeyMetadata
+	describeErr error
+	generateErr error
+	calls       int
+}
+
+func (f *fakeKMS) DescribeKey(context.Context, string) (*awsclient.KeyMetadata, error) {
+	f.calls++
+	return f.key, f.describeErr
+}
+
+func (f *fakeKMS) GenerateDataKeyWithoutPlaintext(context.Context, string) error {
+	return f.generateErr
+}
+
+func TestKMSKeyCheckController(t *testing.T) {
+	enabledKey := &awsclient.KeyMetadata{
+		ARN:      testKMSKeyARN,
+		Enabled:  true,
+		KeyState: "Enabled",
+		KeyUsage: "ENCRYPT_DECRYPT",
+		KeySpec:  "SYMMETRIC_DEFAULT",
+	}
+	pendingDeletion := *enabledKey
+	pendingDeletion.Enabled = false
+	pendingDeletion.KeyState = "PendingDeletion"
+	asymmetric := *enabledKey
+	asymmetric.KeySpec = "RSA_2048"
+
+	tests := []struct {
+		name          string
+		overrides     string
+		kms           *fakeKMS
+		clientErr     error
+		expectedError string
+		expectedCalls int
+	}{
+		{
+			name:          "usable key",
+			overrides:     `{"kmsKeyARN": "` + testKMSKeyARN + `"}`,
+			kms:           &fakeKMS{key: enabledKey},
+			expectedCalls: 1,
+		},
+		{
+			name: "no key",
+			kms:  &fakeKMS{key: enabledKey},
+		},
+		{
+			name:      "disabled",
+			overrides: `{"kmsKeyARN": "` + testKMSKeyARN + `", "kmsKeyCheck": {"disabled": true}}`,
+			kms:       &fakeKMS{key: enabledKey},
+		},
+		{
+			name:          "other region",
+			overrides:     `{"kmsKeyARN": "arn:aws:kms:eu-west-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"}`,
+			kms:           &fakeKMS{key: enabledKey},
+			expectedError: "KMS key arn:aws:kms:eu-west-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab is in region eu-west-1, EBS volumes in region us-east-1 can only be encrypted with a key of the same region",
+		},
+		{
+			name:          "missing key",
+			overrides:     `{"kmsKeyARN": "` + testKMSKeyARN + `"}`,
+			kms:           &fakeKMS{describeErr: &awsclient.APIError{StatusCode: 400, Code: "NotFoundException"}},
+			expectedError: "KMS key " + testKMSKeyARN + " does not exist, check kmsKeyARN in the driver configuration",
+			expectedCalls: 1,
+		},
+		{
+			name:          "pending deletion",
+			overrides:     `{"kmsKeyARN": "` + testKMSKeyARN + `"}`,
+			kms:           &fakeKMS{key: &pendingDeletion},
+			expectedError: "KMS key " + testKMSKeyARN + " is in state PendingDeletion, it must be enabled to encrypt volumes",
+			expectedCalls: 1,
+		},
+		{
+			name:          "asymmetric key",
+			overrides:     `{"kmsKeyARN": "` + testKMSKeyARN + `"}`,
+			kms:           &fakeKMS{key: &asymmetric},
+			expectedError: "KMS key " + testKMSKeyARN + " is a RSA_2048 key for ENCRYPT_DECRYPT, EBS needs a symmetric encryption key",
+			expectedCalls: 1,
+		},
+		{
+			name:      "data key denied",
+			overrides: `{"kmsKeyARN": "` + testKMSKeyARN + `"}`,
+			kms:       &fakeKMS{key: enabledKey, generateErr: &awsclient.APIError{StatusCode: 400, Code: "AccessDeniedException", Message: "denied"}},
+			expectedError: "the AWS credentials of the driver are not allowed to call kms:GenerateDataKeyWithoutPlaintext on KMS key " + testKMSKeyARN + ", " +
+				"add it to the IAM policy of the credentials and the key policy, or set kmsKeyCheck.disabled when the key policy only allows EC2 through kms:ViaService: denied",
+			expectedCalls: 1,
+		},
+		{
+			name:      "no region",
+			overrides: `{"kmsKeyARN": "` + testKMSKeyARN + `"}`,
+			kms:       &fakeKMS{},
+			clientErr: &awsHealthCheckError{
+				status:  opv1.ConditionUnknown,
+				reason:  "NoRegion",
+				message: "no region",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace},
+			}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: driverContainerName,
+				Env:  []corev1.EnvVar{{Name: "AWS_REGION", Value: "us-east-1"}},
+			}}
+			informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(deployment)
+
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c := &kmsKeyCheckController{
+				operatorClient:   v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil),
+				deploymentLister: informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace),
+				newKMSClient: func(*appsv1.Deployment) (kmsAPI, error) {
+					if test.clientErr != nil {
+						return nil, test.clientErr
+					}
+					return test.kms, nil
+				},
+				now: time.Now,
+			}
+
+			syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+			// The second sync reports the result of the first check without calling KMS again.
+			for i := 0; i < 2; i++ {
+				err := c.sync(context.TODO(), syncCtx)
+				if test.expectedError == "" && err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if test.expectedError != "" && (err == nil || err.Error() != test.expectedError) {
+					t.Fatalf("expected error %q, got %v", test.expectedError, err)
+				}
+			}
+			if test.kms.calls != test.expectedCalls {
+				t.Errorf("expected %d DescribeKey calls, got %d", test.expectedCalls, test.kms.calls)
+			}
+		})
+	}
+}
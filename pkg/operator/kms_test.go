@@ -0,0 +1,69 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestWithKMSKeyHook(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      map[string]string
+		expectedError bool
+	}{
+		{
+			name:     "no key",
+			expected: map[string]string{"type": "gp3", "encrypted": "true"},
+		},
+		{
+			name:      "key",
+			overrides: `{"kmsKeyARN": "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"}`,
+			expected: map[string]string{
+				"type":      "gp3",
+				"encrypted": "true",
+				"kmsKeyId":  "arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+			},
+		},
+		{
+			name:      "alias in GovCloud",
+			overrides: `{"kmsKeyARN": "arn:aws-us-gov:kms:us-gov-west-1:123456789012:alias/ebs"}`,
+			expected: map[string]string{
+				"type":      "gp3",
+				"encrypted": "true",
+				"kmsKeyId":  "arn:aws-us-gov:kms:us-gov-west-1:123456789012:alias/ebs",
+			},
+		},
+		{
+			name:          "key ID instead of ARN",
+			overrides:     `{"kmsKeyARN": "1234abcd-12ab-34cd-56ef-1234567890ab"}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			sc := newTestStorageClass("gp3-csi", nil)
+			sc.Parameters = map[string]string{"type": "gp3", "encrypted": "true"}
+			err := withKMSKeyHook()(spec, sc)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, sc.Parameters; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected parameters\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
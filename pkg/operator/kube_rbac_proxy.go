@@ -0,0 +1,86 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// Modes of the kube-rbac-proxy sidecars of the controller on Hypershift.
+	kubeRBACProxiesKeep   = "Keep"
+	kubeRBACProxiesRemove = "Remove"
+
+	kubeRBACProxyContainerSuffix = "-kube-rbac-proxy"
+)
+
+// removeKubeRBACProxies returns true when the kube-rbac-proxy sidecars of the controller are removed.
+func removeKubeRBACProxies(spec *opv1.OperatorSpec) (bool, error) {
+	cfg, err := getDriverConfig(spec)
+	if err != nil {
+		return false, err
+	}
+	switch cfg.KubeRBACProxies {
+	case "", kubeRBACProxiesKeep:
+		return false, nil
+	case kubeRBACProxiesRemove:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid kubeRBACProxies %q, expected %s or %s", cfg.KubeRBACProxies, kubeRBACProxiesKeep, kubeRBACProxiesRemove)
+	}
+}
+
+// withKubeRBACProxiesHook removes the kube-rbac-proxy sidecars from the controller Deployment on Hypershift when
+// kubeRBACProxies is Remove. The sidecars serve the metrics with a certificate of the management cluster by default,
+// e.g. for management clusters whose Prometheus doesn't scrape the hosted control planes they can be removed.
+// The CSI sidecars listen on localhost only, so their metrics are not served at all then.
+func withKubeRBACProxiesHook(isHypershift bool) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		remove, err := removeKubeRBACProxies(spec)
+		if err != nil || !remove {
+			return err
+		}
+		if !isHypershift {
+			return fmt.Errorf("kubeRBACProxies %s is only supported on Hypershift, the metrics of standalone clusters are always authenticated", kubeRBACProxiesRemove)
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		filtered := []corev1.Container{}
+		for i := range podSpec.Containers {
+			if !strings.HasSuffix(podSpec.Containers[i].Name, kubeRBACProxyContainerSuffix) {
+				filtered = append(filtered, podSpec.Containers[i])
+			}
+		}
+		podSpec.Containers = filtered
+		return nil
+	}
+}
+
+// kubeRBACProxiesKept returns a function that reports whether the kube-rbac-proxy sidecars of the controller are kept.
+// It's used to create or delete the ServiceMonitor of the controller, which would only find down targets otherwise.
+func kubeRBACProxiesKept(isHypershift bool, operatorClient v1helpers.OperatorClient) resourceapply.ConditionalFunction {
+	return func() bool {
+		if !isHypershift {
+			return true
+		}
+		spec, _, _, err := operatorClient.GetOperatorState()
+		if err != nil {
+			klog.Warningf("Failed to get the operator spec, assuming the kube-rbac-proxy sidecars are kept: %v", err)
+			return true
+		}
+		remove, err := removeKubeRBACProxies(spec)
+		if err != nil {
+			// The error is reported by the controller Deployment hook, keep the ServiceMonitor untouched.
+			return true
+		}
+		return !remove
+	}
+}
@@ -0,0 +1,106 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWithKubeRBACProxiesHook(t *testing.T) {
+	allContainers := []string{
+		"csi-driver",
+		"driver-kube-rbac-proxy",
+		"csi-provisioner",
+		"provisioner-kube-rbac-proxy",
+		"token-minter",
+	}
+
+	tests := []struct {
+		name          string
+		isHypershift  bool
+		overrides     string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:         "kept by default",
+			isHypershift: true,
+			expected:     allContainers,
+		},
+		{
+			name:         "kept",
+			isHypershift: true,
+			overrides:    `{"kubeRBACProxies": "Keep"}`,
+			expected:     allContainers,
+		},
+		{
+			name:         "removed",
+			isHypershift: true,
+			overrides:    `{"kubeRBACProxies": "Remove"}`,
+			expected:     []string{"csi-driver", "csi-provisioner", "token-minter"},
+		},
+		{
+			name:          "removed on standalone",
+			overrides:     `{"kubeRBACProxies": "Remove"}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid value",
+			isHypershift:  true,
+			overrides:     `{"kubeRBACProxies": "Drop"}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := &appsv1.Deployment{}
+			for _, name := range allContainers {
+				deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, corev1.Container{Name: name})
+			}
+			err := withKubeRBACProxiesHook(test.isHypershift)(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				names = append(names, container.Name)
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("expected containers %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestKubeRBACProxiesKept(t *testing.T) {
+	spec := &opv1.OperatorSpec{
+		ManagementState: opv1.Managed,
+		UnsupportedConfigOverrides: runtime.RawExtension{
+			Raw: []byte(`{"kubeRBACProxies": "Remove"}`),
+		},
+	}
+	operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+
+	if kubeRBACProxiesKept(true, operatorClient)() {
+		t.Errorf("expected the kube-rbac-proxy sidecars to be removed on Hypershift")
+	}
+	if !kubeRBACProxiesKept(false, operatorClient)() {
+		t.Errorf("expected the kube-rbac-proxy sidecars to be kept on standalone clusters")
+	}
+}
@@ -0,0 +1,82 @@
+package operator
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/config/leaderelection"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// The leader election of the CSI sidecars of the controller can be tuned for dense management clusters, where the
+// lease renewals of the hosted control planes add up. The defaults are the recommended values of OpenShift.
+const (
+	leaseDurationEnvName = "LEADER_ELECTION_LEASE_DURATION"
+	renewDeadlineEnvName = "LEADER_ELECTION_RENEW_DEADLINE"
+	retryPeriodEnvName   = "LEADER_ELECTION_RETRY_PERIOD"
+)
+
+// sidecarLeaderElection returns the leader election timings of the CSI sidecars from the environment, with the
+// recommended values of the ones that are not set.
+func sidecarLeaderElection() (configv1.LeaderElection, error) {
+	config := configv1.LeaderElection{}
+	for _, env := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{leaseDurationEnvName, &config.LeaseDuration.Duration},
+		{renewDeadlineEnvName, &config.RenewDeadline.Duration},
+		{retryPeriodEnvName, &config.RetryPeriod.Duration},
+	} {
+		value := os.Getenv(env.name)
+		if value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < time.Second {
+			return config, fmt.Errorf("invalid %s %q: it must be a duration of at least 1s", env.name, value)
+		}
+		*env.value = duration
+	}
+	config = leaderelection.LeaderElectionDefaulting(config, "default", "default")
+
+	// The same constraints as the leader election of client-go, which would make the sidecars crash.
+	if config.LeaseDuration.Duration <= config.RenewDeadline.Duration {
+		return config, fmt.Errorf("invalid leader election: the lease duration %s must be greater than the renew deadline %s",
+			config.LeaseDuration.Duration, config.RenewDeadline.Duration)
+	}
+	if float64(config.RenewDeadline.Duration) <= 1.2*float64(config.RetryPeriod.Duration) {
+		return config, fmt.Errorf("invalid leader election: the renew deadline %s must be greater than 1.2 times the retry period %s",
+			config.RenewDeadline.Duration, config.RetryPeriod.Duration)
+	}
+	return config, nil
+}
+
+// withLeaderElectionDeploymentHook sets the leader election timings of the CSI sidecars of the controller, which
+// the assets set to the recommended values.
+func withLeaderElectionDeploymentHook(config configv1.LeaderElection) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		for i := range deployment.Spec.Template.Spec.Containers {
+			container := &deployment.Spec.Template.Spec.Containers[i]
+			if !hasContainerArg(container, "leader-election") {
+				continue
+			}
+			setLeaderElectionArg(container, "leader-election-lease-duration", config.LeaseDuration.Duration)
+			setLeaderElectionArg(container, "leader-election-renew-deadline", config.RenewDeadline.Duration)
+			setLeaderElectionArg(container, "leader-election-retry-period", config.RetryPeriod.Duration)
+		}
+		return nil
+	}
+}
+
+// setLeaderElectionArg sets the duration argument in seconds, like the library does for the assets.
+func setLeaderElectionArg(container *corev1.Container, name string, value time.Duration) {
+	setContainerArg(container, name, strconv.Itoa(int(value.Seconds()))+"s")
+}
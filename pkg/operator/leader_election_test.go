@@ -0,0 +1,120 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/pointer"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestSidecarLeaderElection(t *testing.T) {
+	tests := []struct {
+		name                  string
+		env                   map[string]string
+		expectedLeaseDuration string
+		expectedError         bool
+	}{
+		{
+			name:                  "defaults",
+			expectedLeaseDuration: "137s",
+		},
+		{
+			name: "longer lease",
+			env: map[string]string{
+				leaseDurationEnvName: "270s",
+				renewDeadlineEnvName: "240s",
+				retryPeriodEnvName:   "60s",
+			},
+			expectedLeaseDuration: "270s",
+		},
+		{
+			name:          "renew deadline longer than the lease",
+			env:           map[string]string{renewDeadlineEnvName: "5m"},
+			expectedError: true,
+		},
+		{
+			name:          "retry period too long for the renew deadline",
+			env:           map[string]string{retryPeriodEnvName: "100s"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid duration",
+			env:           map[string]string{leaseDurationEnvName: "137"},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{leaseDurationEnvName, renewDeadlineEnvName, retryPeriodEnvName} {
+				t.Setenv(name, test.env[name])
+			}
+			config, err := sidecarLeaderElection()
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			asset, err := assets.ReadFile("controller.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := resourceread.ReadDeploymentV1OrDie(asset)
+			if err := withLeaderElectionDeploymentHook(config)(nil, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if !hasContainerArg(&container, "leader-election") {
+					continue
+				}
+				if value, _ := getContainerArg(&container, "leader-election-lease-duration"); value != test.expectedLeaseDuration {
+					t.Errorf("expected lease duration %s of container %s, got %s", test.expectedLeaseDuration, container.Name, value)
+				}
+			}
+		})
+	}
+}
+
+func TestGuestEventReference(t *testing.T) {
+	const controlPlaneNamespace = "clusters-test"
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-ebs-csi-driver-operator", Namespace: controlPlaneNamespace, UID: "deployment-uid"},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-ebs-csi-driver-operator-1", Namespace: controlPlaneNamespace, OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Deployment", APIVersion: "apps/v1", Name: deployment.Name, UID: deployment.UID, Controller: pointer.Bool(true)},
+		}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-ebs-csi-driver-operator-1-abcde", Namespace: controlPlaneNamespace, OwnerReferences: []metav1.OwnerReference{
+			{Kind: "ReplicaSet", APIVersion: "apps/v1", Name: replicaSet.Name, Controller: pointer.Bool(true)},
+		}},
+	}
+	t.Setenv("POD_NAME", pod.Name)
+
+	expected := corev1.ObjectReference{Kind: "Deployment", APIVersion: "apps/v1", Namespace: defaultNamespace, Name: operatorName}
+	client := fake.NewSimpleClientset(deployment, replicaSet, pod)
+	if reference := guestEventReference(context.TODO(), client, controlPlaneNamespace, defaultNamespace); *reference != expected {
+		t.Errorf("expected reference %+v, got %+v", expected, *reference)
+	}
+
+	// The Pod is not found, the reference falls back to the operator Deployment.
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	if reference := guestEventReference(ctx, fake.NewSimpleClientset(), controlPlaneNamespace, defaultNamespace); *reference != expected {
+		t.Errorf("expected reference %+v, got %+v", expected, *reference)
+	}
+}
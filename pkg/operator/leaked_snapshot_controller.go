@@ -0,0 +1,394 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// csiSnapshotNameTag is set by the driver on the snapshots it creates.
+	csiSnapshotNameTag = "CSIVolumeSnapshotName"
+	// retainedSnapshotTag is set by the operator on the snapshots of the VolumeSnapshotContents with the Retain
+	// deletion policy, which are kept on purpose when the VolumeSnapshotContent is deleted.
+	retainedSnapshotTag = "ebs.openshift.io/retained"
+
+	leakedSnapshotActionReport = "Report"
+	leakedSnapshotActionDelete = "Delete"
+
+	// leakedSnapshotCleanupConfigMapName records when the controller first searched for leaked snapshots in
+	// leakedSnapshotCleanupSinceKey. Older snapshots are never deleted, only reported.
+	leakedSnapshotCleanupConfigMapName = "aws-ebs-csi-driver-leaked-snapshot-cleanup"
+	leakedSnapshotCleanupSinceKey      = "since"
+
+	defaultLeakedSnapshotMinAge = 24 * time.Hour
+	minLeakedSnapshotMinAge     = time.Hour
+	leakedSnapshotCheckInterval = time.Hour
+	// maxListedLeakedSnapshots limits the number of snapshot IDs in the condition message.
+	maxListedLeakedSnapshots = 10
+	ec2Timeout               = 30 * time.Second
+)
+
+var volumeSnapshotContentGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshotcontents",
+}
+
+// ec2SnapshotAPI is the part of the EC2 API used by the leakedSnapshotController.
+type ec2SnapshotAPI interface {
+	DescribeSnapshots(ctx context.Context, filters map[string][]string) ([]awsclient.Snapshot, error)
+	DeleteSnapshot(ctx context.Context, snapshotID string) error
+	CreateTags(ctx context.Context, resourceID string, tags map[string]string) error
+}
+
+// leakedSnapshotController finds the EBS snapshots created by the driver for the cluster that no
+// VolumeSnapshotContent references, e.g. the snapshots left behind by failed CSI snapshot operations,
+// and reports or deletes the ones older than the minimum age when enabled in the driver configuration.
+// Only the snapshots with both the cluster tag and the CSIVolumeSnapshotName tag are considered, so the
+// snapshots taken by DLM or by users are never touched. The snapshots that are kept on purpose are never leaked:
+// the ones of the VolumeSnapshotContents with the Retain deletion policy, which the controller tags with
+// retainedSnapshotTag while the VolumeSnapshotContent exists, and the ones with a tag of the excludeTags of the
+// configuration, e.g. the backups of a backup tool. Nothing is done while the snapshot CRDs are missing.
+// Only the snapshots created after the first search, which is recorded in the leakedSnapshotCleanupConfigMapName
+// ConfigMap, are deleted: older snapshots may have been retained on purpose before the controller could tag them.
+//
+// It produces the following conditions:
+// <name>LeakedSnapshots: True when leaked snapshots are left, they're listed in the message.
+// <name>Degraded: produced when the sync() method returns an error.
+type leakedSnapshotController struct {
+	name             string
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+	configMapLister  corev1listers.ConfigMapNamespaceLister
+	kubeClient       kubeclient.Interface
+	namespace        string
+	contentClient    dynamic.ResourceInterface
+	crdsExist        resourceapply.ConditionalFunction
+	// newEC2Client returns an EC2 client configured like the driver in the controller Deployment.
+	newEC2Client func(deployment *appsv1.Deployment) (ec2SnapshotAPI, error)
+
+	now func() time.Time
+	// lastCheck and lastConfig record the last check, to check again when the interval elapses
+	// or the configuration changes.
+	lastCheck  time.Time
+	lastConfig leakedSnapshotCleanupConfig
+}
+
+func newLeakedSnapshotController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	dynamicClient dynamic.Interface,
+	crdsExist resourceapply.ConditionalFunction,
+	crdInformers []factory.Informer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	builder := newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient)
+	c := &leakedSnapshotController{
+		name:             name,
+		operatorClient:   operatorClient,
+		deploymentLister: deploymentInformer.Lister().Deployments(namespace),
+		configMapLister:  configMapInformer.Lister().ConfigMaps(namespace),
+		kubeClient:       kubeClient,
+		namespace:        namespace,
+		contentClient:    dynamicClient.Resource(volumeSnapshotContentGVR),
+		crdsExist:        crdsExist,
+		newEC2Client: func(deployment *appsv1.Deployment) (ec2SnapshotAPI, error) {
+			client, _, err := builder.newClient(deployment, "ec2", ec2Timeout)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+		now: time.Now,
+	}
+	informers := append([]factory.Informer{
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+	}, crdInformers...)
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		informers...,
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *leakedSnapshotController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+	conditionType := c.name + "LeakedSnapshots"
+	if cfg.LeakedSnapshotCleanup == nil {
+		c.lastCheck = time.Time{}
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+	action, minAge, err := parseLeakedSnapshotCleanupConfig(cfg.LeakedSnapshotCleanup)
+	if err != nil {
+		return err
+	}
+	if !c.crdsExist() {
+		return nil
+	}
+
+	now := c.now()
+	if !c.lastCheck.IsZero() && equality.Semantic.DeepEqual(*cfg.LeakedSnapshotCleanup, c.lastConfig) && now.Sub(c.lastCheck) < leakedSnapshotCheckInterval {
+		return nil
+	}
+
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// The credentials of the driver are not known until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	clusterID, err := driverClusterID(deployment)
+	if err != nil {
+		return err
+	}
+
+	since, err := c.cleanupSince(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	// The contents are listed before the snapshots, so a snapshot created in between is not taken for a leak.
+	referenced, retained, err := c.referencedSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := c.newEC2Client(deployment)
+	if err != nil {
+		return err
+	}
+	snapshots, err := client.DescribeSnapshots(ctx, map[string][]string{
+		"tag-key":                         {csiSnapshotNameTag},
+		"tag:" + clusterTagKey(clusterID): {"owned"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the EBS snapshots of the cluster: %w", err)
+	}
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if !retained[snapshot.SnapshotID] || snapshot.Tags[retainedSnapshotTag] != "" {
+			continue
+		}
+		if err := client.CreateTags(ctx, snapshot.SnapshotID, map[string]string{retainedSnapshotTag: "true"}); err != nil {
+			return fmt.Errorf("failed to tag retained EBS snapshot %s: %w", snapshot.SnapshotID, err)
+		}
+		if snapshot.Tags == nil {
+			snapshot.Tags = map[string]string{}
+		}
+		snapshot.Tags[retainedSnapshotTag] = "true"
+	}
+	leaked := findLeakedSnapshots(now, minAge, snapshots, referenced, cfg.LeakedSnapshotCleanup.ExcludeTags)
+	startTimes := map[string]time.Time{}
+	for _, snapshot := range snapshots {
+		startTimes[snapshot.SnapshotID] = snapshot.StartTime
+	}
+
+	var left []string
+	if action == leakedSnapshotActionDelete {
+		for _, id := range leaked {
+			if !startTimes[id].After(since) {
+				left = append(left, id)
+				continue
+			}
+			err := client.DeleteSnapshot(ctx, id)
+			var apiErr *awsclient.APIError
+			switch {
+			case err == nil:
+				klog.V(2).Infof("Deleted leaked EBS snapshot %s", id)
+				syncCtx.Recorder().Eventf("LeakedSnapshotDeleted", "Deleted leaked EBS snapshot %s", id)
+			case errors.As(err, &apiErr) && apiErr.Code == "InvalidSnapshot.NotFound":
+			case errors.As(err, &apiErr) && apiErr.Code == "InvalidSnapshot.InUse":
+				// Snapshots used by an AMI can't be deleted.
+				klog.Warningf("Leaked EBS snapshot %s can't be deleted: %v", id, err)
+				left = append(left, id)
+			default:
+				return fmt.Errorf("failed to delete leaked EBS snapshot %s: %w", id, err)
+			}
+		}
+	} else {
+		left = leaked
+	}
+	c.lastCheck = now
+	c.lastConfig = *cfg.LeakedSnapshotCleanup
+
+	cond := opv1.OperatorCondition{
+		Type:   conditionType,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(left) > 0 {
+		listed := left
+		if len(listed) > maxListedLeakedSnapshots {
+			listed = append(listed[:maxListedLeakedSnapshots:maxListedLeakedSnapshots], "...")
+		}
+		cond.Status = opv1.ConditionTrue
+		cond.Reason = "LeakedSnapshotsFound"
+		cond.Message = fmt.Sprintf("Found %d EBS snapshots of the cluster older than %s without a VolumeSnapshotContent: %s",
+			len(left), minAge, strings.Join(listed, ", "))
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// cleanupSince returns the time of the first search for leaked snapshots, and records it on the first search.
+func (c *leakedSnapshotController) cleanupSince(ctx context.Context, now time.Time) (time.Time, error) {
+	cm, err := c.configMapLister.Get(leakedSnapshotCleanupConfigMapName)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      leakedSnapshotCleanupConfigMapName,
+				Namespace: c.namespace,
+			},
+			Data: map[string]string{
+				leakedSnapshotCleanupSinceKey: now.UTC().Format(time.RFC3339),
+			},
+		}
+		cm, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Create(ctx, cm, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			cm, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Get(ctx, leakedSnapshotCleanupConfigMapName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get the start of the leaked snapshot cleanup: %w", err)
+	}
+	since, err := time.Parse(time.RFC3339, cm.Data[leakedSnapshotCleanupSinceKey])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s in ConfigMap %s/%s: %w", leakedSnapshotCleanupSinceKey, c.namespace, leakedSnapshotCleanupConfigMapName, err)
+	}
+	return since, nil
+}
+
+// referencedSnapshots returns the IDs of the snapshots referenced by VolumeSnapshotContents, either
+// created by the driver or pre-provisioned, and the IDs of the ones referenced by VolumeSnapshotContents with
+// the Retain deletion policy.
+func (c *leakedSnapshotController) referencedSnapshots(ctx context.Context) (map[string]bool, map[string]bool, error) {
+	list, err := c.contentClient.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list VolumeSnapshotContents: %w", err)
+	}
+	referenced := map[string]bool{}
+	retained := map[string]bool{}
+	for _, item := range list.Items {
+		policy, _, _ := unstructured.NestedString(item.Object, "spec", "deletionPolicy")
+		for _, path := range [][]string{{"status", "snapshotHandle"}, {"spec", "source", "snapshotHandle"}} {
+			handle, _, _ := unstructured.NestedString(item.Object, path...)
+			if handle == "" {
+				continue
+			}
+			referenced[handle] = true
+			if policy == "Retain" {
+				retained[handle] = true
+			}
+		}
+	}
+	return referenced, retained, nil
+}
+
+// findLeakedSnapshots returns the sorted IDs of the snapshots older than minAge that are not referenced, retained
+// or excluded by one of their tags.
+func findLeakedSnapshots(now time.Time, minAge time.Duration, snapshots []awsclient.Snapshot, referenced map[string]bool, excludeTags []string) []string {
+	var leaked []string
+	for _, snapshot := range snapshots {
+		if referenced[snapshot.SnapshotID] || snapshot.State == "pending" || now.Sub(snapshot.StartTime) < minAge {
+			continue
+		}
+		if _, ok := snapshot.Tags[retainedSnapshotTag]; ok || hasAnyTag(snapshot.Tags, excludeTags) {
+			continue
+		}
+		leaked = append(leaked, snapshot.SnapshotID)
+	}
+	sort.Strings(leaked)
+	return leaked
+}
+
+func hasAnyTag(tags map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := tags[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parseLeakedSnapshotCleanupConfig(cfg *leakedSnapshotCleanupConfig) (string, time.Duration, error) {
+	action := leakedSnapshotActionReport
+	if cfg.Action != "" {
+		action = cfg.Action
+		if action != leakedSnapshotActionReport && action != leakedSnapshotActionDelete {
+			return "", 0, fmt.Errorf("invalid leakedSnapshotCleanup action %q: it must be %s or %s", action, leakedSnapshotActionReport, leakedSnapshotActionDelete)
+		}
+	}
+	for _, key := range cfg.ExcludeTags {
+		if key == "" {
+			return "", 0, fmt.Errorf("invalid leakedSnapshotCleanup excludeTags: the tag keys must not be empty")
+		}
+	}
+	minAge := defaultLeakedSnapshotMinAge
+	if cfg.MinAge != "" {
+		var err error
+		minAge, err = time.ParseDuration(cfg.MinAge)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid leakedSnapshotCleanup minAge %q: %w", cfg.MinAge, err)
+		}
+		if minAge < minLeakedSnapshotMinAge {
+			return "", 0, fmt.Errorf("invalid leakedSnapshotCleanup minAge %q: it must be at least %s", cfg.MinAge, minLeakedSnapshotMinAge)
+		}
+	}
+	return action, minAge, nil
+}
@@ -0,0 +1,269 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+// fakeContentClient lists the VolumeSnapshotContents. Only List is implemented.
+type fakeContentClient struct {
+	dynamic.ResourceInterface
+	objects []unstructured.Unstructured
+}
+
+func (f *fakeContentClient) List(_ context.Context, _ metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return &unstructured.UnstructuredList{Items: f.objects}, nil
+}
+
+// fakeEC2Snapshots is an in-memory EC2 snapshot API. The filters are not applied.
+type fakeEC2Snapshots struct {
+	snapshots []awsclient.Snapshot
+	deleted   []string
+	tagged    []string
+}
+
+func (f *fakeEC2Snapshots) DescribeSnapshots(_ context.Context, _ map[string][]string) ([]awsclient.Snapshot, error) {
+	return f.snapshots, nil
+}
+
+func (f *fakeEC2Snapshots) DeleteSnapshot(_ context.Context, snapshotID string) error {
+	if snapshotID == "snap-ami" {
+		return &awsclient.APIError{StatusCode: 400, Code: "InvalidSnapshot.InUse"}
+	}
+	f.deleted = append(f.deleted, snapshotID)
+	return nil
+}
+
+func (f *fakeEC2Snapshots) CreateTags(_ context.Context, resourceID string, tags map[string]string) error {
+	if tags[retainedSnapshotTag] != "true" {
+		return fmt.Errorf("unexpected tags %v", tags)
+	}
+	f.tagged = append(f.tagged, resourceID)
+	return nil
+}
+
+func newTestSnapshotContent(statusHandle, sourceHandle string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{}}
+	unstructured.SetNestedField(obj.Object, "Delete", "spec", "deletionPolicy")
+	if statusHandle != "" {
+		unstructured.SetNestedField(obj.Object, statusHandle, "status", "snapshotHandle")
+	}
+	if sourceHandle != "" {
+		unstructured.SetNestedField(obj.Object, sourceHandle, "spec", "source", "snapshotHandle")
+	}
+	return obj
+}
+
+func TestLeakedSnapshotController(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	snapshot := func(id string, age time.Duration) awsclient.Snapshot {
+		return awsclient.Snapshot{SnapshotID: id, State: "completed", StartTime: now.Add(-age)}
+	}
+	snapshots := []awsclient.Snapshot{
+		snapshot("snap-dynamic", 48*time.Hour),
+		snapshot("snap-static", 48*time.Hour),
+		snapshot("snap-young", time.Hour),
+		snapshot("snap-leaked", 48*time.Hour),
+		snapshot("snap-ami", 48*time.Hour),
+		{SnapshotID: "snap-pending", State: "pending", StartTime: now.Add(-48 * time.Hour)},
+	}
+	retainedContent := newTestSnapshotContent("snap-retain-policy", "")
+	unstructured.SetNestedField(retainedContent.Object, "Retain", "spec", "deletionPolicy")
+	contents := []unstructured.Unstructured{
+		newTestSnapshotContent("snap-dynamic", ""),
+		newTestSnapshotContent("", "snap-static"),
+		retainedContent,
+	}
+
+	tests := []struct {
+		name              string
+		overrides         string
+		crdsExist         bool
+		expectedDeleted   []string
+		snapshots         []awsclient.Snapshot
+		expectedTagged    []string
+		expectedCondition opv1.ConditionStatus
+		expectedMessage   string
+		expectedError     bool
+		// since is the recorded first search, none when zero.
+		since time.Time
+	}{
+		{
+			name:      "disabled",
+			crdsExist: true,
+		},
+		{
+			name:              "report",
+			overrides:         `{"leakedSnapshotCleanup": {}}`,
+			crdsExist:         true,
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage:   "Found 2 EBS snapshots of the cluster older than 24h0m0s without a VolumeSnapshotContent: snap-ami, snap-leaked",
+		},
+		{
+			name:              "delete",
+			overrides:         `{"leakedSnapshotCleanup": {"action": "Delete", "minAge": "1h"}}`,
+			crdsExist:         true,
+			since:             now.Add(-72 * time.Hour),
+			expectedDeleted:   []string{"snap-leaked", "snap-young"},
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage:   "Found 1 EBS snapshots of the cluster older than 1h0m0s without a VolumeSnapshotContent: snap-ami",
+		},
+		{
+			name:              "snapshots older than the first search are not deleted",
+			overrides:         `{"leakedSnapshotCleanup": {"action": "Delete", "minAge": "1h"}}`,
+			crdsExist:         true,
+			since:             now.Add(-24 * time.Hour),
+			expectedDeleted:   []string{"snap-young"},
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage:   "Found 2 EBS snapshots of the cluster older than 1h0m0s without a VolumeSnapshotContent: snap-ami, snap-leaked",
+		},
+		{
+			name:              "first search",
+			overrides:         `{"leakedSnapshotCleanup": {"action": "Delete", "minAge": "1h"}}`,
+			crdsExist:         true,
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage:   "Found 3 EBS snapshots of the cluster older than 1h0m0s without a VolumeSnapshotContent: snap-ami, snap-leaked, snap-young",
+		},
+		{
+			name:      "retained and excluded snapshots",
+			overrides: `{"leakedSnapshotCleanup": {"action": "Delete", "excludeTags": ["velero.io/backup"]}}`,
+			crdsExist: true,
+			since:     now.Add(-72 * time.Hour),
+			snapshots: []awsclient.Snapshot{
+				// Still referenced by a VolumeSnapshotContent with the Retain deletion policy.
+				snapshot("snap-retain-policy", 48*time.Hour),
+				// Its VolumeSnapshotContent with the Retain deletion policy was deleted.
+				{SnapshotID: "snap-retained", State: "completed", StartTime: now.Add(-48 * time.Hour),
+					Tags: map[string]string{retainedSnapshotTag: "true"}},
+				{SnapshotID: "snap-backup", State: "completed", StartTime: now.Add(-48 * time.Hour),
+					Tags: map[string]string{"velero.io/backup": "nightly"}},
+				snapshot("snap-leaked", 48*time.Hour),
+			},
+			expectedDeleted:   []string{"snap-leaked"},
+			expectedTagged:    []string{"snap-retain-policy"},
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:          "empty excluded tag",
+			overrides:     `{"leakedSnapshotCleanup": {"excludeTags": [""]}}`,
+			crdsExist:     true,
+			expectedError: true,
+		},
+		{
+			name:      "CRDs missing",
+			overrides: `{"leakedSnapshotCleanup": {"action": "Delete"}}`,
+		},
+		{
+			name:          "invalid action",
+			overrides:     `{"leakedSnapshotCleanup": {"action": "Purge"}}`,
+			crdsExist:     true,
+			expectedError: true,
+		},
+		{
+			name:          "too short minAge",
+			overrides:     `{"leakedSnapshotCleanup": {"minAge": "10m"}}`,
+			crdsExist:     true,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace},
+			}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: driverContainerName,
+				Args: []string{"--k8s-tag-cluster-id=" + testClusterID},
+			}}
+			var objects []runtime.Object
+			if !test.since.IsZero() {
+				objects = append(objects, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: leakedSnapshotCleanupConfigMapName, Namespace: defaultNamespace},
+					Data:       map[string]string{leakedSnapshotCleanupSinceKey: test.since.Format(time.RFC3339)},
+				})
+			}
+			kubeClient := fake.NewSimpleClientset(objects...)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(deployment)
+			for _, obj := range objects {
+				informerFactory.Core().V1().ConfigMaps().Informer().GetIndexer().Add(obj)
+			}
+
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+			ec2 := &fakeEC2Snapshots{snapshots: snapshots}
+			if test.snapshots != nil {
+				ec2.snapshots = test.snapshots
+			}
+			c := &leakedSnapshotController{
+				name:             "Test",
+				operatorClient:   operatorClient,
+				deploymentLister: informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace),
+				configMapLister:  informerFactory.Core().V1().ConfigMaps().Lister().ConfigMaps(defaultNamespace),
+				kubeClient:       kubeClient,
+				namespace:        defaultNamespace,
+				contentClient:    &fakeContentClient{objects: contents},
+				crdsExist:        func() bool { return test.crdsExist },
+				newEC2Client:     func(*appsv1.Deployment) (ec2SnapshotAPI, error) { return ec2, nil },
+				now:              func() time.Time { return now },
+			}
+
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !equality.Semantic.DeepEqual(test.expectedDeleted, ec2.deleted) {
+				t.Errorf("expected deleted snapshots %v, got %v", test.expectedDeleted, ec2.deleted)
+			}
+			if !equality.Semantic.DeepEqual(test.expectedTagged, ec2.tagged) {
+				t.Errorf("expected tagged snapshots %v, got %v", test.expectedTagged, ec2.tagged)
+			}
+			if test.since.IsZero() && test.expectedCondition != "" {
+				cm, err := kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(context.TODO(), leakedSnapshotCleanupConfigMapName, metav1.GetOptions{})
+				if err != nil || cm.Data[leakedSnapshotCleanupSinceKey] != now.Format(time.RFC3339) {
+					t.Errorf("expected the first search to be recorded, got %+v, %v", cm, err)
+				}
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestLeakedSnapshots")
+			if test.expectedCondition == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != test.expectedCondition || cond.Message != test.expectedMessage {
+				t.Errorf("expected LeakedSnapshots=%s with message %q, got %+v", test.expectedCondition, test.expectedMessage, cond)
+			}
+		})
+	}
+}
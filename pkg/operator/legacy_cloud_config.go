@@ -0,0 +1,184 @@
+package operator
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+)
+
+// cloudConfigKey of the kube-cloud-config ConfigMap with the legacy AWS cloud provider config.
+const cloudConfigKey = "cloud.conf"
+
+// legacyCloudConfig are the settings of the legacy AWS cloud provider config that the driver uses.
+type legacyCloudConfig struct {
+	// Region of the Global section, or the region of its Zone.
+	Region string
+	// ServiceEndpoints of the ServiceOverride sections of the region.
+	ServiceEndpoints []configv1.AWSServiceEndpoint
+}
+
+// legacyCloudConfigInfraLister returns the Infrastructure with the region and the service endpoints of the legacy
+// AWS cloud provider config in the kube-cloud-config ConfigMap, for clusters that were configured with it by older
+// tooling. The values of the Infrastructure take precedence, the legacy config only fills the missing ones. The
+// hooks and the controllers that read the region and the service endpoints from the Infrastructure use it.
+type legacyCloudConfigInfraLister struct {
+	configlisters.InfrastructureLister
+	cloudConfigLister corev1listers.ConfigMapNamespaceLister
+}
+
+var _ configlisters.InfrastructureLister = &legacyCloudConfigInfraLister{}
+
+func newLegacyCloudConfigInfraLister(infraLister configlisters.InfrastructureLister, cloudConfigLister corev1listers.ConfigMapNamespaceLister) configlisters.InfrastructureLister {
+	return &legacyCloudConfigInfraLister{
+		InfrastructureLister: infraLister,
+		cloudConfigLister:    cloudConfigLister,
+	}
+}
+
+func (l *legacyCloudConfigInfraLister) List(selector labels.Selector) ([]*configv1.Infrastructure, error) {
+	infras, err := l.InfrastructureLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for i := range infras {
+		if infras[i], err = l.withLegacyCloudConfig(infras[i]); err != nil {
+			return nil, err
+		}
+	}
+	return infras, nil
+}
+
+func (l *legacyCloudConfigInfraLister) Get(name string) (*configv1.Infrastructure, error) {
+	infra, err := l.InfrastructureLister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.withLegacyCloudConfig(infra)
+}
+
+// withLegacyCloudConfig returns a copy of the Infrastructure with the missing region and service endpoints
+// of the legacy cloud provider config.
+func (l *legacyCloudConfigInfraLister) withLegacyCloudConfig(infra *configv1.Infrastructure) (*configv1.Infrastructure, error) {
+	if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.Type != "" && infra.Status.PlatformStatus.Type != configv1.AWSPlatformType {
+		return infra, nil
+	}
+	cm, err := l.cloudConfigLister.Get(cloudConfigName)
+	if apierrors.IsNotFound(err) {
+		return infra, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[cloudConfigKey]
+	if !ok {
+		return infra, nil
+	}
+	legacy, err := parseLegacyCloudConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the %s key of ConfigMap %s: %w", cloudConfigKey, cloudConfigName, err)
+	}
+
+	infra = infra.DeepCopy()
+	if infra.Status.PlatformStatus == nil {
+		infra.Status.PlatformStatus = &configv1.PlatformStatus{Type: configv1.AWSPlatformType}
+	}
+	if infra.Status.PlatformStatus.AWS == nil {
+		infra.Status.PlatformStatus.AWS = &configv1.AWSPlatformStatus{}
+	}
+	aws := infra.Status.PlatformStatus.AWS
+	if aws.Region == "" {
+		aws.Region = legacy.Region
+	}
+	for _, legacyEndpoint := range legacy.ServiceEndpoints {
+		found := false
+		for _, endpoint := range aws.ServiceEndpoints {
+			if endpoint.Name == legacyEndpoint.Name {
+				found = true
+			}
+		}
+		if !found {
+			aws.ServiceEndpoints = append(aws.ServiceEndpoints, legacyEndpoint)
+		}
+	}
+	return infra, nil
+}
+
+// parseLegacyCloudConfig parses the INI config of the legacy AWS cloud provider, e.g.
+//
+//	[Global]
+//	Zone = us-east-1a
+//
+//	[ServiceOverride "1"]
+//	Service = ec2
+//	Region = us-east-1
+//	URL = https://ec2.example.com
+//	SigningRegion = us-east-1
+//
+// The names of the sections and the keys are case insensitive. Only the ec2, kms and sts overrides of the
+// region are kept, the other settings are not used by the driver.
+func parseLegacyCloudConfig(data string) (*legacyCloudConfig, error) {
+	var zone, region string
+	var overrides []map[string]string
+	var current map[string]string
+	global := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section %q", lineNumber, line)
+			}
+			section := strings.Fields(strings.ToLower(strings.Trim(line, "[]")))
+			switch {
+			case len(section) == 1 && section[0] == "global":
+				current = global
+			case len(section) == 2 && section[0] == "serviceoverride":
+				current = map[string]string{}
+				overrides = append(overrides, current)
+			default:
+				// Sections the driver does not use.
+				current = nil
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", lineNumber, line)
+		}
+		if current != nil {
+			current[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	region = global["region"]
+	if zone = global["zone"]; region == "" && zone != "" {
+		// The region of an availability zone, e.g. us-east-1a, is the zone without its letter.
+		region = strings.TrimRight(zone, "abcdefghijklmnopqrstuvwxyz")
+	}
+	cfg := &legacyCloudConfig{Region: region}
+	for _, override := range overrides {
+		service := strings.ToLower(override["service"])
+		if _, ok := serviceEndpointEnvNames[service]; !ok || override["url"] == "" {
+			continue
+		}
+		if override["region"] != "" && region != "" && override["region"] != region {
+			continue
+		}
+		cfg.ServiceEndpoints = append(cfg.ServiceEndpoints, configv1.AWSServiceEndpoint{Name: service, URL: override["url"]})
+	}
+	return cfg, nil
+}
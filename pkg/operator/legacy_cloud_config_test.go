@@ -0,0 +1,183 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testLegacyCloudConfig = `[Global]
+Zone = us-east-1a
+VPC = vpc-1234
+
+; The endpoints of the region.
+[ServiceOverride "1"]
+Service = ec2
+Region = us-east-1
+URL = https://ec2.example.com
+SigningRegion = us-east-1
+
+[ServiceOverride "2"]
+Service = sts
+Region = us-east-1
+URL = https://sts.example.com
+
+[serviceoverride "3"]
+service = kms
+region = us-west-2
+url = https://kms.us-west-2.example.com
+
+[ServiceOverride "4"]
+Service = s3
+URL = https://s3.example.com
+`
+
+func TestParseLegacyCloudConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expected      *legacyCloudConfig
+		expectedError bool
+	}{
+		{
+			name: "region and endpoints",
+			data: testLegacyCloudConfig,
+			expected: &legacyCloudConfig{
+				Region: "us-east-1",
+				ServiceEndpoints: []configv1.AWSServiceEndpoint{
+					{Name: "ec2", URL: "https://ec2.example.com"},
+					{Name: "sts", URL: "https://sts.example.com"},
+				},
+			},
+		},
+		{
+			name:     "explicit region",
+			data:     "[Global]\nRegion = \"us-gov-west-1\"\nZone = us-east-1a\n",
+			expected: &legacyCloudConfig{Region: "us-gov-west-1"},
+		},
+		{
+			name:     "empty config",
+			data:     "",
+			expected: &legacyCloudConfig{},
+		},
+		{
+			name:          "invalid section",
+			data:          "[Global\nZone = us-east-1a\n",
+			expectedError: true,
+		},
+		{
+			name:          "invalid line",
+			data:          "[Global]\nZone\n",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := parseLegacyCloudConfig(test.data)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(cfg, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, cfg)
+			}
+		})
+	}
+}
+
+func TestLegacyCloudConfigInfraLister(t *testing.T) {
+	tests := []struct {
+		name              string
+		infraStatus       *configv1.AWSPlatformStatus
+		cloudConfig       map[string]string
+		expectedRegion    string
+		expectedEndpoints []configv1.AWSServiceEndpoint
+	}{
+		{
+			name:           "no cloud config",
+			infraStatus:    &configv1.AWSPlatformStatus{Region: "us-east-1"},
+			expectedRegion: "us-east-1",
+		},
+		{
+			name:           "cloud config without legacy config",
+			infraStatus:    &configv1.AWSPlatformStatus{Region: "us-east-1"},
+			cloudConfig:    map[string]string{caBundleKey: "bundle"},
+			expectedRegion: "us-east-1",
+		},
+		{
+			name:           "legacy config only",
+			cloudConfig:    map[string]string{cloudConfigKey: testLegacyCloudConfig},
+			expectedRegion: "us-east-1",
+			expectedEndpoints: []configv1.AWSServiceEndpoint{
+				{Name: "ec2", URL: "https://ec2.example.com"},
+				{Name: "sts", URL: "https://sts.example.com"},
+			},
+		},
+		{
+			name: "Infrastructure takes precedence",
+			infraStatus: &configv1.AWSPlatformStatus{
+				Region:           "us-east-1",
+				ServiceEndpoints: []configv1.AWSServiceEndpoint{{Name: "ec2", URL: "https://ec2.vpce.example.com"}},
+			},
+			cloudConfig:    map[string]string{cloudConfigKey: testLegacyCloudConfig},
+			expectedRegion: "us-east-1",
+			expectedEndpoints: []configv1.AWSServiceEndpoint{
+				{Name: "ec2", URL: "https://ec2.vpce.example.com"},
+				{Name: "sts", URL: "https://sts.example.com"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := &configv1.Infrastructure{ObjectMeta: metav1.ObjectMeta{Name: infrastructureName}}
+			if test.infraStatus != nil {
+				infra.Status.PlatformStatus = &configv1.PlatformStatus{Type: configv1.AWSPlatformType, AWS: test.infraStatus}
+			}
+			original := infra.DeepCopy()
+			infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+			infraInformer.Informer().GetIndexer().Add(infra)
+			configMapInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().ConfigMaps()
+			if test.cloudConfig != nil {
+				configMapInformer.Informer().GetIndexer().Add(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: cloudConfigName, Namespace: defaultNamespace},
+					Data:       test.cloudConfig,
+				})
+			}
+
+			lister := newLegacyCloudConfigInfraLister(infraInformer.Lister(), configMapInformer.Lister().ConfigMaps(defaultNamespace))
+			got, err := lister.Get(infrastructureName)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var region string
+			var endpoints []configv1.AWSServiceEndpoint
+			if got.Status.PlatformStatus != nil && got.Status.PlatformStatus.AWS != nil {
+				region = got.Status.PlatformStatus.AWS.Region
+				endpoints = got.Status.PlatformStatus.AWS.ServiceEndpoints
+			}
+			if region != test.expectedRegion {
+				t.Errorf("expected region %q, got %q", test.expectedRegion, region)
+			}
+			if !reflect.DeepEqual(endpoints, test.expectedEndpoints) {
+				t.Errorf("expected service endpoints %+v, got %+v", test.expectedEndpoints, endpoints)
+			}
+			if !reflect.DeepEqual(infra, original) {
+				t.Errorf("the Infrastructure of the informer was modified")
+			}
+		})
+	}
+}
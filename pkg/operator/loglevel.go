@@ -0,0 +1,52 @@
+package operator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/loglevel"
+)
+
+// withLogLevelDeploymentHook propagates spec.logLevel to all containers of the controller Deployment.
+func withLogLevelDeploymentHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		return applyLogLevel(spec, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withLogLevelDaemonSetHook propagates spec.logLevel to all containers of the node DaemonSet.
+func withLogLevelDaemonSetHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		return applyLogLevel(spec, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+// applyLogLevel sets --v of the containers that are not covered by the ${LOG_LEVEL} placeholder in the
+// assets, i.e. kube-rbac-proxy, and enables logging of the AWS API calls of the driver when the log
+// level is Debug or higher.
+func applyLogLevel(spec *opv1.OperatorSpec, podSpec *corev1.PodSpec) error {
+	verbosity := strconv.Itoa(loglevel.LogLevelToVerbosity(spec.LogLevel))
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if hasContainerArg(container, "v") || strings.HasSuffix(container.Name, "kube-rbac-proxy") {
+			setContainerArg(container, "v", verbosity)
+		}
+	}
+
+	switch spec.LogLevel {
+	case opv1.Debug, opv1.Trace, opv1.TraceAll:
+		container := getContainer(podSpec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not enable AWS SDK debug logging because the csi-driver container is missing")
+		}
+		setContainerArg(container, "aws-sdk-debug-log", "true")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestWithLogLevelDeploymentHook(t *testing.T) {
+	newDeployment := func(verbosity string, driverArgs ...string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{
+				Name: "csi-driver",
+				Args: append([]string{"controller", "--v=" + verbosity}, driverArgs...),
+			},
+			{
+				Name: "driver-kube-rbac-proxy",
+				Args: []string{"--secure-listen-address=0.0.0.0:9201", "--v=" + verbosity},
+			},
+			{
+				Name: "token-minter",
+				Args: []string{"--token-audience=openshift"},
+			},
+		}
+		return deployment
+	}
+
+	tests := []struct {
+		name     string
+		logLevel opv1.LogLevel
+		expected *appsv1.Deployment
+	}{
+		{
+			name:     "default",
+			expected: newDeployment("2"),
+		},
+		{
+			name:     "normal",
+			logLevel: opv1.Normal,
+			expected: newDeployment("2"),
+		},
+		{
+			name:     "debug",
+			logLevel: opv1.Debug,
+			expected: newDeployment("4", "--aws-sdk-debug-log=true"),
+		},
+		{
+			name:     "trace all",
+			logLevel: opv1.TraceAll,
+			expected: newDeployment("8", "--aws-sdk-debug-log=true"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{LogLevel: test.logLevel}
+			// kube-rbac-proxy has no --v in the assets.
+			deployment := newDeployment("2")
+			rbacProxy := &deployment.Spec.Template.Spec.Containers[1]
+			rbacProxy.Args = rbacProxy.Args[:1]
+			if err := withLogLevelDeploymentHook()(spec, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, deployment; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package operator
+
+import (
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	// The workqueue metrics report the sync durations, the retries and the queue depth of each controller,
+	// the name of the workqueue is the one of its controller.
+	_ "k8s.io/component-base/metrics/prometheus/workqueue"
+)
+
+const metricsNamespace = "aws_ebs_csi_driver_operator"
+
+var (
+	// operandReplicas reports the rollout state of the operand workloads.
+	operandReplicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "operand_replicas",
+			Help:           "Number of desired, updated and available pods of the CSI driver workloads.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"workload", "state"},
+	)
+
+	// defaultStorageClasses reports the number of default StorageClasses in the cluster.
+	defaultStorageClasses = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "default_storageclasses",
+			Help:           "Number of StorageClasses annotated as the cluster default. More than one leads to unpredictable PVC provisioning.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	// credentialsExpirationTimestamp reports when the expiring AWS credentials of the driver expire.
+	credentialsExpirationTimestamp = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "credentials_expiration_timestamp_seconds",
+			Help:           "Expiration time of the temporary AWS credentials (source=secret) and of the web identity token (source=token) of the CSI driver.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"source"},
+	)
+
+	// credentialsSecretMissing reports whether the credentials Secret of the driver is missing.
+	credentialsSecretMissing = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "credentials_secret_missing",
+			Help:           "1 when the driver uses the credentials Secret and it does not exist, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	// deploymentHookFailures counts the errors of the hooks of the operand workloads, which keep the workload
+	// from being updated.
+	deploymentHookFailures = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Name:           "hook_failures_total",
+			Help:           "Number of errors of the hooks that render the CSI driver workloads, by workload and hook.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"workload", "hook"},
+	)
+
+	// assetApplies counts the objects applied by the operator.
+	assetApplies = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Name:           "asset_applies_total",
+			Help:           "Number of objects created, updated or deleted by the operator and of the failures, by kind and result.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"kind", "result"},
+	)
+
+	// controllerDegraded reports the controllers whose Degraded condition is True.
+	controllerDegraded = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "controller_degraded",
+			Help:           "1 when the last sync of the controller failed and its Degraded condition is True, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller"},
+	)
+
+	// informerSynced reports the informers whose cache is synced.
+	informerSynced = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "informer_synced",
+			Help:           "1 when the cache of the informer of the resource is synced, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"resource"},
+	)
+
+	// customCABundleInUse reports whether the driver uses a custom CA bundle.
+	customCABundleInUse = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "custom_ca_bundle_in_use",
+			Help:           "1 when the CSI driver controller uses a custom CA bundle to call AWS, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	// serviceEndpointOverridden reports the AWS services whose endpoint is overridden for the driver.
+	serviceEndpointOverridden = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "service_endpoint_overridden",
+			Help:           "1 when the CSI driver controller calls a custom endpoint of the AWS service, 0 otherwise.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"service"},
+	)
+
+	// extraTags reports the number of tags the driver adds to the volumes and snapshots.
+	extraTags = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "extra_tags",
+			Help:           "Number of the extra tags the CSI driver adds to the volumes and snapshots it creates.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	// configurationInfo reports the configuration of the driver that is not a number, as labels.
+	configurationInfo = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "configuration_info",
+			Help:           "Configuration of the CSI driver: its AWS region, whether it runs on Hypershift and what sets the replicas of its controller. Always 1.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"region", "hypershift", "replicas_source"},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(operandReplicas)
+	legacyregistry.MustRegister(defaultStorageClasses)
+	legacyregistry.MustRegister(credentialsExpirationTimestamp)
+	legacyregistry.MustRegister(credentialsSecretMissing)
+	legacyregistry.MustRegister(deploymentHookFailures)
+	legacyregistry.MustRegister(assetApplies)
+	legacyregistry.MustRegister(controllerDegraded)
+	legacyregistry.MustRegister(informerSynced)
+	legacyregistry.MustRegister(customCABundleInUse)
+	legacyregistry.MustRegister(serviceEndpointOverridden)
+	legacyregistry.MustRegister(extraTags)
+	legacyregistry.MustRegister(configurationInfo)
+}
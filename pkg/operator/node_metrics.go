@@ -0,0 +1,172 @@
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcehash"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// nodeMetricsServingCertSecretName is the serving certificate of the aws-ebs-csi-driver-node-metrics Service,
+	// issued by the service-ca. All the node pods serve it: the scrapes of the pods, on the IPs of their nodes,
+	// verify the name of the Service, so there is no per-node certificate.
+	nodeMetricsServingCertSecretName = "aws-ebs-csi-driver-node-metrics-serving-cert"
+	// nodeMetricsClientCAConfigMapName is the copy of the client CA of the cluster, which the kube-rbac-proxy
+	// sidecars of the node pods verify the client certificates of the scrapes with.
+	nodeMetricsClientCAConfigMapName = "aws-ebs-csi-driver-node-metrics-client-ca"
+	nodeMetricsClientCAKey           = "client-ca.crt"
+
+	// authenticationConfigMapName in kube-system has the CA of the client certificates that the kube-apiserver
+	// accepts, which also signs the client certificates of Prometheus.
+	authenticationConfigMapName = "extension-apiserver-authentication"
+	authenticationClientCAKey   = "client-ca-file"
+
+	// nodeMetricsContainerName is the kube-rbac-proxy sidecar of the node pods. It listens on the host port 10306,
+	// next to the healthz port 10300 of the driver and its metrics on localhost:10305.
+	nodeMetricsContainerName = "driver-kube-rbac-proxy"
+)
+
+// nodeMetricsVolumeNames are the volumes of the kube-rbac-proxy sidecar of the node pods.
+var nodeMetricsVolumeNames = sets.NewString("metrics-serving-cert", "metrics-client-ca")
+
+// nodeMetricsClientCASyncer copies the client CA of the cluster from kube-system to the namespace of the node pods,
+// so their kube-rbac-proxy sidecars can mount it and authenticate Prometheus with its client certificate.
+type nodeMetricsClientCASyncer struct {
+	operatorClient     v1helpers.OperatorClient
+	namespace          string
+	kubeClient         kubeclient.Interface
+	kubeSystemCMLister corev1listers.ConfigMapNamespaceLister
+}
+
+func newNodeMetricsClientCASyncer(
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	kubeClient kubeclient.Interface,
+	kubeSystemConfigMapInformer coreinformersv1.ConfigMapInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &nodeMetricsClientCASyncer{
+		operatorClient:     operatorClient,
+		namespace:          namespace,
+		kubeClient:         kubeClient,
+		kubeSystemCMLister: kubeSystemConfigMapInformer.Lister().ConfigMaps(installConfigNamespace),
+	}
+	return factory.New().WithSync(
+		traceSync("AWSEBSDriverNodeMetricsClientCASyncer", c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		kubeSystemConfigMapInformer.Informer(),
+		configMapInformer.Informer(),
+	).ToController(
+		"AWSEBSDriverNodeMetricsClientCASyncer",
+		eventRecorder,
+	)
+}
+
+func (c *nodeMetricsClientCASyncer) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	src, err := c.kubeSystemCMLister.Get(authenticationConfigMapName)
+	if err != nil {
+		return fmt.Errorf("failed to get the client CA of the metrics of the node pods: %w", err)
+	}
+	clientCA := src.Data[authenticationClientCAKey]
+	if clientCA == "" {
+		return fmt.Errorf("ConfigMap %s/%s has no %s", installConfigNamespace, authenticationConfigMapName, authenticationClientCAKey)
+	}
+	required := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeMetricsClientCAConfigMapName,
+			Namespace: c.namespace,
+		},
+		Data: map[string]string{
+			nodeMetricsClientCAKey: clientCA,
+		},
+	}
+	_, _, err = resourceapply.ApplyConfigMap(ctx, c.kubeClient.CoreV1(), syncCtx.Recorder(), required)
+	return err
+}
+
+// withNodeMetricsHashHook annotates the node pods with the hashes of their serving certificate and client CA, to
+// restart the kube-rbac-proxy sidecars when the service-ca rotates the certificate or the client CA changes.
+func withNodeMetricsHashHook(
+	namespace string,
+	configMapLister corev1listers.ConfigMapLister,
+	secretLister corev1listers.SecretLister,
+) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(_ *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		inputHashes, err := resourcehash.MultipleObjectHashStringMapForObjectReferenceFromLister(
+			configMapLister,
+			secretLister,
+			resourcehash.NewObjectRef().ForSecret().InNamespace(namespace).Named(nodeMetricsServingCertSecretName),
+			resourcehash.NewObjectRef().ForConfigMap().InNamespace(namespace).Named(nodeMetricsClientCAConfigMapName),
+		)
+		if err != nil {
+			return fmt.Errorf("invalid dependency reference: %w", err)
+		}
+		if daemonSet.Spec.Template.Annotations == nil {
+			daemonSet.Spec.Template.Annotations = map[string]string{}
+		}
+		for k, v := range inputHashes {
+			// Like the hash annotations of library-go, which must be valid annotation names.
+			annotationKey := fmt.Sprintf("operator.openshift.io/dep-%s", k)
+			if len(annotationKey) > 63 {
+				annotationKey = fmt.Sprintf("operator.openshift.io/dep-%x", sha256.Sum256([]byte(k)))[:63]
+			}
+			daemonSet.Spec.Template.Annotations[annotationKey] = v
+		}
+		return nil
+	}
+}
+
+// withoutNodeMetricsHook removes the kube-rbac-proxy sidecar and its volumes from the node pods on Hypershift. Prometheus
+// of the guest cluster doesn't scrape the node pods of hosted clusters, so the sidecar would only take the host port
+// 10306 of the guest nodes.
+func withoutNodeMetricsHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(_ *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		podSpec := &daemonSet.Spec.Template.Spec
+		containers := []corev1.Container{}
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name != nodeMetricsContainerName {
+				containers = append(containers, podSpec.Containers[i])
+			}
+		}
+		podSpec.Containers = containers
+		volumes := []corev1.Volume{}
+		for i := range podSpec.Volumes {
+			if !nodeMetricsVolumeNames.Has(podSpec.Volumes[i].Name) {
+				volumes = append(volumes, podSpec.Volumes[i])
+			}
+		}
+		podSpec.Volumes = volumes
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestNodeMetricsClientCASyncer(t *testing.T) {
+	tests := []struct {
+		name          string
+		kubeSystem    []*corev1.ConfigMap
+		expectedCA    string
+		expectedError string
+	}{
+		{
+			name: "client CA is copied",
+			kubeSystem: []*corev1.ConfigMap{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: installConfigNamespace, Name: authenticationConfigMapName},
+				Data:       map[string]string{authenticationClientCAKey: "a client CA", "requestheader-client-ca-file": "other"},
+			}},
+			expectedCA: "a client CA",
+		},
+		{
+			name:          "authentication ConfigMap missing",
+			expectedError: "not found",
+		},
+		{
+			name: "client CA missing",
+			kubeSystem: []*corev1.ConfigMap{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: installConfigNamespace, Name: authenticationConfigMapName},
+				Data:       map[string]string{"requestheader-client-ca-file": "other"},
+			}},
+			expectedError: "has no client-ca-file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, cm := range test.kubeSystem {
+				objects = append(objects, cm)
+			}
+			kubeClient := fake.NewSimpleClientset(objects...)
+			informer := informers.NewSharedInformerFactory(kubeClient, 0).Core().V1().ConfigMaps()
+			for _, cm := range test.kubeSystem {
+				informer.Informer().GetIndexer().Add(cm)
+			}
+			c := &nodeMetricsClientCASyncer{
+				operatorClient:     v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil),
+				namespace:          defaultNamespace,
+				kubeClient:         kubeClient,
+				kubeSystemCMLister: informer.Lister().ConfigMaps(installConfigNamespace),
+			}
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("expected error containing %q, got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			cm, err := kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(context.TODO(), nodeMetricsClientCAConfigMapName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("expected the client CA ConfigMap: %v", err)
+			}
+			if len(cm.Data) != 1 || cm.Data[nodeMetricsClientCAKey] != test.expectedCA {
+				t.Errorf("expected client CA %q, got %v", test.expectedCA, cm.Data)
+			}
+		})
+	}
+}
+
+func TestWithNodeMetricsHashHook(t *testing.T) {
+	informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	configMapInformer := informerFactory.Core().V1().ConfigMaps()
+	secretInformer := informerFactory.Core().V1().Secrets()
+	hook := withNodeMetricsHashHook(defaultNamespace, configMapInformer.Lister(), secretInformer.Lister())
+
+	annotations := func() map[string]string {
+		daemonSet := &appsv1.DaemonSet{}
+		if err := hook(&opv1.OperatorSpec{}, daemonSet); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for key := range daemonSet.Spec.Template.Annotations {
+			if len(key) > 63 {
+				t.Errorf("invalid annotation %s", key)
+			}
+		}
+		return daemonSet.Spec.Template.Annotations
+	}
+
+	if len(annotations()) != 0 {
+		t.Errorf("expected no hash without the serving certificate and the client CA")
+	}
+
+	secretInformer.Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNamespace, Name: nodeMetricsServingCertSecretName},
+		Data:       map[string][]byte{"tls.crt": []byte("cert")},
+	})
+	clientCA := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNamespace, Name: nodeMetricsClientCAConfigMapName},
+		Data:       map[string]string{nodeMetricsClientCAKey: "a client CA"},
+	}
+	configMapInformer.Informer().GetIndexer().Add(clientCA)
+	hashes := annotations()
+	if len(hashes) != 2 {
+		t.Fatalf("expected the hashes of the serving certificate and the client CA, got %v", hashes)
+	}
+
+	rotated := clientCA.DeepCopy()
+	rotated.Data[nodeMetricsClientCAKey] = "a rotated client CA"
+	configMapInformer.Informer().GetIndexer().Update(rotated)
+	rotatedHashes := annotations()
+	changed := 0
+	for key, value := range rotatedHashes {
+		if hashes[key] != value {
+			changed++
+		}
+	}
+	if len(rotatedHashes) != 2 || changed != 1 {
+		t.Errorf("expected the hash of the client CA to change, got %v and %v", hashes, rotatedHashes)
+	}
+}
+
+func TestWithoutNodeMetricsHook(t *testing.T) {
+	asset, err := assets.ReadFile("node.yaml")
+	if err != nil {
+		t.Fatalf("failed to read node.yaml: %v", err)
+	}
+	daemonSet := resourceread.ReadDaemonSetV1OrDie(asset)
+	if err := withoutNodeMetricsHook()(&opv1.OperatorSpec{}, daemonSet); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	podSpec := daemonSet.Spec.Template.Spec
+	for _, container := range podSpec.Containers {
+		if container.Name == nodeMetricsContainerName {
+			t.Errorf("expected the %s container to be removed", nodeMetricsContainerName)
+		}
+		for _, port := range container.Ports {
+			if port.ContainerPort == 10306 {
+				t.Errorf("expected no host port 10306, got it in container %s", container.Name)
+			}
+		}
+	}
+	for _, volume := range podSpec.Volumes {
+		if nodeMetricsVolumeNames.Has(volume.Name) {
+			t.Errorf("expected the %s volume to be removed", volume.Name)
+		}
+	}
+	if len(podSpec.Containers) == 0 || podSpec.Containers[0].Name != "csi-driver" {
+		t.Errorf("expected the csi-driver container to be kept")
+	}
+}
@@ -0,0 +1,200 @@
+package operator
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// instrumentDeploymentHooks counts the errors of the hooks of the Deployment in deploymentHookFailures and records
+// their spans.
+func instrumentDeploymentHooks(workload string, hooks ...dc.DeploymentHookFunc) []dc.DeploymentHookFunc {
+	instrumented := make([]dc.DeploymentHookFunc, 0, len(hooks))
+	tracer := newHookChainTracer(workload, len(hooks))
+	for i, hook := range hooks {
+		i, hook, name := i, hook, hookName(hook)
+		instrumented = append(instrumented, func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+			err := tracer.trace(i, name, func() error {
+				return hook(spec, deployment)
+			})
+			if err != nil {
+				klog.ErrorS(err, "Hook failed", "namespace", deployment.Namespace, "workload", workload, "hook", name)
+				deploymentHookFailures.WithLabelValues(workload, name).Inc()
+			}
+			return err
+		})
+	}
+	return instrumented
+}
+
+// instrumentDaemonSetHooks counts the errors of the hooks of the DaemonSet in deploymentHookFailures and records
+// their spans.
+func instrumentDaemonSetHooks(workload string, hooks ...csidrivernodeservicecontroller.DaemonSetHookFunc) []csidrivernodeservicecontroller.DaemonSetHookFunc {
+	instrumented := make([]csidrivernodeservicecontroller.DaemonSetHookFunc, 0, len(hooks))
+	tracer := newHookChainTracer(workload, len(hooks))
+	for i, hook := range hooks {
+		i, hook, name := i, hook, hookName(hook)
+		instrumented = append(instrumented, func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+			err := tracer.trace(i, name, func() error {
+				return hook(spec, daemonSet)
+			})
+			if err != nil {
+				klog.ErrorS(err, "Hook failed", "namespace", daemonSet.Namespace, "workload", workload, "hook", name)
+				deploymentHookFailures.WithLabelValues(workload, name).Inc()
+			}
+			return err
+		})
+	}
+	return instrumented
+}
+
+// hookName returns the name of the function that created the hook, e.g. withAWSRegion.
+func hookName(hook interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(hook).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	// Drop the package and the suffix of the closures, e.g. operator.withAWSRegion.func1.
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// applyEventResults are the results of the events of resourceapply, keyed by the suffix of their reason, with the
+// prefix of their message.
+var applyEventResults = []struct {
+	reasonSuffix  string
+	messagePrefix string
+	result        string
+}{
+	{"CreateFailed", "Failed to ", "failed"},
+	{"UpdateFailed", "Failed to ", "failed"},
+	{"DeleteFailed", "Failed to ", "failed"},
+	{"Created", "Created ", "created"},
+	{"Updated", "Updated ", "updated"},
+	{"Deleted", "Deleted ", "deleted"},
+}
+
+// metricsRecorder counts the objects applied by the static resource controllers and the workload controllers in
+// assetApplies, from the events they record.
+type metricsRecorder struct {
+	events.Recorder
+}
+
+var _ events.Recorder = &metricsRecorder{}
+
+func newMetricsRecorder(recorder events.Recorder) events.Recorder {
+	return &metricsRecorder{Recorder: recorder}
+}
+
+func (r *metricsRecorder) Event(reason, message string) {
+	countApply(reason, message)
+	r.Recorder.Event(reason, message)
+}
+
+func (r *metricsRecorder) Eventf(reason, messageFmt string, args ...interface{}) {
+	countApply(reason, messageFmt)
+	r.Recorder.Eventf(reason, messageFmt, args...)
+}
+
+func (r *metricsRecorder) Warning(reason, message string) {
+	countApply(reason, message)
+	r.Recorder.Warning(reason, message)
+}
+
+func (r *metricsRecorder) Warningf(reason, messageFmt string, args ...interface{}) {
+	countApply(reason, messageFmt)
+	r.Recorder.Warningf(reason, messageFmt, args...)
+}
+
+func (r *metricsRecorder) ForComponent(componentName string) events.Recorder {
+	return newMetricsRecorder(r.Recorder.ForComponent(componentName))
+}
+
+func (r *metricsRecorder) WithComponentSuffix(componentNameSuffix string) events.Recorder {
+	return newMetricsRecorder(r.Recorder.WithComponentSuffix(componentNameSuffix))
+}
+
+func (r *metricsRecorder) WithContext(ctx context.Context) events.Recorder {
+	return newMetricsRecorder(r.Recorder.WithContext(ctx))
+}
+
+func countApply(reason, message string) {
+	for _, result := range applyEventResults {
+		kind := strings.TrimSuffix(reason, result.reasonSuffix)
+		if kind != reason && kind != "" && strings.HasPrefix(message, result.messagePrefix) {
+			assetApplies.WithLabelValues(kind, result.result).Inc()
+			return
+		}
+	}
+}
+
+// operatorMetricsController reports the health of the operator in metrics: the controllers whose Degraded condition
+// is True in controllerDegraded, and the informers whose cache is synced in informerSynced.
+type operatorMetricsController struct {
+	operatorClient v1helpers.OperatorClient
+	informers      map[string]factory.Informer
+}
+
+func newOperatorMetricsController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	informers map[string]factory.Informer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &operatorMetricsController{
+		operatorClient: operatorClient,
+		informers:      informers,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		30*time.Second,
+	).WithInformers(
+		operatorClient.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *operatorMetricsController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	for resource, informer := range c.informers {
+		synced := 0.0
+		if informer.HasSynced() {
+			synced = 1
+		}
+		informerSynced.WithLabelValues(resource).Set(synced)
+	}
+
+	_, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	for _, cond := range opStatus.Conditions {
+		controller := strings.TrimSuffix(cond.Type, opv1.OperatorStatusTypeDegraded)
+		if controller == cond.Type || controller == "" {
+			continue
+		}
+		degraded := 0.0
+		if cond.Status == opv1.ConditionTrue {
+			degraded = 1
+		}
+		controllerDegraded.WithLabelValues(controller).Set(degraded)
+	}
+	return nil
+}
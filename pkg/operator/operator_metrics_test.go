@@ -0,0 +1,162 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/component-base/metrics/testutil"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+func TestInstrumentDeploymentHooks(t *testing.T) {
+	failingHook := func(*opv1.OperatorSpec, *appsv1.Deployment) error {
+		return errors.New("failed")
+	}
+	hooks := instrumentDeploymentHooks("test-deployment", withLogLevelDeploymentHook(), failingHook)
+	for _, hook := range hooks {
+		hook(&opv1.OperatorSpec{}, &appsv1.Deployment{})
+	}
+
+	if name := hookName(withLogLevelDeploymentHook()); name != "withLogLevelDeploymentHook" {
+		t.Errorf("expected hook name withLogLevelDeploymentHook, got %q", name)
+	}
+	failures, err := testutil.GetCounterMetricValue(deploymentHookFailures.WithLabelValues("test-deployment", hookName(failingHook)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 1 {
+		t.Errorf("expected 1 hook failure, got %v", failures)
+	}
+	failures, err = testutil.GetCounterMetricValue(deploymentHookFailures.WithLabelValues("test-deployment", "withLogLevelDeploymentHook"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 0 {
+		t.Errorf("expected no failure of withLogLevelDeploymentHook, got %v", failures)
+	}
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	recorder := newMetricsRecorder(events.NewInMemoryRecorder("test")).WithComponentSuffix("test")
+	recorder.Eventf("TestKindCreated", "Created %s because it was missing", "TestKind/foo")
+	recorder.Eventf("TestKindUpdated", "Updated %s because it changed", "TestKind/foo")
+	recorder.Warningf("TestKindUpdateFailed", "Failed to update %s: %v", "TestKind/foo", "conflict")
+	recorder.Eventf("TestKindUpdated", "Something else")
+
+	for result, expected := range map[string]float64{"created": 1, "updated": 1, "failed": 1, "deleted": 0} {
+		value, err := testutil.GetCounterMetricValue(assetApplies.WithLabelValues("TestKind", result))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("expected %v %s applies, got %v", expected, result, value)
+		}
+	}
+}
+
+func TestOperatorMetricsController(t *testing.T) {
+	operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{
+		Conditions: []opv1.OperatorCondition{
+			{Type: "TestFailingControllerDegraded", Status: opv1.ConditionTrue},
+			{Type: "TestControllerDegraded", Status: opv1.ConditionFalse},
+			{Type: "TestControllerAvailable", Status: opv1.ConditionTrue},
+		},
+	}, nil)
+	c := &operatorMetricsController{operatorClient: operatorClient}
+	if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for controller, expected := range map[string]float64{"TestFailingController": 1, "TestController": 0} {
+		value, err := testutil.GetGaugeMetricValue(controllerDegraded.WithLabelValues(controller))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("expected %s degraded %v, got %v", controller, expected, value)
+		}
+	}
+}
+
+func TestPrometheusRuleAsset(t *testing.T) {
+	content, err := assetWithNamespaceFunc(defaultNamespace)("prometheusrule.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := resourceread.ReadGenericWithUnstructured(content)
+	if err != nil {
+		t.Fatalf("invalid PrometheusRule: %v", err)
+	}
+	rule, ok := obj.(*unstructured.Unstructured)
+	if !ok || rule.GetKind() != "PrometheusRule" || rule.GetNamespace() != defaultNamespace {
+		t.Fatalf("expected a PrometheusRule in %s, got %T %+v", defaultNamespace, obj, obj)
+	}
+	groups, _, _ := unstructured.NestedSlice(rule.Object, "spec", "groups")
+	alerts := map[string]bool{}
+	for _, group := range groups {
+		rules, _, _ := unstructured.NestedSlice(group.(map[string]interface{}), "rules")
+		for _, r := range rules {
+			r := r.(map[string]interface{})
+			name, _, _ := unstructured.NestedString(r, "alert")
+			severity, _, _ := unstructured.NestedString(r, "labels", "severity")
+			expr, _, _ := unstructured.NestedString(r, "expr")
+			if severity == "" || expr == "" || strings.Contains(expr, "${NAMESPACE}") {
+				t.Errorf("alert %s: expected a severity and an expression in %s, got %+v", name, defaultNamespace, r)
+			}
+			alerts[name] = true
+		}
+	}
+	for _, name := range []string{
+		"AWSEBSCSIDriverControllerUnavailable",
+		"AWSEBSCSIDriverNodeNotReady",
+		"AWSEBSCSIDriverProvisioningErrors",
+		"AWSEBSCSIDriverSlowAttach",
+		"AWSEBSCSIDriverCredentialsSecretMissing",
+	} {
+		if !alerts[name] {
+			t.Errorf("expected alert %s, got %v", name, alerts)
+		}
+	}
+}
+
+func TestConsoleDashboardAsset(t *testing.T) {
+	content, err := assetWithNamespaceFunc(defaultNamespace)("console_dashboard.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := resourceread.ReadConfigMapV1OrDie(content)
+	if cm.Namespace != "openshift-config-managed" || cm.Labels["console.openshift.io/dashboard"] != "true" {
+		t.Errorf("expected a console dashboard in openshift-config-managed, got %s/%s with labels %v", cm.Namespace, cm.Name, cm.Labels)
+	}
+	dashboard := struct {
+		Panels []struct {
+			Title   string
+			Targets []struct {
+				Expr string
+			}
+		}
+	}{}
+	if err := json.Unmarshal([]byte(cm.Data["aws-ebs-csi-driver.json"]), &dashboard); err != nil {
+		t.Fatalf("invalid dashboard: %v", err)
+	}
+	if len(dashboard.Panels) == 0 {
+		t.Errorf("expected dashboard panels")
+	}
+	for _, panel := range dashboard.Panels {
+		for _, target := range panel.Targets {
+			if target.Expr == "" || strings.Contains(target.Expr, "${NAMESPACE}") {
+				t.Errorf("panel %q: expected a query in %s, got %q", panel.Title, defaultNamespace, target.Expr)
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package operator
+
+import (
+	"os"
+
+	"k8s.io/client-go/dynamic"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+// optionalControllerInputs are the clients and informers of the optional controllers.
+type optionalControllerInputs struct {
+	isHypershift          bool
+	controlPlaneNamespace string
+	guestNamespace        string
+
+	operatorClient v1helpers.OperatorClientWithFinalizers
+	eventRecorder  events.Recorder
+
+	controlPlaneDeploymentInformer appsinformersv1.DeploymentInformer
+	controlPlaneSecretInformer     coreinformersv1.SecretInformer
+	controlPlaneConfigMapInformer  coreinformersv1.ConfigMapInformer
+	controlPlaneKubeClient         kubeclient.Interface
+
+	guestKubeClient          kubeclient.Interface
+	guestDynamicClient       dynamic.Interface
+	guestKubeInformers       v1helpers.KubeInformersForNamespaces
+	guestNodeInformer        coreinformersv1.NodeInformer
+	guestInfraInformer       configinformersv1.InfrastructureInformer
+	guestFeatureGateInformer configinformersv1.FeatureGateInformer
+	// snapshotCRDInformer watches the VolumeSnapshotClass CRD. It's started by RunOperator.
+	snapshotCRDInformer cache.SharedIndexInformer
+}
+
+// optionalController is a controller of an optional feature of the operator with the description of its start log.
+type optionalController struct {
+	description string
+	controller  runnable
+}
+
+// optionalControllers are the controllers that check the AWS account of the cluster and manage the optional
+// features of the driver. The driver is installed and kept running without them.
+type optionalControllers struct {
+	controllers []optionalController
+}
+
+// newOptionalControllers creates the optional controllers. It must be called before the informer factories
+// are started, informers requested after the start are never started.
+func newOptionalControllers(in optionalControllerInputs) *optionalControllers {
+	c := &optionalControllers{}
+	add := func(description string, controller runnable) {
+		c.controllers = append(c.controllers, optionalController{description: description, controller: controller})
+	}
+
+	add("AWS health check controller", newAWSHealthController(
+		"AWSEBSDriverHealthCheckController",
+		in.operatorClient,
+		in.controlPlaneNamespace,
+		in.controlPlaneDeploymentInformer,
+		in.controlPlaneSecretInformer,
+		in.controlPlaneConfigMapInformer,
+		in.controlPlaneKubeClient,
+		in.guestKubeClient,
+		in.eventRecorder,
+	))
+
+	// The events of the volume operations are in the guest cluster.
+	add("AWS errors controller", newAWSErrorsController(
+		"AWSEBSDriverAWSErrorsController",
+		in.operatorClient,
+		in.guestKubeClient,
+		in.eventRecorder,
+	))
+
+	add("endpoint check controller", newEndpointCheckController(
+		"AWSEBSDriverEndpointCheckController",
+		in.operatorClient,
+		in.controlPlaneNamespace,
+		in.controlPlaneDeploymentInformer,
+		in.controlPlaneSecretInformer,
+		in.controlPlaneConfigMapInformer,
+		in.controlPlaneKubeClient,
+		in.guestKubeClient,
+		in.eventRecorder,
+	))
+
+	add("permissions check controller", newPermissionsCheckController(
+		"AWSEBSDriverPermissionsCheckController",
+		in.operatorClient,
+		in.controlPlaneNamespace,
+		in.controlPlaneDeploymentInformer,
+		in.controlPlaneSecretInformer,
+		in.controlPlaneConfigMapInformer,
+		in.controlPlaneKubeClient,
+		in.guestKubeClient,
+		in.guestNodeInformer,
+		in.eventRecorder,
+	))
+
+	add("KMS key check controller", newKMSKeyCheckController(
+		"AWSEBSDriverKMSKeyCheckController",
+		in.operatorClient,
+		in.controlPlaneNamespace,
+		in.controlPlaneDeploymentInformer,
+		in.controlPlaneSecretInformer,
+		in.controlPlaneConfigMapInformer,
+		in.controlPlaneKubeClient,
+		in.guestKubeClient,
+		in.eventRecorder,
+	))
+
+	add("snapshot lifecycle policy controller", newSnapshotLifecyclePolicyController(
+		"AWSEBSDriverSnapshotLifecyclePolicyController",
+		in.operatorClient,
+		in.controlPlaneNamespace,
+		in.controlPlaneDeploymentInformer,
+		in.guestInfraInformer,
+		in.controlPlaneSecretInformer,
+		in.controlPlaneConfigMapInformer,
+		in.controlPlaneKubeClient,
+		in.guestKubeClient,
+		in.eventRecorder,
+	))
+
+	add("VolumeAttributesClass controller", newVolumeAttributesClassController(
+		"AWSEBSDriverVolumeAttributesClassController",
+		in.operatorClient,
+		in.guestDynamicClient,
+		in.guestFeatureGateInformer,
+		assets.ReadFile,
+		[]string{
+			"volumeattributesclass_gp3_baseline.yaml",
+			"volumeattributesclass_gp3_high_performance.yaml",
+		},
+		in.eventRecorder,
+	))
+
+	add("gp2 migration controller", newGP2MigrationController(
+		"AWSEBSDriverGP2MigrationController",
+		in.operatorClient,
+		in.guestKubeClient,
+		in.guestKubeInformers.InformersFor("").Core().V1().PersistentVolumes(),
+		in.guestKubeInformers.InformersFor("").Core().V1().PersistentVolumeClaims(),
+		in.guestKubeInformers.InformersFor("").Storage().V1().StorageClasses(),
+		volumeModifierEnabled(in.guestFeatureGateInformer.Lister(), os.Getenv(volumeModifierImageEnvName)),
+		in.eventRecorder,
+	))
+
+	add("scheduled snapshot controller", newScheduledSnapshotController(
+		"AWSEBSDriverScheduledSnapshotController",
+		in.operatorClient,
+		in.guestDynamicClient,
+		in.guestKubeInformers.InformersFor("").Core().V1().PersistentVolumeClaims(),
+		in.guestKubeInformers.InformersFor("").Core().V1().PersistentVolumes(),
+		in.guestKubeInformers.InformersFor("").Core().V1().Namespaces(),
+		crdExists(in.snapshotCRDInformer, volumeSnapshotClassCRDName),
+		[]factory.Informer{in.snapshotCRDInformer},
+		in.eventRecorder,
+	))
+
+	add("leaked snapshot controller", newLeakedSnapshotController(
+		"AWSEBSDriverLeakedSnapshotController",
+		in.operatorClient,
+		in.controlPlaneNamespace,
+		in.controlPlaneDeploymentInformer,
+		in.controlPlaneSecretInformer,
+		in.controlPlaneConfigMapInformer,
+		in.controlPlaneKubeClient,
+		in.guestKubeClient,
+		in.guestDynamicClient,
+		crdExists(in.snapshotCRDInformer, volumeSnapshotClassCRDName),
+		[]factory.Informer{in.snapshotCRDInformer},
+		in.eventRecorder,
+	))
+
+	return c
+}
+
+// start runs the controllers with the runner. They stop when the context of the runner is cancelled and the
+// runner waits for the controllers on shutdown, like for the other controllers.
+func (c *optionalControllers) start(runner *controllerRunner) {
+	for _, ctrl := range c.controllers {
+		klog.Infof("Starting %s", ctrl.description)
+		runner.run(ctrl.controller)
+	}
+}
@@ -0,0 +1,139 @@
+package operator
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeDynamicClient returns no resource clients. It's enough to create the controllers, not to sync them.
+type fakeDynamicClient struct {
+	dynamic.Interface
+}
+
+func (fakeDynamicClient) Resource(_ schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return nil
+}
+
+// fakeRunnable counts its runs and blocks until its context is cancelled. When it's stuck, it also
+// blocks until release is closed.
+type fakeRunnable struct {
+	runs    int32
+	release chan struct{}
+}
+
+func (r *fakeRunnable) Run(ctx context.Context, _ int) {
+	atomic.AddInt32(&r.runs, 1)
+	<-ctx.Done()
+	if r.release != nil {
+		<-r.release
+	}
+}
+
+func newTestOptionalControllerInputs(isHypershift bool) optionalControllerInputs {
+	kubeClient := fake.NewSimpleClientset()
+	kubeInformers := v1helpers.NewKubeInformersForNamespaces(kubeClient, defaultNamespace, "")
+	configInformers := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0)
+	return optionalControllerInputs{
+		isHypershift:                   isHypershift,
+		controlPlaneNamespace:          defaultNamespace,
+		guestNamespace:                 defaultNamespace,
+		operatorClient:                 v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil),
+		eventRecorder:                  events.NewInMemoryRecorder("test"),
+		controlPlaneDeploymentInformer: kubeInformers.InformersFor(defaultNamespace).Apps().V1().Deployments(),
+		controlPlaneSecretInformer:     kubeInformers.InformersFor(defaultNamespace).Core().V1().Secrets(),
+		controlPlaneConfigMapInformer:  kubeInformers.InformersFor(defaultNamespace).Core().V1().ConfigMaps(),
+		controlPlaneKubeClient:         kubeClient,
+		guestKubeClient:                kubeClient,
+		guestDynamicClient:             fakeDynamicClient{},
+		guestKubeInformers:             kubeInformers,
+		guestNodeInformer:              kubeInformers.InformersFor("").Core().V1().Nodes(),
+		guestInfraInformer:             configInformers.Config().V1().Infrastructures(),
+		guestFeatureGateInformer:       configInformers.Config().V1().FeatureGates(),
+		snapshotCRDInformer:            cache.NewSharedIndexInformer(&cache.ListWatch{}, nil, 0, cache.Indexers{}),
+	}
+}
+
+func TestNewOptionalControllers(t *testing.T) {
+	c := newOptionalControllers(newTestOptionalControllerInputs(true))
+
+	expected := []string{
+		"AWS health check controller",
+		"AWS errors controller",
+		"endpoint check controller",
+		"permissions check controller",
+		"KMS key check controller",
+		"snapshot lifecycle policy controller",
+		"VolumeAttributesClass controller",
+		"gp2 migration controller",
+		"scheduled snapshot controller",
+		"leaked snapshot controller",
+	}
+	if len(c.controllers) != len(expected) {
+		t.Fatalf("expected %d controllers, got %d", len(expected), len(c.controllers))
+	}
+	for i, ctrl := range c.controllers {
+		if ctrl.description != expected[i] {
+			t.Errorf("expected controller %d to be %q, got %q", i, expected[i], ctrl.description)
+		}
+	}
+}
+
+func TestOptionalControllersShutdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		stuck bool
+	}{
+		{
+			name: "controllers stop",
+		},
+		{
+			name:  "controller does not stop",
+			stuck: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			runner := newControllerRunner(ctx, 1)
+
+			first, second := &fakeRunnable{}, &fakeRunnable{}
+			if test.stuck {
+				second.release = make(chan struct{})
+				defer close(second.release)
+			}
+			c := &optionalControllers{
+				controllers: []optionalController{
+					{description: "first controller", controller: first},
+					{description: "second controller", controller: second},
+				},
+			}
+			c.start(runner)
+			cancel()
+
+			// A controller that does not stop must not block the shutdown, and it's not an error either.
+			started := time.Now()
+			if err := runner.wait(time.Second); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if elapsed := time.Since(started); test.stuck != (elapsed >= time.Second) {
+				t.Errorf("unexpected shutdown time %s", elapsed)
+			}
+			firstRuns, secondRuns := atomic.LoadInt32(&first.runs), atomic.LoadInt32(&second.runs)
+			if firstRuns != 1 || secondRuns != 1 {
+				t.Errorf("expected each controller to run once, got %d and %d", firstRuns, secondRuns)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package operator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	opv1 "github.com/openshift/api/operator/v1"
+)
+
+const (
+	// outpostTopologyKey is the topology key of the Outposts reported by the CSI driver on the nodes of an Outpost.
+	// The driver creates the volumes on the Outpost of this topology segment.
+	outpostTopologyKey = "topology.ebs.csi.aws.com/outpost-id"
+	// outpostAnnotation marks the StorageClasses generated for an Outpost, its value is the ARN of the Outpost.
+	outpostAnnotation = "csi.openshift.io/outpost"
+
+	outpostsConditionSuffix = "OutpostStorage"
+)
+
+// outpostARNRegexp matches the ARN of an Outpost, the submatch is the ID of the Outpost.
+var outpostARNRegexp = regexp.MustCompile(`^arn:aws[a-z-]*:outposts:[a-z0-9-]+:[0-9]{12}:outpost/(op-[0-9a-f]{17})$`)
+
+// outpostID returns the ID of the Outpost of the ARN.
+func outpostID(arn string) (string, error) {
+	match := outpostARNRegexp.FindStringSubmatch(arn)
+	if match == nil {
+		return "", fmt.Errorf("invalid outposts %q: it must be the ARN of an Outpost, arn:aws:outposts:<region>:<account ID>:outpost/op-<ID>", arn)
+	}
+	return match[1], nil
+}
+
+// outpostStorageClasses returns a gp2 StorageClass for each Outpost listed in driverConfig.Outposts, named
+// gp2-csi-<Outpost ID>. EBS on Outposts supports only gp2 volumes. The StorageClasses are restricted to the nodes
+// of their Outpost, so the driver creates their volumes on the Outpost and the region StorageClasses are not
+// needed there. They're built from the gp2 asset with the StorageClass hooks, even when the gp2 StorageClass is
+// retired, and are never made default by the operator.
+func (c *storageClassController) outpostStorageClasses(opSpec *opv1.OperatorSpec, cfg *driverConfig) ([]*storagev1.StorageClass, error) {
+	if len(cfg.Outposts) == 0 {
+		return nil, nil
+	}
+	base, err := c.getStorageClass(opSpec, gp2StorageClassFile)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var outpostSCs []*storagev1.StorageClass
+	for _, arn := range cfg.Outposts {
+		id, err := outpostID(arn)
+		if err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("invalid outposts: Outpost %s is listed more than once", id)
+		}
+		seen[id] = true
+
+		sc := base.DeepCopy()
+		sc.Name = fmt.Sprintf("%s-%s", base.Name, id)
+		if sc.Annotations == nil {
+			sc.Annotations = map[string]string{}
+		}
+		sc.Annotations[outpostAnnotation] = arn
+		if cfg.DefaultStorageClass != "" {
+			sc.Annotations[defaultStorageClassAnnotation] = "false"
+		} else {
+			// Keep the annotation set by the cluster administrator, if any.
+			delete(sc.Annotations, defaultStorageClassAnnotation)
+		}
+		sc.AllowedTopologies = []corev1.TopologySelectorTerm{{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+				Key:    outpostTopologyKey,
+				Values: []string{id},
+			}},
+		}}
+		outpostSCs = append(outpostSCs, sc)
+	}
+	return outpostSCs, nil
+}
+
+// outpostsCondition returns the condition that reports the Outposts whose storage is not configured: nodes of an
+// Outpost that is not in driverConfig.Outposts get volumes of the region StorageClasses, which the driver fails to
+// create on the Outpost, and Outposts in driverConfig.Outposts without nodes can't provision volumes. It's nil when
+// there are neither Outposts in the configuration nor nodes on Outposts.
+func (c *storageClassController) outpostsCondition(cfg *driverConfig) (*opv1.OperatorCondition, error) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodeOutposts := map[string]bool{}
+	for _, node := range nodes {
+		if id := node.Labels[outpostTopologyKey]; id != "" {
+			nodeOutposts[id] = true
+		}
+	}
+	if len(cfg.Outposts) == 0 && len(nodeOutposts) == 0 {
+		return nil, nil
+	}
+
+	configured := map[string]bool{}
+	var withoutNodes []string
+	for _, arn := range cfg.Outposts {
+		id, err := outpostID(arn)
+		if err != nil {
+			return nil, err
+		}
+		configured[id] = true
+		if !nodeOutposts[id] {
+			withoutNodes = append(withoutNodes, id)
+		}
+	}
+	var notConfigured []string
+	for id := range nodeOutposts {
+		if !configured[id] {
+			notConfigured = append(notConfigured, id)
+		}
+	}
+	sort.Strings(withoutNodes)
+	sort.Strings(notConfigured)
+
+	cond := &opv1.OperatorCondition{
+		Type:   c.name + outpostsConditionSuffix,
+		Status: opv1.ConditionFalse,
+	}
+	var messages []string
+	if len(notConfigured) > 0 {
+		cond.Reason = "OutpostsNotConfigured"
+		messages = append(messages, fmt.Sprintf("Nodes run on Outposts %s, which are not in the outposts of the driver configuration: volumes of the region StorageClasses can't be created there",
+			strings.Join(notConfigured, ", ")))
+	}
+	if len(withoutNodes) > 0 {
+		if cond.Reason == "" {
+			cond.Reason = "OutpostsWithoutNodes"
+		}
+		messages = append(messages, fmt.Sprintf("There are no nodes on Outposts %s, volumes of their StorageClasses can't be provisioned",
+			strings.Join(withoutNodes, ", ")))
+	}
+	if len(messages) > 0 {
+		cond.Status = opv1.ConditionTrue
+		cond.Message = strings.Join(messages, ". ")
+	}
+	return cond, nil
+}
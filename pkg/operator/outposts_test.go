@@ -0,0 +1,140 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStorageClassControllerOutposts(t *testing.T) {
+	const (
+		outpostARN = "arn:aws:outposts:us-east-1:123456789012:outpost/op-0123456789abcdef0"
+		outpostSC  = "gp2-csi-op-0123456789abcdef0"
+	)
+	tests := []struct {
+		name              string
+		overrides         string
+		nodeOutposts      []string
+		existing          string
+		expectedSC        bool
+		expectedCondition opv1.ConditionStatus
+		expectedReason    string
+		expectedError     bool
+	}{
+		{
+			name: "no Outposts",
+		},
+		{
+			name:              "Outpost with nodes",
+			overrides:         `{"outposts": ["` + outpostARN + `"], "retireGP2": "Delete"}`,
+			nodeOutposts:      []string{"op-0123456789abcdef0"},
+			expectedSC:        true,
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:              "Outpost without nodes",
+			overrides:         `{"outposts": ["` + outpostARN + `"]}`,
+			expectedSC:        true,
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "OutpostsWithoutNodes",
+		},
+		{
+			name:              "nodes on an Outpost that is not configured",
+			nodeOutposts:      []string{"op-0123456789abcdef0", "op-fedcba98765432100"},
+			overrides:         `{"outposts": ["` + outpostARN + `"]}`,
+			expectedSC:        true,
+			expectedCondition: opv1.ConditionTrue,
+			expectedReason:    "OutpostsNotConfigured",
+		},
+		{
+			name:     "removed Outpost",
+			existing: outpostSC,
+		},
+		{
+			name:          "invalid ARN",
+			overrides:     `{"outposts": ["op-0123456789abcdef0"]}`,
+			expectedError: true,
+		},
+		{
+			name:          "duplicate Outpost",
+			overrides:     `{"outposts": ["` + outpostARN + `", "` + outpostARN + `"]}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			var c *storageClassController
+			var kubeClient *fake.Clientset
+			if test.existing != "" {
+				c, kubeClient = newTestStorageClassController(spec, newTestStorageClass(test.existing, map[string]string{
+					managedAnnotation: "true",
+					outpostAnnotation: outpostARN,
+				}))
+			} else {
+				c, kubeClient = newTestStorageClassController(spec)
+			}
+			nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			for i, id := range test.nodeOutposts {
+				nodeInformer.Informer().GetIndexer().Add(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:   "node-" + string(rune('a'+i)),
+					Labels: map[string]string{outpostTopologyKey: id},
+				}})
+			}
+			c.nodeLister = nodeInformer.Lister()
+
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sc, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), outpostSC, metav1.GetOptions{})
+			switch {
+			case test.expectedSC && err != nil:
+				t.Fatalf("expected StorageClass %s: %v", outpostSC, err)
+			case test.expectedSC:
+				if sc.Parameters["type"] != "gp2" {
+					t.Errorf("expected a gp2 StorageClass, got type %q", sc.Parameters["type"])
+				}
+				if sc.Annotations[outpostAnnotation] != outpostARN {
+					t.Errorf("expected Outpost annotation %s, got %q", outpostARN, sc.Annotations[outpostAnnotation])
+				}
+				if len(sc.AllowedTopologies) != 1 || sc.AllowedTopologies[0].MatchLabelExpressions[0].Key != outpostTopologyKey ||
+					sc.AllowedTopologies[0].MatchLabelExpressions[0].Values[0] != "op-0123456789abcdef0" {
+					t.Errorf("expected the StorageClass to be restricted to the Outpost, got %+v", sc.AllowedTopologies)
+				}
+			case err == nil:
+				t.Errorf("expected no StorageClass %s", outpostSC)
+			}
+
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, c.name+outpostsConditionSuffix)
+			if test.expectedCondition == "" {
+				if cond != nil {
+					t.Errorf("expected no condition, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != test.expectedCondition || cond.Reason != test.expectedReason {
+				t.Errorf("expected condition %s with reason %q, got %+v", test.expectedCondition, test.expectedReason, cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	permissionsCheckInterval = time.Hour
+
+	// Placeholders of the resources used in the dry run calls of the actions that change resources, so that
+	// a call that does not honor DryRun can't change a resource of the cluster. EC2 may then report that the
+	// resource does not exist instead of the permissions.
+	placeholderInstanceID = "i-00000000000000000"
+	placeholderVolumeID   = "vol-00000000000000000"
+	placeholderSnapshotID = "snap-00000000000000000"
+)
+
+// ec2DryRunAPI is the part of the EC2 API used by the permissionsCheckController.
+type ec2DryRunAPI interface {
+	DryRun(ctx context.Context, action string, params url.Values) (bool, error)
+}
+
+// dryRunResources are the resources of the cluster the dry run calls are made on.
+type dryRunResources struct {
+	zone string
+}
+
+// driverActions are the EC2 actions called by the driver, with the parameters of their dry run calls.
+var driverActions = []struct {
+	action string
+	params func(r dryRunResources) url.Values
+}{
+	{"AttachVolume", func(dryRunResources) url.Values {
+		return url.Values{"VolumeId": {placeholderVolumeID}, "InstanceId": {placeholderInstanceID}, "Device": {"/dev/xvdbz"}}
+	}},
+	{"CreateSnapshot", func(dryRunResources) url.Values { return url.Values{"VolumeId": {placeholderVolumeID}} }},
+	{"CreateTags", func(dryRunResources) url.Values {
+		return url.Values{"ResourceId.1": {placeholderVolumeID}, "Tag.1.Key": {"csi.openshift.io/permissions-check"}, "Tag.1.Value": {"true"}}
+	}},
+	{"CreateVolume", func(r dryRunResources) url.Values {
+		return url.Values{"AvailabilityZone": {r.zone}, "Size": {"1"}, "VolumeType": {"gp3"}}
+	}},
+	{"DeleteSnapshot", func(dryRunResources) url.Values { return url.Values{"SnapshotId": {placeholderSnapshotID}} }},
+	{"DeleteVolume", func(dryRunResources) url.Values { return url.Values{"VolumeId": {placeholderVolumeID}} }},
+	{"DescribeAvailabilityZones", func(dryRunResources) url.Values { return nil }},
+	{"DescribeInstances", func(dryRunResources) url.Values { return nil }},
+	{"DescribeSnapshots", func(dryRunResources) url.Values { return url.Values{"Owner.1": {"self"}} }},
+	{"DescribeVolumes", func(dryRunResources) url.Values { return nil }},
+	{"DetachVolume", func(dryRunResources) url.Values {
+		return url.Values{"VolumeId": {placeholderVolumeID}, "InstanceId": {placeholderInstanceID}}
+	}},
+	{"ModifyVolume", func(dryRunResources) url.Values {
+		return url.Values{"VolumeId": {placeholderVolumeID}, "VolumeType": {"gp3"}}
+	}},
+}
+
+// permissionsCheckController checks that the credentials of the CSI driver controller are allowed to call the EC2
+// actions the driver needs, with EC2 dry run calls that check the permissions without changing anything. The
+// actions that change resources are called on placeholder resources, never on the ones of the cluster, and a dry run
+// call that succeeds is an error. CreateVolume is called in the zone of a node of the cluster. The check runs hourly
+// and when the Deployment changes, e.g. with new credentials.
+//
+// It produces the following conditions:
+// <name>MissingPermissions: True when the driver is not allowed to call some actions, they're listed in the message.
+// Unknown when the check can't be made.
+// <name>Degraded: produced when the sync() method returns an error.
+type permissionsCheckController struct {
+	name             string
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+	nodeLister       corev1listers.NodeLister
+	// newEC2Client returns an EC2 client configured like the driver in the controller Deployment, and the
+	// description of the source of its credentials.
+	newEC2Client func(deployment *appsv1.Deployment) (ec2DryRunAPI, string, error)
+
+	now func() time.Time
+	// lastCheck and lastGeneration record the last check, to check again when the interval
+	// elapses or the Deployment changes.
+	lastCheck      time.Time
+	lastGeneration int64
+}
+
+func newPermissionsCheckController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	nodeInformer coreinformersv1.NodeInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	builder := newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient)
+	c := &permissionsCheckController{
+		name:             name,
+		operatorClient:   operatorClient,
+		deploymentLister: deploymentInformer.Lister().Deployments(namespace),
+		nodeLister:       nodeInformer.Lister(),
+		newEC2Client: func(deployment *appsv1.Deployment) (ec2DryRunAPI, string, error) {
+			client, target, err := builder.newClient(deployment, "ec2", ec2Timeout)
+			if err != nil {
+				return nil, "", err
+			}
+			return client, target.credentialsSource, nil
+		},
+		now: time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *permissionsCheckController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+	conditionType := c.name + "MissingPermissions"
+	if cfg.PermissionsCheck != nil && cfg.PermissionsCheck.Disabled {
+		c.lastCheck = time.Time{}
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// The credentials of the driver are not known until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	if !c.lastCheck.IsZero() && deployment.Generation == c.lastGeneration && now.Sub(c.lastCheck) < permissionsCheckInterval {
+		return nil
+	}
+
+	cond := opv1.OperatorCondition{
+		Type:   conditionType,
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	client, credentialsSource, err := c.newEC2Client(deployment)
+	var checkErr *awsHealthCheckError
+	switch {
+	case errors.As(err, &checkErr):
+		cond.Status = opv1.ConditionUnknown
+		cond.Reason = checkErr.reason
+		cond.Message = checkErr.message
+	case err != nil:
+		return err
+	default:
+		denied, err := c.check(ctx, client, c.resources(deployment))
+		if err != nil {
+			return err
+		}
+		if len(denied) > 0 {
+			cond.Status = opv1.ConditionTrue
+			cond.Reason = "MissingPermissions"
+			cond.Message = fmt.Sprintf("The AWS credentials of the driver are not allowed to call %s. Add the actions to the IAM policy of the credentials from %s",
+				strings.Join(denied, ", "), credentialsSource)
+			if old := v1helpers.FindOperatorCondition(opStatus.Conditions, conditionType); old == nil || old.Message != cond.Message {
+				klog.Warning(cond.Message)
+				syncCtx.Recorder().Warning("MissingPermissions", cond.Message)
+			}
+		}
+	}
+	c.lastCheck = now
+	c.lastGeneration = deployment.Generation
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// check makes the dry run calls and returns the denied actions. Calls that fail for another reason, e.g. because
+// a placeholder resource does not exist, are inconclusive and ignored.
+func (c *permissionsCheckController) check(ctx context.Context, client ec2DryRunAPI, resources dryRunResources) ([]string, error) {
+	var denied []string
+	for _, a := range driverActions {
+		allowed, err := client.DryRun(ctx, a.action, a.params(resources))
+		var apiErr *awsclient.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.Code != "AuthFailure":
+			klog.V(2).Infof("Permissions check of ec2:%s is inconclusive: %v", a.action, err)
+		case err != nil:
+			return nil, fmt.Errorf("failed to check the permissions of ec2:%s: %w", a.action, err)
+		case !allowed:
+			denied = append(denied, "ec2:"+a.action)
+		}
+	}
+	sort.Strings(denied)
+	return denied, nil
+}
+
+// resources returns the resources of the cluster for the dry run calls: the zone of the first node with an AWS
+// provider ID, or the first zone of the region of the driver.
+func (c *permissionsCheckController) resources(deployment *appsv1.Deployment) dryRunResources {
+	var r dryRunResources
+	if container := getContainer(&deployment.Spec.Template.Spec, driverContainerName); container != nil {
+		if region, ok := getContainerEnv(container, "AWS_REGION"); ok {
+			r.zone = region + "a"
+		}
+	}
+
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.V(2).Infof("Failed to list nodes for the permissions check: %v", err)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, node := range nodes {
+		// The provider ID is aws:///<zone>/<instance ID>.
+		parts := strings.Split(strings.TrimPrefix(node.Spec.ProviderID, "aws:///"), "/")
+		if !strings.HasPrefix(node.Spec.ProviderID, "aws:///") || len(parts) != 2 {
+			continue
+		}
+		r.zone = parts[0]
+		break
+	}
+	return r
+}
@@ -0,0 +1,168 @@
+package operator
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+// fakeEC2DryRun allows all actions but the denied ones, and records the parameters of the calls.
+type fakeEC2DryRun struct {
+	denied map[string]bool
+	failed map[string]error
+	params map[string]url.Values
+}
+
+func (f *fakeEC2DryRun) DryRun(_ context.Context, action string, params url.Values) (bool, error) {
+	f.params[action] = params
+	if err := f.failed[action]; err != nil {
+		return false, err
+	}
+	return !f.denied[action], nil
+}
+
+func TestPermissionsCheckController(t *testing.T) {
+	tests := []struct {
+		name              string
+		overrides         string
+		denied            []string
+		failed            map[string]error
+		clientErr         error
+		expectedCondition opv1.ConditionStatus
+		expectedMessage   string
+		expectedZone      string
+		expectedError     bool
+	}{
+		{
+			name:              "allowed",
+			expectedCondition: opv1.ConditionFalse,
+			expectedZone:      "us-east-1b",
+		},
+		{
+			name:              "denied",
+			denied:            []string{"CreateVolume", "AttachVolume"},
+			expectedCondition: opv1.ConditionTrue,
+			expectedMessage: "The AWS credentials of the driver are not allowed to call ec2:AttachVolume, ec2:CreateVolume. " +
+				"Add the actions to the IAM policy of the credentials from Secret openshift-cluster-csi-drivers/ebs-cloud-credentials",
+		},
+		{
+			name:              "inconclusive",
+			failed:            map[string]error{"DeleteSnapshot": &awsclient.APIError{StatusCode: 400, Code: "InvalidSnapshot.NotFound"}},
+			expectedCondition: opv1.ConditionFalse,
+		},
+		{
+			name:          "invalid credentials",
+			failed:        map[string]error{"DescribeVolumes": &awsclient.APIError{StatusCode: 401, Code: "AuthFailure"}},
+			expectedError: true,
+		},
+		{
+			name: "no region",
+			clientErr: &awsHealthCheckError{
+				status:  opv1.ConditionUnknown,
+				reason:  "NoRegion",
+				message: "no region",
+			},
+			expectedCondition: opv1.ConditionUnknown,
+			expectedMessage:   "no region",
+		},
+		{
+			name:      "disabled",
+			overrides: `{"permissionsCheck": {"disabled": true}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace},
+			}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: driverContainerName,
+				Env:  []corev1.EnvVar{{Name: "AWS_REGION", Value: "us-east-1"}},
+			}}
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+				Spec:       corev1.NodeSpec{ProviderID: "aws:///us-east-1b/i-0123456789abcdef0"},
+			}
+			informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(deployment)
+			informerFactory.Core().V1().Nodes().Informer().GetIndexer().Add(node)
+
+			spec := &opv1.OperatorSpec{ManagementState: opv1.Managed}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+			ec2 := &fakeEC2DryRun{denied: map[string]bool{}, failed: test.failed, params: map[string]url.Values{}}
+			for _, action := range test.denied {
+				ec2.denied[action] = true
+			}
+			c := &permissionsCheckController{
+				name:             "Test",
+				operatorClient:   operatorClient,
+				deploymentLister: informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace),
+				nodeLister:       informerFactory.Core().V1().Nodes().Lister(),
+				newEC2Client: func(*appsv1.Deployment) (ec2DryRunAPI, string, error) {
+					if test.clientErr != nil {
+						return nil, "", test.clientErr
+					}
+					return ec2, "Secret " + defaultNamespace + "/" + defaultSecretName, nil
+				},
+				now: time.Now,
+			}
+
+			recorder := events.NewInMemoryRecorder("test")
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", recorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedZone != "" {
+				// The actions that change resources never touch the resources of the cluster.
+				attach := ec2.params["AttachVolume"]
+				if attach.Get("InstanceId") != placeholderInstanceID || attach.Get("VolumeId") != placeholderVolumeID {
+					t.Errorf("expected the dry run on the placeholder resources, got %v", attach)
+				}
+				if id := ec2.params["DeleteVolume"].Get("VolumeId"); id != placeholderVolumeID {
+					t.Errorf("expected DeleteVolume on the placeholder volume, got %q", id)
+				}
+				if zone := ec2.params["CreateVolume"].Get("AvailabilityZone"); zone != test.expectedZone {
+					t.Errorf("expected the zone of the node, got %q", zone)
+				}
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestMissingPermissions")
+			if test.expectedCondition == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != test.expectedCondition || cond.Message != test.expectedMessage {
+				t.Errorf("expected MissingPermissions=%s with message %q, got %+v", test.expectedCondition, test.expectedMessage, cond)
+			}
+			if test.expectedCondition == opv1.ConditionTrue && len(recorder.Events()) != 1 {
+				t.Errorf("expected one event, got %d", len(recorder.Events()))
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// withNodePlacementDeploymentHook applies the controller node placement from the driver configuration,
+// e.g. to run the controller pods on infra nodes. On Hypershift the controller pods run in the
+// management cluster, where the guest cluster admin has no say, and the configuration is ignored.
+func withNodePlacementDeploymentHook(isHypershift bool) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if isHypershift {
+			return nil
+		}
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.NodePlacement == nil {
+			return nil
+		}
+		return applyNodePlacement("controller", cfg.NodePlacement.Controller, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withNodePlacementDaemonSetHook applies the node placement from the driver configuration to the node
+// DaemonSet, e.g. to skip nodes without EBS support or to tolerate custom taints.
+func withNodePlacementDaemonSetHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.NodePlacement == nil {
+			return nil
+		}
+		return applyNodePlacement("node", cfg.NodePlacement.Node, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+func applyNodePlacement(name string, placement *nodePlacement, podSpec *corev1.PodSpec) error {
+	if placement == nil {
+		return nil
+	}
+	if err := validateNodePlacement(placement); err != nil {
+		return fmt.Errorf("invalid nodePlacement %s: %w", name, err)
+	}
+	if placement.NodeSelector != nil {
+		podSpec.NodeSelector = placement.NodeSelector
+	}
+	if placement.Tolerations != nil {
+		podSpec.Tolerations = placement.Tolerations
+	}
+	return nil
+}
+
+func validateNodePlacement(placement *nodePlacement) error {
+	for key, value := range placement.NodeSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid nodeSelector key %q: %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid nodeSelector value %q: %s", value, strings.Join(errs, ", "))
+		}
+	}
+	for _, toleration := range placement.Tolerations {
+		switch toleration.Operator {
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				return fmt.Errorf("invalid toleration %q: value must be empty when operator is Exists", toleration.Key)
+			}
+		case corev1.TolerationOpEqual, "":
+			if toleration.Key == "" {
+				return fmt.Errorf("invalid toleration: key is required when operator is Equal")
+			}
+		default:
+			return fmt.Errorf("invalid toleration %q: unsupported operator %q", toleration.Key, toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("invalid toleration %q: unsupported effect %q", toleration.Key, toleration.Effect)
+		}
+	}
+	return nil
+}
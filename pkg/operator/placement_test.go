@@ -0,0 +1,112 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestNodePlacementHooks(t *testing.T) {
+	controllerPodSpec := func() corev1.PodSpec {
+		return corev1.PodSpec{
+			NodeSelector: map[string]string{"node-role.kubernetes.io/master": ""},
+			Tolerations: []corev1.Toleration{
+				{Key: "node-role.kubernetes.io/master", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+			},
+		}
+	}
+	nodePodSpec := func() corev1.PodSpec {
+		return corev1.PodSpec{
+			NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+			Tolerations:  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+		}
+	}
+
+	tests := []struct {
+		name               string
+		overrides          string
+		isHypershift       bool
+		expectedController corev1.PodSpec
+		expectedNode       corev1.PodSpec
+		expectedError      bool
+	}{
+		{
+			name:               "no placement",
+			expectedController: controllerPodSpec(),
+			expectedNode:       nodePodSpec(),
+		},
+		{
+			name:      "controller on infra nodes",
+			overrides: `{"nodePlacement": {"controller": {"nodeSelector": {"node-role.kubernetes.io/infra": ""}, "tolerations": [{"key": "node-role.kubernetes.io/infra", "operator": "Exists", "effect": "NoSchedule"}]}}}`,
+			expectedController: corev1.PodSpec{
+				NodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+				Tolerations: []corev1.Toleration{
+					{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+				},
+			},
+			expectedNode: nodePodSpec(),
+		},
+		{
+			name:               "node selector only",
+			overrides:          `{"nodePlacement": {"node": {"nodeSelector": {"kubernetes.io/os": "linux", "ebs": "true"}}}}`,
+			expectedController: controllerPodSpec(),
+			expectedNode: corev1.PodSpec{
+				NodeSelector: map[string]string{"kubernetes.io/os": "linux", "ebs": "true"},
+				Tolerations:  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			},
+		},
+		{
+			name:               "controller placement ignored on Hypershift",
+			overrides:          `{"nodePlacement": {"controller": {"nodeSelector": {"node-role.kubernetes.io/infra": ""}}}}`,
+			isHypershift:       true,
+			expectedController: controllerPodSpec(),
+			expectedNode:       nodePodSpec(),
+		},
+		{
+			name:          "invalid node selector",
+			overrides:     `{"nodePlacement": {"node": {"nodeSelector": {"not a label": "x"}}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid toleration",
+			overrides:     `{"nodePlacement": {"controller": {"tolerations": [{"key": "foo", "operator": "Exists", "value": "bar"}]}}}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec = controllerPodSpec()
+			deploymentErr := withNodePlacementDeploymentHook(test.isHypershift)(spec, deployment)
+
+			daemonSet := &appsv1.DaemonSet{}
+			daemonSet.Spec.Template.Spec = nodePodSpec()
+			daemonSetErr := withNodePlacementDaemonSetHook()(spec, daemonSet)
+
+			if test.expectedError {
+				if deploymentErr == nil && daemonSetErr == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if deploymentErr != nil || daemonSetErr != nil {
+				t.Fatalf("unexpected errors: %v, %v", deploymentErr, daemonSetErr)
+			}
+			if e, a := test.expectedController, deployment.Spec.Template.Spec; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment pod spec\nwant=%#v\ngot= %#v", e, a)
+			}
+			if e, a := test.expectedNode, daemonSet.Spec.Template.Spec; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected daemonset pod spec\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package operator
+
+import (
+	"fmt"
+	"path"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/pointer"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivercontrollerservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+const (
+	// Sources of the AWS credentials of the CSI driver controller.
+	credentialsFromSecret      = "Secret"
+	credentialsFromPodIdentity = "PodIdentity"
+
+	// The EKS Pod Identity Agent serves the credentials of the pods on a link-local address of the nodes,
+	// in exchange for a service account token with its audience.
+	podIdentityAgentHost       = "169.254.170.23"
+	podIdentityCredentialsURI  = "http://" + podIdentityAgentHost + "/v1/credentials"
+	podIdentityTokenVolumeName = "eks-pod-identity-token"
+	podIdentityTokenMountPath  = "/var/run/secrets/pods.eks.amazonaws.com/serviceaccount"
+	podIdentityTokenFileName   = "eks-pod-identity-token"
+	podIdentityTokenAudience   = "pods.eks.amazonaws.com"
+	podIdentityTokenExpiration = 86400
+)
+
+// credentialsEnv are the csi-driver environment variables that pass the credentials from the Secret.
+var credentialsEnv = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_CONFIG_FILE",
+	"AWS_SHARED_CREDENTIALS_FILE",
+	"AWS_ROLE_ARN",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_ROLE_SESSION_NAME",
+}
+
+// usesPodIdentity returns true when the CSI driver controller gets its credentials from EKS Pod Identity
+// instead of the credentials Secret.
+func usesPodIdentity(spec *opv1.OperatorSpec) (bool, error) {
+	cfg, err := getDriverConfig(spec)
+	if err != nil {
+		return false, err
+	}
+	switch cfg.CredentialsSource {
+	case "", credentialsFromSecret:
+		return false, nil
+	case credentialsFromPodIdentity:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid credentialsSource %q, expected %s or %s", cfg.CredentialsSource, credentialsFromSecret, credentialsFromPodIdentity)
+	}
+}
+
+// withPodIdentityCredentials makes the csi-driver container of hosted control planes that run on EKS get its
+// credentials from EKS Pod Identity: the credentials Secret is neither mounted nor passed in env vars, and the
+// container gets the credentials endpoint of the Pod Identity Agent and a projected service account token for it.
+// The Pod Identity association of the controller ServiceAccount is created by the cluster admin in EKS.
+func withPodIdentityCredentials(isHypershift bool) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		podIdentity, err := usesPodIdentity(spec)
+		if err != nil || !podIdentity {
+			return err
+		}
+		if !isHypershift {
+			return fmt.Errorf("credentialsSource %s is only supported on hosted control planes", credentialsFromPodIdentity)
+		}
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		// Both read their source credentials from the Secret.
+		if cfg.AssumeRole != nil || cfg.SharedConfig != nil {
+			return fmt.Errorf("assumeRole and sharedConfig can't be used together with credentialsSource %s, associate the role with the ServiceAccount instead", credentialsFromPodIdentity)
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		container := getContainer(podSpec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not configure EKS Pod Identity because the csi-driver container is missing from the deployment")
+		}
+		for _, name := range credentialsEnv {
+			removeContainerEnv(container, name)
+		}
+		mounts := container.VolumeMounts[:0]
+		for _, mount := range container.VolumeMounts {
+			if mount.Name != credentialsVolumeName {
+				mounts = append(mounts, mount)
+			}
+		}
+		container.VolumeMounts = mounts
+		volumes := podSpec.Volumes[:0]
+		for _, volume := range podSpec.Volumes {
+			if volume.Name != credentialsVolumeName {
+				volumes = append(volumes, volume)
+			}
+		}
+		podSpec.Volumes = volumes
+
+		setContainerEnv(container, "AWS_CONTAINER_CREDENTIALS_FULL_URI", podIdentityCredentialsURI)
+		setContainerEnv(container, "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", path.Join(podIdentityTokenMountPath, podIdentityTokenFileName))
+		// The agent is on the node, the credentials requests must not go through the cluster proxy.
+		_, httpProxy := getContainerEnv(container, "HTTP_PROXY")
+		noProxy, _ := getContainerEnv(container, "NO_PROXY")
+		if httpProxy && !noProxyMatches(noProxy, podIdentityAgentHost) {
+			if noProxy != "" {
+				noProxy += ","
+			}
+			setContainerEnv(container, "NO_PROXY", noProxy+podIdentityAgentHost)
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      podIdentityTokenVolumeName,
+			MountPath: podIdentityTokenMountPath,
+			ReadOnly:  true,
+		})
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: podIdentityTokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          podIdentityTokenAudience,
+							ExpirationSeconds: pointer.Int64(podIdentityTokenExpiration),
+							Path:              podIdentityTokenFileName,
+						},
+					}},
+				},
+			},
+		})
+		return nil
+	}
+}
+
+// withCredentialsSecretHashHook annotates the controller pods with the hash of the credentials Secret to roll
+// them out when the credentials change. Pods that use EKS Pod Identity don't use the Secret, which may not exist.
+func withCredentialsSecretHashHook(namespace, secretName string, secretInformer corev1informers.SecretInformer) dc.DeploymentHookFunc {
+	hashHook := csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(namespace, secretName, secretInformer)
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		podIdentity, err := usesPodIdentity(spec)
+		if err != nil || podIdentity {
+			return err
+		}
+		return hashHook(spec, deployment)
+	}
+}
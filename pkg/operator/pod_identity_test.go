@@ -0,0 +1,129 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWithPodIdentityCredentials(t *testing.T) {
+	tests := []struct {
+		name             string
+		overrides        string
+		isHypershift     bool
+		httpProxy        bool
+		expectedNoChange bool
+		expectedNoProxy  string
+		expectedError    bool
+	}{
+		{
+			name:             "secret",
+			isHypershift:     true,
+			expectedNoChange: true,
+		},
+		{
+			name:         "pod identity",
+			overrides:    `{"credentialsSource": "PodIdentity"}`,
+			isHypershift: true,
+		},
+		{
+			name:            "pod identity behind a proxy",
+			overrides:       `{"credentialsSource": "PodIdentity"}`,
+			isHypershift:    true,
+			httpProxy:       true,
+			expectedNoProxy: ".cluster.local," + podIdentityAgentHost,
+		},
+		{
+			name:          "standalone",
+			overrides:     `{"credentialsSource": "PodIdentity"}`,
+			expectedError: true,
+		},
+		{
+			name:          "with assumeRole",
+			overrides:     `{"credentialsSource": "PodIdentity", "assumeRole": {"roleARN": "` + testRoleARN + `"}}`,
+			isHypershift:  true,
+			expectedError: true,
+		},
+		{
+			name:          "unknown source",
+			overrides:     `{"credentialsSource": "InstanceProfile"}`,
+			isHypershift:  true,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := corev1.Container{
+				Name: driverContainerName,
+				Env: []corev1.EnvVar{
+					{Name: "AWS_ACCESS_KEY_ID", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "aws_access_key_id"}}},
+					{Name: "AWS_SECRET_ACCESS_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{Key: "aws_secret_access_key"}}},
+					{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws/credentials"},
+					{Name: "AWS_REGION", Value: "us-east-1"},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: credentialsVolumeName, MountPath: "/var/run/secrets/aws"}},
+			}
+			if test.httpProxy {
+				container.Env = append(container.Env,
+					corev1.EnvVar{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+					corev1.EnvVar{Name: "NO_PROXY", Value: ".cluster.local"},
+				)
+			}
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
+			deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+				Name:         credentialsVolumeName,
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: defaultSecretName}},
+			}}
+			original := deployment.DeepCopy()
+
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			err := withPodIdentityCredentials(test.isHypershift)(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.expectedNoChange {
+				if len(deployment.Spec.Template.Spec.Containers[0].Env) != len(original.Spec.Template.Spec.Containers[0].Env) {
+					t.Errorf("expected no change, got %+v", deployment.Spec.Template)
+				}
+				return
+			}
+
+			podSpec := deployment.Spec.Template.Spec
+			driver := getContainer(&podSpec, driverContainerName)
+			for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_CONFIG_FILE"} {
+				if _, ok := getContainerEnv(driver, name); ok {
+					t.Errorf("unexpected env var %s", name)
+				}
+			}
+			if uri, _ := getContainerEnv(driver, "AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != podIdentityCredentialsURI {
+				t.Errorf("expected the credentials URI of the agent, got %q", uri)
+			}
+			if file, _ := getContainerEnv(driver, "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "/var/run/secrets/pods.eks.amazonaws.com/serviceaccount/eks-pod-identity-token" {
+				t.Errorf("unexpected token file %q", file)
+			}
+			if noProxy, _ := getContainerEnv(driver, "NO_PROXY"); noProxy != test.expectedNoProxy {
+				t.Errorf("expected NO_PROXY %q, got %q", test.expectedNoProxy, noProxy)
+			}
+			if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].Projected == nil ||
+				podSpec.Volumes[0].Projected.Sources[0].ServiceAccountToken.Audience != podIdentityTokenAudience {
+				t.Errorf("expected only the pod identity token volume, got %+v", podSpec.Volumes)
+			}
+			if len(driver.VolumeMounts) != 1 || driver.VolumeMounts[0].Name != podIdentityTokenVolumeName {
+				t.Errorf("expected only the pod identity token mount, got %+v", driver.VolumeMounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package operator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+const (
+	// defaultPriorityClass is the priority class of the controller pods on standalone clusters.
+	defaultPriorityClass = "system-cluster-critical"
+
+	// controlPlanePriorityClassEnvName overrides the priority class of the controller pods on Hypershift for all the
+	// hosted control planes of the management cluster.
+	controlPlanePriorityClassEnvName = "CONTROL_PLANE_PRIORITY_CLASS"
+	// controlPlanePriorityClassAnnotation is the annotation of the HostedControlPlane, copied from the HostedCluster,
+	// with the priority class of the control plane components of the hosted cluster.
+	controlPlanePriorityClassAnnotation = "hypershift.openshift.io/control-plane-priority-class"
+)
+
+// withPriorityClassHook sets the priority class of the controller pods. On standalone clusters it's the
+// priority class from the driver configuration or system-cluster-critical, so the pods are not evicted
+// before less critical workloads. On Hypershift it's the priority class of the control plane components from the
+// annotation of the HostedControlPlane, CONTROL_PLANE_PRIORITY_CLASS or hypershift-control-plane, in this order.
+func withPriorityClassHook(isHypershift bool, hcpLister cache.GenericNamespaceLister) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if isHypershift {
+			priorityClass, err := hostedControlPlanePriorityClass(hcpLister)
+			if err != nil {
+				return err
+			}
+			deployment.Spec.Template.Spec.PriorityClassName = priorityClass
+			return nil
+		}
+
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		priorityClass := defaultPriorityClass
+		if cfg.PriorityClassName != "" {
+			if errs := validation.IsDNS1123Subdomain(cfg.PriorityClassName); len(errs) > 0 {
+				return fmt.Errorf("invalid priorityClassName %q: %s", cfg.PriorityClassName, strings.Join(errs, ", "))
+			}
+			priorityClass = cfg.PriorityClassName
+		}
+		deployment.Spec.Template.Spec.PriorityClassName = priorityClass
+		return nil
+	}
+}
+
+// hostedControlPlanePriorityClass returns the priority class of the controller pods on Hypershift.
+func hostedControlPlanePriorityClass(hcpLister cache.GenericNamespaceLister) (string, error) {
+	hcp, err := getHostedControlPlane(hcpLister)
+	if err != nil {
+		return "", err
+	}
+	if hcp != nil {
+		if priorityClass := hcp.GetAnnotations()[controlPlanePriorityClassAnnotation]; priorityClass != "" {
+			if errs := validation.IsDNS1123Subdomain(priorityClass); len(errs) > 0 {
+				return "", fmt.Errorf("invalid %s annotation %q of HostedControlPlane %s: %s",
+					controlPlanePriorityClassAnnotation, priorityClass, hcp.GetName(), strings.Join(errs, ", "))
+			}
+			return priorityClass, nil
+		}
+	}
+	if priorityClass := os.Getenv(controlPlanePriorityClassEnvName); priorityClass != "" {
+		if errs := validation.IsDNS1123Subdomain(priorityClass); len(errs) > 0 {
+			return "", fmt.Errorf("invalid %s %q: %s", controlPlanePriorityClassEnvName, priorityClass, strings.Join(errs, ", "))
+		}
+		return priorityClass, nil
+	}
+	return hypershiftPriorityClass, nil
+}
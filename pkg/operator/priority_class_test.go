@@ -0,0 +1,110 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestWithPriorityClassHook(t *testing.T) {
+	tests := []struct {
+		name          string
+		isHypershift  bool
+		overrides     string
+		env           string
+		hcpPriority   string
+		expected      string
+		expectedError bool
+	}{
+		{
+			name:     "standalone",
+			expected: "system-cluster-critical",
+		},
+		{
+			name:      "standalone with custom priority class",
+			overrides: `{"priorityClassName": "storage-critical"}`,
+			expected:  "storage-critical",
+		},
+		{
+			name:          "standalone with invalid priority class",
+			overrides:     `{"priorityClassName": "Storage Critical"}`,
+			expectedError: true,
+		},
+		{
+			name:         "hypershift",
+			isHypershift: true,
+			expected:     "hypershift-control-plane",
+		},
+		{
+			name:         "hypershift ignores custom priority class",
+			isHypershift: true,
+			overrides:    `{"priorityClassName": "storage-critical"}`,
+			expected:     "hypershift-control-plane",
+		},
+		{
+			name:         "hypershift with priority class of the management cluster",
+			isHypershift: true,
+			env:          "control-plane-tier-2",
+			expected:     "control-plane-tier-2",
+		},
+		{
+			name:         "hypershift with priority class of the HostedControlPlane",
+			isHypershift: true,
+			env:          "control-plane-tier-2",
+			hcpPriority:  "request-serving",
+			expected:     "request-serving",
+		},
+		{
+			name:          "hypershift with invalid priority class of the HostedControlPlane",
+			isHypershift:  true,
+			hcpPriority:   "Request Serving",
+			expectedError: true,
+		},
+		{
+			name:          "hypershift with invalid priority class of the management cluster",
+			isHypershift:  true,
+			env:           "Tier 2",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(controlPlanePriorityClassEnvName, test.env)
+			var annotations map[string]string
+			if test.hcpPriority != "" {
+				annotations = map[string]string{controlPlanePriorityClassAnnotation: test.hcpPriority}
+			}
+			hcpLister := newTestHCPListerWithAnnotations(annotations, map[string]interface{}{})
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			asset, err := assets.ReadFile("controller.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := resourceread.ReadDeploymentV1OrDie(asset)
+			// Run the hooks in the same order as the operator.
+			if err := withHypershiftDeploymentHook(test.isHypershift, "hypershift-image")(spec, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = withPriorityClassHook(test.isHypershift, hcpLister)(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if a := deployment.Spec.Template.Spec.PriorityClassName; a != test.expected {
+				t.Errorf("expected priority class %q, got %q", test.expected, a)
+			}
+		})
+	}
+}
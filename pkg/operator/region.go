@@ -0,0 +1,200 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// regionEnvName is the env var of the operator with the AWS region of the driver, used when the
+	// Infrastructure has no region.
+	regionEnvName = "AWS_REGION"
+
+	imdsTimeout = 10 * time.Second
+)
+
+// awsRegion determines the AWS region of the driver, in order of precedence from:
+//   - the Infrastructure, including the legacy cloud provider config.
+//   - the AWS_REGION env var of the operator.
+//   - the topology.kubernetes.io/region label of the nodes, the region of most nodes.
+//   - the instance metadata service of the node of the operator, queried by the awsRegionController on
+//     standalone clusters. On Hypershift, the operator runs in the management cluster, which may be in
+//     another region.
+type awsRegion struct {
+	infraLister configlisters.InfrastructureLister
+	nodeLister  corev1listers.NodeLister
+
+	lock       sync.Mutex
+	imdsRegion string
+}
+
+func newAWSRegion(infraLister configlisters.InfrastructureLister, nodeLister corev1listers.NodeLister) *awsRegion {
+	return &awsRegion{infraLister: infraLister, nodeLister: nodeLister}
+}
+
+// get returns the region and where it comes from, or "" when it is not known.
+func (r *awsRegion) get() (string, string, error) {
+	infra, err := r.infraLister.Get(infrastructureName)
+	if err != nil {
+		return "", "", err
+	}
+	if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.AWS != nil && infra.Status.PlatformStatus.AWS.Region != "" {
+		return infra.Status.PlatformStatus.AWS.Region, "Infrastructure " + infrastructureName, nil
+	}
+	if region := os.Getenv(regionEnvName); region != "" {
+		return region, "the " + regionEnvName + " env var of the operator", nil
+	}
+
+	nodes, err := r.nodeLister.List(labels.Everything())
+	if err != nil {
+		return "", "", err
+	}
+	counts := map[string]int{}
+	var regions []string
+	for _, node := range nodes {
+		region := node.Labels[corev1.LabelTopologyRegion]
+		if region == "" {
+			continue
+		}
+		if counts[region] == 0 {
+			regions = append(regions, region)
+		}
+		counts[region]++
+	}
+	if len(regions) > 0 {
+		sort.SliceStable(regions, func(i, j int) bool {
+			if counts[regions[i]] != counts[regions[j]] {
+				return counts[regions[i]] > counts[regions[j]]
+			}
+			return regions[i] < regions[j]
+		})
+		return regions[0], "the " + corev1.LabelTopologyRegion + " label of the nodes", nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.imdsRegion != "" {
+		return r.imdsRegion, "the instance metadata service", nil
+	}
+	return "", "", nil
+}
+
+func (r *awsRegion) setIMDSRegion(region string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.imdsRegion = region
+}
+
+// withAWSRegion sets AWS_REGION in the csi-driver container of the controller to the region of awsRegion. It's
+// not set while the region is unknown, the awsRegionController reports it.
+func withAWSRegion(region *awsRegion) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		value, _, err := region.get()
+		if err != nil || value == "" {
+			return err
+		}
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not set the AWS region because the csi-driver container is missing from the deployment")
+		}
+		setContainerEnv(container, "AWS_REGION", value)
+		return nil
+	}
+}
+
+// awsRegionController makes sure that the AWS region of the driver is known: without it, the driver guesses
+// the region from the instance metadata service of its node and provisioning breaks when it's wrong. When the
+// Infrastructure, the operator env and the nodes have no region, it queries the instance metadata service of
+// the node of the operator once on standalone clusters.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error, e.g. when no region can be determined.
+type awsRegionController struct {
+	operatorClient v1helpers.OperatorClient
+	isHypershift   bool
+	region         *awsRegion
+	instanceRegion func(ctx context.Context) (string, error)
+}
+
+func newAWSRegionController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	isHypershift bool,
+	region *awsRegion,
+	infraInformer configinformersv1.InfrastructureInformer,
+	nodeInformer coreinformersv1.NodeInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &awsRegionController{
+		operatorClient: operatorClient,
+		isHypershift:   isHypershift,
+		region:         region,
+		instanceRegion: func(ctx context.Context) (string, error) {
+			return awsclient.InstanceRegion(ctx, nil, awsclient.DefaultIMDSEndpoint)
+		},
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		infraInformer.Informer(),
+		nodeInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *awsRegionController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	region, _, err := c.region.get()
+	if err != nil || region != "" {
+		return err
+	}
+	if c.isHypershift {
+		return fmt.Errorf("could not determine the AWS region of the driver: Infrastructure %s has no AWS region, the %s env var of the operator is not set and the nodes have no %s label",
+			infrastructureName, regionEnvName, corev1.LabelTopologyRegion)
+	}
+
+	imdsCtx, cancel := context.WithTimeout(ctx, imdsTimeout)
+	defer cancel()
+	region, err = c.instanceRegion(imdsCtx)
+	if err != nil {
+		return fmt.Errorf("could not determine the AWS region of the driver: Infrastructure %s has no AWS region, the %s env var of the operator is not set, the nodes have no %s label and the instance metadata service failed: %w",
+			infrastructureName, regionEnvName, corev1.LabelTopologyRegion, err)
+	}
+	c.region.setIMDSRegion(region)
+	syncCtx.Recorder().Warningf("AWSRegionFromIMDS", "Infrastructure %s has no AWS region, the driver uses the region %s of the instance metadata service", infrastructureName, region)
+	return nil
+}
@@ -0,0 +1,121 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+func newTestRegionNode(name, region string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelTopologyRegion: region}}}
+}
+
+func TestAWSRegion(t *testing.T) {
+	tests := []struct {
+		name           string
+		infraRegion    string
+		env            string
+		nodes          []*corev1.Node
+		isHypershift   bool
+		imdsRegion     string
+		imdsErr        error
+		expectedRegion string
+		expectedError  bool
+	}{
+		{
+			name:           "Infrastructure",
+			infraRegion:    "us-east-1",
+			env:            "us-west-1",
+			nodes:          []*corev1.Node{newTestRegionNode("node-0", "us-west-2")},
+			expectedRegion: "us-east-1",
+		},
+		{
+			name:           "operator env",
+			env:            "us-west-1",
+			nodes:          []*corev1.Node{newTestRegionNode("node-0", "us-west-2")},
+			expectedRegion: "us-west-1",
+		},
+		{
+			name: "node labels",
+			nodes: []*corev1.Node{
+				newTestRegionNode("node-0", "us-west-2"),
+				newTestRegionNode("node-1", "eu-west-1"),
+				newTestRegionNode("node-2", "us-west-2"),
+			},
+			expectedRegion: "us-west-2",
+		},
+		{
+			name:           "IMDS",
+			imdsRegion:     "eu-central-1",
+			expectedRegion: "eu-central-1",
+		},
+		{
+			name:          "IMDS failure",
+			imdsErr:       errors.New("connection refused"),
+			expectedError: true,
+		},
+		{
+			name:          "no IMDS on Hypershift",
+			isHypershift:  true,
+			imdsRegion:    "eu-central-1",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(regionEnvName, test.env)
+			infra := &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{Type: configv1.AWSPlatformType, AWS: &configv1.AWSPlatformStatus{Region: test.infraRegion}},
+				},
+			}
+			infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+			infraInformer.Informer().GetIndexer().Add(infra)
+			region := newAWSRegion(infraInformer.Lister(), newTestNodeLister(test.nodes...))
+
+			operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+			c := &awsRegionController{
+				operatorClient: operatorClient,
+				isHypershift:   test.isHypershift,
+				region:         region,
+				instanceRegion: func(context.Context) (string, error) {
+					return test.imdsRegion, test.imdsErr
+				},
+			}
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: driverContainerName}}
+			if err := withAWSRegion(region)(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			value, _ := getContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "AWS_REGION")
+			if value != test.expectedRegion {
+				t.Errorf("expected region %q, got %q", test.expectedRegion, value)
+			}
+		})
+	}
+}
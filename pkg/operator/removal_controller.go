@@ -0,0 +1,162 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	storageinformersv1 "k8s.io/client-go/informers/storage/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// managedAnnotation marks the cluster scoped objects created by the operator.
+	// Only objects with this annotation are deleted when the driver is removed.
+	managedAnnotation = "csi.openshift.io/managed"
+
+	driverName          = "ebs.csi.aws.com"
+	volumeSnapshotClass = "csi-aws-vsc"
+)
+
+var volumeSnapshotClassGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshotclasses",
+}
+
+// removalController deletes the objects created by the operator in the guest cluster when the ClusterCSIDriver
+// is set to Removed: the StorageClasses, the VolumeSnapshotClass and the copy of the custom CA bundle ConfigMap.
+// StorageClasses and the VolumeSnapshotClass are deleted only when they have the managed annotation,
+// so classes created by users are never touched. PersistentVolumes and the volumes on AWS are not affected.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error.
+type removalController struct {
+	operatorClient      v1helpers.OperatorClient
+	kubeClient          kubeclient.Interface
+	storageClassLister  storagelisters.StorageClassLister
+	snapshotClassClient dynamic.ResourceInterface
+	namespace           string
+	configMapLister     corev1listers.ConfigMapNamespaceLister
+}
+
+func newRemovalController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	kubeClient kubeclient.Interface,
+	dynamicClient dynamic.Interface,
+	storageClassInformer storageinformersv1.StorageClassInformer,
+	namespace string,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &removalController{
+		operatorClient:      operatorClient,
+		kubeClient:          kubeClient,
+		storageClassLister:  storageClassInformer.Lister(),
+		snapshotClassClient: dynamicClient.Resource(volumeSnapshotClassGVR),
+		namespace:           namespace,
+		configMapLister:     configMapInformer.Lister().ConfigMaps(namespace),
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		storageClassInformer.Informer(),
+		configMapInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *removalController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Removed {
+		return nil
+	}
+
+	if err := c.removeStorageClasses(ctx, syncCtx.Recorder()); err != nil {
+		return err
+	}
+	if err := c.removeVolumeSnapshotClass(ctx, syncCtx.Recorder()); err != nil {
+		return err
+	}
+	return c.removeCABundle(ctx, syncCtx.Recorder())
+}
+
+func (c *removalController) removeStorageClasses(ctx context.Context, recorder events.Recorder) error {
+	storageClasses, err := c.storageClassLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, sc := range storageClasses {
+		if sc.Provisioner != driverName || sc.Annotations[managedAnnotation] != "true" || sc.Annotations[unmanagedAnnotation] == "true" {
+			continue
+		}
+		err := c.kubeClient.StorageV1().StorageClasses().Delete(ctx, sc.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		klog.V(2).Infof("Deleted StorageClass %s", sc.Name)
+		recorder.Eventf("StorageClassDeleted", "Deleted StorageClass %s", sc.Name)
+	}
+	return nil
+}
+
+func (c *removalController) removeVolumeSnapshotClass(ctx context.Context, recorder events.Recorder) error {
+	vsc, err := c.snapshotClassClient.Get(ctx, volumeSnapshotClass, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		// Either the VolumeSnapshotClass or its CRD does not exist.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if vsc.GetAnnotations()[managedAnnotation] != "true" {
+		return nil
+	}
+	err = c.snapshotClassClient.Delete(ctx, volumeSnapshotClass, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.V(2).Infof("Deleted VolumeSnapshotClass %s", volumeSnapshotClass)
+	recorder.Eventf("VolumeSnapshotClassDeleted", "Deleted VolumeSnapshotClass %s", volumeSnapshotClass)
+	return nil
+}
+
+// removeCABundle deletes the copy of the custom CA bundle ConfigMap made either by the custom CA bundle sync
+// controller (standalone clusters) or by the guest CA bundle syncer (Hypershift).
+func (c *removalController) removeCABundle(ctx context.Context, recorder events.Recorder) error {
+	if _, err := c.configMapLister.Get(cloudConfigName); apierrors.IsNotFound(err) {
+		return nil
+	}
+	err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).Delete(ctx, cloudConfigName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	klog.V(2).Infof("Deleted ConfigMap %s/%s", c.namespace, cloudConfigName)
+	recorder.Eventf("ConfigMapDeleted", "Deleted ConfigMap %s/%s", c.namespace, cloudConfigName)
+	return nil
+}
@@ -0,0 +1,160 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeSnapshotClassClient stores VolumeSnapshotClasses in a map. Only Get and Delete are implemented.
+type fakeSnapshotClassClient struct {
+	dynamic.ResourceInterface
+	objects map[string]*unstructured.Unstructured
+}
+
+func (f *fakeSnapshotClassClient) Get(_ context.Context, name string, _ metav1.GetOptions, _ ...string) (*unstructured.Unstructured, error) {
+	obj, ok := f.objects[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(volumeSnapshotClassGVR.GroupResource(), name)
+	}
+	return obj, nil
+}
+
+func (f *fakeSnapshotClassClient) Delete(_ context.Context, name string, _ metav1.DeleteOptions, _ ...string) error {
+	if _, ok := f.objects[name]; !ok {
+		return apierrors.NewNotFound(volumeSnapshotClassGVR.GroupResource(), name)
+	}
+	delete(f.objects, name)
+	return nil
+}
+
+func newTestVolumeSnapshotClass(name string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("snapshot.storage.k8s.io/v1")
+	obj.SetKind("VolumeSnapshotClass")
+	obj.SetName(name)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func TestRemovalController(t *testing.T) {
+	managed := map[string]string{managedAnnotation: "true"}
+	tests := []struct {
+		name                 string
+		managementState      opv1.ManagementState
+		storageClasses       []*storagev1.StorageClass
+		snapshotClass        *unstructured.Unstructured
+		expectStorageClasses []string
+		expectSnapshotClass  bool
+		expectCABundle       bool
+	}{
+		{
+			name:            "managed driver is not cleaned up",
+			managementState: opv1.Managed,
+			storageClasses: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", managed),
+			},
+			snapshotClass:        newTestVolumeSnapshotClass(volumeSnapshotClass, managed),
+			expectStorageClasses: []string{"gp3-csi"},
+			expectSnapshotClass:  true,
+			expectCABundle:       true,
+		},
+		{
+			name:            "removed driver is cleaned up",
+			managementState: opv1.Removed,
+			storageClasses: []*storagev1.StorageClass{
+				newTestStorageClass("gp2-csi", managed),
+				newTestStorageClass("gp3-csi", managed),
+				newTestStorageClass("user", nil),
+			},
+			snapshotClass:        newTestVolumeSnapshotClass(volumeSnapshotClass, managed),
+			expectStorageClasses: []string{"user"},
+		},
+		{
+			name:                "user VolumeSnapshotClass is kept",
+			managementState:     opv1.Removed,
+			snapshotClass:       newTestVolumeSnapshotClass(volumeSnapshotClass, nil),
+			expectSnapshotClass: true,
+		},
+		{
+			name:            "nothing to clean up",
+			managementState: opv1.Removed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			caBundle := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: cloudConfigName, Namespace: defaultNamespace},
+				Data:       map[string]string{caBundleKey: "bundle"},
+			}
+			objects := []runtime.Object{caBundle}
+			for _, sc := range test.storageClasses {
+				objects = append(objects, sc)
+			}
+			kubeClient := fake.NewSimpleClientset(objects...)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			for _, sc := range test.storageClasses {
+				informerFactory.Storage().V1().StorageClasses().Informer().GetIndexer().Add(sc)
+			}
+			informerFactory.Core().V1().ConfigMaps().Informer().GetIndexer().Add(caBundle)
+			snapshotClassClient := &fakeSnapshotClassClient{objects: map[string]*unstructured.Unstructured{}}
+			if test.snapshotClass != nil {
+				snapshotClassClient.objects[test.snapshotClass.GetName()] = test.snapshotClass
+			}
+
+			spec := &opv1.OperatorSpec{ManagementState: test.managementState}
+			c := &removalController{
+				operatorClient:      v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil),
+				kubeClient:          kubeClient,
+				storageClassLister:  informerFactory.Storage().V1().StorageClasses().Lister(),
+				snapshotClassClient: snapshotClassClient,
+				namespace:           defaultNamespace,
+				configMapLister:     informerFactory.Core().V1().ConfigMaps().Lister().ConfigMaps(defaultNamespace),
+			}
+
+			// The sync must be idempotent, run it twice. The second run sees stale informers.
+			syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+			for i := 0; i < 2; i++ {
+				if err := c.sync(context.TODO(), syncCtx); err != nil {
+					t.Fatalf("unexpected error in sync %d: %v", i, err)
+				}
+			}
+
+			scs, err := kubeClient.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list StorageClasses: %v", err)
+			}
+			names := sets.NewString()
+			for _, sc := range scs.Items {
+				names.Insert(sc.Name)
+			}
+			if expected := sets.NewString(test.expectStorageClasses...); !names.Equal(expected) {
+				t.Errorf("expected StorageClasses %v, got %v", expected.List(), names.List())
+			}
+
+			if _, found := snapshotClassClient.objects[volumeSnapshotClass]; found != test.expectSnapshotClass {
+				t.Errorf("expected VolumeSnapshotClass to exist: %t, got %t", test.expectSnapshotClass, found)
+			}
+
+			_, err = kubeClient.CoreV1().ConfigMaps(defaultNamespace).Get(context.TODO(), cloudConfigName, metav1.GetOptions{})
+			if found := err == nil; found != test.expectCABundle {
+				t.Errorf("expected CA bundle ConfigMap to exist: %t, got %t (%v)", test.expectCABundle, found, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// withResourcesDeploymentHook applies the container resources from the driver configuration to the
+// controller Deployment, e.g. larger requests of the sidecars on dense clusters.
+func withResourcesDeploymentHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.Resources == nil {
+			return nil
+		}
+		return applyResources("controller", cfg.Resources.Controller, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withResourcesDaemonSetHook applies the container resources from the driver configuration to the
+// node DaemonSet, e.g. smaller requests on single node clusters.
+func withResourcesDaemonSetHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.Resources == nil {
+			return nil
+		}
+		return applyResources("node", cfg.Resources.Node, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+func applyResources(name string, resources map[string]corev1.ResourceRequirements, podSpec *corev1.PodSpec) error {
+	// Sort the containers to report errors consistently.
+	containerNames := make([]string, 0, len(resources))
+	for containerName := range resources {
+		containerNames = append(containerNames, containerName)
+	}
+	sort.Strings(containerNames)
+
+	for _, containerName := range containerNames {
+		container := getContainer(podSpec, containerName)
+		if container == nil {
+			// The container may not be deployed, e.g. token-minter on standalone clusters.
+			continue
+		}
+		requirements := resources[containerName]
+		merged := *container.Resources.DeepCopy()
+		merged.Requests = mergeResourceList(merged.Requests, requirements.Requests)
+		merged.Limits = mergeResourceList(merged.Limits, requirements.Limits)
+		for resourceName, limit := range merged.Limits {
+			if request, ok := merged.Requests[resourceName]; ok && request.Cmp(limit) > 0 {
+				return fmt.Errorf("invalid resources of %s container %s: %s request %s is greater than the limit %s",
+					name, containerName, resourceName, request.String(), limit.String())
+			}
+		}
+		container.Resources = merged
+	}
+	return nil
+}
+
+func mergeResourceList(list, overrides corev1.ResourceList) corev1.ResourceList {
+	if len(overrides) == 0 {
+		return list
+	}
+	if list == nil {
+		list = corev1.ResourceList{}
+	}
+	for resourceName, quantity := range overrides {
+		list[resourceName] = quantity
+	}
+	return list
+}
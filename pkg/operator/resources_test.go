@@ -0,0 +1,112 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResourcesHooks(t *testing.T) {
+	newPodSpec := func(provisionerResources corev1.ResourceRequirements) corev1.PodSpec {
+		return corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "csi-driver",
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("10m"),
+							corev1.ResourceMemory: resource.MustParse("50Mi"),
+						},
+					},
+				},
+				{
+					Name:      "csi-provisioner",
+					Resources: provisionerResources,
+				},
+			},
+		}
+	}
+	defaultProvisionerResources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("50Mi"),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      corev1.PodSpec
+		expectedError bool
+	}{
+		{
+			name:     "no resources",
+			expected: newPodSpec(defaultProvisionerResources),
+		},
+		{
+			name:      "requests and limits",
+			overrides: `{"resources": {"controller": {"csi-provisioner": {"requests": {"cpu": "100m"}, "limits": {"memory": "1Gi"}}}, "node": {"csi-provisioner": {"requests": {"cpu": "100m"}, "limits": {"memory": "1Gi"}}}}}`,
+			expected: newPodSpec(corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("100m"),
+					corev1.ResourceMemory: resource.MustParse("50Mi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				},
+			}),
+		},
+		{
+			name:      "missing container",
+			overrides: `{"resources": {"controller": {"driver-kube-rbac-proxy": {"requests": {"cpu": "100m"}}}, "node": {"driver-kube-rbac-proxy": {"requests": {"cpu": "100m"}}}}}`,
+			expected:  newPodSpec(defaultProvisionerResources),
+		},
+		{
+			name:          "request greater than limit",
+			overrides:     `{"resources": {"controller": {"csi-driver": {"limits": {"memory": "10Mi"}}}, "node": {"csi-driver": {"limits": {"memory": "10Mi"}}}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid quantity",
+			overrides:     `{"resources": {"node": {"csi-driver": {"requests": {"cpu": "lots"}}}}}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Template.Spec = newPodSpec(*defaultProvisionerResources.DeepCopy())
+			deploymentErr := withResourcesDeploymentHook()(spec, deployment)
+
+			daemonSet := &appsv1.DaemonSet{}
+			daemonSet.Spec.Template.Spec = newPodSpec(*defaultProvisionerResources.DeepCopy())
+			daemonSetErr := withResourcesDaemonSetHook()(spec, daemonSet)
+
+			if test.expectedError {
+				if deploymentErr == nil || daemonSetErr == nil {
+					t.Errorf("expected errors, got %v and %v", deploymentErr, daemonSetErr)
+				}
+				return
+			}
+			if deploymentErr != nil || daemonSetErr != nil {
+				t.Fatalf("unexpected errors: %v, %v", deploymentErr, daemonSetErr)
+			}
+			if e, a := test.expected, deployment.Spec.Template.Spec; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment pod spec\nwant=%#v\ngot= %#v", e, a)
+			}
+			if e, a := test.expected, daemonSet.Spec.Template.Spec; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected daemonset pod spec\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
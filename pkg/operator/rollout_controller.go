@@ -0,0 +1,162 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+// rolloutController reports the rollout progress of the controller Deployment and the node DaemonSet.
+// Unlike the generic Progressing conditions of the Deployment and DaemonSet controllers, the message
+// contains the number of pods that still need to be updated.
+// It produces the following conditions:
+// <name>Progressing: at least one of the workloads is being rolled out.
+type rolloutController struct {
+	name             string
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+	daemonSetLister  appslisters.DaemonSetNamespaceLister
+	deploymentName   string
+	daemonSetName    string
+}
+
+func newRolloutController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	deploymentNamespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	daemonSetNamespace string,
+	daemonSetInformer appsinformersv1.DaemonSetInformer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &rolloutController{
+		name:             name,
+		operatorClient:   operatorClient,
+		deploymentLister: deploymentInformer.Lister().Deployments(deploymentNamespace),
+		daemonSetLister:  daemonSetInformer.Lister().DaemonSets(daemonSetNamespace),
+		deploymentName:   controllerDeploymentName,
+		daemonSetName:    nodeDaemonSetName,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+		daemonSetInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder.WithComponentSuffix("rollout-controller"),
+	)
+}
+
+func (c *rolloutController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	var messages []string
+
+	deployment, err := c.deploymentLister.Get(c.deploymentName)
+	switch {
+	case apierrors.IsNotFound(err):
+		// The Deployment controller creates it and reports its own progress.
+	case err != nil:
+		return err
+	default:
+		if msg := deploymentRolloutMessage(deployment); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+
+	daemonSet, err := c.daemonSetLister.Get(c.daemonSetName)
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		if msg := daemonSetRolloutMessage(daemonSet); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+
+	progressingCondition := opv1.OperatorCondition{
+		Type:   c.name + opv1.OperatorStatusTypeProgressing,
+		Status: opv1.ConditionFalse,
+	}
+	if len(messages) > 0 {
+		progressingCondition.Status = opv1.ConditionTrue
+		progressingCondition.Reason = "RollingOut"
+		progressingCondition.Message = strings.Join(messages, "\n")
+		klog.V(4).Infof("Operand rollout in progress: %s", progressingCondition.Message)
+	}
+
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(progressingCondition))
+	return err
+}
+
+// deploymentRolloutMessage updates the replica metrics of the Deployment and returns
+// a message describing the rollout or an empty string when the rollout is complete.
+func deploymentRolloutMessage(deployment *appsv1.Deployment) string {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	updated := deployment.Status.UpdatedReplicas
+	available := deployment.Status.AvailableReplicas
+	setOperandReplicasMetric("controller", desired, updated, available)
+
+	switch {
+	case deployment.Generation != deployment.Status.ObservedGeneration:
+		return "Waiting for the controller Deployment to act on changes"
+	case updated < desired:
+		return fmt.Sprintf("Waiting for %d/%d controller pods to update", desired-updated, desired)
+	case deployment.Status.Replicas > updated:
+		return fmt.Sprintf("Waiting for %d old controller pods to terminate", deployment.Status.Replicas-updated)
+	case available < desired:
+		return fmt.Sprintf("Waiting for %d/%d controller pods to become available", desired-available, desired)
+	}
+	return ""
+}
+
+// daemonSetRolloutMessage updates the replica metrics of the DaemonSet and returns
+// a message describing the rollout or an empty string when the rollout is complete.
+func daemonSetRolloutMessage(daemonSet *appsv1.DaemonSet) string {
+	desired := daemonSet.Status.DesiredNumberScheduled
+	updated := daemonSet.Status.UpdatedNumberScheduled
+	available := daemonSet.Status.NumberAvailable
+	setOperandReplicasMetric("node", desired, updated, available)
+
+	switch {
+	case daemonSet.Generation != daemonSet.Status.ObservedGeneration:
+		return "Waiting for the node DaemonSet to act on changes"
+	case updated < desired:
+		return fmt.Sprintf("Waiting for %d/%d node pods to update", desired-updated, desired)
+	case available < desired:
+		return fmt.Sprintf("Waiting for %d/%d node pods to become available", desired-available, desired)
+	}
+	return ""
+}
+
+func setOperandReplicasMetric(workload string, desired, updated, available int32) {
+	operandReplicas.WithLabelValues(workload, "desired").Set(float64(desired))
+	operandReplicas.WithLabelValues(workload, "updated").Set(float64(updated))
+	operandReplicas.WithLabelValues(workload, "available").Set(float64(available))
+}
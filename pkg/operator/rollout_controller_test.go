@@ -0,0 +1,103 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRolloutController(t *testing.T) {
+	replicas := int32(2)
+	tests := []struct {
+		name            string
+		deployment      *appsv1.Deployment
+		daemonSet       *appsv1.DaemonSet
+		expectedStatus  opv1.ConditionStatus
+		expectedMessage string
+	}{
+		{
+			name: "rolled out",
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace, Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			},
+			daemonSet: &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeDaemonSetName, Namespace: defaultNamespace, Generation: 3},
+				Status:     appsv1.DaemonSetStatus{ObservedGeneration: 3, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 3},
+			},
+			expectedStatus: opv1.ConditionFalse,
+		},
+		{
+			name: "node pods updating",
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace, Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			},
+			daemonSet: &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeDaemonSetName, Namespace: defaultNamespace, Generation: 3},
+				Status:     appsv1.DaemonSetStatus{ObservedGeneration: 3, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 1, NumberAvailable: 3},
+			},
+			expectedStatus:  opv1.ConditionTrue,
+			expectedMessage: "Waiting for 2/3 node pods to update",
+		},
+		{
+			name: "both rolling out",
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace, Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+			daemonSet: &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeDaemonSetName, Namespace: defaultNamespace, Generation: 4},
+				Status:     appsv1.DaemonSetStatus{ObservedGeneration: 3},
+			},
+			expectedStatus:  opv1.ConditionTrue,
+			expectedMessage: "Waiting for 1/2 controller pods to update\nWaiting for the node DaemonSet to act on changes",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset(test.deployment, test.daemonSet)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+			informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(test.deployment)
+			informerFactory.Apps().V1().DaemonSets().Informer().GetIndexer().Add(test.daemonSet)
+			operatorClient := v1helpers.NewFakeOperatorClient(&opv1.OperatorSpec{ManagementState: opv1.Managed}, &opv1.OperatorStatus{}, nil)
+			recorder := events.NewInMemoryRecorder("test")
+
+			ctrl := &rolloutController{
+				name:             "Test",
+				operatorClient:   operatorClient,
+				deploymentLister: informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace),
+				daemonSetLister:  informerFactory.Apps().V1().DaemonSets().Lister().DaemonSets(defaultNamespace),
+				deploymentName:   controllerDeploymentName,
+				daemonSetName:    nodeDaemonSetName,
+			}
+			if err := ctrl.sync(context.TODO(), factory.NewSyncContext("test", recorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestProgressing")
+			if cond == nil {
+				t.Fatalf("condition TestProgressing not found")
+			}
+			if cond.Status != test.expectedStatus {
+				t.Errorf("expected status %s, got %s", test.expectedStatus, cond.Status)
+			}
+			if cond.Message != test.expectedMessage {
+				t.Errorf("expected message %q, got %q", test.expectedMessage, cond.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	// snapshotScheduleAnnotation enables scheduled snapshots of a PVC, or of all PVCs of the driver in
+	// a namespace: "hourly", "daily", "weekly" or a duration like "12h".
+	snapshotScheduleAnnotation = "csi.openshift.io/snapshot-schedule"
+	// snapshotRetainAnnotation is the number of scheduled snapshots kept for each PVC.
+	snapshotRetainAnnotation = "csi.openshift.io/snapshot-retain"
+	// scheduledSnapshotLabel marks the VolumeSnapshots created by the operator.
+	scheduledSnapshotLabel = "csi.openshift.io/scheduled-snapshot"
+
+	defaultSnapshotRetain       = 7
+	minSnapshotScheduleInterval = 15 * time.Minute
+	// snapshotTimestampFormat is appended to the PVC name to name the VolumeSnapshots.
+	snapshotTimestampFormat = "20060102150405"
+)
+
+var volumeSnapshotGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshots",
+}
+
+// snapshotSchedules maps the named schedules to their intervals.
+var snapshotSchedules = map[string]time.Duration{
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// snapshotSchedule is the schedule of the snapshots of a PVC.
+type snapshotSchedule struct {
+	interval time.Duration
+	retain   int
+}
+
+// scheduledSnapshot is a VolumeSnapshot created by the operator.
+type scheduledSnapshot struct {
+	name    string
+	created time.Time
+	// ready is true when the snapshot can be restored, failed when it has an error and is not ready.
+	ready  bool
+	failed bool
+}
+
+// scheduledSnapshotController takes VolumeSnapshots of the PVCs of the driver on a schedule and deletes the
+// oldest ones. The schedule is set by annotations of the PVC or of its namespace, the PVC annotations win.
+// Only VolumeSnapshots created by the operator are deleted, and the VolumeSnapshots of deleted PVCs or PVCs
+// without a schedule are kept. Nothing is done while the snapshot CRDs are missing.
+//
+// It produces the following conditions:
+// <name>InvalidSnapshotSchedules: True when PVCs have an invalid schedule, they're listed in the message.
+// <name>Degraded: produced when the sync() method returns an error.
+type scheduledSnapshotController struct {
+	name            string
+	operatorClient  v1helpers.OperatorClient
+	snapshotClient  dynamic.NamespaceableResourceInterface
+	pvcLister       corev1listers.PersistentVolumeClaimLister
+	pvLister        corev1listers.PersistentVolumeLister
+	namespaceLister corev1listers.NamespaceLister
+	crdsExist       resourceapply.ConditionalFunction
+	now             func() time.Time
+}
+
+func newScheduledSnapshotController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	dynamicClient dynamic.Interface,
+	pvcInformer coreinformersv1.PersistentVolumeClaimInformer,
+	pvInformer coreinformersv1.PersistentVolumeInformer,
+	namespaceInformer coreinformersv1.NamespaceInformer,
+	crdsExist resourceapply.ConditionalFunction,
+	crdInformers []factory.Informer,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &scheduledSnapshotController{
+		name:            name,
+		operatorClient:  operatorClient,
+		snapshotClient:  dynamicClient.Resource(volumeSnapshotGVR),
+		pvcLister:       pvcInformer.Lister(),
+		pvLister:        pvInformer.Lister(),
+		namespaceLister: namespaceInformer.Lister(),
+		crdsExist:       crdsExist,
+		now:             time.Now,
+	}
+	informers := append([]factory.Informer{
+		operatorClient.Informer(),
+		pvcInformer.Informer(),
+		namespaceInformer.Informer(),
+	}, crdInformers...)
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		informers...,
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *scheduledSnapshotController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed || !c.crdsExist() {
+		return nil
+	}
+
+	pvcs, err := c.pvcLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	sort.Slice(pvcs, func(i, j int) bool {
+		return pvcs[i].Namespace+"/"+pvcs[i].Name < pvcs[j].Namespace+"/"+pvcs[j].Name
+	})
+
+	var snapshotsByPVC map[string][]scheduledSnapshot
+	var invalid []string
+	for _, pvc := range pvcs {
+		if !c.isDriverPVC(pvc) {
+			continue
+		}
+		namespace, err := c.namespaceLister.Get(pvc.Namespace)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		schedule, ok, err := getSnapshotSchedule(pvc, namespace)
+		if err != nil {
+			klog.Warningf("Ignoring the snapshot schedule of PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+			invalid = append(invalid, fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if snapshotsByPVC == nil {
+			// Listed only when a PVC has a schedule, so the snapshot API is not polled for nothing.
+			if snapshotsByPVC, err = c.listScheduledSnapshots(ctx); err != nil {
+				return err
+			}
+		}
+		if err := c.syncPVC(ctx, syncCtx.Recorder(), pvc, schedule, snapshotsByPVC[pvc.Namespace+"/"+pvc.Name]); err != nil {
+			return err
+		}
+	}
+
+	cond := opv1.OperatorCondition{
+		Type:   c.name + "InvalidSnapshotSchedules",
+		Status: opv1.ConditionFalse,
+		Reason: "AsExpected",
+	}
+	if len(invalid) > 0 {
+		cond.Status = opv1.ConditionTrue
+		cond.Reason = "InvalidSchedule"
+		cond.Message = fmt.Sprintf("PVCs with an invalid %s or %s annotation are not snapshotted: %s",
+			snapshotScheduleAnnotation, snapshotRetainAnnotation, strings.Join(invalid, ", "))
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// isDriverPVC returns true if the PVC is bound to a volume of the driver.
+func (c *scheduledSnapshotController) isDriverPVC(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Status.Phase != corev1.ClaimBound || pvc.Spec.VolumeName == "" {
+		return false
+	}
+	pv, err := c.pvLister.Get(pvc.Spec.VolumeName)
+	if err != nil {
+		return false
+	}
+	return pv.Spec.CSI != nil && pv.Spec.CSI.Driver == driverName
+}
+
+// listScheduledSnapshots returns the VolumeSnapshots created by the operator, keyed by namespace/PVC name.
+func (c *scheduledSnapshotController) listScheduledSnapshots(ctx context.Context) (map[string][]scheduledSnapshot, error) {
+	list, err := c.snapshotClient.List(ctx, metav1.ListOptions{LabelSelector: scheduledSnapshotLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VolumeSnapshots: %w", err)
+	}
+	snapshots := map[string][]scheduledSnapshot{}
+	for _, item := range list.Items {
+		pvcName, _, _ := unstructured.NestedString(item.Object, "spec", "source", "persistentVolumeClaimName")
+		key := item.GetNamespace() + "/" + pvcName
+		ready, _, _ := unstructured.NestedBool(item.Object, "status", "readyToUse")
+		snapshotErr, _, _ := unstructured.NestedMap(item.Object, "status", "error")
+		snapshots[key] = append(snapshots[key], scheduledSnapshot{
+			name:    item.GetName(),
+			created: item.GetCreationTimestamp().Time,
+			ready:   ready,
+			failed:  !ready && snapshotErr != nil,
+		})
+	}
+	return snapshots, nil
+}
+
+func (c *scheduledSnapshotController) syncPVC(ctx context.Context, recorder events.Recorder, pvc *corev1.PersistentVolumeClaim, schedule snapshotSchedule, existing []scheduledSnapshot) error {
+	now := c.now()
+	create, expired := planScheduledSnapshots(now, schedule, existing)
+	if create {
+		name := scheduledSnapshotName(pvc.Name, now)
+		snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": pvc.Namespace,
+				"labels": map[string]interface{}{
+					scheduledSnapshotLabel: "true",
+				},
+			},
+			"spec": map[string]interface{}{
+				"volumeSnapshotClassName": volumeSnapshotClass,
+				"source": map[string]interface{}{
+					"persistentVolumeClaimName": pvc.Name,
+				},
+			},
+		}}
+		_, err := c.snapshotClient.Namespace(pvc.Namespace).Create(ctx, snapshot, metav1.CreateOptions{})
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create VolumeSnapshot %s/%s: %w", pvc.Namespace, name, err)
+		}
+		klog.V(2).Infof("Created VolumeSnapshot %s/%s of PVC %s", pvc.Namespace, name, pvc.Name)
+		recorder.Eventf("VolumeSnapshotCreated", "Created VolumeSnapshot %s/%s of PVC %s", pvc.Namespace, name, pvc.Name)
+	}
+	for _, name := range expired {
+		err := c.snapshotClient.Namespace(pvc.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete VolumeSnapshot %s/%s: %w", pvc.Namespace, name, err)
+		}
+		klog.V(2).Infof("Deleted expired VolumeSnapshot %s/%s of PVC %s", pvc.Namespace, name, pvc.Name)
+		recorder.Eventf("VolumeSnapshotDeleted", "Deleted expired VolumeSnapshot %s/%s of PVC %s", pvc.Namespace, name, pvc.Name)
+	}
+	return nil
+}
+
+// getSnapshotSchedule returns the snapshot schedule of the PVC from its annotations or the annotations of
+// its namespace, and false when the PVC has no schedule.
+func getSnapshotSchedule(pvc *corev1.PersistentVolumeClaim, namespace *corev1.Namespace) (snapshotSchedule, bool, error) {
+	annotations := pvc.Annotations
+	if _, ok := annotations[snapshotScheduleAnnotation]; !ok && namespace != nil {
+		annotations = namespace.Annotations
+	}
+	value, ok := annotations[snapshotScheduleAnnotation]
+	if !ok || value == "" {
+		return snapshotSchedule{}, false, nil
+	}
+
+	schedule := snapshotSchedule{retain: defaultSnapshotRetain}
+	if interval, ok := snapshotSchedules[value]; ok {
+		schedule.interval = interval
+	} else {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return snapshotSchedule{}, false, fmt.Errorf("invalid %s %q: it must be hourly, daily, weekly or a duration", snapshotScheduleAnnotation, value)
+		}
+		if interval < minSnapshotScheduleInterval {
+			return snapshotSchedule{}, false, fmt.Errorf("invalid %s %q: it must be at least %s", snapshotScheduleAnnotation, value, minSnapshotScheduleInterval)
+		}
+		schedule.interval = interval
+	}
+	if value, ok := annotations[snapshotRetainAnnotation]; ok {
+		retain, err := strconv.Atoi(value)
+		if err != nil || retain < 1 {
+			return snapshotSchedule{}, false, fmt.Errorf("invalid %s %q: it must be a positive number", snapshotRetainAnnotation, value)
+		}
+		schedule.retain = retain
+	}
+	return schedule, true, nil
+}
+
+// planScheduledSnapshots returns whether a new snapshot is due and the names of the snapshots to delete.
+// Only the ready snapshots count toward the retention, so snapshots that are pending or failed never push a
+// usable snapshot out. A new snapshot is due an interval after the last ready or pending one; a failed one is
+// retried after minSnapshotScheduleInterval. Pending snapshots are kept, and failed ones are deleted except for
+// the last one, which shows the error.
+func planScheduledSnapshots(now time.Time, schedule snapshotSchedule, existing []scheduledSnapshot) (bool, []string) {
+	sorted := make([]scheduledSnapshot, len(existing))
+	copy(sorted, existing)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].created.After(sorted[j].created) })
+
+	var lastTaken, lastFailed *scheduledSnapshot
+	var expired []string
+	ready := 0
+	for i := range sorted {
+		snapshot := &sorted[i]
+		switch {
+		case snapshot.failed && lastFailed == nil:
+			lastFailed = snapshot
+		case snapshot.failed:
+			expired = append(expired, snapshot.name)
+		case snapshot.ready:
+			if lastTaken == nil {
+				lastTaken = snapshot
+			}
+			ready++
+			if ready > schedule.retain {
+				expired = append(expired, snapshot.name)
+			}
+		default:
+			if lastTaken == nil {
+				lastTaken = snapshot
+			}
+		}
+	}
+
+	create := lastTaken == nil || now.Sub(lastTaken.created) >= schedule.interval
+	if create && lastFailed != nil && now.Sub(lastFailed.created) < minSnapshotScheduleInterval {
+		create = false
+	}
+	return create, expired
+}
+
+// scheduledSnapshotName returns the name of the snapshot of the PVC taken at the given time.
+func scheduledSnapshotName(pvcName string, now time.Time) string {
+	const maxPrefix = 253 - len(snapshotTimestampFormat) - 1
+	if len(pvcName) > maxPrefix {
+		pvcName = pvcName[:maxPrefix]
+	}
+	return pvcName + "-" + now.UTC().Format(snapshotTimestampFormat)
+}
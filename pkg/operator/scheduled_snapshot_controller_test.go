@@ -0,0 +1,180 @@
+package operator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSnapshotSchedule(t *testing.T) {
+	tests := []struct {
+		name                 string
+		pvcAnnotations       map[string]string
+		namespaceAnnotations map[string]string
+		expected             snapshotSchedule
+		expectedOK           bool
+		expectedError        bool
+	}{
+		{
+			name: "no schedule",
+		},
+		{
+			name:           "daily",
+			pvcAnnotations: map[string]string{snapshotScheduleAnnotation: "daily"},
+			expected:       snapshotSchedule{interval: 24 * time.Hour, retain: defaultSnapshotRetain},
+			expectedOK:     true,
+		},
+		{
+			name:           "duration and retain",
+			pvcAnnotations: map[string]string{snapshotScheduleAnnotation: "6h", snapshotRetainAnnotation: "4"},
+			expected:       snapshotSchedule{interval: 6 * time.Hour, retain: 4},
+			expectedOK:     true,
+		},
+		{
+			name:                 "namespace schedule",
+			namespaceAnnotations: map[string]string{snapshotScheduleAnnotation: "weekly", snapshotRetainAnnotation: "2"},
+			expected:             snapshotSchedule{interval: 7 * 24 * time.Hour, retain: 2},
+			expectedOK:           true,
+		},
+		{
+			name:                 "PVC overrides namespace",
+			pvcAnnotations:       map[string]string{snapshotScheduleAnnotation: "hourly"},
+			namespaceAnnotations: map[string]string{snapshotScheduleAnnotation: "weekly", snapshotRetainAnnotation: "2"},
+			expected:             snapshotSchedule{interval: time.Hour, retain: defaultSnapshotRetain},
+			expectedOK:           true,
+		},
+		{
+			name:           "invalid schedule",
+			pvcAnnotations: map[string]string{snapshotScheduleAnnotation: "monthly"},
+			expectedError:  true,
+		},
+		{
+			name:           "too short interval",
+			pvcAnnotations: map[string]string{snapshotScheduleAnnotation: "1m"},
+			expectedError:  true,
+		},
+		{
+			name:           "invalid retain",
+			pvcAnnotations: map[string]string{snapshotScheduleAnnotation: "daily", snapshotRetainAnnotation: "0"},
+			expectedError:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data", Annotations: test.pvcAnnotations}}
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: test.namespaceAnnotations}}
+			schedule, ok, err := getSnapshotSchedule(pvc, namespace)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != test.expectedOK || schedule != test.expected {
+				t.Errorf("expected schedule %+v (%v), got %+v (%v)", test.expected, test.expectedOK, schedule, ok)
+			}
+		})
+	}
+}
+
+func TestPlanScheduledSnapshots(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	snapshot := func(name string, age time.Duration) scheduledSnapshot {
+		return scheduledSnapshot{name: name, created: now.Add(-age), ready: true}
+	}
+	pending := func(name string, age time.Duration) scheduledSnapshot {
+		return scheduledSnapshot{name: name, created: now.Add(-age)}
+	}
+	failed := func(name string, age time.Duration) scheduledSnapshot {
+		return scheduledSnapshot{name: name, created: now.Add(-age), failed: true}
+	}
+	daily := snapshotSchedule{interval: 24 * time.Hour, retain: 2}
+
+	tests := []struct {
+		name            string
+		existing        []scheduledSnapshot
+		expectedCreate  bool
+		expectedExpired []string
+	}{
+		{
+			name:           "first snapshot",
+			expectedCreate: true,
+		},
+		{
+			name:     "not due",
+			existing: []scheduledSnapshot{snapshot("a", 2*time.Hour)},
+		},
+		{
+			name:           "due",
+			existing:       []scheduledSnapshot{snapshot("a", 25*time.Hour), snapshot("b", 49*time.Hour)},
+			expectedCreate: true,
+		},
+		{
+			name:            "expired",
+			existing:        []scheduledSnapshot{snapshot("c", 73*time.Hour), snapshot("a", time.Hour), snapshot("b", 25*time.Hour)},
+			expectedExpired: []string{"c"},
+		},
+		{
+			name:     "pending",
+			existing: []scheduledSnapshot{pending("a", 2*time.Hour), snapshot("b", 26*time.Hour)},
+		},
+		{
+			name: "failed snapshots",
+			existing: []scheduledSnapshot{
+				failed("f1", 10*time.Minute),
+				failed("f2", 25*time.Hour),
+				failed("f3", 49*time.Hour),
+				snapshot("a", 73*time.Hour),
+				snapshot("b", 97*time.Hour),
+			},
+			// The failed snapshots don't push the ready ones out, the last failed one is retried later.
+			expectedExpired: []string{"f2", "f3"},
+		},
+		{
+			name: "failed snapshot retried",
+			existing: []scheduledSnapshot{
+				failed("f1", time.Hour),
+				snapshot("a", 25*time.Hour),
+				snapshot("b", 49*time.Hour),
+				snapshot("c", 73*time.Hour),
+			},
+			expectedCreate:  true,
+			expectedExpired: []string{"c"},
+		},
+		{
+			name:           "only failed snapshots",
+			existing:       []scheduledSnapshot{failed("f1", 20*time.Minute)},
+			expectedCreate: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			create, expired := planScheduledSnapshots(now, daily, test.existing)
+			if create != test.expectedCreate {
+				t.Errorf("expected create=%v, got %v", test.expectedCreate, create)
+			}
+			if !equality.Semantic.DeepEqual(test.expectedExpired, expired) {
+				t.Errorf("expected expired snapshots %v, got %v", test.expectedExpired, expired)
+			}
+		})
+	}
+}
+
+func TestScheduledSnapshotName(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 30, 0, 0, time.UTC)
+	if name := scheduledSnapshotName("data", now); name != "data-20230510123000" {
+		t.Errorf("unexpected name %s", name)
+	}
+	if name := scheduledSnapshotName(strings.Repeat("a", 253), now); len(name) > 253 || !strings.HasSuffix(name, "-20230510123000") {
+		t.Errorf("unexpected name %s", name)
+	}
+}
@@ -0,0 +1,104 @@
+package operator
+
+import (
+	"fmt"
+	"path"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	sharedConfigVolumeName = "aws-shared-config"
+	sharedConfigMountPath  = "/var/run/secrets/aws-shared-config"
+	defaultSharedConfigKey = "config"
+)
+
+// withSharedAWSConfig mounts the shared AWS config file referenced in the driver configuration into the csi-driver
+// container and points the AWS SDK to it, so profiles with role chaining (role_arn + source_profile) are honored.
+// The credentials from the ebs-cloud-credentials secret are still used as the shared credentials file. When the
+// secret holds a role profile (role_arn or web_identity_token_file, e.g. STS credentials), it stays the config
+// file and the shared config is used as the shared credentials file instead: the SDK merges the profiles of both.
+func withSharedAWSConfig(secretLister corev1listers.SecretNamespaceLister, secretName string) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		src := cfg.SharedConfig
+		if src == nil {
+			return nil
+		}
+
+		volume := corev1.Volume{Name: sharedConfigVolumeName}
+		switch {
+		case src.ConfigMapName != "" && src.SecretName != "":
+			return fmt.Errorf("invalid sharedConfig: only one of configMapName and secretName can be set")
+		case src.ConfigMapName != "":
+			volume.VolumeSource = corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: src.ConfigMapName},
+				},
+			}
+		case src.SecretName != "":
+			volume.VolumeSource = corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: src.SecretName},
+			}
+		default:
+			return fmt.Errorf("invalid sharedConfig: one of configMapName and secretName must be set")
+		}
+		key := src.Key
+		if key == "" {
+			key = defaultSharedConfigKey
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		container := getContainer(podSpec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not use shared AWS config because the csi-driver container is missing from the deployment")
+		}
+
+		roleProfile, err := hasRoleProfile(secretLister, secretName)
+		if err != nil {
+			return err
+		}
+		if roleProfile {
+			setContainerEnv(container, "AWS_SHARED_CREDENTIALS_FILE", path.Join(sharedConfigMountPath, key))
+		} else {
+			// The asset points AWS_CONFIG_FILE to the credentials secret. Keep loading credentials from there.
+			if credentialsFile, ok := getContainerEnv(container, "AWS_CONFIG_FILE"); ok {
+				setContainerEnv(container, "AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+			}
+			setContainerEnv(container, "AWS_CONFIG_FILE", path.Join(sharedConfigMountPath, key))
+		}
+		setContainerEnv(container, "AWS_SDK_LOAD_CONFIG", "1")
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      sharedConfigVolumeName,
+			MountPath: sharedConfigMountPath,
+			ReadOnly:  true,
+		})
+		podSpec.Volumes = append(podSpec.Volumes, volume)
+		return nil
+	}
+}
+
+// hasRoleProfile returns true when the default profile of the credentials Secret assumes a role.
+func hasRoleProfile(secretLister corev1listers.SecretNamespaceLister, secretName string) (bool, error) {
+	secret, err := secretLister.Get(secretName)
+	if apierrors.IsNotFound(err) {
+		// The driver waits for the Secret, the Deployment is updated when it's created.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	profile := awsclient.ParseSharedConfig(secret.Data[sharedCredentialsKey])["default"]
+	return profile["role_arn"] != "" || profile["web_identity_token_file"] != "", nil
+}
@@ -0,0 +1,191 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWithSharedAWSConfig(t *testing.T) {
+	inDeployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: "csi-driver",
+						Env: []corev1.EnvVar{
+							{Name: "AWS_SDK_LOAD_CONFIG", Value: "1"},
+							{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws/credentials"},
+						},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "aws-credentials",
+							MountPath: "/var/run/secrets/aws",
+							ReadOnly:  true,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "aws-credentials",
+						VolumeSource: corev1.VolumeSource{
+							Secret: &corev1.SecretVolumeSource{SecretName: "ebs-cloud-credentials"},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		overrides   string
+		credentials string
+		expected    *appsv1.Deployment
+		expectError bool
+	}{
+		{
+			name:     "no shared config",
+			expected: inDeployment,
+		},
+		{
+			name:      "shared config in a ConfigMap",
+			overrides: `{"sharedConfig": {"configMapName": "aws-config", "key": "profile"}}`,
+			expected: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "csi-driver",
+								Env: []corev1.EnvVar{
+									{Name: "AWS_SDK_LOAD_CONFIG", Value: "1"},
+									{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws-shared-config/profile"},
+									{Name: "AWS_SHARED_CREDENTIALS_FILE", Value: "/var/run/secrets/aws/credentials"},
+								},
+								VolumeMounts: []corev1.VolumeMount{
+									{
+										Name:      "aws-credentials",
+										MountPath: "/var/run/secrets/aws",
+										ReadOnly:  true,
+									},
+									{
+										Name:      "aws-shared-config",
+										MountPath: "/var/run/secrets/aws-shared-config",
+										ReadOnly:  true,
+									},
+								},
+							}},
+							Volumes: []corev1.Volume{
+								{
+									Name: "aws-credentials",
+									VolumeSource: corev1.VolumeSource{
+										Secret: &corev1.SecretVolumeSource{SecretName: "ebs-cloud-credentials"},
+									},
+								},
+								{
+									Name: "aws-shared-config",
+									VolumeSource: corev1.VolumeSource{
+										ConfigMap: &corev1.ConfigMapVolumeSource{
+											LocalObjectReference: corev1.LocalObjectReference{Name: "aws-config"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// The STS Secret of cloud-credential-operator holds a role profile, which stays the config file.
+			name:      "shared config with STS credentials",
+			overrides: `{"sharedConfig": {"secretName": "aws-config"}}`,
+			credentials: "[default]\nsts_regional_endpoints = regional\nrole_arn = arn:aws:iam::123456789012:role/ebs\n" +
+				"web_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n",
+			expected: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "csi-driver",
+								Env: []corev1.EnvVar{
+									{Name: "AWS_SDK_LOAD_CONFIG", Value: "1"},
+									{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws/credentials"},
+									{Name: "AWS_SHARED_CREDENTIALS_FILE", Value: "/var/run/secrets/aws-shared-config/config"},
+								},
+								VolumeMounts: []corev1.VolumeMount{
+									{
+										Name:      "aws-credentials",
+										MountPath: "/var/run/secrets/aws",
+										ReadOnly:  true,
+									},
+									{
+										Name:      "aws-shared-config",
+										MountPath: "/var/run/secrets/aws-shared-config",
+										ReadOnly:  true,
+									},
+								},
+							}},
+							Volumes: []corev1.Volume{
+								{
+									Name: "aws-credentials",
+									VolumeSource: corev1.VolumeSource{
+										Secret: &corev1.SecretVolumeSource{SecretName: "ebs-cloud-credentials"},
+									},
+								},
+								{
+									Name: "aws-shared-config",
+									VolumeSource: corev1.VolumeSource{
+										Secret: &corev1.SecretVolumeSource{SecretName: "aws-config"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:        "both ConfigMap and Secret",
+			overrides:   `{"sharedConfig": {"configMapName": "aws-config", "secretName": "aws-config"}}`,
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			credentials := test.credentials
+			if credentials == "" {
+				credentials = "[default]\naws_access_key_id = id\naws_secret_access_key = secret\n"
+			}
+			informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			informerFactory.Core().V1().Secrets().Informer().GetIndexer().Add(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: defaultNamespace},
+				Data:       map[string][]byte{sharedCredentialsKey: []byte(credentials)},
+			})
+			secretLister := informerFactory.Core().V1().Secrets().Lister().Secrets(defaultNamespace)
+
+			deployment := inDeployment.DeepCopy()
+			err := withSharedAWSConfig(secretLister, defaultSecretName)(spec, deployment)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, deployment; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
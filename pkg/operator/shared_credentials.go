@@ -0,0 +1,64 @@
+package operator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// sharedCredentialsKey is the key of the credentials Secret with an AWS shared credentials file.
+const sharedCredentialsKey = "credentials"
+
+// withSharedCredentialsFile points the csi-driver container to the shared credentials file in the credentials
+// Secret with AWS_SHARED_CREDENTIALS_FILE, when the Secret has only the file and not the aws_access_key_id and
+// aws_secret_access_key keys, as some credential providers deliver it. The static key variables, which would take
+// precedence over the file, are dropped. Secrets with the static keys are left to the variables of the assets.
+func withSharedCredentialsFile(secretLister corev1listers.SecretNamespaceLister, secretName string) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		secret, err := secretLister.Get(secretName)
+		if apierrors.IsNotFound(err) {
+			// The driver waits for the Secret, the format is not known yet.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := secret.Data["aws_access_key_id"]; ok {
+			return nil
+		}
+		if len(strings.TrimSpace(string(secret.Data[sharedCredentialsKey]))) == 0 {
+			return nil
+		}
+
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not use the shared credentials file because the csi-driver container is missing from the deployment")
+		}
+		mountPath := ""
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == credentialsVolumeName {
+				mountPath = mount.MountPath
+			}
+		}
+		if mountPath == "" {
+			return fmt.Errorf("could not use the shared credentials file because the %s volume is not mounted in the csi-driver container", credentialsVolumeName)
+		}
+
+		env := container.Env[:0]
+		for _, e := range container.Env {
+			if e.Name != "AWS_ACCESS_KEY_ID" && e.Name != "AWS_SECRET_ACCESS_KEY" {
+				env = append(env, e)
+			}
+		}
+		container.Env = env
+		setContainerEnv(container, "AWS_SHARED_CREDENTIALS_FILE", path.Join(mountPath, sharedCredentialsKey))
+		return nil
+	}
+}
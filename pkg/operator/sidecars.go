@@ -0,0 +1,106 @@
+package operator
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// sidecarWorkersFlags are the flags that set the number of workers of each CSI sidecar.
+var sidecarWorkersFlags = map[string]string{
+	"csi-provisioner": "worker-threads",
+	"csi-attacher":    "worker-threads",
+	"csi-resizer":     "workers",
+	"csi-snapshotter": "worker-threads",
+}
+
+// withSidecarTuningHook sets the timeouts, the number of workers and the Kubernetes API rate limits of the
+// CSI sidecars of the controller Deployment from the driver configuration. The flags replace the ones in the asset.
+func withSidecarTuningHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.Sidecars == nil {
+			return nil
+		}
+		if qps := cfg.Sidecars.KubeAPIQPS; qps != nil && *qps <= 0 {
+			return fmt.Errorf("invalid sidecars kubeAPIQPS %v: it must be positive", *qps)
+		}
+		if burst := cfg.Sidecars.KubeAPIBurst; burst != nil && *burst < 1 {
+			return fmt.Errorf("invalid sidecars kubeAPIBurst %d: it must be a positive number", *burst)
+		}
+		tunings := []struct {
+			containerName string
+			tuning        *sidecarTuning
+		}{
+			{"csi-provisioner", cfg.Sidecars.Provisioner},
+			{"csi-attacher", cfg.Sidecars.Attacher},
+			{"csi-resizer", cfg.Sidecars.Resizer},
+			{"csi-snapshotter", cfg.Sidecars.Snapshotter},
+		}
+		for _, t := range tunings {
+			containerName, tuning := t.containerName, t.tuning
+			if tuning == nil {
+				tuning = &sidecarTuning{}
+			}
+			if err := tuning.validate(); err != nil {
+				return fmt.Errorf("invalid sidecars configuration of %s: %w", containerName, err)
+			}
+			container := getContainer(&deployment.Spec.Template.Spec, containerName)
+			if container == nil {
+				// The sidecar may be disabled.
+				continue
+			}
+			if cfg.Sidecars.KubeAPIQPS != nil {
+				setContainerArg(container, "kube-api-qps", strconv.FormatFloat(*cfg.Sidecars.KubeAPIQPS, 'f', -1, 64))
+			}
+			if cfg.Sidecars.KubeAPIBurst != nil {
+				setContainerArg(container, "kube-api-burst", strconv.Itoa(int(*cfg.Sidecars.KubeAPIBurst)))
+			}
+			if tuning.Timeout != "" {
+				setContainerArg(container, "timeout", tuning.Timeout)
+			}
+			if tuning.RetryIntervalMax != "" {
+				setContainerArg(container, "retry-interval-max", tuning.RetryIntervalMax)
+			}
+			if tuning.WorkerThreads != nil {
+				setContainerArg(container, sidecarWorkersFlags[containerName], strconv.Itoa(int(*tuning.WorkerThreads)))
+			}
+		}
+		return nil
+	}
+}
+
+func (t *sidecarTuning) validate() error {
+	if err := validatePositiveDuration("timeout", t.Timeout); err != nil {
+		return err
+	}
+	if err := validatePositiveDuration("retryIntervalMax", t.RetryIntervalMax); err != nil {
+		return err
+	}
+	if t.WorkerThreads != nil && *t.WorkerThreads < 1 {
+		return fmt.Errorf("invalid workerThreads %d: it must be a positive number", *t.WorkerThreads)
+	}
+	return nil
+}
+
+func validatePositiveDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid %s %q: it must be positive", name, value)
+	}
+	return nil
+}
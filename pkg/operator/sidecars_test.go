@@ -0,0 +1,113 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestWithSidecarTuningHook(t *testing.T) {
+	newDeployment := func(provisionerArgs, resizerArgs []string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{
+				Name: "csi-driver",
+				Args: []string{"controller", "--v=2"},
+			},
+			{
+				Name: "csi-provisioner",
+				Args: append([]string{"--csi-address=$(ADDRESS)", "--v=2"}, provisionerArgs...),
+			},
+			{
+				Name: "csi-resizer",
+				Args: append([]string{"--csi-address=$(ADDRESS)", "--timeout=300s"}, resizerArgs...),
+			},
+		}
+		return deployment
+	}
+
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      *appsv1.Deployment
+		expectedError bool
+	}{
+		{
+			name:     "no tuning",
+			expected: newDeployment(nil, nil),
+		},
+		{
+			name:      "provisioner tuning",
+			overrides: `{"sidecars": {"provisioner": {"timeout": "60s", "retryIntervalMax": "1m", "workerThreads": 200}}}`,
+			expected:  newDeployment([]string{"--timeout=60s", "--retry-interval-max=1m", "--worker-threads=200"}, nil),
+		},
+		{
+			name:      "resizer replaces the default timeout",
+			overrides: `{"sidecars": {"resizer": {"timeout": "10m", "workerThreads": 50}}}`,
+			expected: func() *appsv1.Deployment {
+				deployment := newDeployment(nil, []string{"--workers=50"})
+				deployment.Spec.Template.Spec.Containers[2].Args[1] = "--timeout=10m"
+				return deployment
+			}(),
+		},
+		{
+			name:      "missing sidecar",
+			overrides: `{"sidecars": {"snapshotter": {"timeout": "60s"}}}`,
+			expected:  newDeployment(nil, nil),
+		},
+		{
+			name:      "kube API rate limits",
+			overrides: `{"sidecars": {"kubeAPIQPS": 20.5, "kubeAPIBurst": 40, "provisioner": {"workerThreads": 200}}}`,
+			expected: newDeployment(
+				[]string{"--kube-api-qps=20.5", "--kube-api-burst=40", "--worker-threads=200"},
+				[]string{"--kube-api-qps=20.5", "--kube-api-burst=40"},
+			),
+		},
+		{
+			name:          "zero qps",
+			overrides:     `{"sidecars": {"kubeAPIQPS": 0}}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid timeout",
+			overrides:     `{"sidecars": {"attacher": {"timeout": "forever"}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "negative retry interval",
+			overrides:     `{"sidecars": {"provisioner": {"retryIntervalMax": "-1m"}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "zero workers",
+			overrides:     `{"sidecars": {"provisioner": {"workerThreads": 0}}}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			deployment := newDeployment(nil, nil)
+			err := withSidecarTuningHook()(spec, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, deployment; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package operator
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+// isSingleNodeCluster returns true on single-node OpenShift, i.e. when the control plane topology of the
+// Infrastructure is SingleReplica. It's false while the Infrastructure does not exist.
+func isSingleNodeCluster(infraLister configlisters.InfrastructureLister) (bool, error) {
+	infra, err := infraLister.Get(infrastructureName)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return infra.Status.ControlPlaneTopology == configv1.SingleReplicaTopologyMode, nil
+}
+
+// withSingleNodeHook sizes the controller Deployment for single-node OpenShift: it runs a single replica and its
+// pod anti-affinity, which has no other node to spread the replicas to, is removed. The PodDisruptionBudget of the
+// controller is removed too, see controllerPDBDisabled. It must run after withHypershiftReplicasHook.
+func withSingleNodeHook(isHypershift bool, infraLister configlisters.InfrastructureLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if isHypershift {
+			return nil
+		}
+		singleNode, err := isSingleNodeCluster(infraLister)
+		if err != nil || !singleNode {
+			return err
+		}
+		replicas := int32(1)
+		deployment.Spec.Replicas = &replicas
+		if affinity := deployment.Spec.Template.Spec.Affinity; affinity != nil {
+			affinity.PodAntiAffinity = nil
+		}
+		return nil
+	}
+}
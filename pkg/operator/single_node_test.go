@@ -0,0 +1,104 @@
+package operator
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+)
+
+func newTestTopologyInfraLister(topology configv1.TopologyMode) configlisters.InfrastructureLister {
+	infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+	if topology != "" {
+		infraInformer.Informer().GetIndexer().Add(&configv1.Infrastructure{
+			ObjectMeta: metav1.ObjectMeta{Name: infrastructureName},
+			Status:     configv1.InfrastructureStatus{ControlPlaneTopology: topology},
+		})
+	}
+	return infraInformer.Lister()
+}
+
+func TestWithSingleNodeHook(t *testing.T) {
+	tests := []struct {
+		name                 string
+		isHypershift         bool
+		topology             configv1.TopologyMode
+		expectedReplicas     int32
+		expectedAntiAffinity bool
+		expectedPDB          bool
+		expectedPDBRemoved   bool
+	}{
+		{
+			name:                 "highly available",
+			topology:             configv1.HighlyAvailableTopologyMode,
+			expectedReplicas:     2,
+			expectedAntiAffinity: true,
+			expectedPDB:          true,
+		},
+		{
+			name:                 "no Infrastructure",
+			expectedReplicas:     2,
+			expectedAntiAffinity: true,
+			expectedPDB:          true,
+		},
+		{
+			name:               "single-node OpenShift",
+			topology:           configv1.SingleReplicaTopologyMode,
+			expectedReplicas:   1,
+			expectedPDBRemoved: true,
+		},
+		{
+			name:                 "Hypershift",
+			isHypershift:         true,
+			topology:             configv1.SingleReplicaTopologyMode,
+			expectedReplicas:     2,
+			expectedAntiAffinity: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			replicas := int32(2)
+			deployment := &appsv1.Deployment{}
+			deployment.Spec.Replicas = &replicas
+			deployment.Spec.Template.Spec.Affinity = &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+						Weight: 100,
+						PodAffinityTerm: corev1.PodAffinityTerm{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "aws-ebs-csi-driver-controller"}},
+							TopologyKey:   "kubernetes.io/hostname",
+						},
+					}},
+				},
+			}
+			infraLister := newTestTopologyInfraLister(test.topology)
+
+			if err := withSingleNodeHook(test.isHypershift, infraLister)(&opv1.OperatorSpec{}, deployment); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *deployment.Spec.Replicas != test.expectedReplicas {
+				t.Errorf("expected %d replicas, got %d", test.expectedReplicas, *deployment.Spec.Replicas)
+			}
+			if antiAffinity := deployment.Spec.Template.Spec.Affinity.PodAntiAffinity != nil; antiAffinity != test.expectedAntiAffinity {
+				t.Errorf("expected pod anti-affinity %v, got %v", test.expectedAntiAffinity, antiAffinity)
+			}
+			if test.isHypershift {
+				return
+			}
+			if pdb := controllerPDBEnabled(false, nil, infraLister)(); pdb != test.expectedPDB {
+				t.Errorf("expected PodDisruptionBudget %v, got %v", test.expectedPDB, pdb)
+			}
+			if removed := controllerPDBDisabled(false, nil, infraLister)(); removed != test.expectedPDBRemoved {
+				t.Errorf("expected PodDisruptionBudget removal %v, got %v", test.expectedPDBRemoved, removed)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	// dlmPolicyOwnerTag marks the DLM lifecycle policies owned by the operator. Its value is the cluster ID.
+	dlmPolicyOwnerTag = "csi.openshift.io/cluster-id"
+
+	defaultSnapshotLifecycleIntervalHours = 24
+	defaultSnapshotLifecycleTime          = "00:00"
+	defaultSnapshotLifecycleRetainCount   = 7
+	maxSnapshotLifecycleRetainCount       = 1000
+
+	// snapshotLifecyclePolicyResync is the interval of the checks of an unchanged policy.
+	snapshotLifecyclePolicyResync = 10 * time.Minute
+	dlmTimeout                    = 30 * time.Second
+)
+
+var (
+	validSnapshotLifecycleIntervals = map[int32]bool{1: true, 2: true, 3: true, 4: true, 6: true, 8: true, 12: true, 24: true}
+	snapshotLifecycleTimeRegexp     = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+)
+
+// dlmAPI is the part of the DLM API used by the snapshotLifecyclePolicyController.
+type dlmAPI interface {
+	GetLifecyclePolicies(ctx context.Context, targetTag string) ([]awsclient.LifecyclePolicy, error)
+	GetLifecyclePolicy(ctx context.Context, policyID string) (*awsclient.LifecyclePolicy, error)
+	CreateLifecyclePolicy(ctx context.Context, policy *awsclient.LifecyclePolicy) (string, error)
+	UpdateLifecyclePolicy(ctx context.Context, policy *awsclient.LifecyclePolicy) error
+	DeleteLifecyclePolicy(ctx context.Context, policyID string) error
+}
+
+// snapshotLifecyclePolicyController maintains an Amazon Data Lifecycle Manager (DLM) lifecycle policy that
+// snapshots the volumes tagged with the cluster ID when enabled in the driver configuration, so the snapshots
+// are created and aged out by AWS. The policy is owned by the operator: it carries the cluster ID in the
+// csi.openshift.io/cluster-id tag, its changes are reverted, and it's deleted when the configuration is removed
+// or the ClusterCSIDriver is set to Removed. The DLM client uses the credentials and the proxy of the driver, and
+// the dlm service endpoint of the Infrastructure. DLM is reported as not supported in isolated regions without
+// that endpoint, the default one is not reachable there.
+//
+// It produces the following conditions:
+// <name>SnapshotLifecyclePolicyAvailable: False when DLM reports an error of the policy or is not supported,
+// True otherwise. The condition exists only while the operator owns or is asked for a policy.
+// <name>Degraded: produced when the sync() method returns an error.
+type snapshotLifecyclePolicyController struct {
+	name             string
+	operatorClient   v1helpers.OperatorClient
+	deploymentLister appslisters.DeploymentNamespaceLister
+	infraLister      configlisters.InfrastructureLister
+	// newDLMClient returns a DLM client configured like the driver in the controller Deployment, with the
+	// endpoint, if not empty.
+	newDLMClient func(deployment *appsv1.Deployment, endpoint string) (dlmAPI, error)
+
+	now func() time.Time
+	// lastCheck and lastPolicy record the last successful check, to check again when the interval
+	// elapses or the desired policy changes.
+	lastCheck  time.Time
+	lastPolicy *awsclient.LifecyclePolicy
+}
+
+func newSnapshotLifecyclePolicyController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	namespace string,
+	deploymentInformer appsinformersv1.DeploymentInformer,
+	infraInformer configinformersv1.InfrastructureInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
+	kubeClient kubeclient.Interface,
+	guestKubeClient kubeclient.Interface,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	builder := newDriverAWSClientBuilder(namespace, secretInformer, configMapInformer, kubeClient, guestKubeClient)
+	c := &snapshotLifecyclePolicyController{
+		name:             name,
+		operatorClient:   operatorClient,
+		deploymentLister: deploymentInformer.Lister().Deployments(namespace),
+		infraLister:      infraInformer.Lister(),
+		newDLMClient: func(deployment *appsv1.Deployment, endpoint string) (dlmAPI, error) {
+			client, _, err := builder.newClientWithEndpoint(deployment, "dlm", endpoint, dlmTimeout)
+			if err != nil {
+				return nil, err
+			}
+			return client, nil
+		},
+		now: time.Now,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		deploymentInformer.Informer(),
+		infraInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *snapshotLifecyclePolicyController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	var cfg *driverConfig
+	var policyConfig *snapshotLifecyclePolicyConfig
+	switch opSpec.ManagementState {
+	case opv1.Managed:
+		cfg, err = getDriverConfig(opSpec)
+		if err != nil {
+			return err
+		}
+		policyConfig = cfg.SnapshotLifecyclePolicy
+	case opv1.Removed:
+	default:
+		return nil
+	}
+
+	conditionType := c.name + "SnapshotLifecyclePolicyAvailable"
+	if policyConfig == nil && v1helpers.FindOperatorCondition(opStatus.Conditions, conditionType) == nil {
+		// The condition exists while the operator owns a policy, there is nothing to clean up.
+		return nil
+	}
+
+	deployment, err := c.deploymentLister.Get(controllerDeploymentName)
+	if apierrors.IsNotFound(err) {
+		// The credentials of the driver are not known until the Deployment controller creates the Deployment.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	clusterID, err := driverClusterID(deployment)
+	if err != nil {
+		return err
+	}
+
+	var desired *awsclient.LifecyclePolicy
+	if policyConfig != nil {
+		desired, err = desiredLifecyclePolicy(policyConfig, clusterID)
+		if err != nil {
+			return err
+		}
+		now := c.now()
+		if equality.Semantic.DeepEqual(desired, c.lastPolicy) && now.Sub(c.lastCheck) < snapshotLifecyclePolicyResync {
+			return nil
+		}
+	}
+
+	endpoint, err := infraServiceEndpoint(c.infraLister, "dlm")
+	if err != nil {
+		return err
+	}
+	region := ""
+	if container := getContainer(&deployment.Spec.Template.Spec, driverContainerName); container != nil {
+		region, _ = getContainerEnv(container, "AWS_REGION")
+	}
+	if endpoint == "" && inIsolatedRegion(cfg, region) {
+		c.lastPolicy = nil
+		if policyConfig == nil {
+			// No policy can have been created without the endpoint.
+			_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+				v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+				return nil
+			})
+			return err
+		}
+		cond := opv1.OperatorCondition{
+			Type:   conditionType,
+			Status: opv1.ConditionFalse,
+			Reason: "NotSupported",
+			Message: fmt.Sprintf("DLM is not supported in the isolated region %s without the dlm service endpoint in Infrastructure %s",
+				region, infrastructureName),
+		}
+		_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+		return err
+	}
+
+	client, err := c.newDLMClient(deployment, endpoint)
+	if err != nil {
+		return err
+	}
+	policy, err := c.syncPolicy(ctx, client, clusterID, desired, syncCtx.Recorder())
+	if err != nil {
+		return fmt.Errorf("failed to sync the DLM lifecycle policy: %w", err)
+	}
+
+	if policy == nil {
+		c.lastPolicy = nil
+		_, _, err := v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, conditionType)
+			return nil
+		})
+		return err
+	}
+	c.lastCheck = c.now()
+	c.lastPolicy = desired
+
+	cond := opv1.OperatorCondition{
+		Type:   conditionType,
+		Status: opv1.ConditionTrue,
+		Reason: "AsExpected",
+		Message: fmt.Sprintf("DLM lifecycle policy %s snapshots the volumes of the cluster every %d hours and keeps %d snapshots",
+			policy.PolicyID, desired.PolicyDetails.Schedules[0].CreateRule.Interval, desired.PolicyDetails.Schedules[0].RetainRule.Count),
+	}
+	if policy.State == "ERROR" {
+		cond.Status = opv1.ConditionFalse
+		cond.Reason = "PolicyError"
+		cond.Message = fmt.Sprintf("DLM lifecycle policy %s failed: %s", policy.PolicyID, policy.StatusMessage)
+	}
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, v1helpers.UpdateConditionFn(cond))
+	return err
+}
+
+// syncPolicy creates or updates the owned policy to match the desired one, or deletes the owned policies
+// when desired is nil. It returns the owned policy, if any.
+func (c *snapshotLifecyclePolicyController) syncPolicy(ctx context.Context, client dlmAPI, clusterID string, desired *awsclient.LifecyclePolicy, recorder events.Recorder) (*awsclient.LifecyclePolicy, error) {
+	summaries, err := client.GetLifecyclePolicies(ctx, clusterTagKey(clusterID)+"=owned")
+	if err != nil {
+		return nil, err
+	}
+	var owned []string
+	for _, summary := range summaries {
+		if summary.Tags[dlmPolicyOwnerTag] == clusterID {
+			owned = append(owned, summary.PolicyID)
+		}
+	}
+
+	var policy *awsclient.LifecyclePolicy
+	if desired != nil && len(owned) > 0 {
+		policy, err = client.GetLifecyclePolicy(ctx, owned[0])
+		if err != nil {
+			return nil, err
+		}
+		owned = owned[1:]
+	}
+	// Only one policy is kept, the others are leftovers of failed syncs.
+	for _, id := range owned {
+		err := client.DeleteLifecyclePolicy(ctx, id)
+		var apiErr *awsclient.APIError
+		if err != nil && !(errors.As(err, &apiErr) && apiErr.Code == "ResourceNotFoundException") {
+			return nil, err
+		}
+		klog.V(2).Infof("Deleted DLM lifecycle policy %s", id)
+		recorder.Eventf("SnapshotLifecyclePolicyDeleted", "Deleted DLM lifecycle policy %s", id)
+	}
+	if desired == nil {
+		return nil, nil
+	}
+
+	if policy == nil {
+		id, err := client.CreateLifecyclePolicy(ctx, desired)
+		if err != nil {
+			return nil, err
+		}
+		klog.V(2).Infof("Created DLM lifecycle policy %s", id)
+		recorder.Eventf("SnapshotLifecyclePolicyCreated", "Created DLM lifecycle policy %s", id)
+		created := *desired
+		created.PolicyID = id
+		return &created, nil
+	}
+
+	// DLM reports errors of the policy in the ERROR state, which is not a state to revert.
+	state := desired.State
+	if policy.State == "ERROR" {
+		state = policy.State
+	}
+	if policy.Description == desired.Description && state == policy.State && policy.ExecutionRoleARN == desired.ExecutionRoleARN &&
+		equality.Semantic.DeepEqual(policy.PolicyDetails, desired.PolicyDetails) {
+		return policy, nil
+	}
+	updated := *desired
+	updated.PolicyID = policy.PolicyID
+	if err := client.UpdateLifecyclePolicy(ctx, &updated); err != nil {
+		return nil, err
+	}
+	klog.V(2).Infof("Updated DLM lifecycle policy %s", policy.PolicyID)
+	recorder.Eventf("SnapshotLifecyclePolicyUpdated", "Updated DLM lifecycle policy %s", policy.PolicyID)
+	return &updated, nil
+}
+
+// desiredLifecyclePolicy returns the DLM lifecycle policy that snapshots the volumes of the cluster,
+// i.e. the volumes with the kubernetes.io/cluster/<cluster ID>: owned tag.
+func desiredLifecyclePolicy(cfg *snapshotLifecyclePolicyConfig, clusterID string) (*awsclient.LifecyclePolicy, error) {
+	if cfg.ExecutionRoleARN == "" {
+		return nil, fmt.Errorf("snapshotLifecyclePolicy executionRoleARN is required")
+	}
+	interval := int32(defaultSnapshotLifecycleIntervalHours)
+	if cfg.IntervalHours != nil {
+		interval = *cfg.IntervalHours
+		if !validSnapshotLifecycleIntervals[interval] {
+			return nil, fmt.Errorf("invalid snapshotLifecyclePolicy intervalHours %d: it must be one of 1, 2, 3, 4, 6, 8, 12 or 24", interval)
+		}
+	}
+	startTime := defaultSnapshotLifecycleTime
+	if cfg.Time != "" {
+		startTime = cfg.Time
+		if !snapshotLifecycleTimeRegexp.MatchString(startTime) {
+			return nil, fmt.Errorf("invalid snapshotLifecyclePolicy time %q: it must be in hh:mm format", startTime)
+		}
+	}
+	retain := int32(defaultSnapshotLifecycleRetainCount)
+	if cfg.RetainCount != nil {
+		retain = *cfg.RetainCount
+		if retain < 1 || retain > maxSnapshotLifecycleRetainCount {
+			return nil, fmt.Errorf("invalid snapshotLifecyclePolicy retainCount %d: it must be between 1 and %d", retain, maxSnapshotLifecycleRetainCount)
+		}
+	}
+
+	return &awsclient.LifecyclePolicy{
+		Description:      "OpenShift EBS CSI driver snapshots of cluster " + clusterID,
+		State:            "ENABLED",
+		ExecutionRoleARN: cfg.ExecutionRoleARN,
+		PolicyDetails: &awsclient.PolicyDetails{
+			PolicyType:    "EBS_SNAPSHOT_MANAGEMENT",
+			ResourceTypes: []string{"VOLUME"},
+			TargetTags:    []awsclient.Tag{{Key: clusterTagKey(clusterID), Value: "owned"}},
+			Schedules: []awsclient.PolicySchedule{{
+				Name:     "openshift-ebs-csi-driver",
+				CopyTags: true,
+				CreateRule: &awsclient.PolicyCreateRule{
+					Interval:     interval,
+					IntervalUnit: "HOURS",
+					Times:        []string{startTime},
+				},
+				RetainRule: &awsclient.PolicyRetainRule{Count: retain},
+			}},
+		},
+		Tags: map[string]string{dlmPolicyOwnerTag: clusterID},
+	}, nil
+}
+
+// driverClusterID returns the cluster ID the driver uses to tag the volumes and snapshots it creates.
+func driverClusterID(deployment *appsv1.Deployment) (string, error) {
+	clusterID := ""
+	if container := getContainer(&deployment.Spec.Template.Spec, driverContainerName); container != nil {
+		clusterID, _ = getContainerArg(container, "k8s-tag-cluster-id")
+	}
+	if clusterID == "" {
+		return "", fmt.Errorf("the cluster ID is missing from the csi-driver container of Deployment %s", deployment.Name)
+	}
+	return clusterID, nil
+}
+
+// clusterTagKey returns the key of the tag the driver sets on the volumes and snapshots of the cluster.
+func clusterTagKey(clusterID string) string {
+	return "kubernetes.io/cluster/" + clusterID
+}
@@ -0,0 +1,301 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const testClusterID = "test-cluster"
+
+// fakeDLM is an in-memory DLM API.
+type fakeDLM struct {
+	policies map[string]*awsclient.LifecyclePolicy
+	nextID   int
+	calls    int
+}
+
+func newFakeDLM(policies ...*awsclient.LifecyclePolicy) *fakeDLM {
+	f := &fakeDLM{policies: map[string]*awsclient.LifecyclePolicy{}}
+	for _, policy := range policies {
+		f.policies[policy.PolicyID] = policy
+	}
+	return f
+}
+
+func (f *fakeDLM) GetLifecyclePolicies(ctx context.Context, targetTag string) ([]awsclient.LifecyclePolicy, error) {
+	f.calls++
+	var summaries []awsclient.LifecyclePolicy
+	for _, policy := range f.policies {
+		for _, tag := range policy.PolicyDetails.TargetTags {
+			if tag.Key+"="+tag.Value == targetTag {
+				summaries = append(summaries, awsclient.LifecyclePolicy{PolicyID: policy.PolicyID, State: policy.State, Tags: policy.Tags})
+			}
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].PolicyID < summaries[j].PolicyID })
+	return summaries, nil
+}
+
+func (f *fakeDLM) GetLifecyclePolicy(ctx context.Context, policyID string) (*awsclient.LifecyclePolicy, error) {
+	policy, ok := f.policies[policyID]
+	if !ok {
+		return nil, &awsclient.APIError{StatusCode: 404, Code: "ResourceNotFoundException"}
+	}
+	copied := *policy
+	return &copied, nil
+}
+
+func (f *fakeDLM) CreateLifecyclePolicy(ctx context.Context, policy *awsclient.LifecyclePolicy) (string, error) {
+	f.nextID++
+	created := *policy
+	created.PolicyID = fmt.Sprintf("policy-new-%d", f.nextID)
+	f.policies[created.PolicyID] = &created
+	return created.PolicyID, nil
+}
+
+func (f *fakeDLM) UpdateLifecyclePolicy(ctx context.Context, policy *awsclient.LifecyclePolicy) error {
+	existing, ok := f.policies[policy.PolicyID]
+	if !ok {
+		return &awsclient.APIError{StatusCode: 404, Code: "ResourceNotFoundException"}
+	}
+	updated := *policy
+	updated.Tags = existing.Tags
+	f.policies[policy.PolicyID] = &updated
+	return nil
+}
+
+func (f *fakeDLM) DeleteLifecyclePolicy(ctx context.Context, policyID string) error {
+	if _, ok := f.policies[policyID]; !ok {
+		return &awsclient.APIError{StatusCode: 404, Code: "ResourceNotFoundException"}
+	}
+	delete(f.policies, policyID)
+	return nil
+}
+
+func newTestLifecyclePolicy(id string, owned bool, retain int32) *awsclient.LifecyclePolicy {
+	policy, err := desiredLifecyclePolicy(&snapshotLifecyclePolicyConfig{
+		ExecutionRoleARN: "arn:aws:iam::123456789012:role/dlm",
+		RetainCount:      &retain,
+	}, testClusterID)
+	if err != nil {
+		panic(err)
+	}
+	policy.PolicyID = id
+	if !owned {
+		policy.Tags = nil
+	}
+	return policy
+}
+
+func TestSnapshotLifecyclePolicyController(t *testing.T) {
+	const overrides = `{"snapshotLifecyclePolicy": {"executionRoleARN": "arn:aws:iam::123456789012:role/dlm"}}`
+	ownedCondition := opv1.OperatorCondition{Type: "TestSnapshotLifecyclePolicyAvailable", Status: opv1.ConditionTrue}
+
+	tests := []struct {
+		name              string
+		managementState   opv1.ManagementState
+		overrides         string
+		region            string
+		dlmEndpoint       string
+		conditions        []opv1.OperatorCondition
+		policies          []*awsclient.LifecyclePolicy
+		expectedPolicies  map[string]int32
+		expectedCondition opv1.ConditionStatus
+		expectedReason    string
+		expectedError     bool
+	}{
+		{
+			name:             "disabled",
+			managementState:  opv1.Managed,
+			policies:         []*awsclient.LifecyclePolicy{newTestLifecyclePolicy("policy-user", false, 3)},
+			expectedPolicies: map[string]int32{"policy-user": 3},
+		},
+		{
+			name:              "create",
+			managementState:   opv1.Managed,
+			overrides:         overrides,
+			policies:          []*awsclient.LifecyclePolicy{newTestLifecyclePolicy("policy-user", false, 3)},
+			expectedPolicies:  map[string]int32{"policy-user": 3, "policy-new-1": 7},
+			expectedCondition: opv1.ConditionTrue,
+		},
+		{
+			name:              "update and delete duplicates",
+			managementState:   opv1.Managed,
+			overrides:         overrides,
+			policies:          []*awsclient.LifecyclePolicy{newTestLifecyclePolicy("policy-1", true, 3), newTestLifecyclePolicy("policy-2", true, 7)},
+			expectedPolicies:  map[string]int32{"policy-1": 7},
+			expectedCondition: opv1.ConditionTrue,
+		},
+		{
+			name:             "delete when disabled",
+			managementState:  opv1.Managed,
+			conditions:       []opv1.OperatorCondition{ownedCondition},
+			policies:         []*awsclient.LifecyclePolicy{newTestLifecyclePolicy("policy-1", true, 7), newTestLifecyclePolicy("policy-user", false, 3)},
+			expectedPolicies: map[string]int32{"policy-user": 3},
+		},
+		{
+			name:             "delete when removed",
+			managementState:  opv1.Removed,
+			overrides:        overrides,
+			conditions:       []opv1.OperatorCondition{ownedCondition},
+			policies:         []*awsclient.LifecyclePolicy{newTestLifecyclePolicy("policy-1", true, 7)},
+			expectedPolicies: map[string]int32{},
+		},
+		{
+			name:              "dlm endpoint of the Infrastructure",
+			managementState:   opv1.Managed,
+			overrides:         overrides,
+			dlmEndpoint:       "https://dlm.example.com",
+			expectedPolicies:  map[string]int32{"policy-new-1": 7},
+			expectedCondition: opv1.ConditionTrue,
+		},
+		{
+			name:              "isolated region without dlm endpoint",
+			managementState:   opv1.Managed,
+			overrides:         overrides,
+			region:            "us-iso-east-1",
+			expectedPolicies:  map[string]int32{},
+			expectedCondition: opv1.ConditionFalse,
+			expectedReason:    "NotSupported",
+		},
+		{
+			name:              "isolated region with dlm endpoint",
+			managementState:   opv1.Managed,
+			overrides:         overrides,
+			region:            "us-isob-east-1",
+			dlmEndpoint:       "https://dlm.us-isob-east-1.sc2s.sgov.gov",
+			expectedPolicies:  map[string]int32{"policy-new-1": 7},
+			expectedCondition: opv1.ConditionTrue,
+		},
+		{
+			name:              "isolated region mode enabled without dlm endpoint",
+			managementState:   opv1.Managed,
+			overrides:         `{"snapshotLifecyclePolicy": {"executionRoleARN": "arn:aws:iam::123456789012:role/dlm"}, "isolatedRegion": {"mode": "Enabled"}}`,
+			expectedPolicies:  map[string]int32{},
+			expectedCondition: opv1.ConditionFalse,
+			expectedReason:    "NotSupported",
+		},
+		{
+			name:             "not supported condition removed when disabled",
+			managementState:  opv1.Managed,
+			region:           "us-iso-east-1",
+			conditions:       []opv1.OperatorCondition{{Type: "TestSnapshotLifecyclePolicyAvailable", Status: opv1.ConditionFalse, Reason: "NotSupported"}},
+			expectedPolicies: map[string]int32{},
+		},
+		{
+			name:             "invalid interval",
+			managementState:  opv1.Managed,
+			overrides:        `{"snapshotLifecyclePolicy": {"executionRoleARN": "arn:aws:iam::123456789012:role/dlm", "intervalHours": 5}}`,
+			expectedPolicies: map[string]int32{},
+			expectedError:    true,
+		},
+		{
+			name:             "missing execution role",
+			managementState:  opv1.Managed,
+			overrides:        `{"snapshotLifecyclePolicy": {}}`,
+			expectedPolicies: map[string]int32{},
+			expectedError:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: controllerDeploymentName, Namespace: defaultNamespace},
+			}
+			region := test.region
+			if region == "" {
+				region = "us-east-1"
+			}
+			deployment.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: driverContainerName,
+				Args: []string{"--endpoint=$(CSI_ENDPOINT)", "--k8s-tag-cluster-id=" + testClusterID},
+				Env:  []corev1.EnvVar{{Name: "AWS_REGION", Value: region}},
+			}}
+			informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+			informerFactory.Apps().V1().Deployments().Informer().GetIndexer().Add(deployment)
+
+			spec := &opv1.OperatorSpec{ManagementState: test.managementState}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{Conditions: test.conditions}, nil)
+			dlm := newFakeDLM(test.policies...)
+			infraInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().Infrastructures()
+			infra := &configv1.Infrastructure{ObjectMeta: metav1.ObjectMeta{Name: infrastructureName}}
+			infra.Status.PlatformStatus = &configv1.PlatformStatus{Type: configv1.AWSPlatformType, AWS: &configv1.AWSPlatformStatus{}}
+			if test.dlmEndpoint != "" {
+				infra.Status.PlatformStatus.AWS.ServiceEndpoints = []configv1.AWSServiceEndpoint{{Name: "dlm", URL: test.dlmEndpoint}}
+			}
+			infraInformer.Informer().GetIndexer().Add(infra)
+			c := &snapshotLifecyclePolicyController{
+				name:             "Test",
+				operatorClient:   operatorClient,
+				deploymentLister: informerFactory.Apps().V1().Deployments().Lister().Deployments(defaultNamespace),
+				infraLister:      infraInformer.Lister(),
+				newDLMClient: func(_ *appsv1.Deployment, endpoint string) (dlmAPI, error) {
+					if endpoint != test.dlmEndpoint {
+						t.Errorf("expected the DLM endpoint %q, got %q", test.dlmEndpoint, endpoint)
+					}
+					return dlm, nil
+				},
+				now: time.Now,
+			}
+
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test")))
+			if test.expectedError != (err != nil) {
+				t.Fatalf("expected error %v, got %v", test.expectedError, err)
+			}
+
+			policies := map[string]int32{}
+			for id, policy := range dlm.policies {
+				policies[id] = policy.PolicyDetails.Schedules[0].RetainRule.Count
+			}
+			if fmt.Sprint(policies) != fmt.Sprint(test.expectedPolicies) {
+				t.Errorf("expected policies %v, got %v", test.expectedPolicies, policies)
+			}
+
+			_, status, _, _ := operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "TestSnapshotLifecyclePolicyAvailable")
+			if test.expectedCondition == "" {
+				if cond != nil {
+					t.Errorf("unexpected condition %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != test.expectedCondition {
+				t.Errorf("expected SnapshotLifecyclePolicyAvailable=%s, got %+v", test.expectedCondition, cond)
+			}
+			if test.expectedReason != "" && (cond == nil || cond.Reason != test.expectedReason) {
+				t.Errorf("expected reason %s, got %+v", test.expectedReason, cond)
+			}
+
+			// An unchanged policy is not checked again until the resync interval elapses.
+			calls := dlm.calls
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if dlm.calls != calls {
+				t.Errorf("expected no DLM calls, got %d", dlm.calls-calls)
+			}
+		})
+	}
+}
@@ -3,9 +3,14 @@ package operator
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -13,18 +18,23 @@ import (
 	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
 	kubeclient "k8s.io/client-go/kubernetes"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	tracingapi "k8s.io/component-base/tracing/api/v1"
 	"k8s.io/klog/v2"
 
+	configv1 "github.com/openshift/api/config/v1"
 	opv1 "github.com/openshift/api/operator/v1"
 	configclient "github.com/openshift/client-go/config/clientset/versioned"
 	configinformers "github.com/openshift/client-go/config/informers/externalversions"
 	v1 "github.com/openshift/client-go/config/listers/config/v1"
-	"github.com/openshift/library-go/pkg/config/client"
+	operatorclient "github.com/openshift/client-go/operator/clientset/versioned"
+	operatorinformers "github.com/openshift/client-go/operator/informers/externalversions"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/csi/csicontrollerset"
@@ -45,12 +55,28 @@ const (
 	defaultNamespace   = "openshift-cluster-csi-drivers"
 	operatorName       = "aws-ebs-csi-driver-operator"
 	operandName        = "aws-ebs-csi-driver"
-	secretName         = "ebs-cloud-credentials"
+	defaultSecretName  = "ebs-cloud-credentials"
 	infraConfigName    = "cluster"
 	trustedCAConfigMap = "aws-ebs-csi-driver-trusted-ca-bundle"
 
+	controllerDeploymentName = "aws-ebs-csi-driver-controller"
+	nodeDaemonSetName        = "aws-ebs-csi-driver-node"
+
 	hypershiftImageEnvName = "HYPERSHIFT_IMAGE"
 
+	// workloadManagementAnnotation pins the pods of the assets to the management CPUs of workload partitioned
+	// clusters, e.g. single-node OpenShift for telco.
+	workloadManagementAnnotation = "target.workload.openshift.io/management"
+
+	// Tuning of the informers and controllers, see informerResync() and controllerWorkers().
+	informerResyncEnvName    = "INFORMER_RESYNC_PERIOD"
+	controllerWorkersEnvName = "CONTROLLER_WORKERS"
+	defaultInformerResync    = 20 * time.Minute
+	minInformerResync        = 1 * time.Minute
+	maxInformerResync        = 24 * time.Hour
+	defaultControllerWorkers = 1
+	maxControllerWorkers     = 5
+
 	cloudConfigNamespace = "openshift-config-managed"
 	cloudConfigName      = "kube-cloud-config"
 	caBundleKey          = "ca-bundle.pem"
@@ -58,12 +84,33 @@ const (
 	infrastructureName = "cluster"
 
 	hypershiftPriorityClass = "hypershift-control-plane"
+
+	// customCABundleHashAnnotation holds the hash of the custom AWS CA bundle on the controller pods.
+	customCABundleHashAnnotation = "operator.openshift.io/dep-aws-ca-bundle"
+
+	// shutdownTimeout is the time given to the controllers to stop. It must be shorter than
+	// the graceful termination period of library-go controllercmd (10s).
+	shutdownTimeout = 5 * time.Second
 )
 
-func RunOperator(ctx context.Context, controllerConfig *controllercmd.ControllerContext, guestKubeConfigString string) error {
+// RunOperator runs the operator. The spans of the syncs are exported when tracingConfig is not nil.
+func RunOperator(ctx context.Context, controllerConfig *controllercmd.ControllerContext, guestKubeConfigString string, tracingConfig *tracingapi.TracingConfiguration) error {
+	// The operator stops when the guest kubeconfig can't be reloaded, see guestKubeconfigReloader.
+	ctx, stopOperator := context.WithCancel(ctx)
+	defer stopOperator()
+	guestKubeconfigErrCh := make(chan error, 1)
+
 	// Create core clientset and informer for the MANAGEMENT cluster.
 	eventRecorder := controllerConfig.EventRecorder
 	controlPlaneNamespace := controllerConfig.OperatorNamespace
+	if tracingConfig != nil {
+		stopTracing, err := startTracing(ctx, tracingConfig)
+		if err != nil {
+			return fmt.Errorf("could not start tracing: %w", err)
+		}
+		defer stopTracing()
+		traceKubeConfig(controllerConfig.KubeConfig)
+	}
 	controlPlaneKubeClient := kubeclient.NewForConfigOrDie(rest.AddUserAgent(controllerConfig.KubeConfig, operatorName))
 	controlPlaneKubeInformersForNamespaces := v1helpers.NewKubeInformersForNamespaces(controlPlaneKubeClient, controlPlaneNamespace)
 	controlPlaneSecretInformer := controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Core().V1().Secrets()
@@ -86,23 +133,34 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 	guestKubeConfig := controllerConfig.KubeConfig
 	guestKubeClient := controlPlaneKubeClient
 	isHypershift := guestKubeConfigString != ""
+	var kubeconfigReloader *guestKubeconfigReloader
 	if isHypershift {
-		guestKubeConfig, err = client.GetKubeConfigOrInClusterConfig(guestKubeConfigString, nil)
+		// Hypershift rotates the certificates in the guest kubeconfig, the clients of the guest cluster
+		// pick them up from the reloader.
+		kubeconfigReloader, err = newGuestKubeconfigReloader(guestKubeConfigString)
 		if err != nil {
 			return err
 		}
+		go func() {
+			if err := kubeconfigReloader.Run(ctx); err != nil {
+				klog.Errorf("Stopping the operator: %v", err)
+				guestKubeconfigErrCh <- err
+				stopOperator()
+			}
+		}()
+		guestKubeConfig = kubeconfigReloader.ClientConfig()
+		if tracingConfig != nil {
+			traceKubeConfig(guestKubeConfig)
+		}
 		guestKubeClient = kubeclient.NewForConfigOrDie(rest.AddUserAgent(guestKubeConfig, operatorName))
 
-		// Create all events in the GUEST cluster.
-		// Use name of the operator Deployment in the management cluster + namespace
-		// in the guest cluster as the closest approximation of the real involvedObject.
-		controllerRef, err := events.GetControllerReferenceForCurrentPod(ctx, controlPlaneKubeClient, controlPlaneNamespace, nil)
-		controllerRef.Namespace = guestNamespace
-		if err != nil {
-			klog.Warningf("unable to get owner reference (falling back to namespace): %v", err)
-		}
-		eventRecorder = events.NewKubeRecorder(guestKubeClient.CoreV1().Events(guestNamespace), operandName, controllerRef)
+		// Create all events in the GUEST cluster and mirror them in the control plane namespace.
+		guestEventRecorder := events.NewKubeRecorder(guestKubeClient.CoreV1().Events(guestNamespace), operandName,
+			guestEventReference(ctx, controlPlaneKubeClient, controlPlaneNamespace, guestNamespace))
+		eventRecorder = newFanOutRecorder(guestEventRecorder, eventRecorder)
 	}
+	// The objects applied by the controllers are counted from their events.
+	eventRecorder = newMetricsRecorder(eventRecorder)
 
 	guestAPIExtClient, err := apiextclient.NewForConfig(rest.AddUserAgent(guestKubeConfig, operatorName))
 	if err != nil {
@@ -115,13 +173,18 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 	}
 
 	// Client informers for the GUEST cluster.
-	guestKubeInformersForNamespaces := v1helpers.NewKubeInformersForNamespaces(guestKubeClient, guestNamespace, "")
+	guestKubeInformersForNamespaces := v1helpers.NewKubeInformersForNamespaces(guestKubeClient, guestNamespace, "", installConfigNamespace)
 	guestConfigMapInformer := guestKubeInformersForNamespaces.InformersFor(guestNamespace).Core().V1().ConfigMaps()
+	guestSecretInformer := guestKubeInformersForNamespaces.InformersFor(guestNamespace).Core().V1().Secrets()
+	guestInstallConfigInformer := guestKubeInformersForNamespaces.InformersFor(installConfigNamespace).Core().V1().ConfigMaps()
 	guestNodeInformer := guestKubeInformersForNamespaces.InformersFor("").Core().V1().Nodes()
 
 	guestConfigClient := configclient.NewForConfigOrDie(rest.AddUserAgent(guestKubeConfig, operatorName))
-	guestConfigInformers := configinformers.NewSharedInformerFactory(guestConfigClient, 20*time.Minute)
+	guestConfigInformers := configinformers.NewSharedInformerFactory(guestConfigClient, informerResync())
 	guestInfraInformer := guestConfigInformers.Config().V1().Infrastructures()
+	guestIDMSInformer := guestConfigInformers.Config().V1().ImageDigestMirrorSets()
+	guestFeatureGateInformer := guestConfigInformers.Config().V1().FeatureGates()
+	guestNetworkInformer := guestConfigInformers.Config().V1().Networks()
 
 	// Create client and informers for our ClusterCSIDriver CR.
 	gvr := opv1.SchemeGroupVersion.WithResource("clustercsidrivers")
@@ -130,37 +193,93 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		return err
 	}
 
+	// The HostedControlPlane informer is created only on Hypershift, see hostedControlPlaneGVR.
+	controlPlaneDynamicInformers := dynamicinformer.NewFilteredDynamicSharedInformerFactory(controlPlaneDynamicClient, informerResync(), controlPlaneNamespace, nil)
+	var controlPlaneHCPInformer informers.GenericInformer
+	var controlPlaneHCPLister cache.GenericNamespaceLister
+	if isHypershift {
+		// The cloud config informers are started only on standalone clusters. On Hypershift, read
+		// the custom CA bundle from the operator namespace informer, which is always started.
+		controlPlaneCloudConfigLister = controlPlaneConfigMapInformer.Lister().ConfigMaps(controlPlaneNamespace)
+
+		controlPlaneHCPInformer = controlPlaneDynamicInformers.ForResource(hostedControlPlaneGVR)
+		controlPlaneHCPLister = controlPlaneHCPInformer.Lister().ByNamespace(controlPlaneNamespace)
+	}
+
+	// The region and the service endpoints of the driver come from the Infrastructure and from the legacy
+	// cloud provider config of clusters configured by older tooling.
+	infraLister := newLegacyCloudConfigInfraLister(guestInfraInformer.Lister(), controlPlaneCloudConfigLister)
+	driverRegion := newAWSRegion(infraLister, guestNodeInformer.Lister())
+
 	controlPlaneInformersForEvents := []factory.Informer{
 		controlPlaneSecretInformer.Informer(),
 		controlPlaneConfigMapInformer.Informer(),
 		guestNodeInformer.Informer(),
+		guestInstallConfigInformer.Informer(),
 		guestInfraInformer.Informer(),
+		guestIDMSInformer.Informer(),
+		guestFeatureGateInformer.Informer(),
+		guestNetworkInformer.Informer(),
 	}
 	if !isHypershift {
 		controlPlaneInformersForEvents = append(controlPlaneInformersForEvents, controlPlaneCloudConfigInformer.Informer())
+	} else {
+		controlPlaneInformersForEvents = append(controlPlaneInformersForEvents, controlPlaneHCPInformer.Informer())
 	}
 
+	// On Hypershift, the controllers stop reconciling the guest cluster while the HostedControlPlane is paused,
+	// except the controller Deployment in the management cluster, which is scaled to zero.
+	controlPlaneOperatorClient := guestOperatorClient
+	if isHypershift {
+		guestOperatorClient = newPausableOperatorClient(controlPlaneOperatorClient, controlPlaneHCPLister)
+	}
+
+	operandArchs, err := operandArchitectures()
+	if err != nil {
+		return err
+	}
+	sidecarLeaderElection, err := sidecarLeaderElection()
+	if err != nil {
+		return err
+	}
+	var imageOverrides []imageMirror
+	// The controller Deployment runs in the management cluster on Hypershift, the mirrors of the guest cluster
+	// don't apply to it.
+	controllerIDMSLister := guestIDMSInformer.Lister()
+	if isHypershift {
+		imageOverrides, err = hypershiftImageOverrides()
+		if err != nil {
+			return err
+		}
+		controllerIDMSLister = nil
+	}
+	credentialsSecretName := defaultSecretName
+	if isHypershift {
+		credentialsSecretName, err = hypershiftCredentialsSecretName()
+		if err != nil {
+			return err
+		}
+	}
+
+	ec2EndpointFailover := newEC2EndpointFailover(controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Apps().V1().Deployments().Lister().Deployments(controlPlaneNamespace))
+
 	// Start controllers that manage resources in the MANAGEMENT cluster.
 	controlPlaneCSIControllerSet := csicontrollerset.NewCSIControllerSet(
-		guestOperatorClient,
+		controlPlaneOperatorClient,
 		eventRecorder,
 	).WithLogLevelController().WithManagementStateController(
 		operandName,
-		false,
+		true,
 	).WithStaticResourcesController(
 		"AWSEBSDriverControlPlaneStaticResourcesController",
 		controlPlaneKubeClient,
 		controlPlaneDynamicClient,
 		controlPlaneKubeInformersForNamespaces,
-		assetWithNamespaceFunc(controlPlaneNamespace),
+		withHostedControlPlaneLabels(isHypershift, controlPlaneNamespace, assetWithNamespaceFunc(controlPlaneNamespace)),
 		[]string{
 			"controller_sa.yaml",
-			"controller_pdb.yaml",
 			"cabundle_cm.yaml",
 		},
-	).WithCSIConfigObserverController(
-		"AWSEBSDriverCSIConfigObserverController",
-		guestConfigInformers,
 	).WithCSIDriverControllerService(
 		"AWSEBSDriverControllerServiceController",
 		assets.ReadFile,
@@ -169,25 +288,104 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace),
 		guestConfigInformers,
 		controlPlaneInformersForEvents,
-		withHypershiftDeploymentHook(isHypershift, os.Getenv(hypershiftImageEnvName)),
-		withHypershiftReplicasHook(isHypershift, guestNodeInformer.Lister()),
-		withNamespaceDeploymentHook(controlPlaneNamespace),
-		csidrivercontrollerservicecontroller.WithSecretHashAnnotationHook(controlPlaneNamespace, secretName, controlPlaneSecretInformer),
-		csidrivercontrollerservicecontroller.WithObservedProxyDeploymentHook(),
-		withCustomAWSCABundle(isHypershift, controlPlaneCloudConfigLister),
-		withAWSRegion(guestInfraInformer.Lister()),
-		withCustomTags(guestInfraInformer.Lister()),
-		withCustomEndPoint(guestInfraInformer.Lister()),
-		csidrivercontrollerservicecontroller.WithCABundleDeploymentHook(
-			controlPlaneNamespace,
-			trustedCAConfigMap,
-			controlPlaneConfigMapInformer,
-		),
+		instrumentDeploymentHooks(controllerDeploymentName,
+			withHypershiftDeploymentHook(isHypershift, os.Getenv(hypershiftImageEnvName)),
+			withTokenMinterHook(isHypershift),
+			withCredentialsSecretNameHook(credentialsSecretName),
+			withDisabledSidecarsHook(),
+			withKubeRBACProxiesHook(isHypershift),
+			withVolumeModifierHook(guestFeatureGateInformer.Lister(), os.Getenv(volumeModifierImageEnvName)),
+			withPriorityClassHook(isHypershift, controlPlaneHCPLister),
+			withNodePlacementDeploymentHook(isHypershift),
+			withHostedControlPlaneSchedulingHook(isHypershift, controlPlaneNamespace, controlPlaneHCPLister),
+			withRequestServingIsolationHook(isHypershift, controlPlaneHCPLister),
+			withArchitectureDeploymentHook(isHypershift, operandArchs),
+			withResourcesDeploymentHook(),
+			withHypershiftReplicasHook(isHypershift, guestNodeInformer.Lister(), controlPlaneHCPLister),
+			withSingleNodeHook(isHypershift, guestInfraInformer.Lister()),
+			withExternalControlPlaneHook(isHypershift, guestInfraInformer.Lister()),
+			withArbiterHook(isHypershift, guestInfraInformer.Lister(), guestNodeInformer.Lister()),
+			withCompactClusterHook(isHypershift, guestInfraInformer.Lister(), guestNodeInformer.Lister()),
+			withNamespaceDeploymentHook(controlPlaneNamespace),
+			withCredentialsSecretHashHook(controlPlaneNamespace, credentialsSecretName, controlPlaneSecretInformer),
+			withGuestKubeconfigHashHook(isHypershift, controlPlaneSecretInformer.Lister().Secrets(controlPlaneNamespace)),
+			csidrivercontrollerservicecontroller.WithObservedProxyDeploymentHook(),
+			withTLSSecurityProfileDeploymentHook(),
+			withFIPSDeploymentHook(guestInstallConfigInformer.Lister().ConfigMaps(installConfigNamespace)),
+			withCustomAWSCABundle(isHypershift, controlPlaneCloudConfigLister),
+			withCustomAWSCABundleHashHook(isHypershift, controlPlaneCloudConfigLister),
+			withSharedCredentialsFile(controlPlaneSecretInformer.Lister().Secrets(controlPlaneNamespace), credentialsSecretName),
+			withWebIdentityCredentials(controlPlaneSecretInformer.Lister().Secrets(controlPlaneNamespace), credentialsSecretName),
+			withSharedAWSConfig(controlPlaneSecretInformer.Lister().Secrets(controlPlaneNamespace), credentialsSecretName),
+			withAWSRegion(driverRegion),
+			withCustomTags(guestInfraInformer.Lister(), controlPlaneHCPLister),
+			withCustomEndPoint(infraLister),
+			withCustomEndpointsNoProxy(),
+			withEC2EndpointFailoverHook(ec2EndpointFailover),
+			withDualStackEndpointsHook(guestNetworkInformer.Lister()),
+			withIsolatedRegionHook(),
+			withAssumeRole(controlPlaneSecretInformer.Lister().Secrets(controlPlaneNamespace), credentialsSecretName, infraLister),
+			withServiceProxyHook(),
+			withPodIdentityCredentials(isHypershift),
+			csidrivercontrollerservicecontroller.WithCABundleDeploymentHook(
+				controlPlaneNamespace,
+				trustedCAConfigMap,
+				controlPlaneConfigMapInformer,
+			),
+			withBatchingHook(),
+			withSidecarTuningHook(),
+			withLeaderElectionDeploymentHook(sidecarLeaderElection),
+			withLogLevelDeploymentHook(),
+			withExtraArgsDeploymentHook(),
+			withImageMirrorsDeploymentHook(controllerIDMSLister, imageOverrides),
+			withHostedControlPlaneLabelsHook(isHypershift, controlPlaneNamespace, controlPlaneHCPLister),
+		)...,
 	)
 	if err != nil {
 		return err
 	}
 
+	// The controller PodDisruptionBudget follows the availability of the controller on Hypershift, is not
+	// needed on single-node OpenShift and follows the schedulable control plane nodes on compact clusters.
+	controllerPDBController := staticresourcecontroller.NewStaticResourceController(
+		"AWSEBSDriverControllerPDBController",
+		withHostedControlPlaneLabels(isHypershift, controlPlaneNamespace, assetWithNamespaceFunc(controlPlaneNamespace)),
+		[]string{},
+		(&resourceapply.ClientHolder{}).WithKubernetes(controlPlaneKubeClient),
+		guestOperatorClient,
+		eventRecorder,
+	).WithConditionalResources(
+		withHostedControlPlaneLabels(isHypershift, controlPlaneNamespace,
+			withCompactClusterPDB(isHypershift, guestInfraInformer.Lister(), guestNodeInformer.Lister(), assetWithNamespaceFunc(controlPlaneNamespace))),
+		[]string{controllerPDBFile},
+		controllerPDBEnabled(isHypershift, controlPlaneHCPLister, guestInfraInformer.Lister()),
+		controllerPDBDisabled(isHypershift, controlPlaneHCPLister, guestInfraInformer.Lister()),
+	).AddKubeInformers(controlPlaneKubeInformersForNamespaces)
+	if isHypershift {
+		controllerPDBController = controllerPDBController.AddInformer(controlPlaneHCPInformer.Informer())
+	} else {
+		controllerPDBController = controllerPDBController.AddInformer(guestInfraInformer.Informer()).AddInformer(guestNodeInformer.Informer())
+	}
+
+	// The metrics of the node pods are served by their kube-rbac-proxy sidecars and scraped by Prometheus of
+	// standalone clusters. Prometheus of the guest cluster doesn't scrape the node pods on Hypershift, so the sidecars
+	// are removed there and don't take the host port of the guest nodes.
+	guestStaticFiles := []string{
+		"csidriver.yaml",
+		"node_sa.yaml",
+		"rbac/privileged_role.yaml",
+		"rbac/node_privileged_binding.yaml",
+	}
+	nodeMetricsHook := withoutNodeMetricsHook()
+	if !isHypershift {
+		guestStaticFiles = append(guestStaticFiles,
+			"rbac/node_kube_rbac_proxy_role.yaml",
+			"rbac/node_kube_rbac_proxy_binding.yaml",
+			"node_service.yaml",
+		)
+		nodeMetricsHook = withNodeMetricsHashHook(guestNamespace, guestConfigMapInformer.Lister(), guestSecretInformer.Lister())
+	}
+
 	// Start controllers that manage resources in GUEST clusters.
 	guestCSIControllerSet := csicontrollerset.NewCSIControllerSet(
 		guestOperatorClient,
@@ -197,54 +395,192 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		guestKubeClient,
 		guestDynamicClient,
 		guestKubeInformersForNamespaces,
-		assets.ReadFile,
-		[]string{
-			"storageclass_gp2.yaml",
-			"csidriver.yaml",
-			"node_sa.yaml",
-			"rbac/privileged_role.yaml",
-			"rbac/node_privileged_binding.yaml",
-		},
-	).WithConditionalStaticResourcesController(
-		"AWSEBSDriverConditionalStaticResourcesController",
-		guestKubeClient,
-		guestDynamicClient,
-		guestKubeInformersForNamespaces,
-		assets.ReadFile,
-		[]string{
-			"volumesnapshotclass.yaml",
-		},
-		// Only install when CRD exists.
-		func() bool {
-			name := "volumesnapshotclasses.snapshot.storage.k8s.io"
-			_, err := guestAPIExtClient.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), name, metav1.GetOptions{})
-			return err == nil
-		},
-		// Don't ever remove.
-		func() bool {
-			return false
-		},
+		assetWithNamespaceFunc(guestNamespace),
+		guestStaticFiles,
 	).WithCSIDriverNodeService(
 		"AWSEBSDriverNodeServiceController",
 		assets.ReadFile,
 		"node.yaml",
 		guestKubeClient,
 		guestKubeInformersForNamespaces.InformersFor(guestNamespace),
-		[]factory.Informer{guestConfigMapInformer.Informer()},
-		csidrivernodeservicecontroller.WithObservedProxyDaemonSetHook(),
-		csidrivernodeservicecontroller.WithCABundleDaemonSetHook(
-			guestNamespace,
-			trustedCAConfigMap,
-			guestConfigMapInformer,
-		),
-	).WithStorageClassController(
+		[]factory.Informer{
+			guestConfigMapInformer.Informer(),
+			guestSecretInformer.Informer(),
+			guestIDMSInformer.Informer(),
+			guestNodeInformer.Informer(),
+		},
+		instrumentDaemonSetHooks(nodeDaemonSetName,
+			csidrivernodeservicecontroller.WithObservedProxyDaemonSetHook(),
+			csidrivernodeservicecontroller.WithCABundleDaemonSetHook(
+				guestNamespace,
+				trustedCAConfigMap,
+				guestConfigMapInformer,
+			),
+			withCustomAWSCABundleDaemonSetHook(guestConfigMapInformer.Lister().ConfigMaps(guestNamespace)),
+			withNodePlacementDaemonSetHook(),
+			withAWSNodesDaemonSetHook(guestNodeInformer.Lister()),
+			withResourcesDaemonSetHook(),
+			withVolumeAttachLimitHook(),
+			withLogLevelDaemonSetHook(),
+			withTLSSecurityProfileDaemonSetHook(),
+			withFIPSDaemonSetHook(guestInstallConfigInformer.Lister().ConfigMaps(installConfigNamespace)),
+			nodeMetricsHook,
+			withExtraArgsDaemonSetHook(),
+			withImageMirrorsDaemonSetHook(guestIDMSInformer.Lister()),
+		)...,
+	)
+
+	storageClassController := newStorageClassController(
 		"AWSEBSDriverStorageClassController",
 		assets.ReadFile,
-		"storageclass_gp3.yaml",
+		[]string{
+			gp2StorageClassFile,
+			"storageclass_gp3.yaml",
+		},
+		map[string]string{
+			"io2": "storageclass_io2.yaml",
+			"st1": "storageclass_st1.yaml",
+			"sc1": "storageclass_sc1.yaml",
+		},
 		guestKubeClient,
 		guestKubeInformersForNamespaces.InformersFor(""),
+		guestOperatorClient,
+		eventRecorder,
+		withAllowedTopologiesHook(guestNodeInformer.Lister()),
+		withKMSKeyHook(),
+		withGP3PerformanceHook(),
+		withStorageClassParametersHook(),
+		withStorageClassPoliciesHook(),
+		withEncryptionHook(),
+	)
+
+	configObserverController := newConfigObserverController(
+		"AWSEBSDriverCSIConfigObserverController",
+		guestOperatorClient,
+		guestConfigInformers,
+		eventRecorder,
+	)
+
+	fipsController := newFIPSController(
+		"AWSEBSDriverFIPSController",
+		guestOperatorClient,
+		guestInstallConfigInformer,
+		guestInfraInformer,
+		eventRecorder,
+	)
+
+	externalControlPlaneController := newExternalControlPlaneController(
+		"AWSEBSDriverExternalControlPlaneController",
+		guestOperatorClient,
+		guestInfraInformer,
+		eventRecorder,
+	)
+
+	operatorMetricsController := newOperatorMetricsController(
+		"AWSEBSDriverOperatorMetricsController",
+		guestOperatorClient,
+		map[string]factory.Informer{
+			"secrets":               controlPlaneSecretInformer.Informer(),
+			"configmaps":            controlPlaneConfigMapInformer.Informer(),
+			"deployments":           controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Apps().V1().Deployments().Informer(),
+			"nodes":                 guestNodeInformer.Informer(),
+			"infrastructures":       guestInfraInformer.Informer(),
+			"featuregates":          guestFeatureGateInformer.Informer(),
+			"networks":              guestNetworkInformer.Informer(),
+			"imagedigestmirrorsets": guestIDMSInformer.Informer(),
+		},
+		eventRecorder,
+	)
+
+	configMetricsController := newConfigMetricsController(
+		"AWSEBSDriverConfigMetricsController",
+		guestOperatorClient,
+		isHypershift,
+		controlPlaneNamespace,
+		controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Apps().V1().Deployments(),
+		guestInfraInformer,
+		eventRecorder,
 	)
 
+	awsRegionController := newAWSRegionController(
+		"AWSEBSDriverRegionController",
+		guestOperatorClient,
+		isHypershift,
+		driverRegion,
+		guestInfraInformer,
+		guestNodeInformer,
+		eventRecorder,
+	)
+
+	ec2EndpointFailoverController := newEC2EndpointFailoverController(
+		"AWSEBSDriverEC2EndpointFailoverController",
+		guestOperatorClient,
+		controlPlaneNamespace,
+		ec2EndpointFailover,
+		controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Apps().V1().Deployments(),
+		controlPlaneSecretInformer,
+		controlPlaneConfigMapInformer,
+		controlPlaneKubeClient,
+		guestKubeClient,
+		guestInfraInformer,
+		infraLister,
+		eventRecorder,
+	)
+
+	removalController := newRemovalController(
+		"AWSEBSDriverRemovalController",
+		guestOperatorClient,
+		guestKubeClient,
+		guestDynamicClient,
+		guestKubeInformersForNamespaces.InformersFor("").Storage().V1().StorageClasses(),
+		guestNamespace,
+		guestConfigMapInformer,
+		eventRecorder,
+	)
+
+	// The VolumeSnapshotClass is created only when its CRD exists and deleted when the CRD is uninstalled.
+	// The CRD is watched by an informer, so the controller reacts as soon as the CRD is installed or removed.
+	snapshotCRDInformer := newCRDInformer(guestAPIExtClient, volumeSnapshotClassCRDName, informerResync())
+	conditionalStaticResourcesController := staticresourcecontroller.NewStaticResourceController(
+		"AWSEBSDriverConditionalStaticResourcesController",
+		withVolumeSnapshotClassConfig(guestOperatorClient, guestNodeInformer.Lister(), assets.ReadFile),
+		[]string{},
+		(&resourceapply.ClientHolder{}).WithKubernetes(guestKubeClient).WithDynamicClient(guestDynamicClient),
+		guestOperatorClient,
+		eventRecorder,
+	).WithConditionalResources(
+		withVolumeSnapshotClassConfig(guestOperatorClient, guestNodeInformer.Lister(), assets.ReadFile),
+		[]string{
+			volumeSnapshotClassFile,
+		},
+		crdExists(snapshotCRDInformer, volumeSnapshotClassCRDName),
+		// The VolumeSnapshotClass is removed with its CRD. Deleting it again is a no-op, but it clears
+		// the state of the controller instead of leaving it behind when the CRD is re-created.
+		crdMissing(snapshotCRDInformer, volumeSnapshotClassCRDName),
+	).AddKubeInformers(guestKubeInformersForNamespaces).AddInformer(snapshotCRDInformer)
+
+	// The optional controllers are created before the informers are started, so that their informers are started too.
+	optionalControllers := newOptionalControllers(optionalControllerInputs{
+		isHypershift:                   isHypershift,
+		controlPlaneNamespace:          controlPlaneNamespace,
+		guestNamespace:                 guestNamespace,
+		operatorClient:                 guestOperatorClient,
+		eventRecorder:                  eventRecorder,
+		controlPlaneDeploymentInformer: controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Apps().V1().Deployments(),
+		controlPlaneSecretInformer:     controlPlaneSecretInformer,
+		controlPlaneConfigMapInformer:  controlPlaneConfigMapInformer,
+		controlPlaneKubeClient:         controlPlaneKubeClient,
+		guestKubeClient:                guestKubeClient,
+		guestDynamicClient:             guestDynamicClient,
+		guestKubeInformers:             guestKubeInformersForNamespaces,
+		guestNodeInformer:              guestNodeInformer,
+		guestInfraInformer:             guestInfraInformer,
+		guestFeatureGateInformer:       guestFeatureGateInformer,
+		snapshotCRDInformer:            snapshotCRDInformer,
+	})
+
+	runner := newControllerRunner(ctx, controllerWorkers())
+
 	if !isHypershift {
 		caSyncController, err := newCustomAWSBundleSyncer(
 			guestOperatorClient,
@@ -261,7 +597,7 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 		go controlPlaneCloudConfigInformers.Start(ctx.Done())
 
 		klog.Info("Starting custom CA bundle sync controller")
-		go caSyncController.Run(ctx, 1)
+		runner.run(caSyncController)
 
 		staticResourcesController := staticresourcecontroller.NewStaticResourceController(
 			"AWSEBSDriverStaticResourcesController",
@@ -271,13 +607,6 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 				"rbac/attacher_binding.yaml",
 				"rbac/provisioner_role.yaml",
 				"rbac/provisioner_binding.yaml",
-				"rbac/resizer_role.yaml",
-				"rbac/resizer_binding.yaml",
-				"rbac/snapshotter_role.yaml",
-				"rbac/snapshotter_binding.yaml",
-				"service.yaml",
-				"rbac/prometheus_role.yaml",
-				"rbac/prometheus_rolebinding.yaml",
 				"rbac/kube_rbac_proxy_role.yaml",
 				"rbac/kube_rbac_proxy_binding.yaml",
 			},
@@ -285,40 +614,413 @@ func RunOperator(ctx context.Context, controllerConfig *controllercmd.Controller
 			guestOperatorClient,
 			eventRecorder,
 		).AddKubeInformers(controlPlaneKubeInformersForNamespaces)
+		for _, sidecar := range []string{"resizer", "snapshotter"} {
+			staticResourcesController = staticResourcesController.WithConditionalResources(
+				assets.ReadFile,
+				optionalSidecars[sidecar].rbacFiles,
+				sidecarEnabled(guestOperatorClient, sidecar),
+				nil,
+			)
+		}
+		staticResourcesController = staticResourcesController.WithConditionalResources(
+			assets.ReadFile,
+			volumeModifierRBACFiles,
+			volumeModifierEnabled(guestFeatureGateInformer.Lister(), os.Getenv(volumeModifierImageEnvName)),
+			nil,
+		).AddInformer(guestFeatureGateInformer.Informer())
 
 		klog.Info("Starting static resources controller")
-		go staticResourcesController.Run(ctx, 1)
+		runner.run(staticResourcesController)
 
-		serviceMonitorController := staticresourcecontroller.NewStaticResourceController(
-			"AWSEBSDriverServiceMonitorController",
+		// On Hypershift, the credentials are provided by the control plane operator.
+		operatorConfigClient := operatorclient.NewForConfigOrDie(rest.AddUserAgent(guestKubeConfig, operatorName))
+		operatorConfigInformers := operatorinformers.NewSharedInformerFactory(operatorConfigClient, informerResync())
+		credentialsRequestController, err := newCredentialsRequestController(
+			"AWSEBSDriverCredentialsRequestController",
+			guestOperatorClient,
+			controlPlaneDynamicClient,
+			controlPlaneNamespace,
+			assetWithNamespaceFunc(controlPlaneNamespace),
+			operatorConfigInformers.Operator().V1().CloudCredentials(),
+			controlPlaneSecretInformer,
+			eventRecorder,
+		)
+		if err != nil {
+			return fmt.Errorf("could not create the CredentialsRequest controller: %w", err)
+		}
+
+		klog.Info("Starting CloudCredential informers")
+		go operatorConfigInformers.Start(ctx.Done())
+
+		klog.Info("Starting CredentialsRequest controller")
+		runner.run(credentialsRequestController)
+	} else {
+		// The CSI sidecars of the controller use the controller ServiceAccount of the guest cluster, with the same
+		// RBAC as on standalone clusters.
+		hostedControllerStaticResourcesController := staticresourcecontroller.NewStaticResourceController(
+			"AWSEBSDriverHostedControllerStaticResourcesController",
+			assetWithNamespaceFunc(guestNamespace),
+			[]string{
+				"controller_sa.yaml",
+				"rbac/attacher_role.yaml",
+				"rbac/attacher_binding.yaml",
+				"rbac/provisioner_role.yaml",
+				"rbac/provisioner_binding.yaml",
+				"rbac/hypershift_controller_role.yaml",
+				"rbac/hypershift_controller_binding.yaml",
+			},
+			(&resourceapply.ClientHolder{}).WithKubernetes(guestKubeClient).WithDynamicClient(guestDynamicClient),
+			guestOperatorClient,
+			eventRecorder,
+		).AddKubeInformers(guestKubeInformersForNamespaces)
+		for _, sidecar := range []string{"resizer", "snapshotter"} {
+			hostedControllerStaticResourcesController = hostedControllerStaticResourcesController.WithConditionalResources(
+				assets.ReadFile,
+				optionalSidecars[sidecar].rbacFiles,
+				sidecarEnabled(guestOperatorClient, sidecar),
+				nil,
+			)
+		}
+		hostedControllerStaticResourcesController = hostedControllerStaticResourcesController.WithConditionalResources(
 			assets.ReadFile,
-			[]string{"servicemonitor.yaml"},
-			(&resourceapply.ClientHolder{}).WithDynamicClient(controlPlaneDynamicClient),
+			volumeModifierRBACFiles,
+			volumeModifierEnabled(guestFeatureGateInformer.Lister(), os.Getenv(volumeModifierImageEnvName)),
+			nil,
+		).AddInformer(guestFeatureGateInformer.Informer())
+
+		klog.Info("Starting hosted controller static resources controller")
+		runner.run(hostedControllerStaticResourcesController)
+
+		guestKubeconfigController := newGuestKubeconfigController(
+			"AWSEBSDriverGuestKubeconfigController",
+			guestOperatorClient,
+			controlPlaneNamespace,
+			controlPlaneKubeClient,
+			controlPlaneSecretInformer,
+			guestNamespace,
+			guestKubeClient,
+			kubeconfigReloader.Config,
+			eventRecorder,
+		)
+
+		klog.Info("Starting guest kubeconfig controller")
+		runner.run(guestKubeconfigController)
+
+		guestCABundleSyncer := newGuestCABundleSyncer(
+			guestOperatorClient,
+			controlPlaneNamespace,
+			controlPlaneConfigMapInformer,
+			guestNamespace,
+			guestKubeClient,
+			guestConfigMapInformer,
+			eventRecorder,
+		)
+
+		klog.Info("Starting guest CA bundle syncer")
+		runner.run(guestCABundleSyncer)
+
+		hostedTrustBundleSyncer := newHostedTrustBundleSyncer(
+			guestOperatorClient,
+			controlPlaneNamespace,
+			controlPlaneKubeClient,
+			controlPlaneConfigMapInformer,
+			controlPlaneHCPInformer.Informer(),
+			controlPlaneHCPLister,
+			eventRecorder,
+		)
+
+		klog.Info("Starting hosted trust bundle syncer")
+		runner.run(hostedTrustBundleSyncer)
+	}
+
+	// The metrics of the controller are served by the kube-rbac-proxy sidecars with a certificate of the service-ca
+	// of the cluster where the controller runs, i.e. the management cluster on Hypershift, and scraped by its
+	// Prometheus. On Hypershift, the sidecars review the tokens of the scrapes in the management cluster.
+	metricsFiles := []string{
+		"service.yaml",
+		"rbac/prometheus_role.yaml",
+		"rbac/prometheus_rolebinding.yaml",
+	}
+	// The monitoring resources are custom resources of the monitoring stack, which may not be installed.
+	monitoringFiles := []string{"servicemonitor.yaml"}
+	if isHypershift {
+		metricsFiles = append(metricsFiles, "rbac/hypershift_kube_rbac_proxy_binding.yaml")
+	} else {
+		// The operator serves its own metrics on standalone clusters, see operatorMetricsController. The alerts
+		// and the console dashboard need the metrics of the node pods and of the operator, which Prometheus of the
+		// management cluster does not scrape on Hypershift.
+		metricsFiles = append(metricsFiles, "operator_metrics_service.yaml", "console_dashboard.yaml")
+		monitoringFiles = append(monitoringFiles, "operator_servicemonitor.yaml", "prometheusrule.yaml")
+	}
+	metricsStaticResourcesController := staticresourcecontroller.NewStaticResourceController(
+		"AWSEBSDriverMetricsStaticResourcesController",
+		withHostedControlPlaneLabels(isHypershift, controlPlaneNamespace, assetWithNamespaceFunc(controlPlaneNamespace)),
+		metricsFiles,
+		(&resourceapply.ClientHolder{}).WithKubernetes(controlPlaneKubeClient).WithDynamicClient(controlPlaneDynamicClient),
+		guestOperatorClient,
+		eventRecorder,
+	).AddKubeInformers(controlPlaneKubeInformersForNamespaces)
+
+	klog.Info("Starting metrics static resources controller")
+	runner.run(metricsStaticResourcesController)
+
+	// The ServiceMonitor is removed together with the kube-rbac-proxy sidecars, see kubeRBACProxies.
+	serviceMonitorController := staticresourcecontroller.NewStaticResourceController(
+		"AWSEBSDriverServiceMonitorController",
+		withHostedControlPlaneLabels(isHypershift, controlPlaneNamespace, assetWithNamespaceFunc(controlPlaneNamespace)),
+		monitoringFiles,
+		(&resourceapply.ClientHolder{}).WithDynamicClient(controlPlaneDynamicClient),
+		guestOperatorClient,
+		eventRecorder,
+	).WithConditionalResources(
+		withHostedControlPlaneLabels(isHypershift, controlPlaneNamespace, assetWithNamespaceFunc(controlPlaneNamespace)),
+		[]string{"servicemonitor.yaml"},
+		kubeRBACProxiesKept(isHypershift, guestOperatorClient),
+		func() bool {
+			return !kubeRBACProxiesKept(isHypershift, guestOperatorClient)()
+		},
+	).WithIgnoreNotFoundOnCreate()
+
+	klog.Info("Starting ServiceMonitor controller")
+	runner.run(serviceMonitorController)
+
+	if !isHypershift {
+		nodeServiceMonitorController := staticresourcecontroller.NewStaticResourceController(
+			"AWSEBSDriverNodeServiceMonitorController",
+			assets.ReadFile,
+			[]string{"node_servicemonitor.yaml"},
+			(&resourceapply.ClientHolder{}).WithDynamicClient(guestDynamicClient),
 			guestOperatorClient,
 			eventRecorder,
 		).WithIgnoreNotFoundOnCreate()
 
-		klog.Info("Starting ServiceMonitor controller")
-		go serviceMonitorController.Run(ctx, 1)
+		klog.Info("Starting node ServiceMonitor controller")
+		runner.run(nodeServiceMonitorController)
+
+		nodeMetricsClientCASyncer := newNodeMetricsClientCASyncer(
+			guestOperatorClient,
+			guestNamespace,
+			guestKubeClient,
+			guestInstallConfigInformer,
+			guestConfigMapInformer,
+			eventRecorder,
+		)
+
+		klog.Info("Starting node metrics client CA syncer")
+		runner.run(nodeMetricsClientCASyncer)
 	}
 
+	credentialsSecretController := newCredentialsSecretController(
+		"AWSEBSDriverCredentialsSecretController",
+		guestOperatorClient,
+		controlPlaneNamespace,
+		credentialsSecretName,
+		controlPlaneSecretInformer,
+		eventRecorder,
+	)
+
+	credentialsExpiryController := newCredentialsExpiryController(
+		"AWSEBSDriverCredentialsExpiry",
+		guestOperatorClient,
+		controlPlaneNamespace,
+		credentialsSecretName,
+		controlPlaneSecretInformer,
+		eventRecorder,
+	)
+
+	rolloutController := newRolloutController(
+		"AWSEBSDriverRollout",
+		guestOperatorClient,
+		controlPlaneNamespace,
+		controlPlaneKubeInformersForNamespaces.InformersFor(controlPlaneNamespace).Apps().V1().Deployments(),
+		guestNamespace,
+		guestKubeInformersForNamespaces.InformersFor(guestNamespace).Apps().V1().DaemonSets(),
+		eventRecorder,
+	)
+
 	klog.Info("Starting the control plane informers")
 	go controlPlaneKubeInformersForNamespaces.Start(ctx.Done())
+	go controlPlaneDynamicInformers.Start(ctx.Done())
 
 	klog.Info("Starting control plane controllerset")
-	go controlPlaneCSIControllerSet.Run(ctx, 1)
+	runner.run(controlPlaneCSIControllerSet)
+
+	klog.Info("Starting controller PodDisruptionBudget controller")
+	runner.run(controllerPDBController)
 
 	klog.Info("Starting the guest cluster informers")
 	go guestKubeInformersForNamespaces.Start(ctx.Done())
 	go guestDynamicInformers.Start(ctx.Done())
 	go guestConfigInformers.Start(ctx.Done())
 
+	go snapshotCRDInformer.Run(ctx.Done())
+
 	klog.Info("Starting guest cluster controllerset")
-	go guestCSIControllerSet.Run(ctx, 1)
+	runner.run(guestCSIControllerSet)
+
+	klog.Info("Starting conditional static resources controller")
+	runner.run(conditionalStaticResourcesController)
+
+	klog.Info("Starting StorageClass controller")
+	runner.run(storageClassController)
+
+	klog.Info("Starting credentials Secret controller")
+	runner.run(credentialsSecretController)
+
+	klog.Info("Starting credentials expiry controller")
+	runner.run(credentialsExpiryController)
+
+	klog.Info("Starting rollout controller")
+	runner.run(rolloutController)
+
+	klog.Info("Starting config observer controller")
+	runner.run(configObserverController)
+
+	klog.Info("Starting FIPS controller")
+	runner.run(fipsController)
+
+	if !isHypershift {
+		klog.Info("Starting external control plane controller")
+		runner.run(externalControlPlaneController)
+	}
+
+	klog.Info("Starting operator metrics controller")
+	runner.run(operatorMetricsController)
+
+	klog.Info("Starting configuration metrics controller")
+	runner.run(configMetricsController)
+
+	klog.Info("Starting AWS region controller")
+	runner.run(awsRegionController)
+
+	klog.Info("Starting EC2 endpoint failover controller")
+	runner.run(ec2EndpointFailoverController)
+
+	optionalControllers.start(runner)
+
+	klog.Info("Starting removal controller")
+	runner.run(removalController)
 
 	<-ctx.Done()
 
-	return fmt.Errorf("stopped")
+	shutdownErr := runner.wait(shutdownTimeout)
+	select {
+	case err := <-guestKubeconfigErrCh:
+		return err
+	default:
+		return shutdownErr
+	}
+}
+
+// informerResync returns the resync period of the config informers from INFORMER_RESYNC_PERIOD.
+// The default is used when the variable is unset or its value is invalid or out of bounds.
+func informerResync() time.Duration {
+	value := os.Getenv(informerResyncEnvName)
+	if value == "" {
+		return defaultInformerResync
+	}
+	resync, err := time.ParseDuration(value)
+	if err != nil || resync < minInformerResync || resync > maxInformerResync {
+		klog.Warningf("Ignoring invalid %s=%q, it must be a duration between %s and %s; using %s",
+			informerResyncEnvName, value, minInformerResync, maxInformerResync, defaultInformerResync)
+		return defaultInformerResync
+	}
+	return resync
+}
+
+// controllerWorkers returns the number of workers of each controller from CONTROLLER_WORKERS.
+// The default is used when the variable is unset or its value is invalid or out of bounds.
+func controllerWorkers() int {
+	value := os.Getenv(controllerWorkersEnvName)
+	if value == "" {
+		return defaultControllerWorkers
+	}
+	workers, err := strconv.Atoi(value)
+	if err != nil || workers < 1 || workers > maxControllerWorkers {
+		klog.Warningf("Ignoring invalid %s=%q, it must be a number between 1 and %d; using %d",
+			controllerWorkersEnvName, value, maxControllerWorkers, defaultControllerWorkers)
+		return defaultControllerWorkers
+	}
+	return workers
+}
+
+// runnable is a controller or a set of controllers that runs until its context is cancelled.
+type runnable interface {
+	Run(ctx context.Context, workers int)
+}
+
+// controllerRunner runs the controllers of the operator and tracks them, so that the operator can wait for them
+// to stop on shutdown. Note that controller sets run each of their controllers with a single worker.
+type controllerRunner struct {
+	ctx     context.Context
+	workers int
+	wg      sync.WaitGroup
+}
+
+func newControllerRunner(ctx context.Context, workers int) *controllerRunner {
+	return &controllerRunner{
+		ctx:     ctx,
+		workers: workers,
+	}
+}
+
+// run starts the controller in a goroutine. It runs until the context of the runner is cancelled.
+func (r *controllerRunner) run(ctrl runnable) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ctrl.Run(r.ctx, r.workers)
+	}()
+}
+
+// wait waits up to timeout for all controllers started by the runner to stop, see waitForShutdown.
+func (r *controllerRunner) wait(timeout time.Duration) error {
+	return waitForShutdown(r.ctx, &r.wg, timeout)
+}
+
+// waitForShutdown waits up to timeout for the controllers to stop after the context was done.
+// Cancellation of the context (e.g. on SIGTERM) is a clean shutdown and it's not reported as an error,
+// so that a regular restart of the operator is not reported as a failure.
+func waitForShutdown(ctx context.Context, wg *sync.WaitGroup, timeout time.Duration) error {
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		klog.Info("All controllers stopped")
+	case <-time.After(timeout):
+		klog.Warningf("Controllers did not stop in %s", timeout)
+	}
+
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("operator stopped: %w", err)
+	}
+	return nil
+}
+
+// guestEventReference returns the involvedObject of the events of the operator in the guest cluster on Hypershift.
+// It's the operator Deployment in the management cluster with the namespace in the guest cluster, as the closest
+// approximation of the real involvedObject. The UID of the Deployment in the management cluster is left out, it
+// would tie the events of the guest cluster to an object of another cluster.
+func guestEventReference(ctx context.Context, controlPlaneKubeClient kubeclient.Interface, controlPlaneNamespace, guestNamespace string) *corev1.ObjectReference {
+	reference := &corev1.ObjectReference{
+		Kind:       "Deployment",
+		APIVersion: "apps/v1",
+		Namespace:  guestNamespace,
+		Name:       operatorName,
+	}
+	controllerRef, err := events.GetControllerReferenceForCurrentPod(ctx, controlPlaneKubeClient, controlPlaneNamespace, nil)
+	if err != nil {
+		klog.Warningf("unable to get owner reference (falling back to Deployment %s): %v", operatorName, err)
+		return reference
+	}
+	if controllerRef.Kind == "Deployment" {
+		reference.Name = controllerRef.Name
+	}
+	return reference
 }
 
 // withCustomAWSCABundle executes the asset as a template to fill out the parts required when using a custom CA bundle.
@@ -333,35 +1035,82 @@ func withCustomAWSCABundle(isHypershift bool, cloudConfigLister corev1listers.Co
 		if configName == "" {
 			return nil
 		}
+		return injectCustomAWSCABundle(&deployment.Spec.Template.Spec, configName)
+	}
+}
 
-		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, corev1.Volume{
-			Name: "ca-bundle",
-			VolumeSource: corev1.VolumeSource{
-				ConfigMap: &corev1.ConfigMapVolumeSource{
-					LocalObjectReference: corev1.LocalObjectReference{Name: configName},
-				},
-			},
-		})
-		for i := range deployment.Spec.Template.Spec.Containers {
-			container := &deployment.Spec.Template.Spec.Containers[i]
-			if container.Name != "csi-driver" {
-				continue
-			}
-			container.Env = append(container.Env, corev1.EnvVar{
-				Name:  "AWS_CA_BUNDLE",
-				Value: "/etc/ca/ca-bundle.pem",
-			})
-			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-				Name:      "ca-bundle",
-				MountPath: "/etc/ca",
-				ReadOnly:  true,
-			})
+// withCustomAWSCABundleHashHook annotates the controller pods with the hash of the custom CA bundle mounted by
+// withCustomAWSCABundle to roll them out when the bundle changes, the AWS SDK of the driver reads it only at start.
+func withCustomAWSCABundleHashHook(isHypershift bool, cloudConfigLister corev1listers.ConfigMapNamespaceLister) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		configName, err := customAWSCABundle(isHypershift, cloudConfigLister)
+		if err != nil {
+			return fmt.Errorf("could not determine if a custom CA bundle is in use: %w", err)
+		}
+		if configName == "" {
 			return nil
 		}
-		return fmt.Errorf("could not use custom CA bundle because the csi-driver container is missing from the deployment")
+		cm, err := cloudConfigLister.Get(configName)
+		if err != nil {
+			return err
+		}
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+		deployment.Spec.Template.Annotations[customCABundleHashAnnotation] = fmt.Sprintf("%x", sha256.Sum256([]byte(cm.Data[caBundleKey])))
+		return nil
 	}
 }
 
+// withCustomAWSCABundleDaemonSetHook mounts the custom CA bundle to the csi-driver container of the node DaemonSet.
+// The CA bundle ConfigMap is copied to the guest namespace either by the custom CA bundle sync controller
+// (standalone clusters) or by the guest CA bundle syncer (Hypershift).
+func withCustomAWSCABundleDaemonSetHook(guestConfigMapLister corev1listers.ConfigMapNamespaceLister) csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(_ *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		configName, err := customAWSCABundle(false, guestConfigMapLister)
+		if err != nil {
+			return fmt.Errorf("could not determine if a custom CA bundle is in use: %w", err)
+		}
+		if configName == "" {
+			return nil
+		}
+		return injectCustomAWSCABundle(&daemonSet.Spec.Template.Spec, configName)
+	}
+}
+
+// injectCustomAWSCABundle mounts the CA bundle from the given ConfigMap to the csi-driver container
+// and points the AWS SDK to it.
+func injectCustomAWSCABundle(podSpec *corev1.PodSpec, configName string) error {
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: "ca-bundle",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configName},
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != "csi-driver" {
+			continue
+		}
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name:  "AWS_CA_BUNDLE",
+			Value: "/etc/ca/ca-bundle.pem",
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "ca-bundle",
+			MountPath: "/etc/ca",
+			ReadOnly:  true,
+		})
+		return nil
+	}
+	return fmt.Errorf("could not use custom CA bundle because the csi-driver container is missing")
+}
+
+// withCustomEndPoint sets the service endpoints of the Infrastructure that the driver calls, EC2, STS for web
+// identities and assumed roles and KMS for encrypted volumes, in the env vars of the csi-driver container listed in
+// serviceEndpointEnvNames, e.g. for the VPC endpoints of private and disconnected clusters.
 func withCustomEndPoint(infraLister v1.InfrastructureLister) dc.DeploymentHookFunc {
 	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
 		infra, err := infraLister.Get(infrastructureName)
@@ -371,27 +1120,16 @@ func withCustomEndPoint(infraLister v1.InfrastructureLister) dc.DeploymentHookFu
 		if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
 			return nil
 		}
-		serviceEndPoints := infra.Status.PlatformStatus.AWS.ServiceEndpoints
-		ec2EndPoint := ""
-		for _, serviceEndPoint := range serviceEndPoints {
-			if serviceEndPoint.Name == "ec2" {
-				ec2EndPoint = serviceEndPoint.URL
-			}
-		}
-		if ec2EndPoint == "" {
+		container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+		if container == nil {
 			return nil
 		}
-
-		for i := range deployment.Spec.Template.Spec.Containers {
-			container := &deployment.Spec.Template.Spec.Containers[i]
-			if container.Name != "csi-driver" {
+		for _, serviceEndPoint := range infra.Status.PlatformStatus.AWS.ServiceEndpoints {
+			envName, ok := serviceEndpointEnvNames[serviceEndPoint.Name]
+			if !ok || serviceEndPoint.URL == "" {
 				continue
 			}
-			container.Env = append(container.Env, corev1.EnvVar{
-				Name:  "AWS_EC2_ENDPOINT",
-				Value: ec2EndPoint,
-			})
-			return nil
+			setContainerEnv(container, envName, serviceEndPoint.URL)
 		}
 		return nil
 	}
@@ -427,13 +1165,22 @@ func newCustomAWSBundleSyncer(
 	return certController, nil
 }
 
-// customAWSCABundle returns true if the cloud config ConfigMap exists and contains a custom CA bundle.
+// customAWSCABundle returns the name of the ConfigMap with the custom CA bundle, or "" when there is none.
+// On Hypershift, the copy of the additional trust bundle of the HostedControlPlane takes precedence over the
+// user-ca-bundle ConfigMap.
 func customAWSCABundle(isHypershift bool, cloudConfigLister corev1listers.ConfigMapNamespaceLister) (string, error) {
-	configName := cloudConfigName
-	if isHypershift {
-		configName = "user-ca-bundle"
+	if !isHypershift {
+		return customAWSCABundleFrom(cloudConfigLister, cloudConfigName)
+	}
+	configName, err := customAWSCABundleFrom(cloudConfigLister, hostedTrustBundleConfigMapName)
+	if err != nil || configName != "" {
+		return configName, err
 	}
+	return customAWSCABundleFrom(cloudConfigLister, "user-ca-bundle")
+}
 
+// customAWSCABundleFrom returns configName if the ConfigMap exists and contains a custom CA bundle.
+func customAWSCABundleFrom(cloudConfigLister corev1listers.ConfigMapNamespaceLister, configName string) (string, error) {
 	cloudConfigCM, err := cloudConfigLister.Get(configName)
 	if apierrors.IsNotFound(err) {
 		return "", nil
@@ -450,17 +1197,36 @@ func customAWSCABundle(isHypershift bool, cloudConfigLister corev1listers.Config
 
 // withCustomTags add tags from Infrastructure.Status.PlatformStatus.AWS.ResourceTags to the driver command line as
 // --extra-tags=<key1>=<value1>,<key2>=<value2>,...
-func withCustomTags(infraLister v1.InfrastructureLister) dc.DeploymentHookFunc {
+// On Hypershift, the tags of the HostedControlPlane are authoritative: they're added to the tags of the
+// Infrastructure, which may not be populated, and override the values of the same keys.
+func withCustomTags(infraLister v1.InfrastructureLister, hcpLister cache.GenericNamespaceLister) dc.DeploymentHookFunc {
 	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
 		infra, err := infraLister.Get(infrastructureName)
 		if err != nil {
 			return err
 		}
-		if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
-			return nil
+		var userTags []configv1.AWSResourceTag
+		if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.AWS != nil {
+			userTags = append(userTags, infra.Status.PlatformStatus.AWS.ResourceTags...)
+		}
+		if hcpLister != nil {
+			hcpTags, err := hostedControlPlaneResourceTags(hcpLister)
+			if err != nil {
+				return err
+			}
+			for _, hcpTag := range hcpTags {
+				found := false
+				for i := range userTags {
+					if userTags[i].Key == hcpTag.Key {
+						userTags[i].Value = hcpTag.Value
+						found = true
+					}
+				}
+				if !found {
+					userTags = append(userTags, hcpTag)
+				}
+			}
 		}
-
-		userTags := infra.Status.PlatformStatus.AWS.ResourceTags
 		if len(userTags) == 0 {
 			return nil
 		}
@@ -501,26 +1267,17 @@ func withNamespaceDeploymentHook(namespace string) dc.DeploymentHookFunc {
 	}
 }
 
-func withHypershiftReplicasHook(isHypershift bool, guestNodeLister corev1listers.NodeLister) dc.DeploymentHookFunc {
-	if !isHypershift {
-		return csidrivercontrollerservicecontroller.WithReplicasHook(guestNodeLister)
-	}
-	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
-		// TODO: get this information from HostedControlPlane.Spec.AvailabilityPolicy
-		replicas := int32(1)
-		deployment.Spec.Replicas = &replicas
-		return nil
-	}
-
-}
-
 func withHypershiftDeploymentHook(isHypershift bool, hypershiftImage string) dc.DeploymentHookFunc {
 	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
 		if !isHypershift {
 			return nil
 		}
+		if hypershiftImage == "" {
+			return fmt.Errorf("%s must be set to the image of the token minter on Hypershift", hypershiftImageEnvName)
+		}
 
-		deployment.Spec.Template.Spec.PriorityClassName = hypershiftPriorityClass
+		// The controller runs in the management cluster, outside of the workload partitioning of the guest cluster.
+		delete(deployment.Spec.Template.Annotations, workloadManagementAnnotation)
 
 		// Inject into the pod the volumes used by CSI and token minter sidecars.
 		podSpec := &deployment.Spec.Template.Spec
@@ -529,8 +1286,8 @@ func withHypershiftDeploymentHook(isHypershift bool, hypershiftImage string) dc.
 				Name: "hosted-kubeconfig",
 				VolumeSource: corev1.VolumeSource{
 					Secret: &corev1.SecretVolumeSource{
-						// FIXME: use a ServiceAccount from the guest cluster
-						SecretName: "admin-kubeconfig",
+						// See guestKubeconfigController.
+						SecretName: guestKubeconfigSecretName,
 					},
 				},
 			},
@@ -548,28 +1305,6 @@ func withHypershiftDeploymentHook(isHypershift bool, hypershiftImage string) dc.
 			}
 		}
 
-		// The metrics-serving-cert volume is not used in Hypershift.
-		for i := range podSpec.Volumes {
-			if podSpec.Volumes[i].Name == "metrics-serving-cert" {
-				podSpec.Volumes = append(podSpec.Volumes[:i], podSpec.Volumes[i+1:]...)
-				break
-			}
-		}
-
-		filtered := []corev1.Container{}
-		for i := range podSpec.Containers {
-			switch podSpec.Containers[i].Name {
-			case "driver-kube-rbac-proxy":
-			case "provisioner-kube-rbac-proxy":
-			case "attacher-kube-rbac-proxy":
-			case "resizer-kube-rbac-proxy":
-			case "snapshotter-kube-rbac-proxy":
-			default:
-				filtered = append(filtered, podSpec.Containers[i])
-			}
-		}
-		podSpec.Containers = filtered
-
 		// Inject into the CSI sidecars the hosted Kubeconfig.
 		for i := range podSpec.Containers {
 			container := &podSpec.Containers[i]
@@ -578,33 +1313,34 @@ func withHypershiftDeploymentHook(isHypershift bool, hypershiftImage string) dc.
 			case "csi-attacher":
 			case "csi-snapshotter":
 			case "csi-resizer":
+			case "csi-volumemodifier":
 			default:
 				continue
 			}
 			container.Args = append(container.Args, "--kubeconfig=$(KUBECONFIG)")
 			container.Env = append(container.Env, corev1.EnvVar{
 				Name:  "KUBECONFIG",
-				Value: "/etc/hosted-kubernetes/kubeconfig",
+				Value: path.Join(guestKubeconfigMountPath, guestKubeconfigKey),
 			})
 			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
 				Name:      "hosted-kubeconfig",
-				MountPath: "/etc/hosted-kubernetes",
+				MountPath: guestKubeconfigMountPath,
 				ReadOnly:  true,
 			})
 		}
 
 		// Add the token minter sidecar into the pod.
 		podSpec.Containers = append(podSpec.Containers, corev1.Container{
-			Name:            "token-minter",
+			Name:            tokenMinterContainerName,
 			Image:           hypershiftImage,
 			ImagePullPolicy: corev1.PullIfNotPresent,
 			Command:         []string{"/usr/bin/control-plane-operator", "token-minter"},
 			Args: []string{
-				"--service-account-namespace=openshift-cluster-csi-drivers",
-				"--service-account-name=aws-ebs-csi-driver-controller-sa",
+				"--service-account-namespace=" + defaultNamespace,
+				"--service-account-name=" + controllerServiceAccountName,
 				"--token-audience=openshift",
 				"--token-file=/var/run/secrets/openshift/serviceaccount/token",
-				"--kubeconfig=/etc/hosted-kubernetes/kubeconfig",
+				"--kubeconfig=" + path.Join(guestKubeconfigMountPath, guestKubeconfigKey),
 			},
 			Resources: corev1.ResourceRequirements{
 				Requests: corev1.ResourceList{
@@ -619,7 +1355,7 @@ func withHypershiftDeploymentHook(isHypershift bool, hypershiftImage string) dc.
 				},
 				{
 					Name:      "hosted-kubeconfig",
-					MountPath: "/etc/hosted-kubernetes",
+					MountPath: guestKubeconfigMountPath,
 					ReadOnly:  true,
 				},
 			},
@@ -628,33 +1364,3 @@ func withHypershiftDeploymentHook(isHypershift bool, hypershiftImage string) dc.
 		return nil
 	}
 }
-
-func withAWSRegion(infraLister v1.InfrastructureLister) dc.DeploymentHookFunc {
-	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
-		infra, err := infraLister.Get(infrastructureName)
-		if err != nil {
-			return err
-		}
-
-		if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.AWS == nil {
-			return nil
-		}
-
-		region := infra.Status.PlatformStatus.AWS.Region
-		if region == "" {
-			return nil
-		}
-
-		for i := range deployment.Spec.Template.Spec.Containers {
-			container := &deployment.Spec.Template.Spec.Containers[i]
-			if container.Name != "csi-driver" {
-				continue
-			}
-			container.Env = append(container.Env, corev1.EnvVar{
-				Name:  "AWS_REGION",
-				Value: region,
-			})
-		}
-		return nil
-	}
-}
@@ -1,6 +1,8 @@
 package operator
 
 import (
+	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,6 +17,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestWithCustomCABundle(t *testing.T) {
@@ -163,10 +167,42 @@ func TestWithCustomCABundle(t *testing.T) {
 	}
 }
 
+func TestWithCustomAWSCABundleHashHook(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	lister := corev1listers.NewConfigMapLister(indexer).ConfigMaps(defaultNamespace)
+	hash := func() string {
+		deployment := &appsv1.Deployment{}
+		if err := withCustomAWSCABundleHashHook(false, lister)(nil, deployment); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return deployment.Spec.Template.Annotations[customCABundleHashAnnotation]
+	}
+
+	if h := hash(); h != "" {
+		t.Errorf("expected no hash without a custom CA bundle, got %s", h)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: defaultNamespace, Name: cloudConfigName},
+		Data:       map[string]string{caBundleKey: "a custom bundle"},
+	}
+	indexer.Add(cm)
+	first := hash()
+	if first == "" {
+		t.Fatalf("expected a hash of the custom CA bundle")
+	}
+	cm = cm.DeepCopy()
+	cm.Data[caBundleKey] = "a rotated bundle"
+	indexer.Update(cm)
+	if second := hash(); second == first {
+		t.Errorf("expected the hash to change with the custom CA bundle")
+	}
+}
+
 func TestWithCustomTags(t *testing.T) {
 	tests := []struct {
 		name         string
 		userTags     []v1.AWSResourceTag
+		hcpSpec      map[string]interface{}
 		inDeployment *appsv1.Deployment
 		expected     *appsv1.Deployment
 	}{
@@ -240,6 +276,54 @@ func TestWithCustomTags(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "HostedControlPlane tags",
+			userTags: []v1.AWSResourceTag{
+				{
+					Key:   "key1",
+					Value: "value1",
+				},
+				{
+					Key:   "key2",
+					Value: "value2",
+				},
+			},
+			hcpSpec: map[string]interface{}{
+				"platform": map[string]interface{}{
+					"aws": map[string]interface{}{
+						"resourceTags": []interface{}{
+							map[string]interface{}{"key": "key2", "value": "rosa"},
+							map[string]interface{}{"key": "red-hat-managed", "value": "true"},
+						},
+					},
+				},
+			},
+			inDeployment: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "csi-driver",
+							}},
+						},
+					},
+				},
+			},
+			expected: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "csi-driver",
+								Args: []string{
+									"--extra-tags=key1=value1,key2=rosa,red-hat-managed=true",
+								},
+							}},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -266,7 +350,11 @@ func TestWithCustomTags(t *testing.T) {
 				return configInformerFactory.Config().V1().Infrastructures().Informer().HasSynced(), nil
 			})
 			deployment := test.inDeployment.DeepCopy()
-			err := withCustomTags(configInformerFactory.Config().V1().Infrastructures().Lister())(nil, deployment)
+			var hcpLister cache.GenericNamespaceLister
+			if test.hcpSpec != nil {
+				hcpLister = newTestHCPListerWithSpecs(test.hcpSpec)
+			}
+			err := withCustomTags(configInformerFactory.Config().V1().Infrastructures().Lister(), hcpLister)(nil, deployment)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -369,6 +457,55 @@ func TestWithCustomEndPoint(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "when custom sts and kms end points are specified",
+			customEndPoints: []v1.AWSServiceEndpoint{
+				{
+					Name: "sts",
+					URL:  "https://sts.example.com",
+				},
+				{
+					Name: "kms",
+					URL:  "https://kms.example.com",
+				},
+				{
+					Name: "elasticloadbalancing",
+					URL:  "https://elb.example.com",
+				},
+			},
+			inDeployment: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "csi-driver",
+							}},
+						},
+					},
+				},
+			},
+			expected: &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name: "csi-driver",
+								Env: []corev1.EnvVar{
+									{
+										Name:  "AWS_ENDPOINT_URL_STS",
+										Value: "https://sts.example.com",
+									},
+									{
+										Name:  "AWS_ENDPOINT_URL_KMS",
+										Value: "https://kms.example.com",
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -405,3 +542,176 @@ func TestWithCustomEndPoint(t *testing.T) {
 	}
 
 }
+
+func TestWaitForShutdown(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	timedOutCtx, cancelTimeout := context.WithDeadline(context.Background(), time.Now())
+	defer cancelTimeout()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		running     bool
+		expectError bool
+	}{
+		{
+			name: "clean shutdown",
+			ctx:  canceledCtx,
+		},
+		{
+			name:    "controllers do not stop in time",
+			ctx:     canceledCtx,
+			running: true,
+		},
+		{
+			name:        "context deadline exceeded",
+			ctx:         timedOutCtx,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			if test.running {
+				wg.Add(1)
+				defer wg.Done()
+			}
+			err := waitForShutdown(test.ctx, &wg, 100*time.Millisecond)
+			if test.expectError && err == nil {
+				t.Errorf("expected error, got none")
+			}
+			if !test.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithCustomAWSCABundleDaemonSetHook(t *testing.T) {
+	inDaemonSet := &appsv1.DaemonSet{
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "csi-driver"},
+						{Name: "csi-node-driver-registrar"},
+					},
+				},
+			},
+		},
+	}
+	cases := []struct {
+		name     string
+		cm       *corev1.ConfigMap
+		expected *appsv1.DaemonSet
+	}{
+		{
+			name:     "no configmap",
+			expected: inDaemonSet,
+		},
+		{
+			name: "custom CA bundle",
+			cm: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: defaultNamespace,
+					Name:      cloudConfigName,
+				},
+				Data: map[string]string{
+					"ca-bundle.pem": "a custom bundle",
+				},
+			},
+			expected: &appsv1.DaemonSet{
+				Spec: appsv1.DaemonSetSpec{
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{
+								{
+									Name: "csi-driver",
+									Env: []corev1.EnvVar{{
+										Name:  "AWS_CA_BUNDLE",
+										Value: "/etc/ca/ca-bundle.pem",
+									}},
+									VolumeMounts: []corev1.VolumeMount{{
+										Name:      "ca-bundle",
+										MountPath: "/etc/ca",
+										ReadOnly:  true,
+									}},
+								},
+								{Name: "csi-node-driver-registrar"},
+							},
+							Volumes: []corev1.Volume{{
+								Name: "ca-bundle",
+								VolumeSource: corev1.VolumeSource{
+									ConfigMap: &corev1.ConfigMapVolumeSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: cloudConfigName},
+									},
+								},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := fake.NewSimpleClientset()
+			kubeInformersForNamespaces := v1helpers.NewKubeInformersForNamespaces(kubeClient, defaultNamespace)
+			configMapInformer := kubeInformersForNamespaces.InformersFor(defaultNamespace).Core().V1().ConfigMaps()
+			if tc.cm != nil {
+				configMapInformer.Informer().GetIndexer().Add(tc.cm)
+			}
+			daemonSet := inDaemonSet.DeepCopy()
+			err := withCustomAWSCABundleDaemonSetHook(configMapInformer.Lister().ConfigMaps(defaultNamespace))(nil, daemonSet)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if e, a := tc.expected, daemonSet; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected daemonset\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
+
+func TestInformerResync(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: defaultInformerResync},
+		{value: "5m", expected: 5 * time.Minute},
+		{value: "10s", expected: defaultInformerResync},
+		{value: "48h", expected: defaultInformerResync},
+		{value: "foo", expected: defaultInformerResync},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv(informerResyncEnvName, test.value)
+			if a := informerResync(); a != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, a)
+			}
+		})
+	}
+}
+
+func TestControllerWorkers(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{value: "", expected: defaultControllerWorkers},
+		{value: "3", expected: 3},
+		{value: "0", expected: defaultControllerWorkers},
+		{value: "100", expected: defaultControllerWorkers},
+		{value: "two", expected: defaultControllerWorkers},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			t.Setenv(controllerWorkersEnvName, test.value)
+			if a := controllerWorkers(); a != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,704 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// unmanagedAnnotation set to "true" on a managed StorageClass stops the operator from reconciling it.
+	unmanagedAnnotation = "storageclass.operator.openshift.io/unmanaged"
+
+	// Condition of the library-go StorageClassController, which was replaced by storageClassController.
+	legacyStorageClassDegradedCondition = "StorageClassControllerDegraded"
+
+	zonesWithoutNodesConditionSuffix = "ZonesWithoutNodes"
+	edgeZonesConditionSuffix         = "DefaultStorageClassEdgeZones"
+	unencryptedConditionSuffix       = "UnencryptedStorageClasses"
+	defaultOverriddenConditionSuffix = "DefaultStorageClassOverridden"
+	unmanagedConditionSuffix         = "UnmanagedStorageClasses"
+	multipleDefaultsConditionSuffix  = "MultipleDefaultStorageClasses"
+	outdatedTopologyConditionSuffix  = "OutdatedAllowedTopologies"
+
+	gp2StorageClassFile = "storageclass_gp2.yaml"
+	// Values of driverConfig.RetireGP2.
+	retireGP2Retain = "Retain"
+	retireGP2Delete = "Delete"
+)
+
+// storageClassController applies the StorageClasses managed by the operator.
+//
+// By default it respects the default StorageClass chosen by the cluster administrator: a StorageClass is
+// made default only when it's created, its asset has the default annotation and there is no other default
+// StorageClass in the cluster. The default annotation of an existing StorageClass is never added or overwritten.
+// StorageClasses in optionalFiles are applied only when they're enabled in driverConfig.OptionalStorageClasses,
+// otherwise they're deleted. The gp2 StorageClass is not applied when it's retired by driverConfig.RetireGP2. StorageClasses in driverConfig.ZoneStorageClasses are also copied for each zone.
+// A gp2 StorageClass is generated for each Outpost in driverConfig.Outposts.
+// When driverConfig.DefaultStorageClass is set, the operator enforces the default annotation on all
+// StorageClasses it manages, so exactly one (or none) of them is default. StorageClasses that are not
+// managed by the operator are never modified, neither are managed StorageClasses with the unmanaged annotation.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error.
+// <name>ZonesWithoutNodes: True when a zone configured in driverConfig.Zones has no nodes.
+// <name>DefaultStorageClassEdgeZones: True when nodes run in Local Zones or Wavelength Zones, where only gp2
+// volumes can be created, and the default StorageClass is a managed StorageClass of another type.
+// <name>OutpostStorage: True when nodes run on an Outpost that is not in driverConfig.Outposts, or an Outpost
+// in driverConfig.Outposts has no nodes.
+// <name>DefaultStorageClassOverridden: True when driverConfig.DefaultStorageClass is not set and the cluster
+// administrator removed the default annotation from the StorageClass that the operator made default.
+// <name>MultipleDefaultStorageClasses: True when more than one StorageClass in the cluster is default.
+// <name>UnmanagedStorageClasses: True when a managed StorageClass has the unmanaged annotation.
+// <name>UnencryptedStorageClasses: True when driverConfig.EncryptByDefault is set and encryption was removed
+// from a managed StorageClass. The StorageClass is re-created with encryption.
+// <name>OutdatedAllowedTopologies: True when the allowedTopologies of a managed StorageClass changed, e.g. in
+// driverConfig.Zones, and the StorageClass is kept because driverConfig.RecreateOnTopologyChange is not set
+// or it's the default StorageClass.
+type storageClassController struct {
+	name      string
+	assetFunc resourceapply.AssetFunc
+	files     []string
+	// optionalFiles maps the names of optional StorageClasses in driverConfig.OptionalStorageClasses to their assets.
+	optionalFiles      map[string]string
+	kubeClient         kubernetes.Interface
+	storageClassLister storagelisters.StorageClassLister
+	nodeLister         corelisters.NodeLister
+	operatorClient     v1helpers.OperatorClient
+	eventRecorder      events.Recorder
+	// Optional hook functions to modify the StorageClasses. They're called for each StorageClass.
+	optionalStorageClassHooks []csistorageclasscontroller.StorageClassHookFunc
+}
+
+func newStorageClassController(
+	name string,
+	assetFunc resourceapply.AssetFunc,
+	files []string,
+	optionalFiles map[string]string,
+	kubeClient kubernetes.Interface,
+	informerFactory informers.SharedInformerFactory,
+	operatorClient v1helpers.OperatorClient,
+	eventRecorder events.Recorder,
+	optionalStorageClassHooks ...csistorageclasscontroller.StorageClassHookFunc,
+) factory.Controller {
+	c := &storageClassController{
+		name:                      name,
+		assetFunc:                 assetFunc,
+		files:                     files,
+		optionalFiles:             optionalFiles,
+		kubeClient:                kubeClient,
+		storageClassLister:        informerFactory.Storage().V1().StorageClasses().Lister(),
+		nodeLister:                informerFactory.Core().V1().Nodes().Lister(),
+		operatorClient:            operatorClient,
+		eventRecorder:             eventRecorder,
+		optionalStorageClassHooks: optionalStorageClassHooks,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		informerFactory.Storage().V1().StorageClasses().Informer(),
+		informerFactory.Core().V1().Nodes().Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *storageClassController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	klog.V(4).Infof("%s sync started", c.name)
+	defer klog.V(4).Infof("%s sync finished", c.name)
+
+	opSpec, opStatus, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+
+	cfg, err := getDriverConfig(opSpec)
+	if err != nil {
+		return err
+	}
+
+	files, disabledFiles, err := c.storageClassFiles(cfg)
+	if err != nil {
+		return err
+	}
+
+	expectedSCs := make([]*storagev1.StorageClass, 0, len(files))
+	for _, file := range files {
+		sc, err := c.getStorageClass(opSpec, file)
+		if err != nil {
+			return err
+		}
+		expectedSCs = append(expectedSCs, sc)
+	}
+
+	existingSCs, err := c.storageClassLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	unmanaged := unmanagedStorageClasses(existingSCs)
+
+	for _, file := range disabledFiles {
+		if err := c.deleteDisabledStorageClass(ctx, syncCtx.Recorder(), file, existingSCs, unmanaged); err != nil {
+			return err
+		}
+	}
+
+	defaultCondition := c.defaultStorageClassCondition(cfg, expectedSCs, existingSCs)
+	if err := setDefaultStorageClassAnnotations(cfg, expectedSCs, existingSCs); err != nil {
+		return err
+	}
+
+	zoneSCs, err := c.zoneStorageClasses(cfg, expectedSCs)
+	if err != nil {
+		return err
+	}
+	expectedSCs = append(expectedSCs, zoneSCs...)
+	outpostSCs, err := c.outpostStorageClasses(opSpec, cfg)
+	if err != nil {
+		return err
+	}
+	expectedSCs = append(expectedSCs, outpostSCs...)
+	if err := c.deleteStaleZoneStorageClasses(ctx, syncCtx.Recorder(), expectedSCs, existingSCs, unmanaged); err != nil {
+		return err
+	}
+
+	// The unmanaged StorageClasses are skipped only now, so the default StorageClass is still validated
+	// against all StorageClasses of the operator.
+	managedSCs := make([]*storagev1.StorageClass, 0, len(expectedSCs))
+	var unmanagedNames []string
+	for _, sc := range expectedSCs {
+		if unmanaged[sc.Name] {
+			unmanagedNames = append(unmanagedNames, sc.Name)
+			continue
+		}
+		managedSCs = append(managedSCs, sc)
+	}
+
+	if cfg.RemoveDuplicateDefault && cfg.DefaultStorageClass == "" {
+		removeDuplicateDefaults(syncCtx.Recorder(), managedSCs, existingSCs)
+	}
+	defaults := effectiveDefaultStorageClasses(managedSCs, existingSCs)
+	defaultStorageClasses.Set(float64(len(defaults)))
+	multipleDefaultsCondition := c.multipleDefaultsCondition(syncCtx.Recorder(), opStatus, defaults)
+
+	encryptionCondition := c.encryptionCondition(syncCtx.Recorder(), opStatus, cfg, managedSCs, existingSCs)
+
+	var outdatedNames []string
+	for _, sc := range managedSCs {
+		outdated, err := c.deleteOnAllowedTopologiesChange(ctx, syncCtx.Recorder(), cfg, sc, existingSCs)
+		if err != nil {
+			return err
+		}
+		if outdated {
+			outdatedNames = append(outdatedNames, sc.Name)
+		}
+		if _, _, err := resourceapply.ApplyStorageClass(ctx, c.kubeClient.StorageV1(), syncCtx.Recorder(), sc); err != nil {
+			return err
+		}
+	}
+
+	zonesCondition, err := c.zonesCondition(cfg)
+	if err != nil {
+		return err
+	}
+	outpostsCondition, err := c.outpostsCondition(cfg)
+	if err != nil {
+		return err
+	}
+	edgeZonesCondition, err := c.edgeZonesCondition(managedSCs, defaults)
+	if err != nil {
+		return err
+	}
+	outdatedTopologyCondition := c.outdatedTopologyCondition(syncCtx.Recorder(), opStatus, outdatedNames)
+
+	_, _, err = v1helpers.UpdateStatus(ctx, c.operatorClient, func(status *opv1.OperatorStatus) error {
+		v1helpers.RemoveOperatorCondition(&status.Conditions, legacyStorageClassDegradedCondition)
+		if zonesCondition == nil {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.name+zonesWithoutNodesConditionSuffix)
+		} else {
+			v1helpers.SetOperatorCondition(&status.Conditions, *zonesCondition)
+		}
+		if edgeZonesCondition == nil {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.name+edgeZonesConditionSuffix)
+		} else {
+			v1helpers.SetOperatorCondition(&status.Conditions, *edgeZonesCondition)
+		}
+		if outpostsCondition == nil {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.name+outpostsConditionSuffix)
+		} else {
+			v1helpers.SetOperatorCondition(&status.Conditions, *outpostsCondition)
+		}
+		if defaultCondition == nil {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.name+defaultOverriddenConditionSuffix)
+		} else {
+			v1helpers.SetOperatorCondition(&status.Conditions, *defaultCondition)
+		}
+		v1helpers.SetOperatorCondition(&status.Conditions, multipleDefaultsCondition)
+		if len(unmanagedNames) == 0 {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.name+unmanagedConditionSuffix)
+		} else {
+			v1helpers.SetOperatorCondition(&status.Conditions, opv1.OperatorCondition{
+				Type:    c.name + unmanagedConditionSuffix,
+				Status:  opv1.ConditionTrue,
+				Reason:  "UnmanagedAnnotation",
+				Message: fmt.Sprintf("StorageClasses %s are not reconciled because of the %s annotation", strings.Join(unmanagedNames, ", "), unmanagedAnnotation),
+			})
+		}
+		if encryptionCondition == nil {
+			v1helpers.RemoveOperatorCondition(&status.Conditions, c.name+unencryptedConditionSuffix)
+		} else {
+			v1helpers.SetOperatorCondition(&status.Conditions, *encryptionCondition)
+		}
+		v1helpers.SetOperatorCondition(&status.Conditions, outdatedTopologyCondition)
+		return nil
+	})
+	return err
+}
+
+// unmanagedStorageClasses returns the names of the existing StorageClasses with the unmanaged annotation.
+func unmanagedStorageClasses(existingSCs []*storagev1.StorageClass) map[string]bool {
+	unmanaged := map[string]bool{}
+	for _, sc := range existingSCs {
+		if sc.Annotations[unmanagedAnnotation] == "true" {
+			unmanaged[sc.Name] = true
+		}
+	}
+	return unmanaged
+}
+
+// storageClassFiles returns the assets of the StorageClasses to apply and of the StorageClasses that are
+// disabled and must be deleted: the optional StorageClasses that are not enabled and the retired gp2 StorageClass.
+func (c *storageClassController) storageClassFiles(cfg *driverConfig) ([]string, []string, error) {
+	enabled := map[string]bool{}
+	for _, name := range cfg.OptionalStorageClasses {
+		if _, ok := c.optionalFiles[name]; !ok {
+			return nil, nil, fmt.Errorf("invalid optionalStorageClasses %q: unknown StorageClass", name)
+		}
+		enabled[name] = true
+	}
+
+	// Sort the optional StorageClasses to apply them in a stable order.
+	names := make([]string, 0, len(c.optionalFiles))
+	for name := range c.optionalFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files, disabledFiles []string
+	for _, file := range c.files {
+		if file != gp2StorageClassFile {
+			files = append(files, file)
+			continue
+		}
+		switch cfg.RetireGP2 {
+		case "":
+			files = append(files, file)
+		case retireGP2Retain:
+		case retireGP2Delete:
+			disabledFiles = append(disabledFiles, file)
+		default:
+			return nil, nil, fmt.Errorf("invalid retireGP2 %q: it must be %s or %s", cfg.RetireGP2, retireGP2Retain, retireGP2Delete)
+		}
+	}
+	for _, name := range names {
+		if enabled[name] {
+			files = append(files, c.optionalFiles[name])
+		} else {
+			disabledFiles = append(disabledFiles, c.optionalFiles[name])
+		}
+	}
+	return files, disabledFiles, nil
+}
+
+// deleteDisabledStorageClass deletes the StorageClass of a disabled asset when it was created by the operator.
+func (c *storageClassController) deleteDisabledStorageClass(ctx context.Context, recorder events.Recorder, file string, existingSCs []*storagev1.StorageClass, unmanaged map[string]bool) error {
+	scBytes, err := c.assetFunc(file)
+	if err != nil {
+		return err
+	}
+	name := resourceread.ReadStorageClassV1OrDie(scBytes).Name
+	for _, existing := range existingSCs {
+		if existing.Name != name || existing.Annotations[managedAnnotation] != "true" || existing.Provisioner != driverName || unmanaged[existing.Name] {
+			continue
+		}
+		err := c.kubeClient.StorageV1().StorageClasses().Delete(ctx, existing.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		recorder.Eventf("StorageClassDeleted", "Deleted StorageClass %s, it is disabled in the driver configuration", existing.Name)
+	}
+	return nil
+}
+
+// deleteOnAllowedTopologiesChange deletes the existing StorageClass when its allowedTopologies differ from
+// the expected ones and driverConfig.RecreateOnTopologyChange is set. The field is immutable, the StorageClass
+// is then re-created by ApplyStorageClass. Otherwise, and always for a default StorageClass, the expected
+// StorageClass keeps the allowedTopologies of the existing one and true is returned: PersistentVolumeClaims
+// of a deleted StorageClass can't be provisioned until it's re-created.
+// With driverConfig.AutoZones, the zones of the existing StorageClass are kept, zones are only added.
+func (c *storageClassController) deleteOnAllowedTopologiesChange(ctx context.Context, recorder events.Recorder, cfg *driverConfig, expected *storagev1.StorageClass, existingSCs []*storagev1.StorageClass) (bool, error) {
+	for _, existing := range existingSCs {
+		if existing.Name != expected.Name {
+			continue
+		}
+		if cfg.AutoZones {
+			addExistingZones(existing, expected)
+		}
+		if equality.Semantic.DeepEqual(existing.AllowedTopologies, expected.AllowedTopologies) {
+			continue
+		}
+		if !cfg.RecreateOnTopologyChange || existing.Annotations[defaultStorageClassAnnotation] == "true" {
+			expected.AllowedTopologies = existing.AllowedTopologies
+			return true, nil
+		}
+		err := c.kubeClient.StorageV1().StorageClasses().Delete(ctx, existing.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, err
+		}
+		recorder.Eventf("StorageClassDeleted", "Deleted StorageClass %s to re-create it with updated allowedTopologies", existing.Name)
+	}
+	return false, nil
+}
+
+// outdatedTopologyCondition returns the condition that reports the managed StorageClasses that keep outdated
+// allowedTopologies. An event is emitted when the condition changes to True or its message changes.
+func (c *storageClassController) outdatedTopologyCondition(recorder events.Recorder, status *opv1.OperatorStatus, outdatedNames []string) opv1.OperatorCondition {
+	cond := opv1.OperatorCondition{
+		Type:   c.name + outdatedTopologyConditionSuffix,
+		Status: opv1.ConditionFalse,
+	}
+	if len(outdatedNames) == 0 {
+		return cond
+	}
+	cond.Status = opv1.ConditionTrue
+	cond.Reason = "AllowedTopologiesChanged"
+	cond.Message = fmt.Sprintf("The allowedTopologies of StorageClasses %s changed and they were not re-created, see recreateOnTopologyChange", strings.Join(outdatedNames, ", "))
+	previous := v1helpers.FindOperatorCondition(status.Conditions, cond.Type)
+	if previous == nil || previous.Status != opv1.ConditionTrue || previous.Message != cond.Message {
+		recorder.Warning("StorageClassAllowedTopologiesChanged", cond.Message)
+	}
+	return cond
+}
+
+// defaultStorageClassCondition returns the condition that reports whether the cluster administrator changed
+// the default StorageClass set by the operator, or nil when the default is enforced by driverConfig.DefaultStorageClass.
+// It must be called before setDefaultStorageClassAnnotations modifies the expected StorageClasses.
+func (c *storageClassController) defaultStorageClassCondition(cfg *driverConfig, expectedSCs, existingSCs []*storagev1.StorageClass) *opv1.OperatorCondition {
+	if cfg.DefaultStorageClass != "" {
+		return nil
+	}
+	cond := &opv1.OperatorCondition{
+		Type:   c.name + defaultOverriddenConditionSuffix,
+		Status: opv1.ConditionFalse,
+	}
+
+	var overridden []string
+	var defaults []string
+	for _, existing := range existingSCs {
+		if existing.Annotations[defaultStorageClassAnnotation] == "true" {
+			defaults = append(defaults, existing.Name)
+		}
+		for _, expected := range expectedSCs {
+			if existing.Name == expected.Name && expected.Annotations[defaultStorageClassAnnotation] == "true" &&
+				existing.Annotations[defaultStorageClassAnnotation] != "true" {
+				overridden = append(overridden, existing.Name)
+			}
+		}
+	}
+	if len(overridden) == 0 {
+		return cond
+	}
+
+	sort.Strings(defaults)
+	cond.Status = opv1.ConditionTrue
+	cond.Reason = "DefaultStorageClassChanged"
+	if len(defaults) == 0 {
+		cond.Message = fmt.Sprintf("StorageClass %s is not default, there is no default StorageClass", strings.Join(overridden, ", "))
+	} else {
+		cond.Message = fmt.Sprintf("StorageClass %s is not default, the default StorageClass is %s", strings.Join(overridden, ", "), strings.Join(defaults, ", "))
+	}
+	return cond
+}
+
+// effectiveDefaultStorageClasses returns the sorted names of the default StorageClasses once the managed
+// StorageClasses are applied.
+func effectiveDefaultStorageClasses(managedSCs, existingSCs []*storagev1.StorageClass) []string {
+	defaults := map[string]bool{}
+	for _, sc := range existingSCs {
+		if sc.Annotations[defaultStorageClassAnnotation] == "true" {
+			defaults[sc.Name] = true
+		}
+	}
+	for _, sc := range managedSCs {
+		// ApplyStorageClass keeps the existing annotation when the expected StorageClass doesn't have it.
+		if val, ok := sc.Annotations[defaultStorageClassAnnotation]; ok {
+			defaults[sc.Name] = val == "true"
+		}
+	}
+	var names []string
+	for name, isDefault := range defaults {
+		if isDefault {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// removeDuplicateDefaults removes the default annotation from the managed StorageClasses when a StorageClass
+// that is not managed by the operator is default too.
+func removeDuplicateDefaults(recorder events.Recorder, managedSCs, existingSCs []*storagev1.StorageClass) {
+	managed := map[string]*storagev1.StorageClass{}
+	for _, sc := range managedSCs {
+		managed[sc.Name] = sc
+	}
+	otherDefault := false
+	for _, name := range effectiveDefaultStorageClasses(managedSCs, existingSCs) {
+		if managed[name] == nil {
+			otherDefault = true
+		}
+	}
+	if !otherDefault {
+		return
+	}
+	for _, name := range effectiveDefaultStorageClasses(managedSCs, existingSCs) {
+		sc := managed[name]
+		if sc == nil {
+			continue
+		}
+		if sc.Annotations == nil {
+			sc.Annotations = map[string]string{}
+		}
+		sc.Annotations[defaultStorageClassAnnotation] = "false"
+		recorder.Warningf("DuplicateDefaultStorageClassRemoved", "Removed the default annotation from StorageClass %s, another StorageClass is default", name)
+	}
+}
+
+// multipleDefaultsCondition returns the condition that reports whether there is more than one default StorageClass.
+// An event is emitted when the condition becomes True.
+func (c *storageClassController) multipleDefaultsCondition(recorder events.Recorder, status *opv1.OperatorStatus, defaults []string) opv1.OperatorCondition {
+	condType := c.name + multipleDefaultsConditionSuffix
+	if len(defaults) <= 1 {
+		return opv1.OperatorCondition{
+			Type:   condType,
+			Status: opv1.ConditionFalse,
+		}
+	}
+	cond := opv1.OperatorCondition{
+		Type:    condType,
+		Status:  opv1.ConditionTrue,
+		Reason:  "MultipleDefaultStorageClasses",
+		Message: fmt.Sprintf("StorageClasses %s are all default, PersistentVolumeClaims without a StorageClass may use any of them", strings.Join(defaults, ", ")),
+	}
+	if !v1helpers.IsOperatorConditionTrue(status.Conditions, condType) {
+		recorder.Warning("MultipleDefaultStorageClasses", cond.Message)
+	}
+	return cond
+}
+
+// encryptionCondition returns the condition that reports managed StorageClasses whose encryption was removed,
+// or nil when encryption is not enforced. An event is emitted when the condition changes to True or its message changes.
+func (c *storageClassController) encryptionCondition(recorder events.Recorder, status *opv1.OperatorStatus, cfg *driverConfig, expectedSCs, existingSCs []*storagev1.StorageClass) *opv1.OperatorCondition {
+	if !cfg.EncryptByDefault {
+		return nil
+	}
+	cond := &opv1.OperatorCondition{
+		Type:   c.name + unencryptedConditionSuffix,
+		Status: opv1.ConditionFalse,
+	}
+	var unencrypted []string
+	for _, expected := range expectedSCs {
+		for _, existing := range existingSCs {
+			if existing.Name == expected.Name && existing.Parameters["encrypted"] != "true" {
+				unencrypted = append(unencrypted, existing.Name)
+			}
+		}
+	}
+	if len(unencrypted) > 0 {
+		cond.Status = opv1.ConditionTrue
+		cond.Reason = "EncryptionRemoved"
+		cond.Message = fmt.Sprintf("Encryption was removed from StorageClasses %s, they are re-created with encryption", strings.Join(unencrypted, ", "))
+		previous := v1helpers.FindOperatorCondition(status.Conditions, cond.Type)
+		if previous == nil || previous.Status != opv1.ConditionTrue || previous.Message != cond.Message {
+			recorder.Warning("StorageClassEncryptionRemoved", cond.Message)
+		}
+	}
+	return cond
+}
+
+// zonesCondition returns the condition that reports configured zones without nodes, or nil when no zones are configured.
+func (c *storageClassController) zonesCondition(cfg *driverConfig) (*opv1.OperatorCondition, error) {
+	if len(cfg.Zones) == 0 {
+		return nil, nil
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	cond := &opv1.OperatorCondition{
+		Type:   c.name + zonesWithoutNodesConditionSuffix,
+		Status: opv1.ConditionFalse,
+	}
+	if missing := zonesWithoutNodes(cfg.Zones, nodes); len(missing) > 0 {
+		cond.Status = opv1.ConditionTrue
+		cond.Reason = "ZonesWithoutNodes"
+		cond.Message = fmt.Sprintf("Volumes are provisioned only in zones %s, but there are no nodes in zones %s",
+			strings.Join(cfg.Zones, ", "), strings.Join(missing, ", "))
+		klog.Warning(cond.Message)
+	}
+	return cond, nil
+}
+
+// edgeZonesCondition returns the condition that reports the Local Zones and Wavelength Zones where the default
+// StorageClass can't provision volumes, or nil when no nodes run in edge zones.
+func (c *storageClassController) edgeZonesCondition(managedSCs []*storagev1.StorageClass, defaults []string) (*opv1.OperatorCondition, error) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	edgeZones := nodeEdgeZones(nodes)
+	if len(edgeZones) == 0 {
+		return nil, nil
+	}
+	cond := &opv1.OperatorCondition{
+		Type:   c.name + edgeZonesConditionSuffix,
+		Status: opv1.ConditionFalse,
+	}
+	var notGP2 []string
+	for _, sc := range managedSCs {
+		for _, name := range defaults {
+			if sc.Name == name && sc.Parameters["type"] != "gp2" {
+				notGP2 = append(notGP2, name)
+			}
+		}
+	}
+	if len(notGP2) == 0 {
+		return cond, nil
+	}
+	zones := make([]string, 0, len(edgeZones))
+	for zone := range edgeZones {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	cond.Status = opv1.ConditionTrue
+	cond.Reason = "EdgeZonesNotSupported"
+	cond.Message = fmt.Sprintf("The default StorageClass %s can't provision volumes in the Local Zones and Wavelength Zones %s, only gp2 volumes are supported there: use a gp2 StorageClass for their workloads",
+		strings.Join(notGP2, ", "), strings.Join(zones, ", "))
+	return cond, nil
+}
+
+func (c *storageClassController) getStorageClass(opSpec *opv1.OperatorSpec, file string) (*storagev1.StorageClass, error) {
+	scBytes, err := c.assetFunc(file)
+	if err != nil {
+		return nil, err
+	}
+	sc := resourceread.ReadStorageClassV1OrDie(scBytes)
+	for i := range c.optionalStorageClassHooks {
+		if err := c.optionalStorageClassHooks[i](opSpec, sc); err != nil {
+			return nil, fmt.Errorf("error running hook function (index=%d) on StorageClass %s: %w", i, sc.Name, err)
+		}
+	}
+	return sc, nil
+}
+
+// setDefaultStorageClassAnnotations sets the default StorageClass annotation on the expected StorageClasses.
+func setDefaultStorageClassAnnotations(cfg *driverConfig, expectedSCs, existingSCs []*storagev1.StorageClass) error {
+	if cfg.DefaultStorageClass == "" {
+		for _, sc := range expectedSCs {
+			preserveDefaultStorageClassAnnotation(sc, existingSCs)
+		}
+		return nil
+	}
+
+	defaultName := ""
+	if cfg.DefaultStorageClass != defaultStorageClassNone {
+		defaultName = cfg.DefaultStorageClass + "-csi"
+		found := false
+		for _, sc := range expectedSCs {
+			if sc.Name == defaultName {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid defaultStorageClass %q: StorageClass %s is not managed by the operator", cfg.DefaultStorageClass, defaultName)
+		}
+	}
+
+	for _, sc := range expectedSCs {
+		if sc.Annotations == nil {
+			sc.Annotations = map[string]string{}
+		}
+		if sc.Name == defaultName {
+			sc.Annotations[defaultStorageClassAnnotation] = "true"
+		} else {
+			sc.Annotations[defaultStorageClassAnnotation] = "false"
+		}
+	}
+	return nil
+}
+
+// preserveDefaultStorageClassAnnotation keeps the default StorageClass chosen by the cluster administrator:
+// the annotation of an existing StorageClass is preserved, or left out when the administrator removed it,
+// and a new StorageClass is not made default when there already is another default StorageClass.
+func preserveDefaultStorageClassAnnotation(expectedSC *storagev1.StorageClass, existingSCs []*storagev1.StorageClass) {
+	// Skip the default SC annotation check if it's not in the expected StorageClass.
+	if expectedSC.Annotations == nil || expectedSC.Annotations[defaultStorageClassAnnotation] == "" {
+		return
+	}
+
+	defaultSCCount := 0
+	for _, sc := range existingSCs {
+		if sc.Annotations[defaultStorageClassAnnotation] == "true" && sc.Name != expectedSC.Name {
+			defaultSCCount++
+		}
+	}
+	for _, sc := range existingSCs {
+		if sc.Name != expectedSC.Name {
+			continue
+		}
+		// There already is a StorageClass with the same name, copy its annotation. When the annotation was
+		// removed, don't add it back, ApplyStorageClass keeps the annotations missing in the expected object.
+		if val, ok := sc.Annotations[defaultStorageClassAnnotation]; ok {
+			expectedSC.Annotations[defaultStorageClassAnnotation] = val
+		} else {
+			delete(expectedSC.Annotations, defaultStorageClassAnnotation)
+		}
+		return
+	}
+	// There already is a default, and it's not set on the StorageClass we intend to create.
+	if defaultSCCount > 0 {
+		expectedSC.Annotations[defaultStorageClassAnnotation] = "false"
+	}
+}
@@ -0,0 +1,592 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+// newTestStorageClassController returns a storageClassController that manages the gp2 and gp3
+// StorageClasses and the optional io2 and st1 StorageClasses together with its fake kube client.
+func newTestStorageClassController(spec *opv1.OperatorSpec, existing ...*storagev1.StorageClass) (*storageClassController, *fake.Clientset) {
+	objects := []runtime.Object{}
+	for _, sc := range existing {
+		objects = append(objects, sc)
+	}
+	kubeClient := fake.NewSimpleClientset(objects...)
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	for _, sc := range existing {
+		informerFactory.Storage().V1().StorageClasses().Informer().GetIndexer().Add(sc)
+	}
+	spec.ManagementState = opv1.Managed
+	c := &storageClassController{
+		name:               "Test",
+		assetFunc:          assets.ReadFile,
+		files:              []string{"storageclass_gp2.yaml", "storageclass_gp3.yaml"},
+		optionalFiles:      map[string]string{"io2": "storageclass_io2.yaml", "st1": "storageclass_st1.yaml"},
+		kubeClient:         kubeClient,
+		storageClassLister: informerFactory.Storage().V1().StorageClasses().Lister(),
+		nodeLister:         informerFactory.Core().V1().Nodes().Lister(),
+		operatorClient:     v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil),
+		eventRecorder:      events.NewInMemoryRecorder("test"),
+	}
+	return c, kubeClient
+}
+
+func newTestStorageClass(name string, annotations map[string]string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+		},
+		Provisioner: "ebs.csi.aws.com",
+	}
+}
+
+func TestStorageClassControllerDefaultStorageClass(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides string
+		existing  []*storagev1.StorageClass
+		expected  map[string]string
+	}{
+		{
+			name: "new cluster",
+			expected: map[string]string{
+				"gp2-csi": "",
+				"gp3-csi": "true",
+			},
+		},
+		{
+			name: "user default is preserved",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "false"}),
+				newTestStorageClass("user", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expected: map[string]string{
+				"gp2-csi": "",
+				"gp3-csi": "false",
+				"user":    "true",
+			},
+		},
+		{
+			name: "removed default annotation is not added back",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", nil),
+			},
+			expected: map[string]string{
+				"gp2-csi": "",
+				"gp3-csi": "",
+			},
+		},
+		{
+			name: "two defaults are kept",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+				newTestStorageClass("user", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expected: map[string]string{
+				"gp3-csi": "true",
+				"user":    "true",
+			},
+		},
+		{
+			name:      "flip default from gp2 to gp3",
+			overrides: `{"defaultStorageClass": "gp3"}`,
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp2-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "false"}),
+			},
+			expected: map[string]string{
+				"gp2-csi": "false",
+				"gp3-csi": "true",
+			},
+		},
+		{
+			name:      "gp2 default",
+			overrides: `{"defaultStorageClass": "gp2"}`,
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp2-csi", nil),
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expected: map[string]string{
+				"gp2-csi": "true",
+				"gp3-csi": "false",
+			},
+		},
+		{
+			name:      "no default, user StorageClass is not touched",
+			overrides: `{"defaultStorageClass": "None"}`,
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+				newTestStorageClass("user", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expected: map[string]string{
+				"gp2-csi": "false",
+				"gp3-csi": "false",
+				"user":    "true",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec, test.existing...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, expected := range test.expected {
+				sc, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed to get StorageClass %s: %v", name, err)
+				}
+				if a := sc.Annotations[defaultStorageClassAnnotation]; a != expected {
+					t.Errorf("StorageClass %s: expected default annotation %q, got %q", name, expected, a)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerInvalidDefaultStorageClass(t *testing.T) {
+	spec := &opv1.OperatorSpec{}
+	spec.UnsupportedConfigOverrides.Raw = []byte(`{"defaultStorageClass": "io1"}`)
+	c, _ := newTestStorageClassController(spec)
+	if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err == nil {
+		t.Errorf("expected error for invalid defaultStorageClass")
+	}
+}
+
+func TestStorageClassControllerOptionalStorageClasses(t *testing.T) {
+	managed := map[string]string{managedAnnotation: "true"}
+	tests := []struct {
+		name            string
+		overrides       string
+		existing        []*storagev1.StorageClass
+		expectedPresent []string
+		expectedAbsent  []string
+		expectedError   bool
+	}{
+		{
+			name:            "no optional StorageClasses",
+			expectedPresent: []string{"gp2-csi", "gp3-csi"},
+			expectedAbsent:  []string{"io2-csi", "st1-csi"},
+		},
+		{
+			name:            "io2 enabled",
+			overrides:       `{"optionalStorageClasses": ["io2"]}`,
+			expectedPresent: []string{"gp2-csi", "gp3-csi", "io2-csi"},
+			expectedAbsent:  []string{"st1-csi"},
+		},
+		{
+			name:            "disabled StorageClass is deleted",
+			overrides:       `{"optionalStorageClasses": ["io2"]}`,
+			existing:        []*storagev1.StorageClass{newTestStorageClass("st1-csi", managed)},
+			expectedPresent: []string{"io2-csi"},
+			expectedAbsent:  []string{"st1-csi"},
+		},
+		{
+			name:            "user StorageClass with the same name is kept",
+			existing:        []*storagev1.StorageClass{newTestStorageClass("st1-csi", nil)},
+			expectedPresent: []string{"st1-csi"},
+		},
+		{
+			name:          "unknown StorageClass",
+			overrides:     `{"optionalStorageClasses": ["io1"]}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec, test.existing...)
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, name := range test.expectedPresent {
+				if _, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+					t.Errorf("expected StorageClass %s: %v", name, err)
+				}
+			}
+			for _, name := range test.expectedAbsent {
+				if _, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{}); err == nil {
+					t.Errorf("expected StorageClass %s to be absent", name)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerEncryptByDefault(t *testing.T) {
+	managed := map[string]string{managedAnnotation: "true"}
+	tests := []struct {
+		name              string
+		overrides         string
+		existing          []*storagev1.StorageClass
+		expectedCondition *opv1.ConditionStatus
+	}{
+		{
+			name: "encryption not enforced",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", managed),
+			},
+		},
+		{
+			name:      "encrypted StorageClasses",
+			overrides: `{"encryptByDefault": true}`,
+			existing: func() []*storagev1.StorageClass {
+				sc := newTestStorageClass("gp3-csi", managed)
+				sc.Parameters = map[string]string{"type": "gp3", "encrypted": "true"}
+				return []*storagev1.StorageClass{sc}
+			}(),
+			expectedCondition: func() *opv1.ConditionStatus { s := opv1.ConditionFalse; return &s }(),
+		},
+		{
+			name:      "encryption removed by user",
+			overrides: `{"encryptByDefault": true}`,
+			existing: func() []*storagev1.StorageClass {
+				sc := newTestStorageClass("gp3-csi", managed)
+				sc.Parameters = map[string]string{"type": "gp3"}
+				return []*storagev1.StorageClass{sc}
+			}(),
+			expectedCondition: func() *opv1.ConditionStatus { s := opv1.ConditionTrue; return &s }(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec, test.existing...)
+			c.optionalStorageClassHooks = append(c.optionalStorageClassHooks, withEncryptionHook())
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sc, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), "gp3-csi", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get StorageClass: %v", err)
+			}
+			if sc.Parameters["encrypted"] != "true" {
+				t.Errorf("expected encrypted StorageClass, got parameters %v", sc.Parameters)
+			}
+
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+unencryptedConditionSuffix)
+			if test.expectedCondition == nil {
+				if cond != nil {
+					t.Errorf("expected no condition, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil || cond.Status != *test.expectedCondition {
+				t.Errorf("expected condition status %s, got %+v", *test.expectedCondition, cond)
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerEncryptionRemovedEvent(t *testing.T) {
+	sc := newTestStorageClass("gp3-csi", map[string]string{managedAnnotation: "true"})
+	sc.Parameters = map[string]string{"type": "gp3"}
+	spec := &opv1.OperatorSpec{}
+	spec.UnsupportedConfigOverrides.Raw = []byte(`{"encryptByDefault": true}`)
+	c, _ := newTestStorageClassController(spec, sc)
+	c.optionalStorageClassHooks = append(c.optionalStorageClassHooks, withEncryptionHook())
+	recorder := events.NewInMemoryRecorder("test")
+
+	// The informer still has the unencrypted StorageClass, e.g. until it sees the re-created one.
+	for i := 0; i < 3; i++ {
+		if err := c.sync(context.TODO(), factory.NewSyncContext("test", recorder)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	var removed int
+	for _, event := range recorder.Events() {
+		if event.Reason == "StorageClassEncryptionRemoved" {
+			removed++
+		}
+	}
+	if removed != 1 {
+		t.Errorf("expected one StorageClassEncryptionRemoved event, got %d", removed)
+	}
+}
+
+func TestStorageClassControllerDefaultOverriddenCondition(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrides       string
+		existing        []*storagev1.StorageClass
+		expectedStatus  opv1.ConditionStatus
+		expectedMessage string
+	}{
+		{
+			name:           "new cluster",
+			expectedStatus: opv1.ConditionFalse,
+		},
+		{
+			name: "operator default",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expectedStatus: opv1.ConditionFalse,
+		},
+		{
+			name: "user default",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "false"}),
+				newTestStorageClass("user", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expectedStatus:  opv1.ConditionTrue,
+			expectedMessage: "StorageClass gp3-csi is not default, the default StorageClass is user",
+		},
+		{
+			name: "no default",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", nil),
+			},
+			expectedStatus:  opv1.ConditionTrue,
+			expectedMessage: "StorageClass gp3-csi is not default, there is no default StorageClass",
+		},
+		{
+			name:      "default enforced by the operator",
+			overrides: `{"defaultStorageClass": "gp2"}`,
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", nil),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, _ := newTestStorageClassController(spec, test.existing...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+defaultOverriddenConditionSuffix)
+			if test.expectedStatus == "" {
+				if cond != nil {
+					t.Errorf("expected no condition, got %+v", cond)
+				}
+				return
+			}
+			if cond == nil {
+				t.Fatalf("expected condition, got none")
+			}
+			if cond.Status != test.expectedStatus || cond.Message != test.expectedMessage {
+				t.Errorf("unexpected condition %+v", cond)
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerUnmanagedStorageClass(t *testing.T) {
+	unmanaged := newTestStorageClass("gp3-csi", map[string]string{
+		managedAnnotation:   "true",
+		unmanagedAnnotation: "true",
+	})
+	unmanaged.Parameters = map[string]string{"type": "gp3", "throughput": "500"}
+
+	spec := &opv1.OperatorSpec{}
+	c, kubeClient := newTestStorageClassController(spec, unmanaged)
+	if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sc, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), "gp3-csi", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get StorageClass: %v", err)
+	}
+	if e, a := unmanaged.Parameters, sc.Parameters; !equality.Semantic.DeepEqual(e, a) {
+		t.Errorf("unexpected parameters of the unmanaged StorageClass\nwant=%#v\ngot= %#v", e, a)
+	}
+	if _, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), "gp2-csi", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected managed StorageClass gp2-csi: %v", err)
+	}
+
+	_, status, _, _ := c.operatorClient.GetOperatorState()
+	cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+unmanagedConditionSuffix)
+	if cond == nil || cond.Status != opv1.ConditionTrue {
+		t.Errorf("expected unmanaged condition, got %+v", cond)
+	}
+}
+
+func TestStorageClassControllerMultipleDefaults(t *testing.T) {
+	tests := []struct {
+		name              string
+		overrides         string
+		existing          []*storagev1.StorageClass
+		expectedCondition opv1.ConditionStatus
+		expected          map[string]string
+	}{
+		{
+			name:              "single default",
+			expectedCondition: opv1.ConditionFalse,
+			expected: map[string]string{
+				"gp3-csi": "true",
+			},
+		},
+		{
+			name: "multiple defaults",
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+				newTestStorageClass("user", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expectedCondition: opv1.ConditionTrue,
+			expected: map[string]string{
+				"gp3-csi": "true",
+				"user":    "true",
+			},
+		},
+		{
+			name:      "duplicate default removed",
+			overrides: `{"removeDuplicateDefault": true}`,
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "true"}),
+				newTestStorageClass("user", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expectedCondition: opv1.ConditionFalse,
+			expected: map[string]string{
+				"gp3-csi": "false",
+				"user":    "true",
+			},
+		},
+		{
+			name:      "duplicate default of users is not removed",
+			overrides: `{"removeDuplicateDefault": true}`,
+			existing: []*storagev1.StorageClass{
+				newTestStorageClass("gp3-csi", map[string]string{defaultStorageClassAnnotation: "false"}),
+				newTestStorageClass("user1", map[string]string{defaultStorageClassAnnotation: "true"}),
+				newTestStorageClass("user2", map[string]string{defaultStorageClassAnnotation: "true"}),
+			},
+			expectedCondition: opv1.ConditionTrue,
+			expected: map[string]string{
+				"user1": "true",
+				"user2": "true",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec, test.existing...)
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, expected := range test.expected {
+				sc, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed to get StorageClass %s: %v", name, err)
+				}
+				if a := sc.Annotations[defaultStorageClassAnnotation]; a != expected {
+					t.Errorf("StorageClass %s: expected default annotation %q, got %q", name, expected, a)
+				}
+			}
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+multipleDefaultsConditionSuffix)
+			if cond == nil || cond.Status != test.expectedCondition {
+				t.Errorf("expected condition status %s, got %+v", test.expectedCondition, cond)
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerRetireGP2(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     string
+		expectedGP2   bool
+		expectedError bool
+	}{
+		{
+			name:        "gp2 managed",
+			expectedGP2: true,
+		},
+		{
+			name:        "gp2 retained",
+			overrides:   `{"retireGP2": "Retain"}`,
+			expectedGP2: true,
+		},
+		{
+			name:      "gp2 deleted",
+			overrides: `{"retireGP2": "Delete"}`,
+		},
+		{
+			name:          "invalid value",
+			overrides:     `{"retireGP2": "Yes"}`,
+			expectedError: true,
+		},
+		{
+			name:          "retired gp2 default",
+			overrides:     `{"retireGP2": "Delete", "defaultStorageClass": "gp2"}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			existing := newTestStorageClass("gp2-csi", map[string]string{managedAnnotation: "true"})
+			existing.Parameters = map[string]string{"type": "gp2", "iopsPerGB": "10"}
+			c, kubeClient := newTestStorageClassController(spec, existing)
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = kubeClient.StorageV1().StorageClasses().Get(context.TODO(), "gp2-csi", metav1.GetOptions{})
+			if test.expectedGP2 && err != nil {
+				t.Errorf("expected StorageClass gp2-csi: %v", err)
+			}
+			if !test.expectedGP2 && err == nil {
+				t.Errorf("expected StorageClass gp2-csi to be deleted")
+			}
+		})
+	}
+}
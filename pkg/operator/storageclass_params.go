@@ -0,0 +1,150 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+)
+
+const (
+	// csiParameterPrefix is the prefix of the StorageClass parameters interpreted by the CSI sidecars.
+	csiParameterPrefix = "csi.storage.k8s.io/"
+
+	// Limits of gp3 volumes, see https://docs.aws.amazon.com/ebs/latest/userguide/general-purpose.html
+	gp3MinIOPS       = 3000
+	gp3MaxIOPS       = 80000
+	gp3MinThroughput = 125
+	gp3MaxThroughput = 2000
+	// gp3IOPSPerMiBps is the minimum number of IOPS per provisioned MiB/s of throughput.
+	gp3IOPSPerMiBps = 4
+)
+
+// withGP3PerformanceHook sets the IOPS and throughput from the driver configuration on the managed gp3
+// StorageClasses. Parameters of a single StorageClass in storageClassParameters take precedence.
+func withGP3PerformanceHook() csistorageclasscontroller.StorageClassHookFunc {
+	return func(spec *opv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.GP3 == nil || sc.Parameters["type"] != "gp3" {
+			return nil
+		}
+		if err := validateGP3Config(cfg.GP3); err != nil {
+			return err
+		}
+		if cfg.GP3.IOPS != nil {
+			sc.Parameters["iops"] = strconv.Itoa(int(*cfg.GP3.IOPS))
+		}
+		if cfg.GP3.Throughput != nil {
+			sc.Parameters["throughput"] = strconv.Itoa(int(*cfg.GP3.Throughput))
+		}
+		return nil
+	}
+}
+
+func validateGP3Config(gp3 *gp3Config) error {
+	iops := int32(gp3MinIOPS)
+	if gp3.IOPS != nil {
+		iops = *gp3.IOPS
+		if iops < gp3MinIOPS || iops > gp3MaxIOPS {
+			return fmt.Errorf("invalid gp3 iops %d: it must be between %d and %d", iops, gp3MinIOPS, gp3MaxIOPS)
+		}
+	}
+	if gp3.Throughput != nil {
+		throughput := *gp3.Throughput
+		if throughput < gp3MinThroughput || throughput > gp3MaxThroughput {
+			return fmt.Errorf("invalid gp3 throughput %d: it must be between %d and %d MiB/s", throughput, gp3MinThroughput, gp3MaxThroughput)
+		}
+		if throughput*gp3IOPSPerMiBps > iops {
+			return fmt.Errorf("invalid gp3 throughput %d: it requires at least %d iops", throughput, throughput*gp3IOPSPerMiBps)
+		}
+	}
+	return nil
+}
+
+// withStorageClassParametersHook merges the extra parameters from the driver configuration into the
+// managed StorageClass, so admins can tune e.g. throughput of gp3-csi without the operator reverting it.
+// The parameters that define the StorageClass or that are set from other fields of the configuration
+// can't be overridden.
+func withStorageClassParametersHook() csistorageclasscontroller.StorageClassHookFunc {
+	return func(spec *opv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		params := cfg.StorageClassParameters[sc.Name]
+		if len(params) == 0 {
+			return nil
+		}
+
+		// Sort the keys to report errors consistently.
+		keys := make([]string, 0, len(params))
+		for key := range params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := validateStorageClassParameter(cfg, key); err != nil {
+				return fmt.Errorf("invalid storageClassParameters of %s: %w", sc.Name, err)
+			}
+		}
+
+		if sc.Parameters == nil {
+			sc.Parameters = map[string]string{}
+		}
+		for _, key := range keys {
+			sc.Parameters[key] = params[key]
+		}
+		return nil
+	}
+}
+
+// withStorageClassPoliciesHook sets the reclaim policy and volume binding mode from the driver configuration
+// on the managed StorageClasses. Both fields are immutable, the StorageClasses are re-created when they change.
+func withStorageClassPoliciesHook() csistorageclasscontroller.StorageClassHookFunc {
+	return func(spec *opv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		switch policy := corev1.PersistentVolumeReclaimPolicy(cfg.ReclaimPolicy); policy {
+		case "":
+		case corev1.PersistentVolumeReclaimDelete, corev1.PersistentVolumeReclaimRetain:
+			sc.ReclaimPolicy = &policy
+		default:
+			return fmt.Errorf("invalid reclaimPolicy %q: it must be Delete or Retain", cfg.ReclaimPolicy)
+		}
+		switch mode := storagev1.VolumeBindingMode(cfg.VolumeBindingMode); mode {
+		case "":
+		case storagev1.VolumeBindingImmediate, storagev1.VolumeBindingWaitForFirstConsumer:
+			sc.VolumeBindingMode = &mode
+		default:
+			return fmt.Errorf("invalid volumeBindingMode %q: it must be Immediate or WaitForFirstConsumer", cfg.VolumeBindingMode)
+		}
+		return nil
+	}
+}
+
+func validateStorageClassParameter(cfg *driverConfig, key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("parameter name must not be empty")
+	case key == "type":
+		return fmt.Errorf("parameter %q is managed by the operator", key)
+	case strings.HasPrefix(key, csiParameterPrefix):
+		return fmt.Errorf("parameter %q is managed by the operator", key)
+	case cfg.KMSKeyARN != "" && (key == "encrypted" || key == "kmsKeyId"):
+		return fmt.Errorf("parameter %q is set from kmsKeyARN", key)
+	case cfg.EncryptByDefault && key == "encrypted":
+		return fmt.Errorf("parameter %q is set from encryptByDefault", key)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestWithStorageClassParametersHook(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      map[string]string
+		expectedError bool
+	}{
+		{
+			name:     "no parameters",
+			expected: map[string]string{"type": "gp3", "encrypted": "true"},
+		},
+		{
+			name:      "parameters of another StorageClass",
+			overrides: `{"storageClassParameters": {"gp2-csi": {"iopsPerGB": "10"}}}`,
+			expected:  map[string]string{"type": "gp3", "encrypted": "true"},
+		},
+		{
+			name:      "extra parameters",
+			overrides: `{"storageClassParameters": {"gp3-csi": {"throughput": "250", "iops": "6000", "encrypted": "false"}}}`,
+			expected: map[string]string{
+				"type":       "gp3",
+				"encrypted":  "false",
+				"throughput": "250",
+				"iops":       "6000",
+			},
+		},
+		{
+			name:          "type",
+			overrides:     `{"storageClassParameters": {"gp3-csi": {"type": "io2"}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "CSI parameter",
+			overrides:     `{"storageClassParameters": {"gp3-csi": {"csi.storage.k8s.io/fstype": "xfs"}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "encrypted with encryptByDefault",
+			overrides:     `{"encryptByDefault": true, "storageClassParameters": {"gp3-csi": {"encrypted": "false"}}}`,
+			expectedError: true,
+		},
+		{
+			name:          "KMS key with kmsKeyARN",
+			overrides:     `{"kmsKeyARN": "arn:aws:kms:us-east-1:123456789012:alias/ebs", "storageClassParameters": {"gp3-csi": {"kmsKeyId": "foo"}}}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			sc := newTestStorageClass("gp3-csi", nil)
+			sc.Parameters = map[string]string{"type": "gp3", "encrypted": "true"}
+			err := withStorageClassParametersHook()(spec, sc)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, sc.Parameters; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected parameters\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
+
+func TestWithGP3PerformanceHook(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     string
+		scType        string
+		expected      map[string]string
+		expectedError bool
+	}{
+		{
+			name:     "no configuration",
+			scType:   "gp3",
+			expected: map[string]string{"type": "gp3"},
+		},
+		{
+			name:      "iops and throughput",
+			overrides: `{"gp3": {"iops": 6000, "throughput": 500}}`,
+			scType:    "gp3",
+			expected:  map[string]string{"type": "gp3", "iops": "6000", "throughput": "500"},
+		},
+		{
+			name:      "throughput with default iops",
+			overrides: `{"gp3": {"throughput": 750}}`,
+			scType:    "gp3",
+			expected:  map[string]string{"type": "gp3", "throughput": "750"},
+		},
+		{
+			name:      "gp2 is not changed",
+			overrides: `{"gp3": {"iops": 6000}}`,
+			scType:    "gp2",
+			expected:  map[string]string{"type": "gp2"},
+		},
+		{
+			name:          "iops too low",
+			overrides:     `{"gp3": {"iops": 100}}`,
+			scType:        "gp3",
+			expectedError: true,
+		},
+		{
+			name:          "throughput too high for iops",
+			overrides:     `{"gp3": {"iops": 3000, "throughput": 1000}}`,
+			scType:        "gp3",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			sc := newTestStorageClass(test.scType+"-csi", nil)
+			sc.Parameters = map[string]string{"type": test.scType}
+			err := withGP3PerformanceHook()(spec, sc)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, sc.Parameters; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected parameters\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
+
+func TestWithStorageClassPoliciesHook(t *testing.T) {
+	deletePolicy := corev1.PersistentVolumeReclaimDelete
+	retainPolicy := corev1.PersistentVolumeReclaimRetain
+	waitMode := storagev1.VolumeBindingWaitForFirstConsumer
+	immediateMode := storagev1.VolumeBindingImmediate
+
+	tests := []struct {
+		name           string
+		overrides      string
+		expectedPolicy *corev1.PersistentVolumeReclaimPolicy
+		expectedMode   *storagev1.VolumeBindingMode
+		expectedError  bool
+	}{
+		{
+			name:           "defaults",
+			expectedPolicy: &deletePolicy,
+			expectedMode:   &waitMode,
+		},
+		{
+			name:           "retain and immediate",
+			overrides:      `{"reclaimPolicy": "Retain", "volumeBindingMode": "Immediate"}`,
+			expectedPolicy: &retainPolicy,
+			expectedMode:   &immediateMode,
+		},
+		{
+			name:          "invalid reclaim policy",
+			overrides:     `{"reclaimPolicy": "Recycle"}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid volume binding mode",
+			overrides:     `{"volumeBindingMode": "Later"}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			sc := newTestStorageClass("gp3-csi", nil)
+			sc.ReclaimPolicy = &deletePolicy
+			sc.VolumeBindingMode = &waitMode
+			err := withStorageClassPoliciesHook()(spec, sc)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expectedPolicy, sc.ReclaimPolicy; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected reclaimPolicy\nwant=%v\ngot= %v", *e, *a)
+			}
+			if e, a := test.expectedMode, sc.VolumeBindingMode; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected volumeBindingMode\nwant=%v\ngot= %v", *e, *a)
+			}
+		})
+	}
+}
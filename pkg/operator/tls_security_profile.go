@@ -0,0 +1,196 @@
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/crypto"
+	"github.com/openshift/library-go/pkg/operator/configobserver"
+	"github.com/openshift/library-go/pkg/operator/configobserver/proxy"
+	"github.com/openshift/library-go/pkg/operator/csi/csiconfigobservercontroller"
+	"github.com/openshift/library-go/pkg/operator/csi/csidrivernodeservicecontroller"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const apiServerName = "cluster"
+
+// servingInfoConfigPath returns the path of the observed TLS security profile in spec.observedConfig.
+func servingInfoConfigPath() []string {
+	return []string{"targetcsiconfig", "servingInfo"}
+}
+
+// configObserverListers implement the listers of the proxy and the TLS security profile observers.
+type configObserverListers struct {
+	csiconfigobservercontroller.Listers
+	apiServerLister configlistersv1.APIServerLister
+}
+
+func (l configObserverListers) APIServerLister() configlistersv1.APIServerLister {
+	return l.apiServerLister
+}
+
+// newConfigObserverController returns a config observer of the cluster Proxy, like the one of the CSI controller
+// set, and of the TLS security profile of the cluster APIServer. Both must be observed by the same controller,
+// because a config observer replaces the whole spec.observedConfig.
+func newConfigObserverController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	configInformers configinformers.SharedInformerFactory,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	proxyInformer := configInformers.Config().V1().Proxies()
+	apiServerInformer := configInformers.Config().V1().APIServers()
+	return configobserver.NewConfigObserver(
+		operatorClient,
+		eventRecorder.WithComponentSuffix("csi-config-observer-controller-"+strings.ToLower(name)),
+		configObserverListers{
+			Listers: csiconfigobservercontroller.Listers{
+				ProxyLister_: proxyInformer.Lister(),
+				PreRunCachesSynced: []cache.InformerSynced{
+					operatorClient.Informer().HasSynced,
+					proxyInformer.Informer().HasSynced,
+					apiServerInformer.Informer().HasSynced,
+				},
+			},
+			apiServerLister: apiServerInformer.Lister(),
+		},
+		[]factory.Informer{
+			operatorClient.Informer(),
+			proxyInformer.Informer(),
+			apiServerInformer.Informer(),
+		},
+		proxy.NewProxyObserveFunc(csiconfigobservercontroller.ProxyConfigPath()),
+		observeTLSSecurityProfile,
+	)
+}
+
+// observeTLSSecurityProfile observes the TLS security profile of the cluster APIServer and writes its minimum TLS
+// version and cipher suites, with IANA names, to spec.observedConfig.
+func observeTLSSecurityProfile(genericListers configobserver.Listers, recorder events.Recorder, existingConfig map[string]interface{}) (ret map[string]interface{}, _ []error) {
+	defer func() {
+		ret = configobserver.Pruned(ret, servingInfoConfigPath())
+	}()
+
+	var profile *configv1.TLSSecurityProfile
+	apiServer, err := genericListers.(configObserverListers).APIServerLister().Get(apiServerName)
+	switch {
+	case apierrors.IsNotFound(err):
+		// Use the default profile.
+	case err != nil:
+		return existingConfig, []error{err}
+	default:
+		profile = apiServer.Spec.TLSSecurityProfile
+	}
+	spec := tlsProfileSpec(profile)
+
+	observedConfig := map[string]interface{}{}
+	minTLSVersionPath := append(servingInfoConfigPath(), "minTLSVersion")
+	if err := unstructured.SetNestedField(observedConfig, string(spec.MinTLSVersion), minTLSVersionPath...); err != nil {
+		return existingConfig, []error{err}
+	}
+	cipherSuites := crypto.OpenSSLToIANACipherSuites(spec.Ciphers)
+	cipherSuitesPath := append(servingInfoConfigPath(), "cipherSuites")
+	if len(cipherSuites) > 0 {
+		if err := unstructured.SetNestedStringSlice(observedConfig, cipherSuites, cipherSuitesPath...); err != nil {
+			return existingConfig, []error{err}
+		}
+	}
+
+	currentMinTLSVersion, _, _ := unstructured.NestedString(existingConfig, minTLSVersionPath...)
+	currentCipherSuites, _, _ := unstructured.NestedStringSlice(existingConfig, cipherSuitesPath...)
+	if currentMinTLSVersion != string(spec.MinTLSVersion) || !reflect.DeepEqual(currentCipherSuites, cipherSuites) {
+		recorder.Eventf("ObserveTLSSecurityProfile", "TLS security profile changed to minTLSVersion %s and cipherSuites %q", spec.MinTLSVersion, cipherSuites)
+	}
+	return observedConfig, nil
+}
+
+// tlsProfileSpec returns the TLS settings of a TLS security profile. The Intermediate profile is the default.
+func tlsProfileSpec(profile *configv1.TLSSecurityProfile) *configv1.TLSProfileSpec {
+	if profile == nil {
+		return configv1.TLSProfiles[configv1.TLSProfileIntermediateType]
+	}
+	if profile.Type == configv1.TLSProfileCustomType {
+		if profile.Custom != nil {
+			return &profile.Custom.TLSProfileSpec
+		}
+		return configv1.TLSProfiles[configv1.TLSProfileIntermediateType]
+	}
+	if spec, ok := configv1.TLSProfiles[profile.Type]; ok {
+		return spec
+	}
+	return configv1.TLSProfiles[configv1.TLSProfileIntermediateType]
+}
+
+// withTLSSecurityProfileDeploymentHook sets --tls-min-version and --tls-cipher-suites of the kube-rbac-proxy
+// containers from the observed TLS security profile of the cluster APIServer. Without an observed profile, they
+// keep the defaults of the assets. TLS 1.3 cipher suites are not configurable, so a profile without TLS 1.2
+// cipher suites keeps the default --tls-cipher-suites.
+func withTLSSecurityProfileDeploymentHook() dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		return setTLSSecurityProfile(spec, &deployment.Spec.Template.Spec)
+	}
+}
+
+// withTLSSecurityProfileDaemonSetHook is withTLSSecurityProfileDeploymentHook for the node DaemonSet.
+func withTLSSecurityProfileDaemonSetHook() csidrivernodeservicecontroller.DaemonSetHookFunc {
+	return func(spec *opv1.OperatorSpec, daemonSet *appsv1.DaemonSet) error {
+		return setTLSSecurityProfile(spec, &daemonSet.Spec.Template.Spec)
+	}
+}
+
+func setTLSSecurityProfile(spec *opv1.OperatorSpec, podSpec *corev1.PodSpec) error {
+	minTLSVersion, cipherSuites, err := observedTLSSecurityProfile(spec)
+	if err != nil {
+		return err
+	}
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if !strings.HasSuffix(container.Name, "kube-rbac-proxy") {
+			continue
+		}
+		if minTLSVersion != "" {
+			setContainerArg(container, "tls-min-version", minTLSVersion)
+		}
+		if len(cipherSuites) > 0 {
+			setContainerArg(container, "tls-cipher-suites", strings.Join(cipherSuites, ","))
+		}
+	}
+	return nil
+}
+
+// observedTLSSecurityProfile returns the minimum TLS version and the cipher suites observed in the TLS security
+// profile of the cluster APIServer. They are empty until the profile is observed.
+func observedTLSSecurityProfile(spec *opv1.OperatorSpec) (string, []string, error) {
+	if len(spec.ObservedConfig.Raw) == 0 {
+		return "", nil, nil
+	}
+	observedConfig := map[string]interface{}{}
+	if err := json.Unmarshal(spec.ObservedConfig.Raw, &observedConfig); err != nil {
+		return "", nil, fmt.Errorf("failed to parse the observed config: %w", err)
+	}
+	minTLSVersion, _, err := unstructured.NestedString(observedConfig, append(servingInfoConfigPath(), "minTLSVersion")...)
+	if err != nil {
+		return "", nil, err
+	}
+	cipherSuites, _, err := unstructured.NestedStringSlice(observedConfig, append(servingInfoConfigPath(), "cipherSuites")...)
+	if err != nil {
+		return "", nil, err
+	}
+	return minTLSVersion, cipherSuites, nil
+}
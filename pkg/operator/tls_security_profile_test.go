@@ -0,0 +1,136 @@
+package operator
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/operator/events"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObserveTLSSecurityProfile(t *testing.T) {
+	tests := []struct {
+		name                 string
+		profile              *configv1.TLSSecurityProfile
+		noAPIServer          bool
+		expectedMinVersion   string
+		expectedCipherSuites []string
+	}{
+		{
+			name:               "no APIServer",
+			noAPIServer:        true,
+			expectedMinVersion: "VersionTLS12",
+		},
+		{
+			name:               "default",
+			expectedMinVersion: "VersionTLS12",
+		},
+		{
+			name:               "modern",
+			profile:            &configv1.TLSSecurityProfile{Type: configv1.TLSProfileModernType},
+			expectedMinVersion: "VersionTLS13",
+		},
+		{
+			name: "custom",
+			profile: &configv1.TLSSecurityProfile{
+				Type: configv1.TLSProfileCustomType,
+				Custom: &configv1.CustomTLSProfile{
+					TLSProfileSpec: configv1.TLSProfileSpec{
+						Ciphers:       []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256"},
+						MinTLSVersion: configv1.VersionTLS12,
+					},
+				},
+			},
+			expectedMinVersion:   "VersionTLS12",
+			expectedCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiServerInformer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().APIServers()
+			if !test.noAPIServer {
+				apiServerInformer.Informer().GetIndexer().Add(&configv1.APIServer{
+					ObjectMeta: metav1.ObjectMeta{Name: apiServerName},
+					Spec:       configv1.APIServerSpec{TLSSecurityProfile: test.profile},
+				})
+			}
+			listers := configObserverListers{apiServerLister: apiServerInformer.Lister()}
+
+			observedConfig, errs := observeTLSSecurityProfile(listers, events.NewInMemoryRecorder("test"), map[string]interface{}{})
+			if len(errs) > 0 {
+				t.Fatalf("unexpected errors: %v", errs)
+			}
+			minVersion, _, _ := unstructured.NestedString(observedConfig, append(servingInfoConfigPath(), "minTLSVersion")...)
+			if minVersion != test.expectedMinVersion {
+				t.Errorf("expected minTLSVersion %q, got %q", test.expectedMinVersion, minVersion)
+			}
+			cipherSuites, _, _ := unstructured.NestedStringSlice(observedConfig, append(servingInfoConfigPath(), "cipherSuites")...)
+			if test.expectedCipherSuites != nil && !reflect.DeepEqual(cipherSuites, test.expectedCipherSuites) {
+				t.Errorf("expected cipherSuites %v, got %v", test.expectedCipherSuites, cipherSuites)
+			}
+			if test.profile != nil && test.profile.Type == configv1.TLSProfileModernType && len(cipherSuites) != 0 {
+				t.Errorf("expected no configurable cipher suites with TLS 1.3, got %v", cipherSuites)
+			}
+			for _, cipher := range cipherSuites {
+				if !strings.HasPrefix(cipher, "TLS_") {
+					t.Errorf("expected IANA cipher suite names, got %q", cipher)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTLSSecurityProfileDeploymentHook(t *testing.T) {
+	observedConfig, err := json.Marshal(map[string]interface{}{
+		"targetcsiconfig": map[string]interface{}{
+			"servingInfo": map[string]interface{}{
+				"minTLSVersion": "VersionTLS12",
+				"cipherSuites":  []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deployment := &appsv1.Deployment{}
+	deployment.Spec.Template.Spec.Containers = []corev1.Container{
+		{
+			Name: "csi-driver",
+			Args: []string{"controller"},
+		},
+		{
+			Name: "driver-kube-rbac-proxy",
+			Args: []string{"--secure-listen-address=0.0.0.0:9201", "--tls-cipher-suites=TLS_AES_128_GCM_SHA256"},
+		},
+	}
+	spec := &opv1.OperatorSpec{}
+	spec.ObservedConfig.Raw = observedConfig
+	if err := withTLSSecurityProfileDeploymentHook()(spec, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedArgs := [][]string{
+		{"controller"},
+		{
+			"--secure-listen-address=0.0.0.0:9201",
+			"--tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			"--tls-min-version=VersionTLS12",
+		},
+	}
+	for i, container := range deployment.Spec.Template.Spec.Containers {
+		if !reflect.DeepEqual(container.Args, expectedArgs[i]) {
+			t.Errorf("expected args %v of container %s, got %v", expectedArgs[i], container.Name, container.Args)
+		}
+	}
+}
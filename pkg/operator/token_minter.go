@@ -0,0 +1,89 @@
+package operator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+)
+
+const (
+	tokenMinterContainerName = "token-minter"
+
+	// The token of the web identity is requested with the TokenRequest API, which rejects shorter lifetimes.
+	minTokenMinterLifetime = 10 * time.Minute
+	maxTokenMinterLifetime = 24 * time.Hour
+)
+
+// validate returns an error when the token lifetime is not a duration between minTokenMinterLifetime and
+// maxTokenMinterLifetime, the ServiceAccount is not a valid name or the token file is outside of the directory of
+// the projected token, where the driver reads it.
+func (c *tokenMinterConfig) validate() error {
+	if c.TokenLifetime != "" {
+		lifetime, err := time.ParseDuration(c.TokenLifetime)
+		if err != nil || lifetime < minTokenMinterLifetime || lifetime > maxTokenMinterLifetime {
+			return fmt.Errorf("invalid tokenMinter tokenLifetime %q: it must be a duration between %s and %s",
+				c.TokenLifetime, minTokenMinterLifetime, maxTokenMinterLifetime)
+		}
+	}
+	if strings.ContainsAny(c.Audience, " \t\r\n,") {
+		return fmt.Errorf("invalid tokenMinter audience %q: it must be a single audience without whitespace", c.Audience)
+	}
+	if c.ServiceAccountName != "" {
+		if errs := validation.IsDNS1123Subdomain(c.ServiceAccountName); len(errs) > 0 {
+			return fmt.Errorf("invalid tokenMinter serviceAccountName %q: %s", c.ServiceAccountName, strings.Join(errs, ", "))
+		}
+	}
+	if c.ServiceAccountNamespace != "" {
+		if errs := validation.IsDNS1123Label(c.ServiceAccountNamespace); len(errs) > 0 {
+			return fmt.Errorf("invalid tokenMinter serviceAccountNamespace %q: %s", c.ServiceAccountNamespace, strings.Join(errs, ", "))
+		}
+	}
+	if c.TokenFile != "" && (path.Dir(c.TokenFile) != boundSATokenMountPath || path.Clean(c.TokenFile) != c.TokenFile) {
+		return fmt.Errorf("invalid tokenMinter tokenFile %q: it must be a file in %s", c.TokenFile, boundSATokenMountPath)
+	}
+	return nil
+}
+
+// withTokenMinterHook configures the token minter sidecar of the controller on Hypershift from the driver
+// configuration. Its resources are set like the ones of the other containers, in resources.controller.
+// It must run after withHypershiftDeploymentHook, which adds the sidecar.
+func withTokenMinterHook(isHypershift bool) dc.DeploymentHookFunc {
+	return func(spec *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		if !isHypershift {
+			return nil
+		}
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		if cfg.TokenMinter == nil {
+			return nil
+		}
+		if err := cfg.TokenMinter.validate(); err != nil {
+			return err
+		}
+		container := getContainer(&deployment.Spec.Template.Spec, tokenMinterContainerName)
+		if container == nil {
+			return fmt.Errorf("could not configure the token minter because the %s container is missing", tokenMinterContainerName)
+		}
+		for _, arg := range []struct{ name, value string }{
+			{"token-lifetime", cfg.TokenMinter.TokenLifetime},
+			{"token-audience", cfg.TokenMinter.Audience},
+			{"service-account-name", cfg.TokenMinter.ServiceAccountName},
+			{"service-account-namespace", cfg.TokenMinter.ServiceAccountNamespace},
+			{"token-file", cfg.TokenMinter.TokenFile},
+		} {
+			if arg.value != "" {
+				setContainerArg(container, arg.name, arg.value)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,200 @@
+package operator
+
+import (
+	"testing"
+
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestWithTokenMinterHook(t *testing.T) {
+	tests := []struct {
+		name            string
+		hypershiftImage string
+		overrides       string
+		expectedArg     string
+		expectedMemory  string
+		expectedError   bool
+	}{
+		{
+			name:            "defaults",
+			hypershiftImage: "hypershift-image",
+			expectedArg:     "--token-audience=openshift",
+			expectedMemory:  "10Mi",
+		},
+		{
+			name:            "custom audience",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"audience": "sts.amazonaws.com"}}`,
+			expectedArg:     "--token-audience=sts.amazonaws.com",
+			expectedMemory:  "10Mi",
+		},
+		{
+			name:            "custom service account",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"serviceAccountName": "ebs-csi", "serviceAccountNamespace": "kube-system"}}`,
+			expectedArg:     "--service-account-name=ebs-csi",
+			expectedMemory:  "10Mi",
+		},
+		{
+			name:            "custom token file",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"tokenFile": "/var/run/secrets/openshift/serviceaccount/web-identity-token"}}`,
+			expectedArg:     "--token-file=/var/run/secrets/openshift/serviceaccount/web-identity-token",
+			expectedMemory:  "10Mi",
+		},
+		{
+			name:            "token file outside of the token directory",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"tokenFile": "/var/run/secrets/openshift/serviceaccount/../token"}}`,
+			expectedError:   true,
+		},
+		{
+			name:            "invalid service account namespace",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"serviceAccountNamespace": "Kube_System"}}`,
+			expectedError:   true,
+		},
+		{
+			name:            "token lifetime and resources",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"tokenLifetime": "2h"}, "resources": {"controller": {"token-minter": {"requests": {"memory": "50Mi"}}}}}`,
+			expectedArg:     "--token-lifetime=2h",
+			expectedMemory:  "50Mi",
+		},
+		{
+			name:            "too short token lifetime",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"tokenLifetime": "1m"}}`,
+			expectedError:   true,
+		},
+		{
+			name:            "invalid token lifetime",
+			hypershiftImage: "hypershift-image",
+			overrides:       `{"tokenMinter": {"tokenLifetime": "forever"}}`,
+			expectedError:   true,
+		},
+		{
+			name:          "missing image",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			asset, err := assets.ReadFile("controller.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := resourceread.ReadDeploymentV1OrDie(asset)
+			// Run the hooks in the same order as the operator.
+			err = withHypershiftDeploymentHook(true, test.hypershiftImage)(spec, deployment)
+			if err == nil {
+				err = withTokenMinterHook(true)(spec, deployment)
+			}
+			if err == nil {
+				err = withResourcesDeploymentHook()(spec, deployment)
+			}
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			container := getContainer(&deployment.Spec.Template.Spec, tokenMinterContainerName)
+			found := test.expectedArg == ""
+			for _, arg := range container.Args {
+				if arg == test.expectedArg {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected argument %s, got %v", test.expectedArg, container.Args)
+			}
+			if memory := container.Resources.Requests.Memory().String(); memory != test.expectedMemory {
+				t.Errorf("expected memory request %s, got %s", test.expectedMemory, memory)
+			}
+		})
+	}
+}
+
+func TestTokenMinterTokenFileOfTheSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     string
+		tokenFile     string
+		expectedError bool
+	}{
+		{
+			name:      "default token file",
+			tokenFile: "/var/run/secrets/openshift/serviceaccount/token",
+		},
+		{
+			name:      "custom token file",
+			overrides: `{"tokenMinter": {"tokenFile": "/var/run/secrets/openshift/serviceaccount/web-identity-token"}}`,
+			tokenFile: "/var/run/secrets/openshift/serviceaccount/web-identity-token",
+		},
+		{
+			name:          "custom token file not in the Secret",
+			overrides:     `{"tokenMinter": {"tokenFile": "/var/run/secrets/openshift/serviceaccount/web-identity-token"}}`,
+			tokenFile:     "/var/run/secrets/openshift/serviceaccount/token",
+			expectedError: true,
+		},
+		{
+			name:          "token file of the Secret not written by the token minter",
+			tokenFile:     "/var/run/secrets/openshift/serviceaccount/web-identity-token",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			asset, err := assets.ReadFile("controller.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := resourceread.ReadDeploymentV1OrDie(asset)
+			informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			informer.Informer().GetIndexer().Add(newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = " + test.tokenFile + "\n"))
+
+			// Run the hooks in the same order as the operator.
+			err = withHypershiftDeploymentHook(true, "hypershift-image")(spec, deployment)
+			if err == nil {
+				err = withTokenMinterHook(true)(spec, deployment)
+			}
+			if err == nil {
+				err = withWebIdentityCredentials(informer.Lister().Secrets(defaultNamespace), defaultSecretName)(spec, deployment)
+			}
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			container := getContainer(&deployment.Spec.Template.Spec, driverContainerName)
+			if tokenFile, _ := getContainerEnv(container, "AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != test.tokenFile {
+				t.Errorf("expected the driver to read the token from %s, got %s", test.tokenFile, tokenFile)
+			}
+		})
+	}
+}
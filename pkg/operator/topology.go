@@ -0,0 +1,162 @@
+package operator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/csi/csistorageclasscontroller"
+)
+
+const (
+	// driverZoneTopologyKey is the topology key of the zones reported by the CSI driver.
+	driverZoneTopologyKey = "topology.ebs.csi.aws.com/zone"
+	// edgeNodeRoleLabel is set by the installer on the nodes in Local Zones and Wavelength Zones.
+	edgeNodeRoleLabel = "node-role.kubernetes.io/edge"
+)
+
+// edgeZoneRegexp matches the names of the Local Zones, e.g. us-east-1-bos-1a, and of the Wavelength Zones,
+// e.g. us-east-1-wl1-bos-wlz-1: the region followed by the location, unlike the availability zones.
+var edgeZoneRegexp = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+-[a-z0-9-]+$`)
+
+// withAllowedTopologiesHook restricts provisioning of the managed StorageClasses to the zones
+// listed in the driver configuration or, with autoZones, to the zones that have nodes. With autoZones, the
+// storageClassController keeps the zones of existing StorageClasses, see deleteOnAllowedTopologiesChange.
+// The StorageClasses have no allowedTopologies when there are no zones.
+func withAllowedTopologiesHook(nodeLister corelisters.NodeLister) csistorageclasscontroller.StorageClassHookFunc {
+	return func(spec *opv1.OperatorSpec, sc *storagev1.StorageClass) error {
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return err
+		}
+		zones := cfg.Zones
+		if cfg.AutoZones {
+			if len(cfg.Zones) > 0 {
+				return fmt.Errorf("invalid driver configuration: zones and autoZones can't be used together")
+			}
+			nodes, err := nodeLister.List(labels.Everything())
+			if err != nil {
+				return err
+			}
+			zones = sortedNodeZones(nodes)
+		}
+		if sc.Parameters["type"] != "gp2" {
+			// Only gp2 volumes can be created in the Local Zones and Wavelength Zones, keep the other
+			// StorageClasses in the availability zones when there are nodes in edge zones.
+			nodes, err := nodeLister.List(labels.Everything())
+			if err != nil {
+				return err
+			}
+			if edgeZones := nodeEdgeZones(nodes); len(edgeZones) > 0 {
+				if len(zones) == 0 {
+					zones = sortedNodeZones(nodes)
+				}
+				if zones = withoutEdgeZones(zones, edgeZones); len(zones) == 0 {
+					return fmt.Errorf("StorageClass %s can't provision volumes in the Local Zones and Wavelength Zones, only gp2 volumes are supported there", sc.Name)
+				}
+			}
+		}
+		if len(zones) == 0 {
+			return nil
+		}
+		sc.AllowedTopologies = []corev1.TopologySelectorTerm{{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+				Key:    driverZoneTopologyKey,
+				Values: append([]string{}, zones...),
+			}},
+		}}
+		return nil
+	}
+}
+
+// addExistingZones adds the zones of the allowedTopologies of the existing StorageClass to the expected one, so
+// zones whose nodes are removed stay allowed. StorageClasses without zones are not changed.
+func addExistingZones(existing, expected *storagev1.StorageClass) {
+	existingZones, expectedZones := allowedZones(existing), allowedZones(expected)
+	if existingZones == nil || expectedZones == nil {
+		return
+	}
+	zones := sets.NewString(existingZones...).Insert(expectedZones...).List()
+	expected.AllowedTopologies[0].MatchLabelExpressions[0].Values = zones
+}
+
+// allowedZones returns the zones of allowedTopologies set by withAllowedTopologiesHook, or nil.
+func allowedZones(sc *storagev1.StorageClass) []string {
+	if len(sc.AllowedTopologies) != 1 || len(sc.AllowedTopologies[0].MatchLabelExpressions) != 1 {
+		return nil
+	}
+	requirement := sc.AllowedTopologies[0].MatchLabelExpressions[0]
+	if requirement.Key != driverZoneTopologyKey {
+		return nil
+	}
+	return requirement.Values
+}
+
+// nodeZones returns the zones of the nodes.
+func nodeZones(nodes []*corev1.Node) map[string]bool {
+	zones := map[string]bool{}
+	for _, node := range nodes {
+		for _, key := range []string{corev1.LabelTopologyZone, driverZoneTopologyKey} {
+			if zone := node.Labels[key]; zone != "" {
+				zones[zone] = true
+			}
+		}
+	}
+	return zones
+}
+
+// sortedNodeZones returns the sorted list of zones of the nodes.
+func sortedNodeZones(nodes []*corev1.Node) []string {
+	var zones []string
+	for zone := range nodeZones(nodes) {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// zonesWithoutNodes returns the sorted list of zones that have no nodes.
+func zonesWithoutNodes(zones []string, nodes []*corev1.Node) []string {
+	existing := nodeZones(nodes)
+	var missing []string
+	for _, zone := range zones {
+		if !existing[zone] {
+			missing = append(missing, zone)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// nodeEdgeZones returns the Local Zones and Wavelength Zones of the nodes: the zones of the edge nodes and the
+// zones named like edge zones.
+func nodeEdgeZones(nodes []*corev1.Node) map[string]bool {
+	zones := map[string]bool{}
+	for _, node := range nodes {
+		_, edge := node.Labels[edgeNodeRoleLabel]
+		for _, key := range []string{corev1.LabelTopologyZone, driverZoneTopologyKey} {
+			if zone := node.Labels[key]; zone != "" && (edge || edgeZoneRegexp.MatchString(zone)) {
+				zones[zone] = true
+			}
+		}
+	}
+	return zones
+}
+
+// withoutEdgeZones returns the zones that are not edge zones.
+func withoutEdgeZones(zones []string, edgeZones map[string]bool) []string {
+	var filtered []string
+	for _, zone := range zones {
+		if !edgeZones[zone] {
+			filtered = append(filtered, zone)
+		}
+	}
+	return filtered
+}
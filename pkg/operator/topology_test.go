@@ -0,0 +1,445 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWithAllowedTopologiesHook(t *testing.T) {
+	tests := []struct {
+		name          string
+		overrides     string
+		expected      []corev1.TopologySelectorTerm
+		expectedError bool
+	}{
+		{
+			name: "no zones",
+		},
+		{
+			name:      "empty zones",
+			overrides: `{"zones": []}`,
+		},
+		{
+			name:      "zones",
+			overrides: `{"zones": ["us-east-1a", "us-east-1b"]}`,
+			expected: []corev1.TopologySelectorTerm{{
+				MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+					Key:    "topology.ebs.csi.aws.com/zone",
+					Values: []string{"us-east-1a", "us-east-1b"},
+				}},
+			}},
+		},
+		{
+			name:      "zones of nodes",
+			overrides: `{"autoZones": true}`,
+			expected: []corev1.TopologySelectorTerm{{
+				MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+					Key:    "topology.ebs.csi.aws.com/zone",
+					Values: []string{"us-east-1a", "us-east-1b"},
+				}},
+			}},
+		},
+		{
+			name:          "zones and autoZones",
+			overrides:     `{"zones": ["us-east-1a"], "autoZones": true}`,
+			expectedError: true,
+		},
+	}
+
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+	nodeInformer.Informer().GetIndexer().Add(newTestNode("node-b", "us-east-1b"))
+	nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a1", "us-east-1a"))
+	nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a2", "us-east-1a"))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			sc := newTestStorageClass("gp3-csi", nil)
+			err := withAllowedTopologiesHook(nodeInformer.Lister())(spec, sc)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, sc.AllowedTopologies; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected allowedTopologies\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
+
+func newTestNode(name, zone string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{corev1.LabelTopologyZone: zone},
+		},
+	}
+}
+
+func TestStorageClassControllerZones(t *testing.T) {
+	existing := newTestStorageClass("gp3-csi", nil)
+	existing.AllowedTopologies = []corev1.TopologySelectorTerm{{
+		MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+			Key:    driverZoneTopologyKey,
+			Values: []string{"us-east-1c"},
+		}},
+	}}
+
+	tests := []struct {
+		name                   string
+		overrides              string
+		expectedZones          []string
+		expectedConditionState opv1.ConditionStatus
+		// expectedExistingZones of the existing gp3-csi StorageClass when it's not re-created.
+		expectedExistingZones []string
+	}{
+		{
+			name:      "no zones",
+			overrides: `{"recreateOnTopologyChange": true}`,
+		},
+		{
+			name:                   "all zones have nodes",
+			overrides:              `{"zones": ["us-east-1a", "us-east-1b"], "recreateOnTopologyChange": true}`,
+			expectedZones:          []string{"us-east-1a", "us-east-1b"},
+			expectedConditionState: opv1.ConditionFalse,
+		},
+		{
+			name:                   "zone without nodes",
+			overrides:              `{"zones": ["us-east-1a", "us-east-1d"], "recreateOnTopologyChange": true}`,
+			expectedZones:          []string{"us-east-1a", "us-east-1d"},
+			expectedConditionState: opv1.ConditionTrue,
+		},
+		{
+			name:                   "existing StorageClass is kept",
+			overrides:              `{"zones": ["us-east-1a", "us-east-1b"]}`,
+			expectedZones:          []string{"us-east-1a", "us-east-1b"},
+			expectedConditionState: opv1.ConditionFalse,
+			expectedExistingZones:  []string{"us-east-1c"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec, existing.DeepCopy())
+			nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a", "us-east-1a"))
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-b", "us-east-1b"))
+			c.nodeLister = nodeInformer.Lister()
+			c.optionalStorageClassHooks = append(c.optionalStorageClassHooks, withAllowedTopologiesHook(c.nodeLister))
+
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			checkAllowedTopologies(t, "gp2-csi", kubeClient, test.expectedZones)
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			outdated := v1helpers.FindOperatorCondition(status.Conditions, "Test"+outdatedTopologyConditionSuffix)
+			if test.expectedExistingZones != nil {
+				checkAllowedTopologies(t, "gp3-csi", kubeClient, test.expectedExistingZones)
+				if outdated == nil || outdated.Status != opv1.ConditionTrue {
+					t.Errorf("expected outdated allowedTopologies, got %#v", outdated)
+				}
+			} else {
+				// The existing gp3-csi StorageClass with different allowedTopologies is re-created.
+				checkAllowedTopologies(t, "gp3-csi", kubeClient, test.expectedZones)
+				if outdated == nil || outdated.Status != opv1.ConditionFalse {
+					t.Errorf("expected no outdated allowedTopologies, got %#v", outdated)
+				}
+			}
+
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+zonesWithoutNodesConditionSuffix)
+			switch {
+			case test.expectedConditionState == "" && cond != nil:
+				t.Errorf("expected no condition, got %#v", cond)
+			case test.expectedConditionState != "" && (cond == nil || cond.Status != test.expectedConditionState):
+				t.Errorf("expected condition status %s, got %#v", test.expectedConditionState, cond)
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerAutoZones(t *testing.T) {
+	newExisting := func(zones []string, annotations map[string]string) *storagev1.StorageClass {
+		sc := newTestStorageClass("gp3-csi", annotations)
+		sc.AllowedTopologies = []corev1.TopologySelectorTerm{{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+				Key:    driverZoneTopologyKey,
+				Values: zones,
+			}},
+		}}
+		return sc
+	}
+
+	tests := []struct {
+		name             string
+		existing         *storagev1.StorageClass
+		expectedGP3Zones []string
+		expectedOutdated opv1.ConditionStatus
+	}{
+		{
+			name:             "zones of nodes are added",
+			existing:         newExisting([]string{"us-east-1c"}, nil),
+			expectedGP3Zones: []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+			expectedOutdated: opv1.ConditionFalse,
+		},
+		{
+			name:             "zones without nodes are kept",
+			existing:         newExisting([]string{"us-east-1a", "us-east-1b", "us-east-1c"}, nil),
+			expectedGP3Zones: []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+			expectedOutdated: opv1.ConditionFalse,
+		},
+		{
+			name:             "default StorageClass is not re-created",
+			existing:         newExisting([]string{"us-east-1c"}, map[string]string{defaultStorageClassAnnotation: "true"}),
+			expectedGP3Zones: []string{"us-east-1c"},
+			expectedOutdated: opv1.ConditionTrue,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			spec.UnsupportedConfigOverrides.Raw = []byte(`{"autoZones": true, "recreateOnTopologyChange": true}`)
+			c, kubeClient := newTestStorageClassController(spec, test.existing)
+			nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a", "us-east-1a"))
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-b", "us-east-1b"))
+			c.nodeLister = nodeInformer.Lister()
+			c.optionalStorageClassHooks = append(c.optionalStorageClassHooks, withAllowedTopologiesHook(c.nodeLister))
+
+			if err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			checkAllowedTopologies(t, "gp2-csi", kubeClient, []string{"us-east-1a", "us-east-1b"})
+			checkAllowedTopologies(t, "gp3-csi", kubeClient, test.expectedGP3Zones)
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+outdatedTopologyConditionSuffix)
+			if cond == nil || cond.Status != test.expectedOutdated {
+				t.Errorf("expected condition status %s, got %#v", test.expectedOutdated, cond)
+			}
+		})
+	}
+}
+
+func checkAllowedTopologies(t *testing.T, name string, kubeClient *fake.Clientset, expectedZones []string) {
+	t.Helper()
+	sc, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get StorageClass %s: %v", name, err)
+	}
+	if len(expectedZones) == 0 {
+		if sc.AllowedTopologies != nil {
+			t.Errorf("StorageClass %s: expected no allowedTopologies, got %#v", name, sc.AllowedTopologies)
+		}
+		return
+	}
+	expected := []corev1.TopologySelectorTerm{{
+		MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+			Key:    driverZoneTopologyKey,
+			Values: expectedZones,
+		}},
+	}}
+	if !equality.Semantic.DeepEqual(expected, sc.AllowedTopologies) {
+		t.Errorf("StorageClass %s: unexpected allowedTopologies\nwant=%#v\ngot= %#v", name, expected, sc.AllowedTopologies)
+	}
+}
+
+func TestStorageClassControllerZoneStorageClasses(t *testing.T) {
+	managed := map[string]string{managedAnnotation: "true"}
+	staleSC := newTestStorageClass("gp3-csi-us-east-1c", map[string]string{managedAnnotation: "true", zoneAnnotation: "us-east-1c"})
+	userSC := newTestStorageClass("gp3-csi-us-east-1d", managed)
+
+	tests := []struct {
+		name            string
+		overrides       string
+		expectedPresent []string
+		expectedAbsent  []string
+		expectedZones   map[string]string
+		expectedError   bool
+	}{
+		{
+			name:            "no zone StorageClasses",
+			expectedPresent: []string{"gp3-csi-us-east-1d"},
+			expectedAbsent:  []string{"gp3-csi-us-east-1a", "gp3-csi-us-east-1b", "gp3-csi-us-east-1c"},
+		},
+		{
+			name:            "zones of nodes",
+			overrides:       `{"zoneStorageClasses": ["gp3"]}`,
+			expectedPresent: []string{"gp3-csi-us-east-1a", "gp3-csi-us-east-1b", "gp3-csi-us-east-1d"},
+			expectedAbsent:  []string{"gp3-csi-us-east-1c", "gp2-csi-us-east-1a"},
+			expectedZones: map[string]string{
+				"gp3-csi-us-east-1a": "us-east-1a",
+				"gp3-csi-us-east-1b": "us-east-1b",
+			},
+		},
+		{
+			name:            "configured zones",
+			overrides:       `{"zones": ["us-east-1b", "us-east-1c"], "zoneStorageClasses": ["gp2"]}`,
+			expectedPresent: []string{"gp2-csi-us-east-1b", "gp2-csi-us-east-1c"},
+			expectedAbsent:  []string{"gp2-csi-us-east-1a", "gp3-csi-us-east-1c"},
+			expectedZones: map[string]string{
+				"gp2-csi-us-east-1b": "us-east-1b",
+				"gp2-csi-us-east-1c": "us-east-1c",
+			},
+		},
+		{
+			name:          "unknown StorageClass",
+			overrides:     `{"zoneStorageClasses": ["io1"]}`,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec, staleSC.DeepCopy(), userSC.DeepCopy())
+			nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a", "us-east-1a"))
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-b", "us-east-1b"))
+			c.nodeLister = nodeInformer.Lister()
+
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, name := range test.expectedPresent {
+				if _, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+					t.Errorf("expected StorageClass %s: %v", name, err)
+				}
+			}
+			for _, name := range test.expectedAbsent {
+				if _, err := kubeClient.StorageV1().StorageClasses().Get(context.TODO(), name, metav1.GetOptions{}); err == nil {
+					t.Errorf("expected StorageClass %s to be absent", name)
+				}
+			}
+			for name, zone := range test.expectedZones {
+				checkAllowedTopologies(t, name, kubeClient, []string{zone})
+			}
+		})
+	}
+}
+
+func TestStorageClassControllerEdgeZones(t *testing.T) {
+	tests := []struct {
+		name                   string
+		overrides              string
+		edgeNodes              []*corev1.Node
+		expectedGP2Zones       []string
+		expectedGP3Zones       []string
+		expectedConditionState opv1.ConditionStatus
+		expectedError          bool
+	}{
+		{
+			name: "no edge zones",
+		},
+		{
+			name:                   "Local Zone",
+			edgeNodes:              []*corev1.Node{newTestNode("node-nyc", "us-east-1-nyc-1a")},
+			expectedGP3Zones:       []string{"us-east-1a", "us-east-1b"},
+			expectedConditionState: opv1.ConditionTrue,
+		},
+		{
+			name: "Wavelength Zone of an edge node",
+			edgeNodes: func() []*corev1.Node {
+				node := newTestNode("node-wlz", "us-east-1-wl1-bos-wlz-1")
+				node.Labels[edgeNodeRoleLabel] = ""
+				return []*corev1.Node{node}
+			}(),
+			expectedGP3Zones:       []string{"us-east-1a", "us-east-1b"},
+			expectedConditionState: opv1.ConditionTrue,
+		},
+		{
+			name:                   "gp2 default",
+			overrides:              `{"defaultStorageClass": "gp2"}`,
+			edgeNodes:              []*corev1.Node{newTestNode("node-nyc", "us-east-1-nyc-1a")},
+			expectedGP3Zones:       []string{"us-east-1a", "us-east-1b"},
+			expectedConditionState: opv1.ConditionFalse,
+		},
+		{
+			name:                   "zones",
+			overrides:              `{"zones": ["us-east-1a", "us-east-1-nyc-1a"]}`,
+			edgeNodes:              []*corev1.Node{newTestNode("node-nyc", "us-east-1-nyc-1a")},
+			expectedGP2Zones:       []string{"us-east-1a", "us-east-1-nyc-1a"},
+			expectedGP3Zones:       []string{"us-east-1a"},
+			expectedConditionState: opv1.ConditionTrue,
+		},
+		{
+			name:          "only edge zones",
+			overrides:     `{"zones": ["us-east-1-nyc-1a"]}`,
+			edgeNodes:     []*corev1.Node{newTestNode("node-nyc", "us-east-1-nyc-1a")},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			c, kubeClient := newTestStorageClassController(spec)
+			nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a", "us-east-1a"))
+			nodeInformer.Informer().GetIndexer().Add(newTestNode("node-b", "us-east-1b"))
+			for _, node := range test.edgeNodes {
+				nodeInformer.Informer().GetIndexer().Add(node)
+			}
+			c.nodeLister = nodeInformer.Lister()
+			c.optionalStorageClassHooks = append(c.optionalStorageClassHooks, withAllowedTopologiesHook(c.nodeLister))
+
+			err := c.sync(context.TODO(), factory.NewSyncContext("test", c.eventRecorder))
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkAllowedTopologies(t, "gp2-csi", kubeClient, test.expectedGP2Zones)
+			checkAllowedTopologies(t, "gp3-csi", kubeClient, test.expectedGP3Zones)
+
+			_, status, _, _ := c.operatorClient.GetOperatorState()
+			cond := v1helpers.FindOperatorCondition(status.Conditions, "Test"+edgeZonesConditionSuffix)
+			switch {
+			case test.expectedConditionState == "" && cond != nil:
+				t.Errorf("expected no condition, got %#v", cond)
+			case test.expectedConditionState != "" && (cond == nil || cond.Status != test.expectedConditionState):
+				t.Errorf("expected condition status %s, got %#v", test.expectedConditionState, cond)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package operator
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+	"k8s.io/component-base/tracing"
+	tracingapi "k8s.io/component-base/tracing/api/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+)
+
+// tracerName is the name of the tracer of the spans of the operator.
+const tracerName = "github.com/openshift/aws-ebs-csi-driver-operator"
+
+// startTracing sets the global TracerProvider to export the spans of the operator to the OTLP collector of the
+// configuration. The returned function flushes the spans on shutdown.
+func startTracing(ctx context.Context, config *tracingapi.TracingConfiguration) (func(), error) {
+	provider, err := tracing.NewProvider(ctx, config, nil, []resource.Option{
+		resource.WithAttributes(semconv.ServiceNameKey.String(operatorName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		sdkProvider, ok := provider.(*sdktrace.TracerProvider)
+		if !ok {
+			return
+		}
+		// The context of the operator is done on shutdown.
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := sdkProvider.Shutdown(ctx); err != nil {
+			klog.Warningf("Failed to flush the spans: %v", err)
+		}
+	}, nil
+}
+
+// traceKubeConfig wraps the transport of the kubeconfig, so that the API calls are child spans of the syncs.
+func traceKubeConfig(kubeConfig *rest.Config) {
+	kubeConfig.Wrap(tracing.WrapperFor(otel.GetTracerProvider()))
+}
+
+// traceSync records a span per sync of the controller. The calls of the sync to the API servers and AWS are its
+// child spans.
+func traceSync(name string, sync factory.SyncFunc) factory.SyncFunc {
+	return func(ctx context.Context, syncCtx factory.SyncContext) error {
+		ctx, span := otel.Tracer(tracerName).Start(ctx, name,
+			trace.WithAttributes(attribute.String("controller", name), attribute.String("queueKey", syncCtx.QueueKey())))
+		err := sync(ctx, syncCtx)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// traceAWSTransport records a span per call to AWS, named after the host of the service, e.g. AWS
+// ec2.us-east-1.amazonaws.com. The trace context is not propagated to AWS.
+func traceAWSTransport(transport http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(transport,
+		otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "AWS " + r.URL.Host
+		}),
+	)
+}
+
+// hookChainTracer records the hooks of a workload as child spans of a span of the whole chain. The controller of
+// the workload runs the hooks one after the other, from the first one to the last one or the first one that fails,
+// and never runs two chains at the same time. The hooks have no context, so the span of the chain has no parent.
+type hookChainTracer struct {
+	workload string
+	hooks    int
+
+	chainCtx  context.Context
+	chainSpan trace.Span
+}
+
+func newHookChainTracer(workload string, hooks int) *hookChainTracer {
+	return &hookChainTracer{workload: workload, hooks: hooks}
+}
+
+// trace runs the hook at the index of the chain in its span.
+func (t *hookChainTracer) trace(index int, name string, hook func() error) error {
+	tracer := otel.Tracer(tracerName)
+	if index == 0 || t.chainSpan == nil {
+		t.chainCtx, t.chainSpan = tracer.Start(context.Background(), t.workload+" hooks",
+			trace.WithAttributes(attribute.String("workload", t.workload)))
+	}
+	_, span := tracer.Start(t.chainCtx, name, trace.WithAttributes(attribute.String("hook", name)))
+	err := hook()
+	endSpan(span, err)
+	if err != nil || index == t.hooks-1 {
+		endSpan(t.chainSpan, err)
+		t.chainCtx, t.chainSpan = nil, nil
+	}
+	return err
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
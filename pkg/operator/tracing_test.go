@@ -0,0 +1,128 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	appsv1 "k8s.io/api/apps/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// testSpanRecorder records the ended spans.
+type testSpanRecorder struct {
+	lock  sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (r *testSpanRecorder) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (r *testSpanRecorder) OnEnd(s sdktrace.ReadOnlySpan) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *testSpanRecorder) Shutdown(context.Context) error   { return nil }
+func (r *testSpanRecorder) ForceFlush(context.Context) error { return nil }
+
+func (r *testSpanRecorder) span(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	for _, span := range r.spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("expected span %s", name)
+	return nil
+}
+
+func newTestSpanRecorder(t *testing.T) *testSpanRecorder {
+	recorder := &testSpanRecorder{}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+	return recorder
+}
+
+func TestTraceSync(t *testing.T) {
+	recorder := newTestSpanRecorder(t)
+
+	syncErr := errors.New("sync failed")
+	sync := traceSync("TestController", func(ctx context.Context, syncCtx factory.SyncContext) error {
+		_, span := otel.Tracer(tracerName).Start(ctx, "API call")
+		span.End()
+		return syncErr
+	})
+	if err := sync(context.TODO(), factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))); err != syncErr {
+		t.Fatalf("expected the error of the sync, got %v", err)
+	}
+
+	syncSpan := recorder.span(t, "TestController")
+	if syncSpan.StatusCode() != codes.Error {
+		t.Errorf("expected the sync span to have an error status, got %v", syncSpan.StatusCode())
+	}
+	if callSpan := recorder.span(t, "API call"); callSpan.Parent().SpanID() != syncSpan.SpanContext().SpanID() {
+		t.Errorf("expected the API call to be a child span of the sync")
+	}
+}
+
+func TestInstrumentDeploymentHooksTracing(t *testing.T) {
+	recorder := newTestSpanRecorder(t)
+
+	var failHook bool
+	hooks := instrumentDeploymentHooks("test-workload",
+		withTestHook(func() error { return nil }),
+		withTestHook(func() error {
+			if failHook {
+				return errors.New("hook failed")
+			}
+			return nil
+		}),
+		withTestHook(func() error { return nil }),
+	)
+	runHooks := func() {
+		for _, hook := range hooks {
+			if err := hook(&opv1.OperatorSpec{}, &appsv1.Deployment{}); err != nil {
+				return
+			}
+		}
+	}
+
+	runHooks()
+	if len(recorder.spans) != 4 {
+		t.Fatalf("expected spans of the 3 hooks and of the chain, got %d", len(recorder.spans))
+	}
+	chain := recorder.span(t, "test-workload hooks")
+	for _, span := range recorder.spans[:3] {
+		if span.Parent().SpanID() != chain.SpanContext().SpanID() {
+			t.Errorf("expected span %s to be a child span of the chain", span.Name())
+		}
+	}
+
+	recorder.spans = nil
+	failHook = true
+	runHooks()
+	if len(recorder.spans) != 3 {
+		t.Fatalf("expected spans of the 2 hooks and of the chain, got %d", len(recorder.spans))
+	}
+	if chain := recorder.span(t, "test-workload hooks"); chain.StatusCode() != codes.Error {
+		t.Errorf("expected the chain to have an error status, got %v", chain.StatusCode())
+	}
+}
+
+// withTestHook returns a hook that runs fn.
+func withTestHook(fn func() error) dc.DeploymentHookFunc {
+	return func(*opv1.OperatorSpec, *appsv1.Deployment) error {
+		return fn()
+	}
+}
@@ -0,0 +1,113 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+var volumeAttributesClassGVR = schema.GroupVersionResource{
+	Group:    "storage.k8s.io",
+	Version:  "v1beta1",
+	Resource: "volumeattributesclasses",
+}
+
+// volumeAttributesClassController creates the default VolumeAttributesClasses of the driver when the
+// VolumeAttributesClass feature gate is enabled, so gp3 volumes can be switched between the baseline
+// and a high performance IOPS and throughput without re-creating them.
+// The parameters of a VolumeAttributesClass are immutable, so existing VolumeAttributesClasses are not updated.
+//
+// It produces the following conditions:
+// <name>Degraded: produced when the sync() method returns an error.
+type volumeAttributesClassController struct {
+	operatorClient    v1helpers.OperatorClient
+	vacClient         dynamic.ResourceInterface
+	featureGateLister configlisters.FeatureGateLister
+	assetFunc         resourceapply.AssetFunc
+	files             []string
+}
+
+func newVolumeAttributesClassController(
+	name string,
+	operatorClient v1helpers.OperatorClient,
+	dynamicClient dynamic.Interface,
+	featureGateInformer configinformersv1.FeatureGateInformer,
+	assetFunc resourceapply.AssetFunc,
+	files []string,
+	eventRecorder events.Recorder,
+) factory.Controller {
+	c := &volumeAttributesClassController{
+		operatorClient:    operatorClient,
+		vacClient:         dynamicClient.Resource(volumeAttributesClassGVR),
+		featureGateLister: featureGateInformer.Lister(),
+		assetFunc:         assetFunc,
+		files:             files,
+	}
+	return factory.New().WithSync(
+		traceSync(name, c.sync),
+	).ResyncEvery(
+		time.Minute,
+	).WithSyncDegradedOnError(
+		operatorClient,
+	).WithInformers(
+		operatorClient.Informer(),
+		featureGateInformer.Informer(),
+	).ToController(
+		name,
+		eventRecorder,
+	)
+}
+
+func (c *volumeAttributesClassController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	opSpec, _, _, err := c.operatorClient.GetOperatorState()
+	if err != nil {
+		return err
+	}
+	if opSpec.ManagementState != opv1.Managed {
+		return nil
+	}
+	enabled, err := featureGateEnabled(c.featureGateLister, volumeAttributesClassFeatureGate)
+	if err != nil || !enabled {
+		return err
+	}
+
+	for _, file := range c.files {
+		content, err := c.assetFunc(file)
+		if err != nil {
+			return err
+		}
+		required := resourceread.ReadUnstructuredOrDie(content)
+		_, err = c.vacClient.Get(ctx, required.GetName(), metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if meta.IsNoMatchError(err) {
+			return fmt.Errorf("the %s feature gate is enabled, but the VolumeAttributesClass API is not available: %w", volumeAttributesClassFeatureGate, err)
+		}
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if _, err := c.vacClient.Create(ctx, required, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create VolumeAttributesClass %s: %w", required.GetName(), err)
+		}
+		klog.V(2).Infof("Created VolumeAttributesClass %s", required.GetName())
+		syncCtx.Recorder().Eventf("VolumeAttributesClassCreated", "Created VolumeAttributesClass %s", required.GetName())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package operator
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+)
+
+const (
+	volumeAttributesClassFeatureGate = "VolumeAttributesClass"
+	volumeModifierImageEnvName       = "VOLUME_MODIFIER_IMAGE"
+	volumeModifierContainerName      = "csi-volumemodifier"
+)
+
+// volumeModifierRBACFiles are the RBAC assets of the volume modifier and of the sidecars that read
+// VolumeAttributesClasses.
+var volumeModifierRBACFiles = []string{
+	"rbac/volumemodifier_role.yaml",
+	"rbac/volumemodifier_binding.yaml",
+}
+
+// withVolumeModifierHook enables online modification of volumes when the VolumeAttributesClass feature gate is
+// enabled: it deploys the volume modifier sidecar with the given image and enables the feature gate in the
+// provisioner and the resizer. Otherwise, the volume modifier sidecar is removed from the controller Deployment.
+func withVolumeModifierHook(featureGateLister configlisters.FeatureGateLister, image string) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		podSpec := &deployment.Spec.Template.Spec
+		enabled, err := featureGateEnabled(featureGateLister, volumeAttributesClassFeatureGate)
+		if err != nil {
+			return err
+		}
+		if !enabled || image == "" {
+			filtered := []corev1.Container{}
+			for i := range podSpec.Containers {
+				if podSpec.Containers[i].Name != volumeModifierContainerName {
+					filtered = append(filtered, podSpec.Containers[i])
+				}
+			}
+			podSpec.Containers = filtered
+			return nil
+		}
+
+		container := getContainer(podSpec, volumeModifierContainerName)
+		if container == nil {
+			return fmt.Errorf("could not enable volume modification because the %s container is missing", volumeModifierContainerName)
+		}
+		container.Image = image
+		for _, name := range []string{"csi-provisioner", "csi-resizer"} {
+			// The resizer may be disabled.
+			if sidecar := getContainer(podSpec, name); sidecar != nil {
+				addContainerFeatureGate(sidecar, volumeAttributesClassFeatureGate)
+			}
+		}
+		return nil
+	}
+}
+
+// volumeModifierEnabled returns a function that reports whether the volume modifier is deployed.
+// It's used to create the RBAC assets of the volume modifier.
+func volumeModifierEnabled(featureGateLister configlisters.FeatureGateLister, image string) resourceapply.ConditionalFunction {
+	gateEnabled := featureGateEnabledFunc(featureGateLister, volumeAttributesClassFeatureGate)
+	return func() bool {
+		return image != "" && gateEnabled()
+	}
+}
@@ -0,0 +1,144 @@
+package operator
+
+import (
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	opv1 "github.com/openshift/api/operator/v1"
+	fakeconfig "github.com/openshift/client-go/config/clientset/versioned/fake"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	configlisters "github.com/openshift/client-go/config/listers/config/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testVolumeModifierImage = "quay.io/example/volume-modifier-for-k8s:latest"
+
+func newTestFeatureGateLister(featureGate *configv1.FeatureGate) configlisters.FeatureGateLister {
+	informer := configinformers.NewSharedInformerFactory(fakeconfig.NewSimpleClientset(), 0).Config().V1().FeatureGates()
+	if featureGate != nil {
+		informer.Informer().GetIndexer().Add(featureGate)
+	}
+	return informer.Lister()
+}
+
+func newTestFeatureGate(featureSet configv1.FeatureSet, enabled, disabled []string) *configv1.FeatureGate {
+	featureGate := &configv1.FeatureGate{
+		ObjectMeta: metav1.ObjectMeta{Name: featureGateName},
+	}
+	featureGate.Spec.FeatureSet = featureSet
+	if featureSet == configv1.CustomNoUpgrade {
+		featureGate.Spec.CustomNoUpgrade = &configv1.CustomFeatureGates{Enabled: enabled, Disabled: disabled}
+	}
+	return featureGate
+}
+
+func TestFeatureGateEnabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		featureGate *configv1.FeatureGate
+		expected    bool
+	}{
+		{
+			name: "no FeatureGate",
+		},
+		{
+			name:        "default feature set",
+			featureGate: newTestFeatureGate(configv1.Default, nil, nil),
+		},
+		{
+			name:        "tech preview",
+			featureGate: newTestFeatureGate(configv1.TechPreviewNoUpgrade, nil, nil),
+			expected:    true,
+		},
+		{
+			name:        "custom enabled",
+			featureGate: newTestFeatureGate(configv1.CustomNoUpgrade, []string{volumeAttributesClassFeatureGate}, nil),
+			expected:    true,
+		},
+		{
+			name:        "custom enabled and disabled",
+			featureGate: newTestFeatureGate(configv1.CustomNoUpgrade, []string{volumeAttributesClassFeatureGate}, []string{volumeAttributesClassFeatureGate}),
+		},
+		{
+			name:        "custom without the feature gate",
+			featureGate: newTestFeatureGate(configv1.CustomNoUpgrade, []string{"Foo"}, nil),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			enabled, err := featureGateEnabled(newTestFeatureGateLister(test.featureGate), volumeAttributesClassFeatureGate)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if enabled != test.expected {
+				t.Errorf("expected enabled=%v, got %v", test.expected, enabled)
+			}
+		})
+	}
+}
+
+func TestWithVolumeModifierHook(t *testing.T) {
+	newDeployment := func(modifier bool, provisionerArgs, resizerArgs []string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		podSpec := &deployment.Spec.Template.Spec
+		podSpec.Containers = []corev1.Container{
+			{Name: "csi-driver"},
+			{Name: "csi-provisioner", Args: provisionerArgs},
+			{Name: "csi-resizer", Args: resizerArgs},
+		}
+		if modifier {
+			podSpec.Containers = append(podSpec.Containers, corev1.Container{Name: volumeModifierContainerName, Image: "${VOLUME_MODIFIER_IMAGE}"})
+		}
+		return deployment
+	}
+	provisionerArgs := []string{"--csi-address=$(ADDRESS)", "--feature-gates=Topology=true"}
+	resizerArgs := []string{"--csi-address=$(ADDRESS)"}
+
+	enabledDeployment := newDeployment(false,
+		[]string{"--csi-address=$(ADDRESS)", "--feature-gates=Topology=true,VolumeAttributesClass=true"},
+		[]string{"--csi-address=$(ADDRESS)", "--feature-gates=VolumeAttributesClass=true"},
+	)
+	enabledDeployment.Spec.Template.Spec.Containers = append(enabledDeployment.Spec.Template.Spec.Containers,
+		corev1.Container{Name: volumeModifierContainerName, Image: testVolumeModifierImage})
+
+	tests := []struct {
+		name        string
+		featureGate *configv1.FeatureGate
+		image       string
+		expected    *appsv1.Deployment
+	}{
+		{
+			name:     "feature gate disabled",
+			image:    testVolumeModifierImage,
+			expected: newDeployment(false, provisionerArgs, resizerArgs),
+		},
+		{
+			name:        "no image",
+			featureGate: newTestFeatureGate(configv1.TechPreviewNoUpgrade, nil, nil),
+			expected:    newDeployment(false, provisionerArgs, resizerArgs),
+		},
+		{
+			name:        "feature gate enabled",
+			featureGate: newTestFeatureGate(configv1.TechPreviewNoUpgrade, nil, nil),
+			image:       testVolumeModifierImage,
+			expected:    enabledDeployment,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deployment := newDeployment(true, provisionerArgs, resizerArgs)
+			err := withVolumeModifierHook(newTestFeatureGateLister(test.featureGate), test.image)(&opv1.OperatorSpec{}, deployment)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, deployment; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
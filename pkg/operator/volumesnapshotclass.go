@@ -0,0 +1,94 @@
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+const (
+	volumeSnapshotClassFile = "volumesnapshotclass.yaml"
+	// fsrParameter is the VolumeSnapshotClass parameter with the zones of Fast Snapshot Restore.
+	fsrParameter = "fastSnapshotRestoreAvailabilityZones"
+)
+
+// withVolumeSnapshotClassConfig returns an AssetFunc that renders the VolumeSnapshotClass asset with the
+// deletion policy, parameters and Fast Snapshot Restore zones from the driver configuration. The zones must
+// have nodes. Other assets are returned unchanged.
+func withVolumeSnapshotClassConfig(operatorClient v1helpers.OperatorClient, nodeLister corelisters.NodeLister, assetFunc resourceapply.AssetFunc) resourceapply.AssetFunc {
+	return func(name string) ([]byte, error) {
+		data, err := assetFunc(name)
+		if err != nil || name != volumeSnapshotClassFile {
+			return data, err
+		}
+		spec, _, _, err := operatorClient.GetOperatorState()
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := getDriverConfig(spec)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.VolumeSnapshotClass == nil {
+			return data, nil
+		}
+		if len(cfg.VolumeSnapshotClass.FastSnapshotRestoreAvailabilityZones) > 0 {
+			nodes, err := nodeLister.List(labels.Everything())
+			if err != nil {
+				return nil, err
+			}
+			missing := zonesWithoutNodes(cfg.VolumeSnapshotClass.FastSnapshotRestoreAvailabilityZones, nodes)
+			if len(missing) > 0 {
+				return nil, fmt.Errorf("invalid volumeSnapshotClass %s: zones %s have no nodes", fsrParameter, strings.Join(missing, ", "))
+			}
+		}
+		return renderVolumeSnapshotClass(data, cfg.VolumeSnapshotClass)
+	}
+}
+
+func renderVolumeSnapshotClass(data []byte, vscConfig *volumeSnapshotClassConfig) ([]byte, error) {
+	switch vscConfig.DeletionPolicy {
+	case "", "Delete", "Retain":
+	default:
+		return nil, fmt.Errorf("invalid volumeSnapshotClass deletionPolicy %q: it must be Delete or Retain", vscConfig.DeletionPolicy)
+	}
+	for key := range vscConfig.Parameters {
+		if key == "" {
+			return nil, fmt.Errorf("invalid volumeSnapshotClass parameters: parameter name must not be empty")
+		}
+		if key == fsrParameter && len(vscConfig.FastSnapshotRestoreAvailabilityZones) > 0 {
+			return nil, fmt.Errorf("invalid volumeSnapshotClass parameters: %s is set from %s", key, fsrParameter)
+		}
+	}
+
+	jsonData, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	vsc := map[string]interface{}{}
+	if err := json.Unmarshal(jsonData, &vsc); err != nil {
+		return nil, err
+	}
+	if vscConfig.DeletionPolicy != "" {
+		vsc["deletionPolicy"] = vscConfig.DeletionPolicy
+	}
+	parameters := map[string]string{}
+	for key, value := range vscConfig.Parameters {
+		parameters[key] = value
+	}
+	if len(vscConfig.FastSnapshotRestoreAvailabilityZones) > 0 {
+		parameters[fsrParameter] = strings.Join(vscConfig.FastSnapshotRestoreAvailabilityZones, ",")
+	}
+	if len(parameters) > 0 {
+		vsc["parameters"] = parameters
+	}
+	// JSON is valid YAML.
+	return json.Marshal(vsc)
+}
@@ -0,0 +1,97 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestWithVolumeSnapshotClassConfig(t *testing.T) {
+	tests := []struct {
+		name               string
+		overrides          string
+		expectedPolicy     string
+		expectedParameters map[string]interface{}
+		expectedError      bool
+	}{
+		{
+			name:           "default",
+			expectedPolicy: "Delete",
+		},
+		{
+			name:           "retain with parameters",
+			overrides:      `{"volumeSnapshotClass": {"deletionPolicy": "Retain", "parameters": {"tagSpecification_1": "backup=true"}}}`,
+			expectedPolicy: "Retain",
+			expectedParameters: map[string]interface{}{
+				"tagSpecification_1": "backup=true",
+			},
+		},
+		{
+			name:           "fast snapshot restore",
+			overrides:      `{"volumeSnapshotClass": {"fastSnapshotRestoreAvailabilityZones": ["us-east-1a", "us-east-1b"]}}`,
+			expectedPolicy: "Delete",
+			expectedParameters: map[string]interface{}{
+				"fastSnapshotRestoreAvailabilityZones": "us-east-1a,us-east-1b",
+			},
+		},
+		{
+			name:          "fast snapshot restore in zone without nodes",
+			overrides:     `{"volumeSnapshotClass": {"fastSnapshotRestoreAvailabilityZones": ["us-east-1a", "us-east-1c"]}}`,
+			expectedError: true,
+		},
+		{
+			name:          "fast snapshot restore in parameters and zones",
+			overrides:     `{"volumeSnapshotClass": {"parameters": {"fastSnapshotRestoreAvailabilityZones": "us-east-1b"}, "fastSnapshotRestoreAvailabilityZones": ["us-east-1a"]}}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid deletion policy",
+			overrides:     `{"volumeSnapshotClass": {"deletionPolicy": "Keep"}}`,
+			expectedError: true,
+		},
+	}
+
+	nodeInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Nodes()
+	nodeInformer.Informer().GetIndexer().Add(newTestNode("node-a", "us-east-1a"))
+	nodeInformer.Informer().GetIndexer().Add(newTestNode("node-b", "us-east-1b"))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &opv1.OperatorSpec{}
+			if test.overrides != "" {
+				spec.UnsupportedConfigOverrides.Raw = []byte(test.overrides)
+			}
+			operatorClient := v1helpers.NewFakeOperatorClient(spec, &opv1.OperatorStatus{}, nil)
+			data, err := withVolumeSnapshotClassConfig(operatorClient, nodeInformer.Lister(), assets.ReadFile)(volumeSnapshotClassFile)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			vsc := resourceread.ReadUnstructuredOrDie(data)
+			if vsc.GetName() != "csi-aws-vsc" || vsc.GetAnnotations()[managedAnnotation] != "true" {
+				t.Errorf("unexpected metadata: %#v", vsc.Object["metadata"])
+			}
+			if policy, _, _ := unstructured.NestedString(vsc.Object, "deletionPolicy"); policy != test.expectedPolicy {
+				t.Errorf("expected deletionPolicy %s, got %s", test.expectedPolicy, policy)
+			}
+			parameters, _, _ := unstructured.NestedMap(vsc.Object, "parameters")
+			if e, a := test.expectedParameters, parameters; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected parameters\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
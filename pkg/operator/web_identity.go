@@ -0,0 +1,153 @@
+package operator
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/utils/pointer"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	dc "github.com/openshift/library-go/pkg/operator/deploymentcontroller"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/pkg/awsclient"
+)
+
+const (
+	boundSATokenVolumeName = "bound-sa-token"
+	boundSATokenMountPath  = "/var/run/secrets/openshift/serviceaccount"
+	boundSATokenAudience   = "openshift"
+	boundSATokenExpiration = 3600
+)
+
+// roleARNRegexp matches the ARNs of IAM roles in all partitions.
+var roleARNRegexp = regexp.MustCompile(`^arn:aws[a-z-]*:iam::[0-9]{12}:role/[\w+=,.@/-]+$`)
+
+// webIdentityConfig is the web identity configuration of the default profile of a shared credentials file.
+type webIdentityConfig struct {
+	roleARN     string
+	tokenFile   string
+	sessionName string
+}
+
+// getWebIdentityConfig returns the web identity configuration of the credentials Secret, and false when the
+// Secret carries other credentials. The configuration is validated.
+func getWebIdentityConfig(secret *corev1.Secret) (*webIdentityConfig, bool, error) {
+	profile, ok := awsclient.ParseSharedConfig(secret.Data["credentials"])["default"]
+	if !ok || (profile["role_arn"] == "" && profile["web_identity_token_file"] == "") {
+		return nil, false, nil
+	}
+	cfg := &webIdentityConfig{
+		roleARN:     profile["role_arn"],
+		tokenFile:   profile["web_identity_token_file"],
+		sessionName: profile["role_session_name"],
+	}
+	switch {
+	case cfg.roleARN == "":
+		return nil, true, fmt.Errorf("credentials Secret %s/%s sets web_identity_token_file without role_arn", secret.Namespace, secret.Name)
+	case cfg.tokenFile == "":
+		// role_arn without a token is role chaining with source_profile, which the SDK handles from the file.
+		return nil, false, nil
+	case !roleARNRegexp.MatchString(cfg.roleARN):
+		return nil, true, fmt.Errorf("credentials Secret %s/%s has a malformed role_arn %q, expected arn:aws:iam::<account ID>:role/<name>", secret.Namespace, secret.Name, cfg.roleARN)
+	case path.Dir(cfg.tokenFile) != boundSATokenMountPath:
+		return nil, true, fmt.Errorf("credentials Secret %s/%s has web_identity_token_file %q outside of %s, where the service account token is mounted",
+			secret.Namespace, secret.Name, cfg.tokenFile, boundSATokenMountPath)
+	}
+	return cfg, true, nil
+}
+
+// withWebIdentityCredentials configures the csi-driver container for STS web identity credentials when the
+// credentials Secret has a role_arn and a web_identity_token_file: the role and the token file are passed
+// in AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE, the static key variables are dropped, and the projected
+// service account token is mounted at the token file path. The token volume of Hypershift, filled by the
+// token minter, is kept as is, and the token minter must write the token file. Static credentials are left
+// untouched.
+func withWebIdentityCredentials(secretLister corev1listers.SecretNamespaceLister, secretName string) dc.DeploymentHookFunc {
+	return func(_ *opv1.OperatorSpec, deployment *appsv1.Deployment) error {
+		secret, err := secretLister.Get(secretName)
+		if apierrors.IsNotFound(err) {
+			// The driver waits for the Secret, there is nothing to configure yet.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cfg, ok, err := getWebIdentityConfig(secret)
+		if err != nil || !ok {
+			return err
+		}
+
+		podSpec := &deployment.Spec.Template.Spec
+		container := getContainer(podSpec, driverContainerName)
+		if container == nil {
+			return fmt.Errorf("could not configure web identity credentials because the csi-driver container is missing from the deployment")
+		}
+		// On Hypershift, the token file is written by the token minter, which is configured apart from the Secret.
+		if minter := getContainer(podSpec, tokenMinterContainerName); minter != nil {
+			if tokenFile, _ := getContainerArg(minter, "token-file"); tokenFile != cfg.tokenFile {
+				return fmt.Errorf("credentials Secret %s/%s has web_identity_token_file %q, but the token minter writes the token to %q, tokenMinter tokenFile must match it",
+					secret.Namespace, secret.Name, cfg.tokenFile, tokenFile)
+			}
+		}
+		env := container.Env[:0]
+		for _, e := range container.Env {
+			if e.Name != "AWS_ACCESS_KEY_ID" && e.Name != "AWS_SECRET_ACCESS_KEY" {
+				env = append(env, e)
+			}
+		}
+		container.Env = env
+		setContainerEnv(container, "AWS_ROLE_ARN", cfg.roleARN)
+		setContainerEnv(container, "AWS_WEB_IDENTITY_TOKEN_FILE", cfg.tokenFile)
+		if cfg.sessionName != "" {
+			setContainerEnv(container, "AWS_ROLE_SESSION_NAME", cfg.sessionName)
+		}
+
+		mounted := false
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == boundSATokenVolumeName {
+				mounted = true
+			}
+		}
+		if !mounted {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      boundSATokenVolumeName,
+				MountPath: boundSATokenMountPath,
+				ReadOnly:  true,
+			})
+		}
+		for i := range podSpec.Volumes {
+			volume := &podSpec.Volumes[i]
+			if volume.Name != boundSATokenVolumeName {
+				continue
+			}
+			if volume.Projected != nil {
+				for j := range volume.Projected.Sources {
+					if token := volume.Projected.Sources[j].ServiceAccountToken; token != nil {
+						token.Path = path.Base(cfg.tokenFile)
+					}
+				}
+			}
+			return nil
+		}
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: boundSATokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Path:              path.Base(cfg.tokenFile),
+							Audience:          boundSATokenAudience,
+							ExpirationSeconds: pointer.Int64(boundSATokenExpiration),
+						},
+					}},
+				},
+			},
+		})
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testRoleARN = "arn:aws:iam::123456789012:role/ebs-csi"
+
+func newTestCredentialsSecret(credentials string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultSecretName, Namespace: defaultNamespace},
+		Data:       map[string][]byte{"credentials": []byte(credentials)},
+	}
+}
+
+func TestWithWebIdentityCredentials(t *testing.T) {
+	newDeployment := func(webIdentity bool, tokenPath string) *appsv1.Deployment {
+		container := corev1.Container{
+			Name: driverContainerName,
+			Env: []corev1.EnvVar{
+				{Name: "CSI_ENDPOINT", Value: "unix:///var/lib/csi/sockets/pluginproxy/csi.sock"},
+				{Name: "AWS_ACCESS_KEY_ID", ValueFrom: &corev1.EnvVarSource{}},
+				{Name: "AWS_SECRET_ACCESS_KEY", ValueFrom: &corev1.EnvVarSource{}},
+				{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws/credentials"},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: boundSATokenVolumeName, MountPath: boundSATokenMountPath, ReadOnly: true},
+			},
+		}
+		if webIdentity {
+			container.Env = []corev1.EnvVar{
+				{Name: "CSI_ENDPOINT", Value: "unix:///var/lib/csi/sockets/pluginproxy/csi.sock"},
+				{Name: "AWS_CONFIG_FILE", Value: "/var/run/secrets/aws/credentials"},
+				{Name: "AWS_ROLE_ARN", Value: testRoleARN},
+				{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: boundSATokenMountPath + "/" + tokenPath},
+			}
+		}
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{container}
+		deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: boundSATokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: tokenPath, Audience: boundSATokenAudience},
+					}},
+				},
+			},
+		}}
+		return deployment
+	}
+
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		expected      *appsv1.Deployment
+		expectedError bool
+	}{
+		{
+			name:     "no secret",
+			expected: newDeployment(false, "token"),
+		},
+		{
+			name:     "static credentials",
+			secret:   newTestCredentialsSecret("[default]\naws_access_key_id = id\naws_secret_access_key = secret\n"),
+			expected: newDeployment(false, "token"),
+		},
+		{
+			name:     "web identity",
+			secret:   newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"),
+			expected: newDeployment(true, "token"),
+		},
+		{
+			name:     "web identity with another token file name",
+			secret:   newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/sts-token\n"),
+			expected: newDeployment(true, "sts-token"),
+		},
+		{
+			name:          "malformed role ARN",
+			secret:        newTestCredentialsSecret("[default]\nrole_arn = ebs-csi\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"),
+			expectedError: true,
+		},
+		{
+			name:          "token outside of the token volume",
+			secret:        newTestCredentialsSecret("[default]\nrole_arn = " + testRoleARN + "\nweb_identity_token_file = /tmp/token\n"),
+			expectedError: true,
+		},
+		{
+			name:          "token without role",
+			secret:        newTestCredentialsSecret("[default]\nweb_identity_token_file = /var/run/secrets/openshift/serviceaccount/token\n"),
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			informer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if test.secret != nil {
+				informer.Informer().GetIndexer().Add(test.secret)
+			}
+			deployment := newDeployment(false, "token")
+			err := withWebIdentityCredentials(informer.Lister().Secrets(defaultNamespace), defaultSecretName)(&opv1.OperatorSpec{}, deployment)
+			if test.expectedError {
+				if err == nil {
+					t.Errorf("expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e, a := test.expected, deployment; !equality.Semantic.DeepEqual(e, a) {
+				t.Errorf("unexpected deployment\nwant=%#v\ngot= %#v", e, a)
+			}
+		})
+	}
+}
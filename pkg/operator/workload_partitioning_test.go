@@ -0,0 +1,36 @@
+package operator
+
+import (
+	"testing"
+
+	opv1 "github.com/openshift/api/operator/v1"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceread"
+
+	"github.com/openshift/aws-ebs-csi-driver-operator/assets"
+)
+
+func TestWorkloadManagementAnnotation(t *testing.T) {
+	asset, err := assets.ReadFile("controller.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := resourceread.ReadDeploymentV1OrDie(asset)
+	if _, ok := deployment.Spec.Template.Annotations[workloadManagementAnnotation]; !ok {
+		t.Errorf("expected the %s annotation on the pods of controller.yaml", workloadManagementAnnotation)
+	}
+	asset, err = assets.ReadFile("node.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemonSet := resourceread.ReadDaemonSetV1OrDie(asset)
+	if _, ok := daemonSet.Spec.Template.Annotations[workloadManagementAnnotation]; !ok {
+		t.Errorf("expected the %s annotation on the pods of node.yaml", workloadManagementAnnotation)
+	}
+
+	if err := withHypershiftDeploymentHook(true, "hypershift-image")(&opv1.OperatorSpec{}, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := deployment.Spec.Template.Annotations[workloadManagementAnnotation]; ok {
+		t.Errorf("expected no %s annotation on the controller pods on Hypershift", workloadManagementAnnotation)
+	}
+}
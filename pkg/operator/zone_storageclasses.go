@@ -0,0 +1,101 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// zoneAnnotation marks the StorageClasses generated for a single availability zone, its value is the zone.
+const zoneAnnotation = "csi.openshift.io/zone"
+
+// zoneStorageClasses returns a copy of each StorageClass listed in driverConfig.ZoneStorageClasses for each
+// zone from driverConfig.Zones, or of the nodes when no zones are configured. The copies are named
+// <StorageClass>-<zone> and restricted to their zone. They're never made default by the operator. Only the gp2
+// StorageClasses are copied for the Local Zones and Wavelength Zones.
+func (c *storageClassController) zoneStorageClasses(cfg *driverConfig, expectedSCs []*storagev1.StorageClass) ([]*storagev1.StorageClass, error) {
+	if len(cfg.ZoneStorageClasses) == 0 {
+		return nil, nil
+	}
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	zones := cfg.Zones
+	if len(zones) == 0 {
+		zones = sortedNodeZones(nodes)
+	}
+	edgeZones := nodeEdgeZones(nodes)
+
+	var zoneSCs []*storagev1.StorageClass
+	for _, name := range cfg.ZoneStorageClasses {
+		var base *storagev1.StorageClass
+		for _, sc := range expectedSCs {
+			if sc.Name == name+"-csi" {
+				base = sc
+			}
+		}
+		if base == nil {
+			return nil, fmt.Errorf("invalid zoneStorageClasses %q: StorageClass %s-csi is not managed by the operator", name, name)
+		}
+		for _, zone := range zones {
+			if edgeZones[zone] && base.Parameters["type"] != "gp2" {
+				// Only gp2 volumes can be created in the Local Zones and Wavelength Zones.
+				continue
+			}
+			sc := base.DeepCopy()
+			sc.Name = fmt.Sprintf("%s-%s", base.Name, zone)
+			if sc.Annotations == nil {
+				sc.Annotations = map[string]string{}
+			}
+			sc.Annotations[zoneAnnotation] = zone
+			if cfg.DefaultStorageClass != "" {
+				sc.Annotations[defaultStorageClassAnnotation] = "false"
+			} else {
+				// Keep the annotation set by the cluster administrator, if any.
+				delete(sc.Annotations, defaultStorageClassAnnotation)
+			}
+			sc.AllowedTopologies = []corev1.TopologySelectorTerm{{
+				MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{
+					Key:    driverZoneTopologyKey,
+					Values: []string{zone},
+				}},
+			}}
+			zoneSCs = append(zoneSCs, sc)
+		}
+	}
+	return zoneSCs, nil
+}
+
+// deleteStaleZoneStorageClasses deletes the zone and Outpost StorageClasses generated by the operator that are
+// not expected anymore, e.g. when the last node of a zone was removed.
+func (c *storageClassController) deleteStaleZoneStorageClasses(ctx context.Context, recorder events.Recorder, expectedSCs, existingSCs []*storagev1.StorageClass, unmanaged map[string]bool) error {
+	expected := map[string]bool{}
+	for _, sc := range expectedSCs {
+		expected[sc.Name] = true
+	}
+	for _, existing := range existingSCs {
+		zone, outpost := existing.Annotations[zoneAnnotation], existing.Annotations[outpostAnnotation]
+		if (zone == "" && outpost == "") || existing.Annotations[managedAnnotation] != "true" ||
+			existing.Provisioner != driverName || expected[existing.Name] || unmanaged[existing.Name] {
+			continue
+		}
+		err := c.kubeClient.StorageV1().StorageClasses().Delete(ctx, existing.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if zone != "" {
+			recorder.Eventf("StorageClassDeleted", "Deleted StorageClass %s of zone %s", existing.Name, zone)
+		} else {
+			recorder.Eventf("StorageClassDeleted", "Deleted StorageClass %s of Outpost %s", existing.Name, outpost)
+		}
+	}
+	return nil
+}